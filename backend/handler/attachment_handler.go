@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errAttachmentContentMismatch はファイルの内容が送られた種類と食い違う場合のエラー
+var errAttachmentContentMismatch = errors.New("file content does not match its type")
+
+// 添付ファイルを付けられるレコードの種類
+const (
+	attachmentParentTestCase    = "test_case"
+	attachmentParentTestRunCase = "test_run_case"
+	attachmentParentComment     = "comment"
+)
+
+// DefaultAttachmentMaxSizeMB は添付ファイルの最大サイズ（MB）の既定値
+const DefaultAttachmentMaxSizeMB = 20
+
+// DefaultAttachmentAllowedTypes は添付できるファイルの種類の既定値（スクリーンショット、動画、ログ、HAR など）
+const DefaultAttachmentAllowedTypes = "image/*,video/*,text/*,application/json,application/pdf,application/zip,application/gzip,application/x-har"
+
+// AttachmentMaxSize は添付ファイルの最大サイズ（バイト）を返す（ATTACHMENT_MAX_SIZE_MB で変更できる）
+func AttachmentMaxSize() int64 {
+	if sizeMB, _ := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_SIZE_MB")); sizeMB > 0 {
+		return int64(sizeMB) << 20
+	}
+	return DefaultAttachmentMaxSizeMB << 20
+}
+
+// AttachmentAllowedTypes は添付できる Content-Type の一覧を返す（ATTACHMENT_ALLOWED_TYPES にカンマ区切りで指定する。"image/*" のように指定もできる）
+func AttachmentAllowedTypes() []string {
+	value := os.Getenv("ATTACHMENT_ALLOWED_TYPES")
+	if strings.TrimSpace(value) == "" {
+		value = DefaultAttachmentAllowedTypes
+	}
+	types := []string{}
+	for _, contentType := range strings.Split(value, ",") {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			types = append(types, contentType)
+		}
+	}
+	return types
+}
+
+type AttachmentHandler struct {
+	DB           *gorm.DB
+	Storage      util.FileStorage
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+func NewAttachmentHandler(db *gorm.DB, storage util.FileStorage) *AttachmentHandler {
+	return &AttachmentHandler{DB: db, Storage: storage, MaxSize: AttachmentMaxSize(), AllowedTypes: AttachmentAllowedTypes()}
+}
+
+func (h *AttachmentHandler) GetTestCaseAttachments(c *gin.Context) {
+	h.getAttachments(c, attachmentParentTestCase)
+}
+
+func (h *AttachmentHandler) PostTestCaseAttachment(c *gin.Context) {
+	h.postAttachment(c, attachmentParentTestCase)
+}
+
+func (h *AttachmentHandler) GetTestRunCaseAttachments(c *gin.Context) {
+	h.getAttachments(c, attachmentParentTestRunCase)
+}
+
+func (h *AttachmentHandler) PostTestRunCaseAttachment(c *gin.Context) {
+	h.postAttachment(c, attachmentParentTestRunCase)
+}
+
+func (h *AttachmentHandler) GetCommentAttachments(c *gin.Context) {
+	h.getAttachments(c, attachmentParentComment)
+}
+
+func (h *AttachmentHandler) PostCommentAttachment(c *gin.Context) {
+	h.postAttachment(c, attachmentParentComment)
+}
+
+// DownloadAttachment はファイル本体を返す。X-Checksum-Sha256 ヘッダーでアップロード時のチェックサムを確認できる
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	reader, err := h.Storage.Open(attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, util.ErrFileNotFound) {
+			handleError(c, http.StatusNotFound, "File not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Failed to read file", err)
+		}
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Checksum-Sha256":   attachment.Checksum,
+	})
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	// 先にレコードを削除する。ファイルの削除に失敗してもファイルが残るだけで、参照できないレコードは残らない
+	if err := h.DB.Unscoped().Delete(&attachment).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete attachment", err)
+		return
+	}
+	h.deleteStoredFile(attachment.StorageKey)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AttachmentHandler) getAttachments(c *gin.Context, parentType string) {
+	parentID, ok := h.findAttachmentParent(c, parentType)
+	if !ok {
+		return
+	}
+
+	var attachments []model.Attachment
+	if err := h.DB.Preload("CreatedBy").Where("parent_type = ? AND parent_id = ?", parentType, parentID).Order("id").Find(&attachments).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve attachments", err)
+		return
+	}
+
+	response := util.AttachmentsResponseData{ParentType: parentType, ParentID: parentID, Attachments: []util.Attachment{}}
+	for _, attachment := range attachments {
+		response.Attachments = append(response.Attachments, convertAttachmentToJSON(attachment))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// postAttachment は multipart の file を保存する。checksum（SHA-256）を送った場合は受け取った内容と一致するかを確認する
+func (h *AttachmentHandler) postAttachment(c *gin.Context, parentType string) {
+	parentID, ok := h.findAttachmentParent(c, parentType)
+	if !ok {
+		return
+	}
+
+	// multipart の区切りなどの分を見込んで本文の上限を決める
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			handleError(c, http.StatusRequestEntityTooLarge, "File is too large", err)
+		} else {
+			handleError(c, http.StatusBadRequest, "File is required", err)
+		}
+		return
+	}
+	if fileHeader.Size > h.MaxSize {
+		handleError(c, http.StatusRequestEntityTooLarge, "File is too large", nil)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Failed to read file", err)
+		return
+	}
+	defer file.Close()
+
+	contentType, err := detectAttachmentContentType(fileHeader, file)
+	if err != nil {
+		if errors.Is(err, errAttachmentContentMismatch) {
+			handleError(c, http.StatusUnsupportedMediaType, "File content does not match its type", err)
+			return
+		}
+		handleError(c, http.StatusBadRequest, "Failed to read file", err)
+		return
+	}
+	if !h.isAllowedContentType(contentType) {
+		handleError(c, http.StatusUnsupportedMediaType, "File type is not allowed", nil)
+		return
+	}
+
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	key, err := newAttachmentKey(parentType, parentID)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to save file", err)
+		return
+	}
+	hash := sha256.New()
+	if err := h.Storage.Save(key, io.TeeReader(file, hash), fileHeader.Size, contentType); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to save file", err)
+		return
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if expected := strings.TrimSpace(c.PostForm("checksum")); expected != "" && !strings.EqualFold(expected, checksum) {
+		h.deleteStoredFile(key)
+		handleError(c, http.StatusBadRequest, "Checksum mismatch", nil)
+		return
+	}
+
+	attachment := model.Attachment{
+		ParentType:  parentType,
+		ParentID:    parentID,
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		Checksum:    checksum,
+		StorageKey:  key,
+		CreatedByID: accessUser.ID,
+	}
+	if err := h.DB.Create(&attachment).Error; err != nil {
+		h.deleteStoredFile(key)
+		handleError(c, http.StatusInternalServerError, "Failed to create attachment", err)
+		return
+	}
+	attachment.CreatedBy = accessUser
+
+	c.JSON(http.StatusCreated, convertAttachmentToJSON(attachment))
+}
+
+func (h *AttachmentHandler) findAttachment(c *gin.Context) (model.Attachment, bool) {
+	var attachment model.Attachment
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return attachment, false
+	}
+	if err := h.DB.First(&attachment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Attachment not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", err)
+		}
+		return attachment, false
+	}
+	return attachment, true
+}
+
+// findAttachmentParent は添付先のレコードがあるかを確認して ID を返す
+func (h *AttachmentHandler) findAttachmentParent(c *gin.Context, parentType string) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return 0, false
+	}
+
+	var parent interface{}
+	var name string
+	switch parentType {
+	case attachmentParentTestCase:
+		parent, name = &model.TestCase{}, "Test case"
+	case attachmentParentTestRunCase:
+		parent, name = &model.TestRunCase{}, "Test run case"
+	default:
+		parent, name = &model.Comment{}, "Comment"
+	}
+	if err := h.DB.Select("id").First(parent, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, name+" not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", err)
+		}
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (h *AttachmentHandler) isAllowedContentType(contentType string) bool {
+	for _, allowed := range h.AllowedTypes {
+		if allowed == "*/*" || allowed == contentType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *AttachmentHandler) deleteStoredFile(key string) {
+	if err := h.Storage.Delete(key); err != nil {
+		log.Printf("Error: failed to delete attachment file %s: %v", key, err)
+	}
+}
+
+// detectAttachmentContentType はファイルの種類を送られた Content-Type、拡張子、内容の順に判定する（パラメータは除く）。
+// 内容から判定した種類と系統が異なる場合は errAttachmentContentMismatch を返す
+func detectAttachmentContentType(fileHeader *multipart.FileHeader, file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sniffed, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", err
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	contentType := fileHeader.Header.Get("Content-Type")
+	if ext == ".har" {
+		// HAR は JSON だが種類で制限できるように分ける
+		contentType = "application/x-har"
+	} else if contentType == "" || contentType == "application/octet-stream" {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType == "" {
+		return sniffed, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+	mediaType = strings.ToLower(mediaType)
+	// 内容から判定できない場合（application/octet-stream）は送られた種類を使う
+	if sniffed != "application/octet-stream" && attachmentContentFamily(mediaType) != attachmentContentFamily(sniffed) {
+		return "", errAttachmentContentMismatch
+	}
+	return mediaType, nil
+}
+
+// attachmentContentFamily は内容から区別できる粒度に種類をまとめる。
+// テキスト形式（JSON や HAR、SVG など）や zip 形式（Office 文書など）は内容だけでは細かい種類を区別できない
+func attachmentContentFamily(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "text/"), contentType == "application/json", contentType == "application/x-har",
+		contentType == "application/xml", contentType == "application/javascript",
+		strings.HasSuffix(contentType, "+json"), strings.HasSuffix(contentType, "+xml"):
+		return "text"
+	case contentType == "application/x-zip-compressed", strings.HasSuffix(contentType, "+zip"),
+		strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(contentType, "application/vnd.oasis.opendocument."):
+		return "application/zip"
+	case contentType == "application/x-gzip":
+		return "application/gzip"
+	case strings.HasPrefix(contentType, "video/"), strings.HasPrefix(contentType, "audio/"), contentType == "application/ogg":
+		// webm や ogg は動画と音声のどちらにも使われる
+		return "media"
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	}
+	return contentType
+}
+
+// newAttachmentKey は添付先ごとのディレクトリに推測できない名前で保存するキーを返す
+func newAttachmentKey(parentType string, parentID uint) (string, error) {
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return "", err
+	}
+	return path.Join("attachments", parentType, fmt.Sprint(parentID), hex.EncodeToString(name)), nil
+}
+
+// DeleteOrphanAttachments は添付先のレコードが物理削除された添付ファイルを保存先から削除する。
+// 論理削除（ゴミ箱）の間は復元できるように残す
+func DeleteOrphanAttachments(db *gorm.DB, storage util.FileStorage) error {
+	var attachments []model.Attachment
+	if err := db.Where(
+		"(parent_type = ? AND parent_id NOT IN (SELECT id FROM test_cases)) OR "+
+			"(parent_type = ? AND parent_id NOT IN (SELECT id FROM test_run_cases)) OR "+
+			"(parent_type = ? AND parent_id NOT IN (SELECT id FROM comments))",
+		attachmentParentTestCase, attachmentParentTestRunCase, attachmentParentComment,
+	).Find(&attachments).Error; err != nil {
+		return err
+	}
+
+	ids := []uint{}
+	for _, attachment := range attachments {
+		// 削除できなかったファイルは次回に再度削除する
+		if err := storage.Delete(attachment.StorageKey); err != nil {
+			log.Printf("Error: failed to delete attachment file %s: %v", attachment.StorageKey, err)
+			continue
+		}
+		ids = append(ids, attachment.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Unscoped().Where("id IN ?", ids).Delete(&model.Attachment{}).Error
+}
+
+func convertAttachmentToJSON(attachment model.Attachment) util.Attachment {
+	return util.Attachment{
+		ID:          attachment.ID,
+		ParentType:  attachment.ParentType,
+		ParentID:    attachment.ParentID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Checksum:    attachment.Checksum,
+		CreatedBy:   convertUserToJSON(attachment.CreatedBy),
+		CreatedAt:   attachment.CreatedAt.Format("2006-01-02 15:04"),
+	}
+}
@@ -0,0 +1,637 @@
+package handler
+
+import (
+	"archive/zip"
+	"backend/model"
+	"backend/util"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// scenarioKeyField はシナリオを取り込み直したときに同じテストケースを探すためのカスタムフィールド
+const scenarioKeyField = "scenario_key"
+
+// scenarioKeyTagPrefix はシナリオのキーを明示するタグ（例: @key:login-with-password）
+const scenarioKeyTagPrefix = "@key:"
+
+var gherkinStepKeywords = []string{"Given ", "When ", "Then ", "And ", "But ", "* "}
+
+var scenarioKeyPattern = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// gherkinImport は .feature ファイルを取り込み用の行に変換したもの
+type gherkinImport struct {
+	SuitePaths [][]string // Feature と Rule のパス（シナリオの無いものも含めて文書順）
+	Keys       []string   // Rows と同じ順のシナリオのキー
+	Rows       []testCaseImportRow
+}
+
+// scenarioKey は名前からシナリオのキーを作る（タグに書けるよう空白や記号は "-" にする）
+func scenarioKey(names ...string) string {
+	parts := []string{}
+	for _, name := range names {
+		if part := strings.Trim(scenarioKeyPattern.ReplaceAllString(strings.ToLower(name), "-"), "-"); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func gherkinKeyword(line string, keywords ...string) (string, bool) {
+	for _, keyword := range keywords {
+		if strings.HasPrefix(line, keyword+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, keyword+":")), true
+		}
+	}
+	return "", false
+}
+
+func isGherkinStep(line string) bool {
+	for _, keyword := range gherkinStepKeywords {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGherkinFeature は .feature ファイルを読み込む。
+// Feature はスイート、Rule はその子スイート、Scenario / Scenario Outline はテストケースになる。
+// Background の手順は各シナリオの先頭に加え、Examples は本文の末尾に残す
+func parseGherkinFeature(fileName string, r io.Reader, basePath []string, parsed *gherkinImport) error {
+	type scenario struct {
+		row         testCaseImportRow
+		key         string
+		description []string
+		examples    []string
+	}
+
+	var (
+		featureName, ruleName          string
+		featurePath, rulePath          []string
+		featureTags, ruleTags, pending []string
+		featureBackground              []util.TestCaseStep
+		ruleBackground                 []util.TestCaseStep
+		background                     *[]util.TestCaseStep
+		current                        *scenario
+		inExamples                     bool
+		docString                      string
+		docStringIndent                int
+	)
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		content := strings.TrimSpace(strings.Join(current.description, "\n"))
+		if len(current.examples) > 0 {
+			if content != "" {
+				content += "\n\n"
+			}
+			content += strings.Join(current.examples, "\n")
+		}
+		current.row.Content = content
+		if current.key == "" {
+			current.key = scenarioKey(featureName, ruleName, current.row.Title)
+		}
+		current.row.CustomFields = map[string]string{scenarioKeyField: current.key}
+		parsed.Rows = append(parsed.Rows, current.row)
+		parsed.Keys = append(parsed.Keys, current.key)
+		current = nil
+	}
+	// 最後の手順（Background を読んでいる間は Background の手順）に続きの行を加える
+	appendToStep := func(line string) {
+		steps := background
+		if current != nil {
+			steps = &current.row.Steps
+		}
+		if steps != nil && len(*steps) > 0 {
+			(*steps)[len(*steps)-1].Action += "\n" + line
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportFileSize)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		if lineNumber == 1 {
+			raw = strings.TrimPrefix(raw, "\xef\xbb\xbf")
+		}
+		line := strings.TrimSpace(raw)
+
+		if docString != "" {
+			indent := len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+			if indent > docStringIndent {
+				indent = docStringIndent
+			}
+			appendToStep(raw[indent:])
+			if line == docString {
+				docString = ""
+			}
+			continue
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			if current != nil && line == "" && len(current.row.Steps) == len(featureBackground)+len(ruleBackground) && len(current.description) > 0 {
+				current.description = append(current.description, "")
+			}
+		case strings.HasPrefix(line, "@"):
+			pending = append(pending, strings.Fields(line)...)
+		case strings.HasPrefix(line, `"""`) || strings.HasPrefix(line, "```"):
+			docString = line[:3]
+			docStringIndent = len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+			appendToStep(line)
+		default:
+			if name, ok := gherkinKeyword(line, "Feature"); ok {
+				finish()
+				featureName, ruleName = name, ""
+				featurePath = append(append([]string{}, basePath...), name)
+				rulePath = featurePath
+				featureTags, ruleTags, pending = pending, nil, nil
+				featureBackground, ruleBackground, background = nil, nil, nil
+				parsed.SuitePaths = append(parsed.SuitePaths, featurePath)
+				continue
+			}
+			if featurePath == nil {
+				return fmt.Errorf("%s: line %d: Feature is required", fileName, lineNumber)
+			}
+			if name, ok := gherkinKeyword(line, "Rule"); ok {
+				finish()
+				ruleName = name
+				rulePath = append(append([]string{}, featurePath...), name)
+				ruleTags, pending = pending, nil
+				ruleBackground, background = nil, nil
+				parsed.SuitePaths = append(parsed.SuitePaths, rulePath)
+				continue
+			}
+			if _, ok := gherkinKeyword(line, "Background"); ok {
+				finish()
+				pending = nil
+				background = &featureBackground
+				if ruleName != "" {
+					background = &ruleBackground
+				}
+				continue
+			}
+			if name, ok := gherkinKeyword(line, "Scenario Outline", "Scenario Template", "Scenario", "Example"); ok {
+				finish()
+				background = nil
+				inExamples = false
+				current = &scenario{row: testCaseImportRow{
+					SourceFile:    fileName,
+					SourceRow:     lineNumber,
+					TestSuitePath: rulePath,
+					Title:         name,
+					Tags:          []string{},
+				}}
+				for _, tag := range append(append(append([]string{}, featureTags...), ruleTags...), pending...) {
+					if strings.HasPrefix(tag, scenarioKeyTagPrefix) {
+						current.key = strings.TrimPrefix(tag, scenarioKeyTagPrefix)
+						continue
+					}
+					current.row.Tags = append(current.row.Tags, strings.TrimPrefix(tag, "@"))
+				}
+				pending = nil
+				current.row.Steps = append(append([]util.TestCaseStep{}, featureBackground...), ruleBackground...)
+				continue
+			}
+			if _, ok := gherkinKeyword(line, "Examples", "Scenarios"); ok && current != nil {
+				inExamples = true
+				if len(current.examples) > 0 {
+					current.examples = append(current.examples, "")
+				}
+				if len(pending) > 0 {
+					current.examples = append(current.examples, strings.Join(pending, " "))
+					pending = nil
+				}
+				current.examples = append(current.examples, line)
+				continue
+			}
+
+			switch {
+			case inExamples && current != nil:
+				current.examples = append(current.examples, "  "+line)
+			case isGherkinStep(line) && background != nil:
+				*background = append(*background, util.TestCaseStep{Action: line})
+			case isGherkinStep(line) && current != nil:
+				current.row.Steps = append(current.row.Steps, util.TestCaseStep{Action: line})
+			case strings.HasPrefix(line, "|"):
+				appendToStep(line)
+			case current != nil && len(current.row.Steps) == len(featureBackground)+len(ruleBackground):
+				// 手順より前の行はシナリオの説明
+				current.description = append(current.description, line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %s", fileName, err.Error())
+	}
+	if featurePath == nil {
+		return fmt.Errorf("%s: Feature is required", fileName)
+	}
+	finish()
+	return nil
+}
+
+// PostGherkinImport は .feature ファイル（複数可）を取り込む。
+// キーが一致する既存のテストケースは作り直さずに更新する
+func (h *ImportHandler) PostGherkinImport(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+	dryRun, err := parseBoolQuery(c, "dry_run")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		handleError(c, http.StatusBadRequest, "File is required", err)
+		return
+	}
+	basePath := splitTestSuitePath(c.PostForm("test_suite_path"))
+	parsed := gherkinImport{}
+	for _, fileHeader := range form.File["file"] {
+		if fileHeader.Size > maxImportFileSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fileHeader.Filename + ": file is too large"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Failed to read file", err)
+			return
+		}
+		err = parseGherkinFeature(fileHeader.Filename, file, basePath, &parsed)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	result, err := importGherkinFeatures(h.DB, project, accessUser, parsed, dryRun)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Insert failed", err)
+		return
+	}
+	if !dryRun && len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// findScenarioTestCases は各行に対応する既存のテストケースを探す。
+// キーで見つからない場合は、キーを持たない同じスイートの同じタイトルのテストケースを使う
+func findScenarioTestCases(db *gorm.DB, importer *testCaseImporter, parsed gherkinImport) ([]uint, error) {
+	matches := make([]uint, len(parsed.Rows))
+	if len(parsed.Rows) == 0 {
+		return matches, nil
+	}
+
+	var keyed []struct {
+		TestCaseID uint
+		Value      string
+	}
+	if err := db.Model(&model.TestCaseCustomField{}).
+		Select("test_case_custom_fields.test_case_id, test_case_custom_fields.value").
+		Joins("JOIN test_cases ON test_cases.id = test_case_custom_fields.test_case_id AND test_cases.deleted_at IS NULL").
+		Where("test_cases.project_id = ? AND test_case_custom_fields.name = ? AND test_case_custom_fields.value IN ?", importer.project.ID, scenarioKeyField, parsed.Keys).
+		Scan(&keyed).Error; err != nil {
+		return nil, err
+	}
+	byKey := map[string]uint{}
+	for _, match := range keyed {
+		byKey[match.Value] = match.TestCaseID
+	}
+
+	titles := make([]string, 0, len(parsed.Rows))
+	for _, row := range parsed.Rows {
+		titles = append(titles, row.Title)
+	}
+	var unkeyed []model.TestCase
+	if err := db.Select("id", "test_suite_id", "title").
+		Where("project_id = ? AND title IN ?", importer.project.ID, titles).
+		Where("id NOT IN (SELECT test_case_id FROM test_case_custom_fields WHERE name = ? AND deleted_at IS NULL)", scenarioKeyField).
+		Order("id").
+		Find(&unkeyed).Error; err != nil {
+		return nil, err
+	}
+	byTitle := map[string]uint{}
+	for _, testCase := range unkeyed {
+		if testCase.TestSuiteID == nil {
+			continue
+		}
+		if key := fmt.Sprintf("%d\x00%s", *testCase.TestSuiteID, testCase.Title); byTitle[key] == 0 {
+			byTitle[key] = testCase.ID
+		}
+	}
+
+	claimed := map[uint]bool{}
+	for index, row := range parsed.Rows {
+		id := byKey[parsed.Keys[index]]
+		if id == 0 {
+			if testSuiteID := importer.testSuites[testSuitePathKey(row.TestSuitePath)]; testSuiteID != 0 {
+				id = byTitle[fmt.Sprintf("%d\x00%s", testSuiteID, row.Title)]
+			}
+		}
+		if id != 0 && !claimed[id] {
+			claimed[id] = true
+			matches[index] = id
+		}
+	}
+	return matches, nil
+}
+
+// importGherkinFeatures はスイートを文書の順に用意し、新しいシナリオは作成、既存のものは更新する
+func importGherkinFeatures(db *gorm.DB, project model.Project, user model.User, parsed gherkinImport, dryRun bool) (util.GherkinImportResult, error) {
+	result := util.GherkinImportResult{
+		TestCaseImportResult: util.TestCaseImportResult{
+			DryRun:      dryRun,
+			TestCases:   len(parsed.Rows),
+			TestCaseIDs: []uint{},
+		},
+	}
+	var importer *testCaseImporter
+	var matches []uint
+	err := runImport(db, dryRun, func(db *gorm.DB) (bool, error) {
+		var err error
+		if importer, err = newTestCaseImporter(db, project, user); err != nil {
+			return false, err
+		}
+		result.Errors = importer.validate(parsed.Rows)
+		seen := map[string]bool{}
+		for index, key := range parsed.Keys {
+			if seen[key] {
+				row := parsed.Rows[index]
+				result.Errors = append(result.Errors, util.TestCaseImportError{File: row.SourceFile, Row: row.SourceRow, Field: scenarioKeyField, Message: "duplicate scenario key " + key})
+			}
+			seen[key] = true
+		}
+
+		if matches, err = findScenarioTestCases(db, importer, parsed); err != nil {
+			return false, err
+		}
+		for _, id := range matches {
+			if id != 0 {
+				result.Updated++
+			}
+		}
+		result.Created = len(parsed.Rows) - result.Updated
+
+		if dryRun {
+			result.TestSuites = importer.countNewTestSuitePaths(parsed.SuitePaths)
+		}
+		return len(result.Errors) == 0, nil
+	}, func(tx *gorm.DB) error {
+		suiteCount := len(importer.testSuites)
+		for _, path := range parsed.SuitePaths {
+			if _, err := importer.ensureTestSuite(tx, path); err != nil {
+				return err
+			}
+		}
+
+		newRows := []testCaseImportRow{}
+		for index, row := range parsed.Rows {
+			if matches[index] == 0 {
+				newRows = append(newRows, row)
+				continue
+			}
+			testSuiteID, err := importer.ensureTestSuite(tx, row.TestSuitePath)
+			if err != nil {
+				return err
+			}
+			testCase := model.TestCase{}
+			testCase.ID = matches[index]
+			if err := tx.Model(&testCase).Updates(map[string]interface{}{
+				"test_suite_id": testSuiteID,
+				"title":         row.Title,
+				"content":       row.Content,
+				"updated_by_id": user.ID,
+			}).Error; err != nil {
+				return err
+			}
+			// キーはタイトルで対応付けた場合にも保存し、以降はキーで探せるようにする
+			if err := tx.Where("test_case_id = ? AND name = ?", testCase.ID, scenarioKeyField).Delete(&model.TestCaseCustomField{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&model.TestCaseCustomField{TestCaseID: testCase.ID, Name: scenarioKeyField, Value: parsed.Keys[index]}).Error; err != nil {
+				return err
+			}
+			tags, steps := row.Tags, row.Steps
+			if err := saveTestCaseAttributes(tx, &testCase, testCaseAttributesRequest{Tags: &tags, Steps: &steps}); err != nil {
+				return err
+			}
+		}
+
+		created, _, err := importer.importRows(tx, newRows)
+		if err != nil {
+			return err
+		}
+		result.TestSuites = len(importer.testSuites) - suiteCount
+		for _, id := range matches {
+			if id == 0 {
+				id = created[0].ID
+				created = created[1:]
+			}
+			result.TestCaseIDs = append(result.TestCaseIDs, id)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ExportGherkin はスイートを .feature ファイルとして返す。
+// test_suite_id を指定した場合はそのスイートを Feature、子スイートを Rule とした一つのファイルを返し、
+// 指定しない場合は最上位のスイートごとのファイルを ZIP にまとめて返す
+func (h *TestCaseHandler) ExportGherkin(c *gin.Context) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	testSuites := []model.TestSuite{}
+	if result := h.DB.Where("project_id = ?", project.ID).
+		Order("test_suites.order_index ASC, test_suites.id ASC").
+		Find(&testSuites); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
+	}
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+
+	features := testSuiteMap[0]
+	query := h.DB.Where("project_id = ? AND test_suite_id IS NOT NULL", project.ID)
+	if v := c.Query("test_suite_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid test_suite_id", err)
+			return
+		}
+		features = nil
+		for _, testSuite := range testSuites {
+			if testSuite.ID == uint(id) {
+				features = []model.TestSuite{testSuite}
+			}
+		}
+		if features == nil {
+			handleError(c, http.StatusNotFound, "Test suite not found", nil)
+			return
+		}
+		query = query.Where("test_suite_id IN ?", collectTestSuiteSubtree(uint(id), testSuiteMap))
+	}
+
+	testCases := []model.TestCase{}
+	if result := query.
+		Preload("Tags").
+		Preload("CustomFields").
+		Preload("Steps", orderTestCaseSteps).
+		Order("order_index ASC, id ASC").
+		Find(&testCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
+	}
+	testCaseMap := make(map[uint][]model.TestCase)
+	for _, testCase := range testCases {
+		testCaseMap[*testCase.TestSuiteID] = append(testCaseMap[*testCase.TestSuiteID], testCase)
+	}
+
+	if c.Query("test_suite_id") != "" {
+		c.Header("Content-Disposition", `attachment; filename="`+gherkinFileName(features[0].Name)+`"`)
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(gherkinFeatureText(features[0], testSuiteMap, testCaseMap)))
+		return
+	}
+
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	names := map[string]int{}
+	for _, feature := range features {
+		name := gherkinFileName(feature.Name)
+		if names[name]++; names[name] > 1 {
+			name = strings.TrimSuffix(name, ".feature") + "-" + strconv.Itoa(names[name]) + ".feature"
+		}
+		writer, err := archive.Create(name)
+		if err == nil {
+			_, err = io.WriteString(writer, gherkinFeatureText(feature, testSuiteMap, testCaseMap))
+		}
+		if err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to export test cases", err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to export test cases", err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+project.Code+`-features.zip"`)
+	c.Data(http.StatusOK, "application/zip", buffer.Bytes())
+}
+
+func gherkinFileName(name string) string {
+	if name = scenarioKey(name); name == "" {
+		name = "feature"
+	}
+	return name + ".feature"
+}
+
+// gherkinFeatureText はスイートを Feature、子スイートを Rule として書き出す（孫以下のスイートのテストケースは Rule にまとめる）
+func gherkinFeatureText(feature model.TestSuite, testSuiteMap map[uint][]model.TestSuite, testCaseMap map[uint][]model.TestCase) string {
+	var b strings.Builder
+	b.WriteString("Feature: " + feature.Name + "\n")
+	writeGherkinScenarios(&b, "  ", []string{feature.Name}, testCaseMap[feature.ID])
+
+	for _, rule := range testSuiteMap[feature.ID] {
+		b.WriteString("\n  Rule: " + rule.Name + "\n")
+		for _, id := range collectTestSuiteSubtree(rule.ID, testSuiteMap) {
+			writeGherkinScenarios(&b, "    ", []string{feature.Name, rule.Name}, testCaseMap[id])
+		}
+	}
+	return b.String()
+}
+
+func writeGherkinScenarios(b *strings.Builder, indent string, names []string, testCases []model.TestCase) {
+	for _, testCase := range testCases {
+		// 本文の Examples より前は説明、以降は Examples として書き戻す
+		description, examples := testCase.Content, ""
+		lines := strings.Split(strings.ReplaceAll(testCase.Content, "\r\n", "\n"), "\n")
+		for i, line := range lines {
+			if _, ok := gherkinKeyword(strings.TrimSpace(line), "Examples", "Scenarios"); ok {
+				start := i
+				if i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "@") {
+					start = i - 1
+				}
+				description = strings.Join(lines[:start], "\n")
+				examples = strings.Join(lines[start:], "\n")
+				break
+			}
+		}
+
+		tags := []string{}
+		for _, tag := range testCaseTagNames(testCase.Tags) {
+			tags = append(tags, "@"+strings.ReplaceAll(tag, " ", "_"))
+		}
+		key := testCaseCustomFieldMap(testCase.CustomFields)[scenarioKeyField]
+		if key == "" || strings.ContainsAny(key, " \t") {
+			key = scenarioKey(append(append([]string{}, names...), testCase.Title)...)
+		}
+		tags = append(tags, scenarioKeyTagPrefix+key)
+
+		keyword := "Scenario"
+		if examples != "" {
+			keyword = "Scenario Outline"
+		}
+		b.WriteString("\n" + indent + strings.Join(tags, " ") + "\n")
+		b.WriteString(indent + keyword + ": " + testCase.Title + "\n")
+		for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+			if strings.TrimSpace(line) != "" {
+				b.WriteString(indent + "  " + strings.TrimSpace(line) + "\n")
+			}
+		}
+		for _, step := range testCaseStepResponses(testCase.Steps) {
+			action := step.Action
+			if !isGherkinStep(action) {
+				action = "* " + action
+			}
+			for i, line := range strings.Split(action, "\n") {
+				if i == 0 {
+					b.WriteString(indent + "  " + line + "\n")
+				} else {
+					b.WriteString(indent + "    " + line + "\n")
+				}
+			}
+			if step.ExpectedResult != "" {
+				b.WriteString(indent + "  Then " + strings.Join(strings.Fields(step.ExpectedResult), " ") + "\n")
+			}
+		}
+		if examples != "" {
+			b.WriteString("\n")
+			for _, line := range strings.Split(examples, "\n") {
+				if strings.TrimSpace(line) != "" {
+					b.WriteString(indent + "  " + line + "\n")
+				}
+			}
+		}
+	}
+}
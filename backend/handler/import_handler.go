@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ImportHandler struct {
+	DB *gorm.DB
+}
+
+func NewImportHandler(db *gorm.DB) *ImportHandler {
+	return &ImportHandler{DB: db}
+}
+
+type importProfileRequest struct {
+	ProjectID uint                     `json:"project_id"`
+	Name      string                   `json:"name"`
+	Mapping   util.ImportColumnMapping `json:"mapping"`
+}
+
+func createImportProfileResponse(profile model.ImportProfile) util.ImportProfile {
+	var mapping util.ImportColumnMapping
+	if err := json.Unmarshal([]byte(profile.Mapping), &mapping); err != nil {
+		mapping = util.ImportColumnMapping{}
+	}
+	return util.ImportProfile{ID: profile.ID, Name: profile.Name, Mapping: mapping}
+}
+
+func (h *ImportHandler) findProject(c *gin.Context) (model.Project, bool) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return project, false
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return project, false
+	}
+	return project, true
+}
+
+func (h *ImportHandler) GetImportProfiles(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+
+	var profiles []model.ImportProfile
+	if err := h.DB.Where("project_id = ?", project.ID).Order("name, id").Find(&profiles).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve import profiles", err)
+		return
+	}
+
+	responses := []util.ImportProfile{}
+	for _, profile := range profiles {
+		responses = append(responses, createImportProfileResponse(profile))
+	}
+	c.JSON(http.StatusOK, util.ImportProfilesResponseData{
+		ProjectID: project.ID,
+		Profiles:  responses,
+	})
+}
+
+func (h *ImportHandler) PostImportProfile(c *gin.Context) {
+	var req importProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Mapping.Title) == "" {
+		handleError(c, http.StatusBadRequest, "Name and title column are required", nil)
+		return
+	}
+
+	mapping, _ := json.Marshal(req.Mapping)
+	profile := model.ImportProfile{
+		ProjectID: req.ProjectID,
+		Name:      strings.TrimSpace(req.Name),
+		Mapping:   string(mapping),
+	}
+	if err := h.DB.Create(&profile).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create import profile", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, createImportProfileResponse(profile))
+}
+
+func (h *ImportHandler) PutImportProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	var req importProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Mapping.Title) == "" {
+		handleError(c, http.StatusBadRequest, "Name and title column are required", nil)
+		return
+	}
+
+	var profile model.ImportProfile
+	if result := h.DB.First(&profile, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Import profile not found", result.Error)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", result.Error)
+		}
+		return
+	}
+
+	mapping, _ := json.Marshal(req.Mapping)
+	if err := h.DB.Model(&profile).Updates(map[string]interface{}{
+		"name":    strings.TrimSpace(req.Name),
+		"mapping": string(mapping),
+	}).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update import profile", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, createImportProfileResponse(profile))
+}
+
+func (h *ImportHandler) DeleteImportProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	if err := h.DB.Delete(&model.ImportProfile{}, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete import profile", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewTestCaseImport はファイルを解析した結果と検証エラーを返す（何も書き込まない）
+func (h *ImportHandler) PreviewTestCaseImport(c *gin.Context) {
+	project, records, rows, ok := h.parseUpload(c)
+	if !ok {
+		return
+	}
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	result, err := importTestCases(h.DB, project, accessUser, rows, true)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to prepare import", err)
+		return
+	}
+
+	previewRows := []util.TestCaseImportPreviewRow{}
+	for _, row := range rows {
+		previewRows = append(previewRows, util.TestCaseImportPreviewRow{
+			Row:           row.SourceRow,
+			TestSuitePath: strings.Join(row.TestSuitePath, testSuitePathSeparator),
+			Title:         row.Title,
+			Content:       row.Content,
+			Milestone:     row.MilestoneTitle,
+			Tags:          row.Tags,
+			CustomFields:  row.CustomFields,
+			Steps:         row.Steps,
+		})
+	}
+	c.JSON(http.StatusOK, util.TestCaseImportPreview{
+		Columns: records[0],
+		Rows:    previewRows,
+		Result:  result,
+	})
+}
+
+// PostTestCaseImport はファイルのテストケースを一つのトランザクションで取り込む
+func (h *ImportHandler) PostTestCaseImport(c *gin.Context) {
+	project, _, rows, ok := h.parseUpload(c)
+	if !ok {
+		return
+	}
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	result, err := importTestCases(h.DB, project, accessUser, rows, false)
+	writeTestCaseImportResult(c, result, err)
+}
+
+// parseUpload はアップロードされたファイルを対応付けに従って解析する。
+// 対応付けは mapping（JSON）、profile_id、既定値の順で決まる（既定値ではヘッダー行に無い列は読まない）
+func (h *ImportHandler) parseUpload(c *gin.Context) (model.Project, [][]string, []testCaseImportRow, bool) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return project, nil, nil, false
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "File is required", err)
+		return project, nil, nil, false
+	}
+
+	var mapping *util.ImportColumnMapping
+	if value := c.PostForm("mapping"); value != "" {
+		mapping = &util.ImportColumnMapping{}
+		if err := json.Unmarshal([]byte(value), mapping); err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid mapping", err)
+			return project, nil, nil, false
+		}
+	} else if value := c.PostForm("profile_id"); value != "" {
+		var profile model.ImportProfile
+		if result := h.DB.Where("id = ? AND project_id = ?", value, project.ID).First(&profile); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				handleError(c, http.StatusNotFound, "Import profile not found", result.Error)
+			} else {
+				handleError(c, http.StatusInternalServerError, "Database error", result.Error)
+			}
+			return project, nil, nil, false
+		}
+		profileMapping := createImportProfileResponse(profile).Mapping
+		mapping = &profileMapping
+	}
+
+	records, err := readImportFile(fileHeader, strings.ToLower(c.PostForm("format")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return project, nil, nil, false
+	}
+	if mapping == nil && len(records) > 0 {
+		defaultMapping := defaultImportMappingFor(records[0])
+		mapping = &defaultMapping
+	} else if mapping == nil {
+		mapping = &defaultImportColumnMapping
+	}
+	rows, err := parseImportRecords(records, *mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return project, nil, nil, false
+	}
+	return project, records, rows, true
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"backend/util"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const maxImportFileSize = 10 << 20
+
+// defaultImportColumnMapping は対応付けが指定されなかった場合のヘッダー名（エクスポートと同じ）
+var defaultImportColumnMapping = util.ImportColumnMapping{
+	Title:           "Title",
+	TestSuitePath:   "Suite",
+	Content:         "Content",
+	Steps:           "Steps",
+	ExpectedResults: "Expected Results",
+	Milestone:       "Milestone",
+	Tags:            "Tags",
+}
+
+var stepNumberPattern = regexp.MustCompile(`^\s*\d+[.)]\s*`)
+var stepBulletPattern = regexp.MustCompile(`^\s*[-*•]\s+`)
+
+// defaultImportMappingFor は既定の対応付けのうちヘッダー行に無い列を除いたものを返す（タイトル列は常に必要）
+func defaultImportMappingFor(header []string) util.ImportColumnMapping {
+	present := map[string]bool{}
+	for _, name := range header {
+		present[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	optional := func(name string) string {
+		if present[strings.ToLower(name)] {
+			return name
+		}
+		return ""
+	}
+	return util.ImportColumnMapping{
+		Title:           defaultImportColumnMapping.Title,
+		TestSuitePath:   optional(defaultImportColumnMapping.TestSuitePath),
+		Content:         optional(defaultImportColumnMapping.Content),
+		Steps:           optional(defaultImportColumnMapping.Steps),
+		ExpectedResults: optional(defaultImportColumnMapping.ExpectedResults),
+		Milestone:       optional(defaultImportColumnMapping.Milestone),
+		Tags:            optional(defaultImportColumnMapping.Tags),
+	}
+}
+
+// readImportFile はアップロードされた CSV / XLSX を行ごとのセルの値として読み込む
+func readImportFile(fileHeader *multipart.FileHeader, format string) ([][]string, error) {
+	if fileHeader.Size > maxImportFileSize {
+		return nil, errors.New("file is too large")
+	}
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportFileSize {
+		return nil, errors.New("file is too large")
+	}
+
+	switch format {
+	case "csv":
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, errors.New("invalid csv file: " + err.Error())
+		}
+		return records, nil
+	case "xlsx":
+		return util.ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	}
+	return nil, errors.New("unsupported file format")
+}
+
+// parseImportRecords はヘッダー行と対応付けに従って各行をテストケースに変換する。
+// タイトルとスイートが空で手順だけがある行は直前のテストケースの手順の続きとして扱う
+func parseImportRecords(records [][]string, mapping util.ImportColumnMapping) ([]testCaseImportRow, error) {
+	if len(records) == 0 {
+		return nil, errors.New("file has no header row")
+	}
+	if strings.TrimSpace(mapping.Title) == "" {
+		return nil, errors.New("title column is required")
+	}
+
+	header := map[string]int{}
+	for i, name := range records[0] {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if _, ok := header[key]; !ok {
+			header[key] = i
+		}
+	}
+	column := func(name string) (int, error) {
+		if strings.TrimSpace(name) == "" {
+			return -1, nil
+		}
+		index, ok := header[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("column %q not found", name)
+		}
+		return index, nil
+	}
+
+	columns := map[string]int{}
+	for _, field := range []struct {
+		key  string
+		name string
+	}{
+		{"title", mapping.Title},
+		{"test_suite_path", mapping.TestSuitePath},
+		{"content", mapping.Content},
+		{"steps", mapping.Steps},
+		{"expected_results", mapping.ExpectedResults},
+		{"milestone", mapping.Milestone},
+		{"tags", mapping.Tags},
+	} {
+		index, err := column(field.name)
+		if err != nil {
+			return nil, err
+		}
+		columns[field.key] = index
+	}
+	customFieldColumns := map[string]int{}
+	if mapping.CustomFields == nil {
+		// 対応付けが無い場合はエクスポートと同じ接頭辞の列をカスタムフィールドとして読み込む
+		for i, name := range records[0] {
+			name = strings.TrimSpace(name)
+			if len(name) > len(customFieldColumnPrefix) && strings.EqualFold(name[:len(customFieldColumnPrefix)], customFieldColumnPrefix) {
+				customFieldColumns[strings.TrimSpace(name[len(customFieldColumnPrefix):])] = i
+			}
+		}
+	}
+	for field, name := range mapping.CustomFields {
+		index, err := column(name)
+		if err != nil {
+			return nil, err
+		}
+		if index >= 0 {
+			customFieldColumns[field] = index
+		}
+	}
+
+	rows := []testCaseImportRow{}
+	for i, record := range records[1:] {
+		cell := func(field string) string {
+			index := columns[field]
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return record[index]
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		steps := parseImportSteps(cell("steps"), cell("expected_results"))
+		if strings.TrimSpace(cell("title")) == "" && strings.TrimSpace(cell("test_suite_path")) == "" && len(steps) > 0 && len(rows) > 0 {
+			previous := &rows[len(rows)-1]
+			previous.Steps = append(previous.Steps, steps...)
+			continue
+		}
+
+		row := testCaseImportRow{
+			SourceRow:      i + 2,
+			TestSuitePath:  splitTestSuitePath(cell("test_suite_path")),
+			Title:          strings.TrimSpace(cell("title")),
+			Content:        cell("content"),
+			MilestoneTitle: strings.TrimSpace(cell("milestone")),
+			Tags:           splitImportTags(cell("tags")),
+			CustomFields:   map[string]string{},
+			Steps:          steps,
+		}
+		for field, index := range customFieldColumns {
+			if index < len(record) && strings.TrimSpace(record[index]) != "" {
+				row.CustomFields[field] = record[index]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func splitImportTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseImportSteps は複数行の手順と期待結果を行ごとに対応付ける
+func parseImportSteps(actions string, expectedResults string) []util.TestCaseStep {
+	actionLines := splitStepLines(actions)
+	expectedLines := splitStepLines(expectedResults)
+
+	steps := []util.TestCaseStep{}
+	for i := 0; i < len(actionLines) || i < len(expectedLines); i++ {
+		step := util.TestCaseStep{}
+		if i < len(actionLines) {
+			step.Action = actionLines[i]
+		}
+		if i < len(expectedLines) {
+			step.ExpectedResult = expectedLines[i]
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// splitStepLines はセル内の手順を分割する。
+// "1." や "2)" のような番号があれば番号ごとにまとめ（番号の無い行は直前の手順の続き）、無ければ一行を一手順とする
+func splitStepLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	numbered := false
+	for _, line := range lines {
+		if stepNumberPattern.MatchString(line) {
+			numbered = true
+			break
+		}
+	}
+
+	items := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if numbered {
+			if stepNumberPattern.MatchString(line) || len(items) == 0 {
+				items = append(items, strings.TrimSpace(stepNumberPattern.ReplaceAllString(line, "")))
+			} else {
+				items[len(items)-1] += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+		items = append(items, strings.TrimSpace(stepBulletPattern.ReplaceAllString(line, "")))
+	}
+	return items
+}
@@ -272,3 +272,18 @@ func (h *MemberHandler) DeleteMember(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// findAccessUser はリクエストを送ったログイン中のユーザーを取得する
+func findAccessUser(c *gin.Context, db *gorm.DB) (model.User, error) {
+	var accessUser model.User
+	err := db.Where("email = ?", c.GetString("email")).First(&accessUser).Error
+	return accessUser, err
+}
+
+func handleAccessUserError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		handleError(c, http.StatusUnauthorized, "User not found", err)
+		return
+	}
+	handleError(c, http.StatusInternalServerError, "Failed to retrieve user", err)
+}
@@ -3,8 +3,10 @@ package handler
 import (
 	"backend/model"
 	"backend/util"
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
@@ -12,6 +14,9 @@ import (
 	"time"
 )
 
+// errMilestoneNotFound はテストプランやテストランと別のプロジェクトや存在しないマイルストーンを指定した場合のエラー
+var errMilestoneNotFound = errors.New("milestone not found in project")
+
 type MilestoneHandler struct {
 	DB *gorm.DB
 }
@@ -43,17 +48,16 @@ func (h *MilestoneHandler) GetMilestones(c *gin.Context) {
 		log.Fatal("Failed to retrieve records: ", result.Error)
 	}
 
+	stats, err := loadMilestoneStats(h.DB, Milestones, time.Now())
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve milestone stats", err)
+		return
+	}
+
 	// Prepare the response data
 	milestoneResponses := []util.Milestone{}
 	for _, Milestone := range Milestones {
-		milestoneResponses = append(milestoneResponses, util.Milestone{
-			ID:            Milestone.ID,
-			Title:         Milestone.Title,
-			Description:   Milestone.Description,
-			DueDate:       Milestone.DueDate.Format("2006-01-02"),
-			Status:        Milestone.Status,
-			TestCaseCount: 0,
-		})
+		milestoneResponses = append(milestoneResponses, createMilestoneResponse(Milestone, stats[Milestone.ID]))
 	}
 
 	data := util.MilestonesResponseData{
@@ -64,6 +68,7 @@ func (h *MilestoneHandler) GetMilestones(c *gin.Context) {
 	c.JSON(http.StatusOK, data)
 }
 
+// GetMilestone はマイルストーンの進捗と、マイルストーンで実施したすべてのテストプランの結果をまとめて返す
 func (h *MilestoneHandler) GetMilestone(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -82,7 +87,56 @@ func (h *MilestoneHandler) GetMilestone(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, Milestone)
+	stats, err := loadMilestoneStats(h.DB, []model.Milestone{Milestone}, time.Now())
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve milestone stats", err)
+		return
+	}
+
+	// テストランにマイルストーンが無い場合はテストプランのマイルストーンで実施したものとする
+	var testRuns []model.TestRun
+	if err := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Preload("TestRunCases.Status").
+		Where("milestone_id = ? OR (milestone_id IS NULL AND test_plan_id IN (?))", Milestone.ID,
+			h.DB.Model(&model.TestPlan{}).Select("id").Where("milestone_id = ?", Milestone.ID)).
+		Find(&testRuns).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test runs", err)
+		return
+	}
+	testPlanIDs := []uint{}
+	for _, run := range testRuns {
+		testPlanIDs = append(testPlanIDs, run.TestPlanID)
+	}
+	var testPlans []model.TestPlan
+	if err := h.DB.Preload("CreatedBy").Preload("UpdatedBy").
+		Where("milestone_id = ? OR id IN ?", Milestone.ID, uniqueUints(testPlanIDs)).
+		Find(&testPlans).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test plans", err)
+		return
+	}
+
+	milestone := createMilestoneResponse(Milestone, stats[Milestone.ID])
+	detail := util.MilestoneDetail{
+		ID:            milestone.ID,
+		ProjectID:     Milestone.ProjectID,
+		Title:         milestone.Title,
+		Description:   milestone.Description,
+		DueDate:       milestone.DueDate,
+		Status:        milestone.Status,
+		TestCaseCount: milestone.TestCaseCount,
+		Stats:         milestone.Stats,
+		TestPlans:     []util.TestPlan{},
+		TestRuns:      []util.TestRun{},
+		Charts:        aggregateStatusCounts(testRuns),
+		Percentage:    calcPercentage(testRuns),
+	}
+	for _, testPlan := range testPlans {
+		detail.TestPlans = append(detail.TestPlans, createTestPlanResponse(testPlan))
+	}
+	for _, run := range testRuns {
+		detail.TestRuns = append(detail.TestRuns, createTestRunResponse(run))
+	}
+
+	c.JSON(http.StatusOK, detail)
 }
 
 type jsonMilestone struct {
@@ -173,3 +227,36 @@ func (h *MilestoneHandler) DeleteMilestone(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// validateMilestone はマイルストーンがプロジェクトのものか確かめる。未指定の場合は何もしない
+func validateMilestone(db *gorm.DB, projectID uint, milestoneID *uint) error {
+	if milestoneID == nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&model.Milestone{}).Where("id = ? AND project_id = ?", *milestoneID, projectID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errMilestoneNotFound
+	}
+	return nil
+}
+
+func handleMilestoneError(c *gin.Context, err error) {
+	if errors.Is(err, errMilestoneNotFound) {
+		handleError(c, http.StatusBadRequest, "Milestone not found in project", err)
+		return
+	}
+	handleError(c, http.StatusInternalServerError, "Failed to retrieve milestone", err)
+}
+
+// milestoneSpecified はリクエストに milestone_id が含まれるか返す。null を指定した場合はマイルストーンを解除する
+func milestoneSpecified(c *gin.Context) (bool, error) {
+	var fields map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&fields, binding.JSON); err != nil {
+		return false, err
+	}
+	_, ok := fields["milestone_id"]
+	return ok, nil
+}
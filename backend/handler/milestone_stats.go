@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"time"
+)
+
+// loadMilestoneStats はマイルストーンに紐づくテストケースの数と、最新の結果から見た進捗を集計する。
+// 完了の予測は結果を登録した速さから求め、期日を過ぎる場合は遅延の恐れありとする
+func loadMilestoneStats(db *gorm.DB, milestones []model.Milestone, now time.Time) (map[uint]util.MilestoneStats, error) {
+	stats := map[uint]util.MilestoneStats{}
+	if len(milestones) == 0 {
+		return stats, nil
+	}
+	milestoneIDs := []uint{}
+	for _, milestone := range milestones {
+		milestoneIDs = append(milestoneIDs, milestone.ID)
+	}
+
+	var testCases []model.TestCase
+	if err := db.Select("id, milestone_id").Where("milestone_id IN ?", milestoneIDs).Find(&testCases).Error; err != nil {
+		return nil, err
+	}
+	testCaseIDs := []uint{}
+	testCaseMilestones := map[uint]uint{}
+	for _, testCase := range testCases {
+		testCaseIDs = append(testCaseIDs, testCase.ID)
+		testCaseMilestones[testCase.ID] = *testCase.MilestoneID
+	}
+
+	// テストケースごとに、同じマイルストーンで実施した結果のうち最後に更新されたものを使う。
+	// テストランにマイルストーンが無い場合はテストプランのマイルストーンで実施したものとする
+	latest := map[uint]model.TestRunCase{}
+	if len(testCaseIDs) > 0 {
+		var testPlans []model.TestPlan
+		if err := db.Select("id, milestone_id").Where("milestone_id IN ?", milestoneIDs).Find(&testPlans).Error; err != nil {
+			return nil, err
+		}
+		testPlanMilestones := map[uint]uint{}
+		testPlanIDs := []uint{}
+		for _, testPlan := range testPlans {
+			testPlanMilestones[testPlan.ID] = *testPlan.MilestoneID
+			testPlanIDs = append(testPlanIDs, testPlan.ID)
+		}
+		var testRuns []model.TestRun
+		if err := db.Select("id, milestone_id, test_plan_id").
+			Where("milestone_id IN ? OR (milestone_id IS NULL AND test_plan_id IN ?)", milestoneIDs, testPlanIDs).
+			Find(&testRuns).Error; err != nil {
+			return nil, err
+		}
+		testRunMilestones := map[uint]uint{}
+		testRunIDs := []uint{}
+		for _, testRun := range testRuns {
+			if testRun.MilestoneID != nil {
+				testRunMilestones[testRun.ID] = *testRun.MilestoneID
+			} else {
+				testRunMilestones[testRun.ID] = testPlanMilestones[testRun.TestPlanID]
+			}
+			testRunIDs = append(testRunIDs, testRun.ID)
+		}
+
+		if len(testRunIDs) > 0 {
+			var testRunCases []model.TestRunCase
+			if err := db.Preload("Status").
+				Where("test_case_id IN ? AND test_run_id IN ?", testCaseIDs, testRunIDs).
+				Order("updated_at DESC, id DESC").
+				Find(&testRunCases).Error; err != nil {
+				return nil, err
+			}
+			for _, testRunCase := range testRunCases {
+				if testRunMilestones[testRunCase.TestRunID] != testCaseMilestones[testRunCase.TestCaseID] {
+					continue
+				}
+				if _, ok := latest[testRunCase.TestCaseID]; !ok {
+					latest[testRunCase.TestCaseID] = testRunCase
+				}
+			}
+		}
+	}
+
+	type milestoneResults struct {
+		stats     util.MilestoneStats
+		statuses  map[uint]*model.Status
+		counts    map[uint]int
+		startedAt *time.Time
+		lastAt    time.Time
+	}
+	results := map[uint]*milestoneResults{}
+	for _, milestone := range milestones {
+		results[milestone.ID] = &milestoneResults{statuses: map[uint]*model.Status{}, counts: map[uint]int{}}
+	}
+	for _, testCase := range testCases {
+		result, ok := results[*testCase.MilestoneID]
+		if !ok {
+			continue
+		}
+		testRunCase, ok := latest[testCase.ID]
+		// 既定のステータス（未実施）のままの結果は結果が無いものとして扱う
+		if !ok || testRunCase.Status == nil || testRunCase.Status.Default {
+			result.stats.Untested++
+			continue
+		}
+		result.stats.Tested++
+		if testRunCase.Status.Name == statusNamePassed {
+			result.stats.Passed++
+		}
+		result.statuses[testRunCase.Status.ID] = testRunCase.Status
+		result.counts[testRunCase.Status.ID]++
+		if result.startedAt == nil || testRunCase.UpdatedAt.Before(*result.startedAt) {
+			updatedAt := testRunCase.UpdatedAt
+			result.startedAt = &updatedAt
+		}
+		if testRunCase.UpdatedAt.After(result.lastAt) {
+			result.lastAt = testRunCase.UpdatedAt
+		}
+	}
+
+	today := milestoneDate(now)
+	for _, milestone := range milestones {
+		result := results[milestone.ID]
+		s := result.stats
+		s.Results = []util.Chart{}
+		ids := []uint{}
+		for id := range result.statuses {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+		for _, id := range ids {
+			s.Results = append(s.Results, util.Chart{Name: result.statuses[id].Name, Color: result.statuses[id].Color, Count: result.counts[id]})
+		}
+		if s.Tested > 0 {
+			s.PassRate = s.Passed * 100 / s.Tested
+		}
+		if s.Tested+s.Untested > 0 {
+			s.Progress = s.Tested * 100 / (s.Tested + s.Untested)
+		}
+
+		dueDate := milestoneDate(milestone.DueDate)
+		s.DaysRemaining = int(dueDate.Sub(today).Hours() / 24)
+
+		// すべてに結果がある場合は最後の結果の日、それ以外は最初の結果から今日までの速さで残りを実施した場合の日
+		var forecast *time.Time
+		switch {
+		case s.Untested == 0 && s.Tested > 0:
+			date := milestoneDate(result.lastAt)
+			forecast = &date
+		case result.startedAt != nil:
+			days := math.Max(today.Sub(milestoneDate(*result.startedAt)).Hours()/24, 1)
+			remaining := time.Duration(math.Ceil(float64(s.Untested)*days/float64(s.Tested))) * 24 * time.Hour
+			date := today.Add(remaining)
+			forecast = &date
+		}
+		if forecast != nil {
+			formatted := forecast.Format("2006-01-02")
+			s.ForecastDate = &formatted
+		}
+		// 結果が一件も無い場合は予測できないため、期日を過ぎた場合だけ遅延とする
+		if s.Untested > 0 {
+			s.AtRisk = (forecast != nil && forecast.After(dueDate)) || (forecast == nil && today.After(dueDate))
+		}
+		stats[milestone.ID] = s
+	}
+	return stats, nil
+}
+
+// milestoneDate は日付だけを比べるために時刻を切り捨てる
+func milestoneDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// createMilestoneResponse はマイルストーンと集計した進捗をレスポンスにする
+func createMilestoneResponse(milestone model.Milestone, stats util.MilestoneStats) util.Milestone {
+	return util.Milestone{
+		ID:            milestone.ID,
+		Title:         milestone.Title,
+		Description:   milestone.Description,
+		DueDate:       milestone.DueDate.Format("2006-01-02"),
+		Status:        milestone.Status,
+		TestCaseCount: stats.Tested + stats.Untested,
+		Stats:         stats,
+	}
+}
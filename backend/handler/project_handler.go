@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type ProjectHandler struct {
@@ -58,40 +59,19 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 		return
 	}
 
+	stats, err := loadMilestoneStats(h.DB, project.Milestones, time.Now())
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve milestone stats", err)
+		return
+	}
 	milestones := make([]util.Milestone, len(project.Milestones))
 	for i, m := range project.Milestones {
-		milestones[i] = util.Milestone{
-			ID:            m.ID,
-			Title:         m.Title,
-			Description:   m.Description,
-			DueDate:       m.DueDate.Format("2006-01-02"),
-			Status:        m.Status,
-			TestCaseCount: 0, // このフィールド名は実際のmodel.Milestone型に応じて調整してください
-		}
+		milestones[i] = createMilestoneResponse(m, stats[m.ID])
 	}
 
 	testRuns := make([]util.TestPlan, len(project.TestPlans))
 	for i, tp := range project.TestPlans {
-		var startedAtStr *string
-		var completedAtStr *string
-		if tp.StartedAt != nil && !tp.StartedAt.IsZero() {
-			formattedStartedAt := tp.StartedAt.Format("2006-01-02 15:04")
-			startedAtStr = &formattedStartedAt
-		}
-		if tp.CompletedAt != nil && !tp.CompletedAt.IsZero() {
-			formattedCompletedAt := tp.CompletedAt.Format("2006-01-02 15:04")
-			completedAtStr = &formattedCompletedAt
-		}
-		testRuns[i] = util.TestPlan{
-			ID:          tp.ID,
-			ProjectID:   tp.ProjectID,
-			Title:       tp.Title,
-			Status:      tp.Status,
-			StartedAt:   startedAtStr,
-			CompletedAt: completedAtStr,
-			CreatedBy:   util.User{ID: tp.CreatedByID, Name: tp.CreatedBy.Name},
-			UpdatedBy:   util.User{ID: tp.UpdatedByID, Name: tp.UpdatedBy.Name},
-		}
+		testRuns[i] = createTestPlanResponse(tp)
 	}
 
 	response := util.ProjectResponseData{
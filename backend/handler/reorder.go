@@ -0,0 +1,74 @@
+package handler
+
+import "sort"
+
+// reorderEntry は並び替え対象の現在の親（ルートは 0）と並び順を表す
+type reorderEntry struct {
+	ParentID   uint
+	OrderIndex int
+}
+
+// reorderRow はクライアントが指定した移動先での並び順を表す
+type reorderRow struct {
+	ID         uint
+	OrderIndex int
+}
+
+// reorderUpdate は実際に書き込みが必要な行を表す
+type reorderUpdate struct {
+	ID         uint
+	ParentID   uint
+	OrderIndex int
+}
+
+// planReorder は rows を parentID の子として指定順に並べ、残りの兄弟をその後ろに元の順序で続ける。
+// 移動先と移動元の並び順は 0 からの連番に振り直し、値が変わる行だけを返す
+func planReorder(entries map[uint]reorderEntry, siblings map[uint][]uint, parentID uint, rows []reorderRow) []reorderUpdate {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].OrderIndex < rows[j].OrderIndex
+	})
+
+	moved := make(map[uint]bool, len(rows))
+	order := make([]uint, 0, len(rows)+len(siblings[parentID]))
+	for _, row := range rows {
+		moved[row.ID] = true
+		order = append(order, row.ID)
+	}
+	for _, id := range siblings[parentID] {
+		if !moved[id] {
+			order = append(order, id)
+		}
+	}
+
+	updates := []reorderUpdate{}
+	apply := func(parent uint, ids []uint) {
+		for i, id := range ids {
+			if entry, ok := entries[id]; !ok || entry.ParentID != parent || entry.OrderIndex != i {
+				updates = append(updates, reorderUpdate{ID: id, ParentID: parent, OrderIndex: i})
+			}
+		}
+	}
+	apply(parentID, order)
+
+	// 移動元に残った兄弟の並び順を詰める
+	oldParents := []uint{}
+	seen := map[uint]bool{parentID: true}
+	for _, row := range rows {
+		if parent := entries[row.ID].ParentID; !seen[parent] {
+			seen[parent] = true
+			oldParents = append(oldParents, parent)
+		}
+	}
+	sort.Slice(oldParents, func(i, j int) bool { return oldParents[i] < oldParents[j] })
+	for _, parent := range oldParents {
+		remaining := []uint{}
+		for _, id := range siblings[parent] {
+			if !moved[id] {
+				remaining = append(remaining, id)
+			}
+		}
+		apply(parent, remaining)
+	}
+
+	return updates
+}
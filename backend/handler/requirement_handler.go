@@ -0,0 +1,525 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	coverageTested    = "tested"    // 紐づくすべてのテストケースに結果がある
+	coverageUntested  = "untested"  // 結果の無いテストケースがある
+	coverageUncovered = "uncovered" // 紐づくテストケースが無い
+)
+
+type RequirementHandler struct {
+	DB *gorm.DB
+}
+
+func NewRequirementHandler(db *gorm.DB) *RequirementHandler {
+	return &RequirementHandler{DB: db}
+}
+
+type requirementRequest struct {
+	ProjectID   uint    `json:"project_id"`
+	Key         string  `json:"key"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	URL         string  `json:"url"`
+	TestCaseIDs *[]uint `json:"test_case_ids"` // 省略した場合は紐づけを変えない
+}
+
+func createRequirementResponse(requirement model.Requirement) util.Requirement {
+	testCaseIDs := []uint{}
+	for _, testCase := range requirement.TestCases {
+		testCaseIDs = append(testCaseIDs, testCase.ID)
+	}
+	return util.Requirement{
+		ID:          requirement.ID,
+		ProjectID:   requirement.ProjectID,
+		Key:         requirement.Key,
+		Title:       requirement.Title,
+		Description: requirement.Description,
+		URL:         requirement.URL,
+		TestCaseIDs: testCaseIDs,
+	}
+}
+
+func (h *RequirementHandler) findProject(c *gin.Context) (model.Project, bool) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return project, false
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return project, false
+	}
+	return project, true
+}
+
+func orderRequirementTestCases(db *gorm.DB) *gorm.DB {
+	return db.Order("test_cases.order_index, test_cases.id")
+}
+
+func (h *RequirementHandler) GetRequirements(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+
+	var requirements []model.Requirement
+	if err := h.DB.Preload("TestCases", orderRequirementTestCases).
+		Where("project_id = ?", project.ID).
+		Order("requirement_key, id").
+		Find(&requirements).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirements", err)
+		return
+	}
+
+	responses := []util.Requirement{}
+	for _, requirement := range requirements {
+		responses = append(responses, createRequirementResponse(requirement))
+	}
+	c.JSON(http.StatusOK, util.RequirementsResponseData{
+		ProjectID:    project.ID,
+		Requirements: responses,
+	})
+}
+
+func (h *RequirementHandler) PostRequirement(c *gin.Context) {
+	var req requirementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	requirement := model.Requirement{
+		ProjectID:   req.ProjectID,
+		Key:         strings.TrimSpace(req.Key),
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		URL:         strings.TrimSpace(req.URL),
+	}
+
+	var project model.Project
+	if result := h.DB.First(&project, req.ProjectID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+	if !h.validateRequirement(c, requirement, req.TestCaseIDs) {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("TestCases").Create(&requirement).Error; err != nil {
+			return err
+		}
+		if req.TestCaseIDs == nil {
+			return nil
+		}
+		return replaceRequirementTestCases(tx, requirement.ID, *req.TestCaseIDs)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create requirement", err)
+		return
+	}
+
+	h.respondRequirement(c, http.StatusCreated, requirement.ID)
+}
+
+func (h *RequirementHandler) PutRequirement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	var req requirementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	var requirement model.Requirement
+	if result := h.DB.First(&requirement, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Requirement not found", result.Error)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", result.Error)
+		}
+		return
+	}
+	requirement.Key = strings.TrimSpace(req.Key)
+	requirement.Title = strings.TrimSpace(req.Title)
+	requirement.Description = req.Description
+	requirement.URL = strings.TrimSpace(req.URL)
+	if !h.validateRequirement(c, requirement, req.TestCaseIDs) {
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Requirement{}).Where("id = ?", requirement.ID).Updates(map[string]interface{}{
+			"requirement_key": requirement.Key,
+			"title":           requirement.Title,
+			"description":     requirement.Description,
+			"url":             requirement.URL,
+		}).Error; err != nil {
+			return err
+		}
+		if req.TestCaseIDs == nil {
+			return nil
+		}
+		return replaceRequirementTestCases(tx, requirement.ID, *req.TestCaseIDs)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update requirement", err)
+		return
+	}
+
+	h.respondRequirement(c, http.StatusOK, requirement.ID)
+}
+
+func (h *RequirementHandler) DeleteRequirement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	if err := h.DB.Delete(&model.Requirement{}, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete requirement", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// validateRequirement はキーとタイトルが空でないこと、キーがプロジェクト内で重複しないこと、
+// テストケースが同じプロジェクトにあることを確認する
+func (h *RequirementHandler) validateRequirement(c *gin.Context, requirement model.Requirement, testCaseIDs *[]uint) bool {
+	if requirement.Key == "" || requirement.Title == "" {
+		handleError(c, http.StatusBadRequest, "Key and title are required", nil)
+		return false
+	}
+
+	var count int64
+	if err := h.DB.Model(&model.Requirement{}).
+		Where("project_id = ? AND requirement_key = ? AND id <> ?", requirement.ProjectID, requirement.Key, requirement.ID).
+		Count(&count).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirements", err)
+		return false
+	}
+	if count > 0 {
+		handleError(c, http.StatusConflict, "Requirement key already exists", nil)
+		return false
+	}
+
+	if testCaseIDs == nil || len(*testCaseIDs) == 0 {
+		return true
+	}
+	ids := uniqueUints(*testCaseIDs)
+	if err := h.DB.Model(&model.TestCase{}).Where("project_id = ? AND id IN ?", requirement.ProjectID, ids).Count(&count).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+		return false
+	}
+	if int(count) != len(ids) {
+		handleError(c, http.StatusBadRequest, "Test case not found in project", nil)
+		return false
+	}
+	return true
+}
+
+// replaceRequirementTestCases は要件に紐づくテストケースを testCaseIDs に置き換える
+func replaceRequirementTestCases(tx *gorm.DB, requirementID uint, testCaseIDs []uint) error {
+	if err := tx.Where("requirement_id = ?", requirementID).Delete(&model.RequirementTestCase{}).Error; err != nil {
+		return err
+	}
+	links := []model.RequirementTestCase{}
+	for _, id := range uniqueUints(testCaseIDs) {
+		links = append(links, model.RequirementTestCase{RequirementID: requirementID, TestCaseID: id})
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return tx.Create(&links).Error
+}
+
+func uniqueUints(values []uint) []uint {
+	unique := []uint{}
+	seen := map[uint]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func (h *RequirementHandler) respondRequirement(c *gin.Context, code int, id uint) {
+	var requirement model.Requirement
+	if err := h.DB.Preload("TestCases", orderRequirementTestCases).First(&requirement, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirement", err)
+		return
+	}
+	c.JSON(code, createRequirementResponse(requirement))
+}
+
+// ImportRequirements は CSV / XLSX の要件を取り込む。
+// 列は Key, Title, Description, Link（大文字小文字は区別しない）で、既にあるキーの要件は更新する
+func (h *RequirementHandler) ImportRequirements(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+	dryRun, err := parseBoolQuery(c, "dry_run")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "File is required", err)
+		return
+	}
+	records, err := readImportFile(fileHeader, c.PostForm("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "url" {
+			name = "link"
+		}
+		if _, exists := columns[name]; !exists {
+			columns[name] = i
+		}
+	}
+	for _, name := range []string{"key", "title"} {
+		if _, ok := columns[name]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "column not found: " + name})
+			return
+		}
+	}
+	cell := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var existing []model.Requirement
+	if err := h.DB.Where("project_id = ?", project.ID).Find(&existing).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirements", err)
+		return
+	}
+	existingByKey := map[string]model.Requirement{}
+	for _, requirement := range existing {
+		existingByKey[requirement.Key] = requirement
+	}
+
+	result := util.RequirementImportResult{DryRun: dryRun, Errors: []util.TestCaseImportError{}}
+	creates := []model.Requirement{}
+	updates := []model.Requirement{}
+	seen := map[string]int{}
+	for index, record := range records[1:] {
+		row := index + 2
+		requirement := model.Requirement{
+			ProjectID:   project.ID,
+			Key:         cell(record, "key"),
+			Title:       cell(record, "title"),
+			Description: cell(record, "description"),
+			URL:         cell(record, "link"),
+		}
+		if requirement.Key == "" && requirement.Title == "" && requirement.Description == "" && requirement.URL == "" {
+			continue
+		}
+		result.Requirements++
+		if requirement.Key == "" {
+			result.Errors = append(result.Errors, util.TestCaseImportError{Row: row, Field: "key", Message: "key is required"})
+			continue
+		}
+		if requirement.Title == "" {
+			result.Errors = append(result.Errors, util.TestCaseImportError{Row: row, Field: "title", Message: "title is required"})
+			continue
+		}
+		if first, ok := seen[requirement.Key]; ok {
+			result.Errors = append(result.Errors, util.TestCaseImportError{Row: row, Field: "key", Message: "duplicate key (row " + strconv.Itoa(first) + ")"})
+			continue
+		}
+		seen[requirement.Key] = row
+
+		if current, ok := existingByKey[requirement.Key]; ok {
+			requirement.ID = current.ID
+			updates = append(updates, requirement)
+		} else {
+			creates = append(creates, requirement)
+		}
+	}
+	result.Created = len(creates)
+	result.Updated = len(updates)
+	if dryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if len(creates) > 0 {
+			if err := tx.Omit("TestCases").CreateInBatches(&creates, 100).Error; err != nil {
+				return err
+			}
+		}
+		for _, requirement := range updates {
+			if err := tx.Model(&model.Requirement{}).Where("id = ?", requirement.ID).Updates(map[string]interface{}{
+				"title":       requirement.Title,
+				"description": requirement.Description,
+				"url":         requirement.URL,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to import requirements", err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTraceability は要件ごとに紐づくテストケースと最新の結果を返す。
+// test_plan_id を指定するとそのプランのテストランの結果、milestone_id を指定するとそのマイルストーンの
+// テストケースだけを対象にする。指定しない場合はプロジェクトのすべてのテストランの結果を使う
+func (h *RequirementHandler) GetTraceability(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+
+	matrix := util.TraceabilityMatrix{ProjectID: project.ID, Requirements: []util.TraceabilityRequirement{}}
+	runQuery := h.DB.Model(&model.TestRun{}).Select("id").Where("project_id = ?", project.ID)
+	if v := c.Query("test_plan_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid test_plan_id", err)
+			return
+		}
+		testPlanID := uint(id)
+		matrix.TestPlanID = &testPlanID
+		runQuery = runQuery.Where("test_plan_id = ?", testPlanID)
+	}
+	if v := c.Query("milestone_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid milestone_id", err)
+			return
+		}
+		milestoneID := uint(id)
+		matrix.MilestoneID = &milestoneID
+	}
+
+	testCaseScope := func(db *gorm.DB) *gorm.DB {
+		db = orderRequirementTestCases(db)
+		if matrix.MilestoneID != nil {
+			db = db.Where("test_cases.milestone_id = ?", *matrix.MilestoneID)
+		}
+		return db
+	}
+	var requirements []model.Requirement
+	if err := h.DB.Preload("TestCases", testCaseScope).
+		Where("project_id = ?", project.ID).
+		Order("requirement_key, id").
+		Find(&requirements).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirements", err)
+		return
+	}
+
+	testCaseIDs := []uint{}
+	for _, requirement := range requirements {
+		for _, testCase := range requirement.TestCases {
+			testCaseIDs = append(testCaseIDs, testCase.ID)
+		}
+	}
+	// テストケースごとに最後に更新された結果を使う
+	latest := map[uint]model.TestRunCase{}
+	if len(testCaseIDs) > 0 {
+		var testRunCases []model.TestRunCase
+		if err := h.DB.Preload("Status").
+			Where("test_case_id IN ? AND test_run_id IN (?)", uniqueUints(testCaseIDs), runQuery).
+			Order("updated_at DESC, id DESC").
+			Find(&testRunCases).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test results", err)
+			return
+		}
+		for _, testRunCase := range testRunCases {
+			if _, ok := latest[testRunCase.TestCaseID]; !ok {
+				latest[testRunCase.TestCaseID] = testRunCase
+			}
+		}
+	}
+
+	for _, requirement := range requirements {
+		row := util.TraceabilityRequirement{
+			ID:        requirement.ID,
+			Key:       requirement.Key,
+			Title:     requirement.Title,
+			URL:       requirement.URL,
+			Coverage:  coverageTested,
+			TestCases: []util.TraceabilityTestCase{},
+		}
+		for _, testCase := range requirement.TestCases {
+			item := util.TraceabilityTestCase{ID: testCase.ID, Title: testCase.Title}
+			testRunCase, ok := latest[testCase.ID]
+			// 既定のステータス（未実施）のままの結果は結果が無いものとして扱う
+			if ok && testRunCase.Status != nil && !testRunCase.Status.Default {
+				testRunID := testRunCase.TestRunID
+				item.TestRunID = &testRunID
+				item.Status = &util.Status{ID: testRunCase.Status.ID, Name: testRunCase.Status.Name, Color: testRunCase.Status.Color}
+			} else {
+				row.Coverage = coverageUntested
+			}
+			row.TestCases = append(row.TestCases, item)
+		}
+		if len(row.TestCases) == 0 {
+			row.Coverage = coverageUncovered
+		}
+
+		matrix.Summary.Requirements++
+		switch row.Coverage {
+		case coverageTested:
+			matrix.Summary.Tested++
+		case coverageUntested:
+			matrix.Summary.Untested++
+		case coverageUncovered:
+			matrix.Summary.Uncovered++
+		}
+		matrix.Requirements = append(matrix.Requirements, row)
+	}
+	if matrix.Summary.Requirements > 0 {
+		matrix.Summary.Percentage = matrix.Summary.Tested * 100 / matrix.Summary.Requirements
+	}
+
+	c.JSON(http.StatusOK, matrix)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 検索結果の件数
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+)
+
+type SearchHandler struct {
+	DB    *gorm.DB
+	Index SearchIndex
+}
+
+func NewSearchHandler(db *gorm.DB, index SearchIndex) *SearchHandler {
+	return &SearchHandler{DB: db, Index: index}
+}
+
+// Search はすべてのプロジェクトを検索する
+func (h *SearchHandler) Search(c *gin.Context) {
+	h.search(c, nil)
+}
+
+// SearchProject はプロジェクトの中を検索する
+func (h *SearchHandler) SearchProject(c *gin.Context) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+	h.search(c, &project.ID)
+}
+
+func (h *SearchHandler) search(c *gin.Context, projectID *uint) {
+	query := SearchQuery{Text: strings.TrimSpace(c.Query("q")), ProjectID: projectID, Types: searchTypes, Limit: searchDefaultLimit}
+	if query.Text == "" {
+		handleError(c, http.StatusBadRequest, "Query is required", nil)
+		return
+	}
+	if value := c.Query("type"); value != "" {
+		valid := map[string]bool{}
+		for _, searchType := range searchTypes {
+			valid[searchType] = true
+		}
+		query.Types = []string{}
+		for _, searchType := range strings.Split(value, ",") {
+			searchType = strings.TrimSpace(searchType)
+			if !valid[searchType] {
+				handleError(c, http.StatusBadRequest, "Invalid search type", nil)
+				return
+			}
+			query.Types = append(query.Types, searchType)
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			handleError(c, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		if limit > searchMaxLimit {
+			limit = searchMaxLimit
+		}
+		query.Limit = limit
+	}
+
+	hits, err := h.Index.Search(h.DB, query)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to search", err)
+		return
+	}
+	sortSearchHits(hits)
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+
+	// 結果からプロジェクトの画面へ移動できるようにプロジェクトのコードを返す
+	projectIDs := []uint{}
+	for _, hit := range hits {
+		projectIDs = append(projectIDs, hit.ProjectID)
+	}
+	projectIDs = uniqueUints(projectIDs)
+	if len(projectIDs) > 0 {
+		var projects []model.Project
+		if result := h.DB.Where("id IN ?", projectIDs).Find(&projects); result.Error != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve projects", result.Error)
+			return
+		}
+		codes := map[uint]string{}
+		for _, project := range projects {
+			codes[project.ID] = project.Code
+		}
+		for i := range hits {
+			hits[i].ProjectCode = codes[hits[i].ProjectID]
+		}
+	}
+
+	c.JSON(http.StatusOK, util.SearchResponseData{Query: query.Text, ProjectID: projectID, Hits: hits})
+}
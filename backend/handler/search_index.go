@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 検索の対象
+const (
+	searchTypeTestCase  = "test_case"
+	searchTypeTestSuite = "test_suite"
+	searchTypeTestRun   = "test_run"
+	searchTypeComment   = "comment"
+)
+
+var searchTypes = []string{searchTypeTestCase, searchTypeTestSuite, searchTypeTestRun, searchTypeComment}
+
+// searchSnippetLength は抜粋の前後に含める文字数
+const searchSnippetLength = 60
+
+// SearchQuery は検索の条件。ProjectID が nil の場合はすべてのプロジェクトを検索する
+type SearchQuery struct {
+	Text      string
+	ProjectID *uint
+	Types     []string
+	Limit     int
+}
+
+// SearchIndex は全文検索の実装（SEARCH_BACKEND で切り替える）
+type SearchIndex interface {
+	// Prepare は起動時に索引を作成し、書き込みに合わせて索引を更新できるようにする
+	Prepare(db *gorm.DB) error
+	// Search は Types ごとに Limit 件までの結果を返す（並べ替えと件数の制限は呼び出し側で行う）
+	Search(db *gorm.DB, query SearchQuery) ([]util.SearchHit, error)
+}
+
+// NewSearchIndex は backend に合わせた検索の実装を返す。空の場合は MySQL の FULLTEXT 索引を使う
+func NewSearchIndex(backend string) (SearchIndex, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "", "mysql":
+		return &MySQLSearchIndex{}, nil
+	case "memory":
+		return NewMemorySearchIndex(), nil
+	}
+	return nil, fmt.Errorf("unknown search backend: %s", backend)
+}
+
+// searchRow は検索の対象を同じ形で読み込むための行
+type searchRow struct {
+	ID            uint
+	ProjectID     uint
+	Title         string
+	Body          string
+	TestSuiteID   *uint
+	TestPlanID    *uint
+	TestRunID     *uint
+	TestCaseID    *uint
+	TestRunCaseID *uint
+	Score         float64
+}
+
+// searchSource は検索の対象の種類ごとに、削除されていないレコードを searchRow の列で読み込むクエリを返す。
+// columns には追加で読み込む列（スコアなど）を指定する
+func searchSource(db *gorm.DB, searchType string, projectID *uint, columns string, args ...interface{}) *gorm.DB {
+	var query *gorm.DB
+	var projectColumn string
+	switch searchType {
+	case searchTypeTestCase:
+		query = db.Table("test_cases").
+			Select("test_cases.id, test_cases.project_id, test_cases.title, test_cases.content AS body, test_cases.test_suite_id"+columns, args...).
+			Where("test_cases.deleted_at IS NULL")
+		projectColumn = "test_cases.project_id"
+	case searchTypeTestSuite:
+		query = db.Table("test_suites").
+			Select("test_suites.id, test_suites.project_id, test_suites.name AS title"+columns, args...).
+			Where("test_suites.deleted_at IS NULL")
+		projectColumn = "test_suites.project_id"
+	case searchTypeTestRun:
+		query = db.Table("test_runs").
+			Select("test_runs.id, test_runs.project_id, test_runs.title, test_runs.test_plan_id"+columns, args...).
+			Where("test_runs.deleted_at IS NULL")
+		projectColumn = "test_runs.project_id"
+	default:
+		// コメントはテストランのケースを通してテストランとテストケースに紐づける
+		query = db.Table("comments").
+			Select("comments.id, test_runs.project_id, test_cases.title, comments.content AS body, test_runs.test_plan_id, "+
+				"test_runs.id AS test_run_id, test_run_cases.test_case_id, test_run_cases.id AS test_run_case_id"+columns, args...).
+			Joins("JOIN test_run_cases ON test_run_cases.id = comments.test_run_case_id AND test_run_cases.deleted_at IS NULL").
+			Joins("JOIN test_runs ON test_runs.id = test_run_cases.test_run_id AND test_runs.deleted_at IS NULL").
+			Joins("JOIN test_cases ON test_cases.id = test_run_cases.test_case_id").
+			Where("comments.deleted_at IS NULL")
+		projectColumn = "test_runs.project_id"
+	}
+	if projectID != nil {
+		return query.Where(projectColumn+" = ?", *projectID)
+	}
+	return query.Where(projectColumn + " IN (SELECT id FROM projects WHERE deleted_at IS NULL)")
+}
+
+// appendSearchSteps はテストケースの本文に手順と期待結果を加える（共有ステップの参照はその共有ステップの手順を加える）
+func appendSearchSteps(db *gorm.DB, rows []searchRow) error {
+	ids := []uint{}
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var steps []model.TestCaseStep
+	if err := db.Where("test_case_id IN ?", ids).Order("test_case_id, order_index").Find(&steps).Error; err != nil {
+		return err
+	}
+	if err := resolveSharedSteps(db, &steps); err != nil {
+		return err
+	}
+	stepTexts := map[uint][]string{}
+	for _, step := range steps {
+		stepTexts[step.TestCaseID] = append(stepTexts[step.TestCaseID], step.Action, step.ExpectedResult)
+	}
+	for i := range rows {
+		if texts := stepTexts[rows[i].ID]; len(texts) > 0 {
+			rows[i].Body = strings.TrimSpace(rows[i].Body + "\n" + strings.Join(texts, "\n"))
+		}
+	}
+	return nil
+}
+
+func newSearchHit(searchType string, row searchRow, terms []string) util.SearchHit {
+	return util.SearchHit{
+		Type:          searchType,
+		ID:            row.ID,
+		ProjectID:     row.ProjectID,
+		Title:         row.Title,
+		Snippet:       searchSnippet(row.Title, row.Body, terms),
+		Score:         row.Score,
+		TestSuiteID:   row.TestSuiteID,
+		TestPlanID:    row.TestPlanID,
+		TestRunID:     row.TestRunID,
+		TestCaseID:    row.TestCaseID,
+		TestRunCaseID: row.TestRunCaseID,
+	}
+}
+
+// searchTerms は検索語を空白で区切る（大文字と小文字は区別しない）
+func searchTerms(text string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchSnippet は本文のうち最初に検索語が現れる位置の前後を返す。本文に無い場合は本文の先頭（本文が無い場合はタイトル）を返す
+func searchSnippet(title string, body string, terms []string) string {
+	text := []rune(strings.Join(strings.Fields(body), " "))
+	if len(text) == 0 {
+		return title
+	}
+	lower := strings.ToLower(string(text))
+	start := -1
+	for _, term := range terms {
+		if index := strings.Index(lower, term); index >= 0 {
+			position := len([]rune(lower[:index]))
+			if start < 0 || position < start {
+				start = position
+			}
+		}
+	}
+	if start < 0 || start > len(text) {
+		start = 0
+	}
+
+	from := start - searchSnippetLength
+	if from < 0 {
+		from = 0
+	}
+	to := start + searchSnippetLength*2
+	if to > len(text) {
+		to = len(text)
+	}
+	snippet := string(text[from:to])
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// searchTokens は文章を索引の語に分ける。英数字などは単語ごとに、日本語などの単語の区切りが無い文字は 2 文字ずつに分ける
+func searchTokens(text string) []string {
+	tokens := []string{}
+	var word []rune
+	var ideographs []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushIdeographs := func() {
+		if len(ideographs) == 1 {
+			tokens = append(tokens, string(ideographs))
+		}
+		for i := 0; i+1 < len(ideographs); i++ {
+			tokens = append(tokens, string(ideographs[i:i+2]))
+		}
+		ideographs = ideographs[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			ideographs = append(ideographs, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushIdeographs()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushIdeographs()
+		}
+	}
+	flushWord()
+	flushIdeographs()
+	return tokens
+}
+
+// sortSearchHits はスコアの高い順に並べる（同じスコアは種類と ID の順）
+func sortSearchHits(hits []util.SearchHit) {
+	order := map[string]int{}
+	for i, searchType := range searchTypes {
+		order[searchType] = i
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Type != hits[j].Type {
+			return order[hits[i].Type] < order[hits[j].Type]
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"backend/util"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memorySearchSettle は書き込みの後も索引を読み込み直す期間。
+// 書き込みはトランザクションのコミット前に通知されるため、コミットされるまでの間は読み込み直しを続ける
+const memorySearchSettle = 10 * time.Second
+
+// memorySearchMaxAge は書き込みが無くても索引を読み込み直すまでの期間。
+// 書き込みの検知は同じプロセスの中だけのため、他のインスタンスの書き込みはこの期間の後に反映される
+const memorySearchMaxAge = time.Minute
+
+// memorySearchTitleWeight はタイトルに含まれる語の重み
+const memorySearchTitleWeight = 3
+
+// memorySearchTables は書き込まれたテーブルと、読み込み直す検索の対象
+var memorySearchTables = map[string][]string{
+	"test_cases":        {searchTypeTestCase, searchTypeComment},
+	"test_case_steps":   {searchTypeTestCase},
+	"shared_steps":      {searchTypeTestCase},
+	"shared_step_items": {searchTypeTestCase},
+	"test_suites":       {searchTypeTestSuite},
+	"test_runs":         {searchTypeTestRun, searchTypeComment},
+	"test_run_cases":    {searchTypeComment},
+	"comments":          {searchTypeComment},
+	"projects":          searchTypes,
+}
+
+// MemorySearchIndex はアプリケーションのメモリ上に索引を持って検索する（FULLTEXT 索引を使えないデータベース向け）。
+// gorm の書き込みを検知して、変更された対象を次の検索で読み込み直す。複数のインスタンスで動かす場合は、
+// 他のインスタンスの書き込みは memorySearchMaxAge ごとの読み込み直しで反映される
+type MemorySearchIndex struct {
+	mu        sync.Mutex
+	changedAt map[string]time.Time
+	// 対象ごとに索引を持ち、読み込み直している間も他の対象は検索できるようにする
+	indexes map[string]*memorySearchTypeIndex
+}
+
+type memorySearchTypeIndex struct {
+	mu        sync.Mutex
+	documents []memorySearchDocument
+	loadedAt  time.Time
+}
+
+type memorySearchDocument struct {
+	row    searchRow
+	title  map[string]int
+	body   map[string]int
+	tokens []string
+}
+
+func NewMemorySearchIndex() *MemorySearchIndex {
+	indexes := map[string]*memorySearchTypeIndex{}
+	for _, searchType := range searchTypes {
+		indexes[searchType] = &memorySearchTypeIndex{}
+	}
+	return &MemorySearchIndex{changedAt: map[string]time.Time{}, indexes: indexes}
+}
+
+// Prepare は書き込みを検知するコールバックを登録する
+func (s *MemorySearchIndex) Prepare(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Create().After("gorm:create").Register("search:create", s.markChanged); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:update").Register("search:update", s.markChanged); err != nil {
+		return err
+	}
+	if err := callback.Delete().After("gorm:delete").Register("search:delete", s.markChanged); err != nil {
+		return err
+	}
+	return callback.Raw().After("gorm:raw").Register("search:raw", s.markChanged)
+}
+
+// markChanged は書き込まれたテーブルに関係する検索の対象を読み込み直す対象にする。
+// SQL を直接実行した場合はテーブルが分からないため、すべての対象を読み込み直す
+func (s *MemorySearchIndex) markChanged(tx *gorm.DB) {
+	types, ok := memorySearchTables[tx.Statement.Table]
+	if !ok {
+		if tx.Statement.Table != "" {
+			return
+		}
+		types = searchTypes
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, searchType := range types {
+		s.changedAt[searchType] = now
+	}
+}
+
+func (s *MemorySearchIndex) Search(db *gorm.DB, query SearchQuery) ([]util.SearchHit, error) {
+	tokens := searchTokens(query.Text)
+	terms := searchTerms(query.Text)
+	hits := []util.SearchHit{}
+	if len(tokens) == 0 {
+		return hits, nil
+	}
+
+	for _, searchType := range query.Types {
+		documents, err := s.load(db, searchType)
+		if err != nil {
+			return nil, err
+		}
+
+		// すべての語を含む文書を探し、語ごとの文書数を数える
+		type match struct {
+			document *memorySearchDocument
+			counts   []float64
+		}
+		matches := []match{}
+		frequencies := make([]int, len(tokens))
+		for i := range documents {
+			document := &documents[i]
+			if query.ProjectID != nil && document.row.ProjectID != *query.ProjectID {
+				continue
+			}
+			counts := make([]float64, len(tokens))
+			found := true
+			for j, token := range tokens {
+				counts[j] = document.count(token)
+				if counts[j] == 0 {
+					found = false
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+			for j := range tokens {
+				frequencies[j]++
+			}
+			matches = append(matches, match{document: document, counts: counts})
+		}
+
+		// TF-IDF でスコアを付ける
+		searchTypeHits := []util.SearchHit{}
+		for _, m := range matches {
+			row := m.document.row
+			row.Score = 0
+			for j, count := range m.counts {
+				row.Score += count * math.Log(1+float64(len(documents))/float64(frequencies[j]))
+			}
+			row.Score = math.Round(row.Score*10000) / 10000
+			searchTypeHits = append(searchTypeHits, newSearchHit(searchType, row, terms))
+		}
+		sortSearchHits(searchTypeHits)
+		if len(searchTypeHits) > query.Limit {
+			searchTypeHits = searchTypeHits[:query.Limit]
+		}
+		hits = append(hits, searchTypeHits...)
+	}
+	return hits, nil
+}
+
+// load は検索の対象の索引を返す。読み込んでいない場合や、読み込んだ後に書き込まれた場合、
+// 読み込んでから memorySearchMaxAge が過ぎた場合は読み込み直す
+func (s *MemorySearchIndex) load(db *gorm.DB, searchType string) ([]memorySearchDocument, error) {
+	index := s.indexes[searchType]
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	s.mu.Lock()
+	changedAt := s.changedAt[searchType]
+	s.mu.Unlock()
+	if !index.loadedAt.IsZero() && changedAt.Before(index.loadedAt.Add(-memorySearchSettle)) && time.Since(index.loadedAt) < memorySearchMaxAge {
+		return index.documents, nil
+	}
+
+	startedAt := time.Now()
+	var rows []searchRow
+	if err := searchSource(db, searchType, nil, "").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if searchType == searchTypeTestCase {
+		if err := appendSearchSteps(db, rows); err != nil {
+			return nil, err
+		}
+	}
+	documents := make([]memorySearchDocument, 0, len(rows))
+	for _, row := range rows {
+		documents = append(documents, newMemorySearchDocument(row))
+	}
+	index.documents = documents
+	index.loadedAt = startedAt
+	return documents, nil
+}
+
+func newMemorySearchDocument(row searchRow) memorySearchDocument {
+	document := memorySearchDocument{row: row, title: map[string]int{}, body: map[string]int{}}
+	for _, token := range searchTokens(row.Title) {
+		document.title[token]++
+	}
+	for _, token := range searchTokens(row.Body) {
+		document.body[token]++
+	}
+	for token := range document.title {
+		document.tokens = append(document.tokens, token)
+	}
+	for token := range document.body {
+		if _, ok := document.title[token]; !ok {
+			document.tokens = append(document.tokens, token)
+		}
+	}
+	sort.Strings(document.tokens)
+	return document
+}
+
+// count は語が現れる回数を返す（タイトルは重みを付ける）。入力途中の語でも検索できるように前方一致で数える
+func (d *memorySearchDocument) count(token string) float64 {
+	count := 0
+	for i := sort.SearchStrings(d.tokens, token); i < len(d.tokens) && strings.HasPrefix(d.tokens[i], token); i++ {
+		count += d.title[d.tokens[i]]*memorySearchTitleWeight + d.body[d.tokens[i]]
+	}
+	return float64(count)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"backend/util"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// MySQLSearchIndex は MySQL の FULLTEXT 索引（ngram パーサー）で検索する。
+// 索引は MySQL が書き込みと同時に更新するため、同期の処理は不要
+type MySQLSearchIndex struct{}
+
+// mysqlSearchIndexes は検索に使う FULLTEXT 索引
+var mysqlSearchIndexes = []struct {
+	Table   string
+	Name    string
+	Columns string
+}{
+	{"test_cases", "idx_test_cases_search", "title, content"},
+	{"test_case_steps", "idx_test_case_steps_search", "action, expected_result"},
+	{"shared_step_items", "idx_shared_step_items_search", "action, expected_result"},
+	{"test_suites", "idx_test_suites_search", "name"},
+	{"test_runs", "idx_test_runs_search", "title"},
+	{"comments", "idx_comments_search", "content"},
+}
+
+// Prepare は FULLTEXT 索引が無ければ作成する
+func (s *MySQLSearchIndex) Prepare(db *gorm.DB) error {
+	for _, index := range mysqlSearchIndexes {
+		if db.Migrator().HasIndex(index.Table, index.Name) {
+			continue
+		}
+		if err := db.Exec("ALTER TABLE " + index.Table + " ADD FULLTEXT INDEX " + index.Name + " (" + index.Columns + ") WITH PARSER ngram").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MySQLSearchIndex) Search(db *gorm.DB, query SearchQuery) ([]util.SearchHit, error) {
+	terms := searchTerms(query.Text)
+	against := mysqlBooleanQuery(terms)
+	hits := []util.SearchHit{}
+	if against == "" {
+		return hits, nil
+	}
+
+	for _, searchType := range query.Types {
+		var tx *gorm.DB
+		switch searchType {
+		case searchTypeTestCase:
+			// 手順と期待結果、参照している共有ステップの手順に一致したテストケースも対象にする
+			match := "MATCH(test_cases.title, test_cases.content) AGAINST (? IN BOOLEAN MODE)"
+			stepMatch := "MATCH(test_case_steps.action, test_case_steps.expected_result) AGAINST (? IN BOOLEAN MODE)"
+			sharedStepMatch := "MATCH(shared_step_items.action, shared_step_items.expected_result) AGAINST (? IN BOOLEAN MODE)"
+			sharedStepItems := "FROM test_case_steps JOIN shared_step_items ON shared_step_items.shared_step_id = test_case_steps.shared_step_id AND shared_step_items.deleted_at IS NULL " +
+				"WHERE test_case_steps.deleted_at IS NULL"
+			tx = searchSource(db, searchType, query.ProjectID,
+				", GREATEST("+match+
+					", COALESCE((SELECT MAX("+stepMatch+") FROM test_case_steps WHERE test_case_steps.test_case_id = test_cases.id AND test_case_steps.deleted_at IS NULL), 0)"+
+					", COALESCE((SELECT MAX("+sharedStepMatch+") "+sharedStepItems+" AND test_case_steps.test_case_id = test_cases.id), 0)) AS score",
+				against, against, against).
+				Where(match+
+					" OR test_cases.id IN (SELECT test_case_id FROM test_case_steps WHERE test_case_steps.deleted_at IS NULL AND "+stepMatch+")"+
+					" OR test_cases.id IN (SELECT test_case_steps.test_case_id "+sharedStepItems+" AND "+sharedStepMatch+")", against, against, against)
+		case searchTypeTestSuite:
+			tx = mysqlMatchSource(db, searchType, query.ProjectID, "MATCH(test_suites.name) AGAINST (? IN BOOLEAN MODE)", against)
+		case searchTypeTestRun:
+			tx = mysqlMatchSource(db, searchType, query.ProjectID, "MATCH(test_runs.title) AGAINST (? IN BOOLEAN MODE)", against)
+		case searchTypeComment:
+			tx = mysqlMatchSource(db, searchType, query.ProjectID, "MATCH(comments.content) AGAINST (? IN BOOLEAN MODE)", against)
+		default:
+			continue
+		}
+
+		var rows []searchRow
+		if err := tx.Order("score DESC").Limit(query.Limit).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		if searchType == searchTypeTestCase {
+			if err := appendSearchSteps(db, rows); err != nil {
+				return nil, err
+			}
+		}
+		for _, row := range rows {
+			hits = append(hits, newSearchHit(searchType, row, terms))
+		}
+	}
+	return hits, nil
+}
+
+// mysqlMatchSource は match に一致したレコードを一致の度合いとともに読み込むクエリを返す
+func mysqlMatchSource(db *gorm.DB, searchType string, projectID *uint, match string, against string) *gorm.DB {
+	return searchSource(db, searchType, projectID, ", "+match+" AS score", against).Where(match, against)
+}
+
+// mysqlBooleanQuery は検索語をすべて含むことを条件にした BOOLEAN MODE の検索文字列を返す（演算子として扱われる記号は取り除く）
+func mysqlBooleanQuery(terms []string) string {
+	parts := []string{}
+	for _, term := range terms {
+		term = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`"+-<>()~*@`, r) {
+				return -1
+			}
+			return r
+		}, term)
+		if term != "" {
+			parts = append(parts, `+"`+term+`"`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,474 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errSharedStepNotFound はテスト手順が別のプロジェクトや存在しない共有ステップを参照している場合のエラー
+var errSharedStepNotFound = errors.New("shared step not found in project")
+
+type SharedStepHandler struct {
+	DB *gorm.DB
+}
+
+func NewSharedStepHandler(db *gorm.DB) *SharedStepHandler {
+	return &SharedStepHandler{DB: db}
+}
+
+type sharedStepRequest struct {
+	ProjectID   uint                `json:"project_id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Steps       []util.TestCaseStep `json:"steps"`
+}
+
+type sharedStepUsageCount struct {
+	SharedStepID uint
+	Count        int
+}
+
+func createSharedStepResponse(sharedStep model.SharedStep, usageCount int) util.SharedStep {
+	steps := []util.TestCaseStep{}
+	for _, item := range sharedStep.Steps {
+		steps = append(steps, util.TestCaseStep{Action: item.Action, ExpectedResult: item.ExpectedResult})
+	}
+	return util.SharedStep{
+		ID:          sharedStep.ID,
+		ProjectID:   sharedStep.ProjectID,
+		Title:       sharedStep.Title,
+		Description: sharedStep.Description,
+		Steps:       steps,
+		UsageCount:  usageCount,
+		CreatedBy:   util.User{ID: sharedStep.CreatedByID, Name: sharedStep.CreatedBy.Name},
+		UpdatedBy:   util.User{ID: sharedStep.UpdatedByID, Name: sharedStep.UpdatedBy.Name},
+	}
+}
+
+func (h *SharedStepHandler) GetSharedSteps(c *gin.Context) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	var sharedSteps []model.SharedStep
+	if err := h.DB.Preload("CreatedBy").
+		Preload("Steps", orderTestCaseSteps).
+		Preload("UpdatedBy").
+		Where("project_id = ?", project.ID).
+		Order("title, id").
+		Find(&sharedSteps).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+		return
+	}
+
+	ids := []uint{}
+	for _, sharedStep := range sharedSteps {
+		ids = append(ids, sharedStep.ID)
+	}
+	usageCounts, err := countSharedStepUsages(h.DB, ids)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to count shared step usages", err)
+		return
+	}
+
+	responses := []util.SharedStep{}
+	for _, sharedStep := range sharedSteps {
+		responses = append(responses, createSharedStepResponse(sharedStep, usageCounts[sharedStep.ID]))
+	}
+	c.JSON(http.StatusOK, util.SharedStepsResponseData{
+		ProjectID:   project.ID,
+		SharedSteps: responses,
+	})
+}
+
+func (h *SharedStepHandler) GetSharedStep(c *gin.Context) {
+	sharedStep, ok := h.findSharedStep(c)
+	if !ok {
+		return
+	}
+	h.respondSharedStep(c, http.StatusOK, sharedStep.ID)
+}
+
+func (h *SharedStepHandler) PostSharedStep(c *gin.Context) {
+	var req sharedStepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		handleError(c, http.StatusBadRequest, "Title is required", nil)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	sharedStep := model.SharedStep{
+		ProjectID:   req.ProjectID,
+		Title:       req.Title,
+		Description: req.Description,
+		CreatedByID: user.ID,
+		UpdatedByID: user.ID,
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Steps").Create(&sharedStep).Error; err != nil {
+			return err
+		}
+		return replaceSharedStepItems(tx, sharedStep.ID, req.Steps)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create shared step", err)
+		return
+	}
+
+	h.respondSharedStep(c, http.StatusCreated, sharedStep.ID)
+}
+
+// PutSharedStep は共有ステップの手順を置き換える。テストケースは参照しているだけなので、
+// 変更は参照しているすべてのテストケースに反映される（完了したテストランの確定データは変わらない）
+func (h *SharedStepHandler) PutSharedStep(c *gin.Context) {
+	var req sharedStepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		handleError(c, http.StatusBadRequest, "Title is required", nil)
+		return
+	}
+
+	sharedStep, ok := h.findSharedStep(c)
+	if !ok {
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.SharedStep{}).Where("id = ?", sharedStep.ID).Updates(map[string]interface{}{
+			"title":         req.Title,
+			"description":   req.Description,
+			"updated_by_id": user.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return replaceSharedStepItems(tx, sharedStep.ID, req.Steps)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update shared step", err)
+		return
+	}
+
+	h.respondSharedStep(c, http.StatusOK, sharedStep.ID)
+}
+
+// DeleteSharedStep は共有ステップを削除する。参照しているテストケース（ゴミ箱にあるものも含む）には
+// 削除前の手順を通常の手順として展開するため、テストケースの手順は変わらない
+func (h *SharedStepHandler) DeleteSharedStep(c *gin.Context) {
+	sharedStep, ok := h.findSharedStep(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var testCaseIDs []uint
+		if err := tx.Model(&model.TestCaseStep{}).
+			Distinct("test_case_id").
+			Where("shared_step_id = ?", sharedStep.ID).
+			Pluck("test_case_id", &testCaseIDs).Error; err != nil {
+			return err
+		}
+		if err := inlineSharedSteps(tx, testCaseIDs, []uint{sharedStep.ID}); err != nil {
+			return err
+		}
+		if err := tx.Where("shared_step_id = ?", sharedStep.ID).Delete(&model.SharedStepItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.SharedStep{}, sharedStep.ID).Error
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete shared step", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSharedStepUsages は共有ステップを参照しているテストケースを返す
+func (h *SharedStepHandler) GetSharedStepUsages(c *gin.Context) {
+	sharedStep, ok := h.findSharedStep(c)
+	if !ok {
+		return
+	}
+
+	var testCases []model.TestCase
+	if err := h.DB.Where("id IN (?)", h.DB.Model(&model.TestCaseStep{}).Select("test_case_id").Where("shared_step_id = ?", sharedStep.ID)).
+		Order("test_suite_id, order_index, id").
+		Find(&testCases).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+		return
+	}
+
+	usages := []util.SharedStepUsage{}
+	for _, testCase := range testCases {
+		usages = append(usages, util.SharedStepUsage{
+			ID:          testCase.ID,
+			ProjectID:   testCase.ProjectID,
+			TestSuiteID: testCase.TestSuiteID,
+			Title:       testCase.Title,
+		})
+	}
+	c.JSON(http.StatusOK, util.SharedStepUsagesResponseData{
+		SharedStepID: sharedStep.ID,
+		TestCases:    usages,
+	})
+}
+
+func (h *SharedStepHandler) findSharedStep(c *gin.Context) (model.SharedStep, bool) {
+	var sharedStep model.SharedStep
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return sharedStep, false
+	}
+	if err := h.DB.First(&sharedStep, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Shared step not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", err)
+		}
+		return sharedStep, false
+	}
+	return sharedStep, true
+}
+
+func (h *SharedStepHandler) respondSharedStep(c *gin.Context, code int, id uint) {
+	var sharedStep model.SharedStep
+	if err := h.DB.Preload("CreatedBy").Preload("Steps", orderTestCaseSteps).Preload("UpdatedBy").First(&sharedStep, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared step", err)
+		return
+	}
+	usageCounts, err := countSharedStepUsages(h.DB, []uint{id})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to count shared step usages", err)
+		return
+	}
+	c.JSON(code, createSharedStepResponse(sharedStep, usageCounts[id]))
+}
+
+// countSharedStepUsages は共有ステップごとに参照しているテストケース（ゴミ箱にあるものを除く）の数を返す
+func countSharedStepUsages(db *gorm.DB, ids []uint) (map[uint]int, error) {
+	usageCounts := map[uint]int{}
+	if len(ids) == 0 {
+		return usageCounts, nil
+	}
+	var counts []sharedStepUsageCount
+	if err := db.Model(&model.TestCaseStep{}).
+		Select("test_case_steps.shared_step_id, COUNT(DISTINCT test_case_steps.test_case_id) AS count").
+		Joins("JOIN test_cases ON test_cases.id = test_case_steps.test_case_id AND test_cases.deleted_at IS NULL").
+		Where("test_case_steps.shared_step_id IN ?", ids).
+		Group("test_case_steps.shared_step_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		usageCounts[count.SharedStepID] = count.Count
+	}
+	return usageCounts, nil
+}
+
+// replaceSharedStepItems は空の手順を除いて共有ステップの手順を置き換える（共有ステップの入れ子はできない）
+func replaceSharedStepItems(tx *gorm.DB, sharedStepID uint, steps []util.TestCaseStep) error {
+	if err := tx.Where("shared_step_id = ?", sharedStepID).Delete(&model.SharedStepItem{}).Error; err != nil {
+		return err
+	}
+	items := []model.SharedStepItem{}
+	for _, step := range steps {
+		if strings.TrimSpace(step.Action) == "" && strings.TrimSpace(step.ExpectedResult) == "" {
+			continue
+		}
+		items = append(items, model.SharedStepItem{
+			SharedStepID:   sharedStepID,
+			OrderIndex:     len(items),
+			Action:         step.Action,
+			ExpectedResult: step.ExpectedResult,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.Create(&items).Error
+}
+
+// validateSharedStepReferences はテスト手順が参照する共有ステップが同じプロジェクトにあることを確認する
+func validateSharedStepReferences(db *gorm.DB, projectID uint, steps []util.TestCaseStep) error {
+	ids := []uint{}
+	for _, step := range steps {
+		if step.SharedStepID != nil {
+			ids = append(ids, *step.SharedStepID)
+		}
+	}
+	if ids = uniqueUints(ids); len(ids) == 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&model.SharedStep{}).Where("project_id = ? AND id IN ?", projectID, ids).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return errSharedStepNotFound
+	}
+	return nil
+}
+
+// loadSharedSteps は ID ごとに共有ステップを手順と合わせて取得する
+func loadSharedSteps(db *gorm.DB, ids []uint) (map[uint]model.SharedStep, error) {
+	sharedSteps := map[uint]model.SharedStep{}
+	if ids = uniqueUints(ids); len(ids) == 0 {
+		return sharedSteps, nil
+	}
+	var records []model.SharedStep
+	if err := db.Preload("Steps", orderTestCaseSteps).Where("id IN ?", ids).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		sharedSteps[record.ID] = record
+	}
+	return sharedSteps, nil
+}
+
+// resolveSharedSteps はテスト手順に含まれる共有ステップの参照を、その時点の共有ステップの手順に置き換える。
+// 置き換えた手順には参照元の SharedStepID を残すため、保存し直しても参照に戻る
+func resolveSharedSteps(db *gorm.DB, stepLists ...*[]model.TestCaseStep) error {
+	ids := []uint{}
+	for _, steps := range stepLists {
+		for _, step := range *steps {
+			if step.SharedStepID != nil {
+				ids = append(ids, *step.SharedStepID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sharedSteps, err := loadSharedSteps(db, ids)
+	if err != nil {
+		return err
+	}
+	for _, steps := range stepLists {
+		*steps = expandSharedSteps(*steps, sharedSteps, nil)
+	}
+	return nil
+}
+
+// expandSharedSteps は共有ステップの参照を展開した手順を返す。targets が nil の場合は表示用にすべての参照を展開し、
+// 展開した手順に参照元を残す。targets を指定した場合はその参照だけを参照元を持たない通常の手順にする
+func expandSharedSteps(steps []model.TestCaseStep, sharedSteps map[uint]model.SharedStep, targets map[uint]bool) []model.TestCaseStep {
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].OrderIndex < steps[j].OrderIndex
+	})
+	expanded := []model.TestCaseStep{}
+	for _, step := range steps {
+		if step.SharedStepID == nil || (targets != nil && !targets[*step.SharedStepID]) {
+			step.OrderIndex = len(expanded)
+			expanded = append(expanded, step)
+			continue
+		}
+		for _, item := range sharedSteps[*step.SharedStepID].Steps {
+			expandedStep := model.TestCaseStep{
+				TestCaseID:     step.TestCaseID,
+				OrderIndex:     len(expanded),
+				Action:         item.Action,
+				ExpectedResult: item.ExpectedResult,
+			}
+			if targets == nil {
+				expandedStep.SharedStepID = step.SharedStepID
+			}
+			expanded = append(expanded, expandedStep)
+		}
+	}
+	return expanded
+}
+
+// inlineSharedSteps はテストケースの共有ステップの参照を通常の手順に置き換えて保存する。
+// sharedStepIDs を指定した場合はその共有ステップの参照だけを置き換える
+func inlineSharedSteps(tx *gorm.DB, testCaseIDs []uint, sharedStepIDs []uint) error {
+	if testCaseIDs = uniqueUints(testCaseIDs); len(testCaseIDs) == 0 {
+		return nil
+	}
+	var steps []model.TestCaseStep
+	if err := tx.Where("test_case_id IN ?", testCaseIDs).Order("test_case_id, order_index, id").Find(&steps).Error; err != nil {
+		return err
+	}
+
+	var targets map[uint]bool
+	if sharedStepIDs != nil {
+		targets = map[uint]bool{}
+		for _, id := range sharedStepIDs {
+			targets[id] = true
+		}
+	}
+	stepsByTestCase := map[uint][]model.TestCaseStep{}
+	ids := []uint{}
+	for _, step := range steps {
+		stepsByTestCase[step.TestCaseID] = append(stepsByTestCase[step.TestCaseID], step)
+		if step.SharedStepID != nil && (targets == nil || targets[*step.SharedStepID]) {
+			ids = append(ids, *step.SharedStepID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sharedSteps, err := loadSharedSteps(tx, ids)
+	if err != nil {
+		return err
+	}
+	if targets == nil {
+		targets = map[uint]bool{}
+		for _, id := range ids {
+			targets[id] = true
+		}
+	}
+
+	inlined := []model.TestCaseStep{}
+	for _, testCaseID := range testCaseIDs {
+		for _, step := range expandSharedSteps(stepsByTestCase[testCaseID], sharedSteps, targets) {
+			inlined = append(inlined, model.TestCaseStep{
+				TestCaseID:     step.TestCaseID,
+				OrderIndex:     step.OrderIndex,
+				Action:         step.Action,
+				ExpectedResult: step.ExpectedResult,
+				SharedStepID:   step.SharedStepID,
+			})
+		}
+	}
+	if err := tx.Where("test_case_id IN ?", testCaseIDs).Delete(&model.TestCaseStep{}).Error; err != nil {
+		return err
+	}
+	if len(inlined) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(&inlined, 100).Error
+}
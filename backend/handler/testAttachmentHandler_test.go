@@ -0,0 +1,391 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/util"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// memoryStorage はメモリに保存する添付ファイルの保存先
+type memoryStorage struct {
+	files map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{files: map[string][]byte{}}
+}
+
+func (s *memoryStorage) Save(key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.files[key] = data
+	return nil
+}
+
+func (s *memoryStorage) Open(key string) (io.ReadCloser, error) {
+	data, ok := s.files[key]
+	if !ok {
+		return nil, util.ErrFileNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+func setupMockAttachmentHandler(storage util.FileStorage) (*handler.AttachmentHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewAttachmentHandler(gormDB, storage), mock
+}
+
+// newAttachmentRequest は file（と checksum）を multipart で送るリクエストを作る
+func newAttachmentRequest(url string, fileName string, content []byte, checksum string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", fileName)
+	part.Write(content)
+	if checksum != "" {
+		writer.WriteField("checksum", checksum)
+	}
+	writer.Close()
+	req := httptest.NewRequest("POST", url, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPostTestRunCaseAttachment(t *testing.T) {
+	dir := t.TempDir()
+	h, mock := setupMockAttachmentHandler(&util.LocalStorage{Dir: dir})
+	gin.SetMode(gin.TestMode)
+
+	content := []byte("ERROR payment gateway timed out\n")
+	checksum := sha256Hex(content)
+	mock.ExpectQuery("^SELECT `id` FROM `test_run_cases` WHERE `test_run_cases`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `attachments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "test_run_case", 5, "error.txt", "text/plain", len(content), checksum, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/runs/cases/:id/attachments", h.PostTestRunCaseAttachment)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newAttachmentRequest("/protected/runs/cases/5/attachments", "error.txt", content, checksum))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.Attachment
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(4), response.ID)
+	assert.Equal(t, checksum, response.Checksum)
+	assert.Equal(t, "User", response.CreatedBy.Name)
+
+	// 添付先ごとのディレクトリに保存される
+	files, _ := filepath.Glob(filepath.Join(dir, "attachments", "test_run_case", "5", "*"))
+	if assert.Len(t, files, 1) {
+		saved, _ := os.ReadFile(files[0])
+		assert.Equal(t, content, saved)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostAttachmentRejectsChecksumMismatch(t *testing.T) {
+	storage := newMemoryStorage()
+	h, mock := setupMockAttachmentHandler(storage)
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/cases/:id/attachments", h.PostTestCaseAttachment)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newAttachmentRequest("/protected/cases/3/attachments", "note.txt", []byte("expected"), sha256Hex([]byte("other"))))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, storage.files)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostAttachmentRejectsLargeFile(t *testing.T) {
+	h, mock := setupMockAttachmentHandler(newMemoryStorage())
+	h.MaxSize = 10
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	r := gin.Default()
+	r.POST("/protected/cases/:id/attachments", h.PostTestCaseAttachment)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newAttachmentRequest("/protected/cases/3/attachments", "note.txt", []byte("more than ten bytes"), ""))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostAttachmentRejectsFileType(t *testing.T) {
+	h, mock := setupMockAttachmentHandler(newMemoryStorage())
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT `id` FROM `comments` WHERE `comments`.`id` = \\?").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+	r := gin.Default()
+	r.POST("/protected/runs/cases/comments/:id/attachments", h.PostCommentAttachment)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newAttachmentRequest("/protected/runs/cases/comments/9/attachments", "tool.bin", []byte{0x4d, 0x5a, 0x90, 0x00, 0x03}, ""))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostAttachmentRejectsMismatchedContent(t *testing.T) {
+	storage := newMemoryStorage()
+	h, mock := setupMockAttachmentHandler(storage)
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	r := gin.Default()
+	r.POST("/protected/cases/:id/attachments", h.PostTestCaseAttachment)
+	w := httptest.NewRecorder()
+	// 拡張子は画像だが内容は HTML
+	r.ServeHTTP(w, newAttachmentRequest("/protected/cases/3/attachments", "screenshot.png", []byte("<html><script>alert(1)</script></html>"), ""))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Empty(t, storage.files)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTestCaseAttachments(t *testing.T) {
+	h, mock := setupMockAttachmentHandler(newMemoryStorage())
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE \\(parent_type = \\? AND parent_id = \\?\\)").
+		WithArgs("test_case", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_type", "parent_id", "file_name", "content_type", "size", "checksum", "created_by_id"}).
+			AddRow(4, "test_case", 3, "login.png", "image/png", 2048, "abc", 1))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+
+	r := gin.Default()
+	r.GET("/protected/cases/:id/attachments", h.GetTestCaseAttachments)
+	req := httptest.NewRequest("GET", "/protected/cases/3/attachments", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.AttachmentsResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Attachments, 1) {
+		assert.Equal(t, "login.png", response.Attachments[0].FileName)
+		assert.Equal(t, "User", response.Attachments[0].CreatedBy.Name)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.files["attachments/test_case/3/abc"] = []byte("screenshot")
+	h, mock := setupMockAttachmentHandler(storage)
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE `attachments`.`id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_type", "parent_id", "file_name", "content_type", "size", "checksum", "storage_key"}).
+			AddRow(4, "test_case", 3, "login.png", "image/png", 10, "abc123", "attachments/test_case/3/abc"))
+
+	r := gin.Default()
+	r.GET("/protected/attachments/:id/download", h.DownloadAttachment)
+	req := httptest.NewRequest("GET", "/protected/attachments/4/download", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "screenshot", w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=login.png`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "abc123", w.Header().Get("X-Checksum-Sha256"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAttachment(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.files["attachments/test_case/3/abc"] = []byte("screenshot")
+	h, mock := setupMockAttachmentHandler(storage)
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE `attachments`.`id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_type", "parent_id", "storage_key"}).AddRow(4, "test_case", 3, "attachments/test_case/3/abc"))
+	// レコードを先に削除してからファイルを削除する
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `attachments` WHERE `attachments`.`id` = \\?").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.DELETE("/protected/attachments/:id", h.DeleteAttachment)
+	req := httptest.NewRequest("DELETE", "/protected/attachments/4", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, storage.files)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// s3StandIn は S3 互換の保存先の代わりに、署名付きのリクエストを受けてメモリにオブジェクトを保存する
+type s3StandIn struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	// アップロードは内容を読み込まずに長さを付けて送る
+	if r.Method == http.MethodPut {
+		if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" || r.ContentLength != int64(len(body)) || len(r.TransferEncoding) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		object, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestAttachmentsWithS3Storage(t *testing.T) {
+	standIn := &s3StandIn{objects: map[string][]byte{}}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+	storage := &util.S3Storage{Endpoint: server.URL, Region: "ap-northeast-1", Bucket: "evidence", AccessKeyID: "access", SecretAccessKey: "secret", PathStyle: true}
+	h, mock := setupMockAttachmentHandler(storage)
+	gin.SetMode(gin.TestMode)
+
+	content := []byte("\x89PNG\r\n\x1a\nscreenshot")
+	mock.ExpectQuery("^SELECT `id` FROM `comments` WHERE `comments`.`id` = \\?").
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `attachments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "comment", 9, "login.png", "image/png", len(content), sha256Hex(content), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/runs/cases/comments/:id/attachments", h.PostCommentAttachment)
+	r.GET("/protected/attachments/:id/download", h.DownloadAttachment)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newAttachmentRequest("/protected/runs/cases/comments/9/attachments", "login.png", content, ""))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// バケット名をパスに含めて保存される
+	var objectPath string
+	for path := range standIn.objects {
+		objectPath = path
+	}
+	assert.True(t, strings.HasPrefix(objectPath, "/evidence/attachments/comment/9/"))
+
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE `attachments`.`id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "file_name", "content_type", "size", "storage_key"}).
+			AddRow(4, "login.png", "image/png", len(content), strings.TrimPrefix(objectPath, "/evidence/")))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/protected/attachments/4/download", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, content, w.Body.Bytes())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
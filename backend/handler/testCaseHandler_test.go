@@ -5,11 +5,14 @@ import (
 	"backend/model"
 	"backend/util"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
@@ -56,15 +59,18 @@ func TestGetTestCases(t *testing.T) {
 	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
 		WithArgs(projectID).
 		WillReturnRows(testSuiteRows)
-	// テストケースのクエリを期待
+	// テストケースのクエリを期待（階層に関わらずプロジェクト単位で一度だけ取得する）
 	testCaseRows := sqlmock.NewRows([]string{"id", "test_suite_id", "title", "content"}).
 		AddRow(1, 1, "Test Case 1", "Test Content")
-	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
-		WithArgs(1).
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE test_cases.project_id = \\? AND test_cases.test_suite_id IS NOT NULL").
+		WithArgs(projectID).
 		WillReturnRows(testCaseRows)
-	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE `test_suites`.`parent_id` = ?").
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
 		WithArgs(1).
-		WillReturnRows(testSuiteRows)
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}).AddRow(1, 1, "smoke"))
 
 	// Set up HTTP request
 	r := gin.Default()
@@ -87,6 +93,199 @@ func TestGetTestCases(t *testing.T) {
 	assert.Equal(t, uint(projectID), responseData.ProjectID)
 	assert.Len(t, responseData.TestSuites, 1)
 	assert.Equal(t, "TestSuites 1", responseData.TestSuites[0].Name)
+	assert.Equal(t, 1, responseData.TestSuites[0].CaseCount)
+	assert.Equal(t, []string{"smoke"}, responseData.TestSuites[0].TestCases[0].Tags)
+}
+
+func TestGetTestCasesWithFilter(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+
+	rows := sqlmock.NewRows([]string{"id", "code"}).
+		AddRow(projectID, projectCode)
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(rows)
+
+	// 該当ケースを持たないスイート 2 は除外される
+	testSuiteRows := sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).
+		AddRow(1, projectID, "TestSuites 1", nil).
+		AddRow(2, projectID, "TestSuites 2", nil)
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(projectID).
+		WillReturnRows(testSuiteRows)
+	testCaseRows := sqlmock.NewRows([]string{"id", "test_suite_id", "title", "content"}).
+		AddRow(1, 1, "Login", "Test Content")
+	// 検索語の _ はワイルドカードとして扱わない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE test_cases.project_id = \\? AND .*test_cases.title LIKE .*SELECT test_case_id FROM test_case_tags").
+		WithArgs(projectID, "%Log\\_in%", "%Log\\_in%", "smoke", 1).
+		WillReturnRows(testCaseRows)
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}).AddRow(1, 1, "smoke"))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases", h.GetTestCases)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/cases?q=Log_in&tags=smoke", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var responseData util.TestCasesResponseData
+	err := json.Unmarshal(w.Body.Bytes(), &responseData)
+	assert.NoError(t, err)
+	assert.Len(t, responseData.TestSuites, 1)
+	assert.Equal(t, uint(1), responseData.TestSuites[0].ID)
+	assert.Equal(t, 1, responseData.TestSuites[0].CaseCount)
+	assert.Len(t, responseData.OnlyTestSuites, 2)
+}
+
+func TestGetTestCasesDeepHierarchy(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+	creatorID := 9
+
+	rows := sqlmock.NewRows([]string{"id", "code"}).
+		AddRow(projectID, projectCode)
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(rows)
+
+	// 4 階層のスイート（order_index 順に返される）
+	testSuiteRows := sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id", "order_index"}).
+		AddRow(1, projectID, "Level 1", nil, 0).
+		AddRow(4, projectID, "Level 4", 3, 0).
+		AddRow(3, projectID, "Level 3", 2, 0).
+		AddRow(2, projectID, "Level 2", 1, 0)
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(projectID).
+		WillReturnRows(testSuiteRows)
+
+	testCaseRows := sqlmock.NewRows([]string{"id", "test_suite_id", "title", "created_by_id", "order_index"}).
+		AddRow(11, 4, "Deep B", creatorID, 0).
+		AddRow(10, 4, "Deep A", creatorID, 1)
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(projectID).
+		WillReturnRows(testCaseRows)
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WithArgs(creatorID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(creatorID, "Creator"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(11, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(11, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases", h.GetTestCases)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/cases", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var responseData util.TestCasesResponseData
+	err := json.Unmarshal(w.Body.Bytes(), &responseData)
+	assert.NoError(t, err)
+	if assert.Len(t, responseData.TestSuites, 1) {
+		level4 := responseData.TestSuites[0].TestSuites[0].TestSuites[0].TestSuites[0]
+		assert.Equal(t, "Level 4", level4.Name)
+		assert.Equal(t, 2, responseData.TestSuites[0].CaseCount)
+		if assert.Len(t, level4.TestCases, 2) {
+			assert.Equal(t, "Deep B", level4.TestCases[0].Title)
+			assert.Equal(t, "Deep A", level4.TestCases[1].Title)
+			assert.Equal(t, "Creator", level4.TestCases[1].CreatedBy.Name)
+		}
+	}
+}
+
+func TestGetTestCasesInvalidFilter(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases", h.GetTestCases)
+	req, _ := http.NewRequest("GET", "/protected/PROJECT/cases?sort=unknown", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTestCaseChildren(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+
+	rows := sqlmock.NewRows([]string{"id", "code"}).
+		AddRow(projectID, projectCode)
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(rows)
+
+	testSuiteRows := sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).
+		AddRow(1, projectID, "Parent", nil).
+		AddRow(2, projectID, "Child", 1).
+		AddRow(3, projectID, "GrandChild", 2)
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(projectID).
+		WillReturnRows(testSuiteRows)
+
+	// スイートごとの件数集計用
+	mock.ExpectQuery("^SELECT test_cases.id, test_cases.test_suite_id FROM `test_cases`").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id"}).AddRow(1, 1).AddRow(2, 1).AddRow(3, 3))
+
+	// limit+1 件取得して次ページの有無を判定する
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE test_cases.project_id = \\? AND test_cases.test_suite_id = \\?").
+		WithArgs(projectID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "title"}).AddRow(1, 1, "Case 1").AddRow(2, 1, "Case 2"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases/children", h.GetTestCaseChildren)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/cases/children?suite_id=1&limit=1", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var responseData util.TestSuiteChildrenResponseData
+	err := json.Unmarshal(w.Body.Bytes(), &responseData)
+	assert.NoError(t, err)
+	assert.Len(t, responseData.TestSuites, 1)
+	assert.Equal(t, uint(2), responseData.TestSuites[0].ID)
+	assert.Equal(t, 1, responseData.TestSuites[0].CaseCount)
+	assert.Len(t, responseData.TestCases, 1)
+	if assert.NotNil(t, responseData.NextOffset) {
+		assert.Equal(t, 1, *responseData.NextOffset)
+	}
 }
 
 func TestPostTestCase(t *testing.T) {
@@ -94,8 +293,9 @@ func TestPostTestCase(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mock.ExpectBegin()
+	// 新規作成したテストケースは下書きになる
 	mock.ExpectExec("^INSERT INTO `test_cases`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "Draft", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -115,6 +315,121 @@ func TestPostTestCase(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+// TestPostTestCaseWithParameters - パラメータ表を保存するテスト
+func TestPostTestCaseWithParameters(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, 0, nil, "Checkout succeeds", "", 0, "Draft", 0, 0, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE `test_case_parameters` SET `deleted_at`=\\? WHERE test_case_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// 列名の前後の空白は取り除く
+	mock.ExpectExec("^INSERT INTO `test_case_parameters`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, `["locale","payment"]`, `[["ja","card"],["en","paypal"]]`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"title": "Checkout succeeds", "parameters": {"names": [" locale ", "payment"], "rows": [["ja", "card"], ["en", "paypal"]]}}`
+	r := gin.Default()
+	r.POST("protected/cases", h.PostTestCase)
+	req, _ := http.NewRequest("POST", "/protected/cases", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.TestCase
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, &util.TestCaseParameters{
+		Names: []string{"locale", "payment"},
+		Rows:  [][]string{{"ja", "card"}, {"en", "paypal"}},
+	}, response.Parameters)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostTestCaseRejectsInvalidParameters - 行の値の数が列と合わないパラメータ表は保存しないテスト
+func TestPostTestCaseRejectsInvalidParameters(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	body := `{"title": "Checkout succeeds", "parameters": {"names": ["locale", "payment"], "rows": [["ja"]]}}`
+	r := gin.Default()
+	r.POST("protected/cases", h.PostTestCase)
+	req, _ := http.NewRequest("POST", "/protected/cases", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostTestCaseWithTemplate - テンプレートで内容・手順・カスタムフィールドの既定値を補うテスト
+func TestPostTestCaseWithTemplate(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_templates` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "content", "steps", "custom_fields"}).
+			AddRow(5, 1, "UI", "## Preconditions", `[{"action":"Open the page","expected_result":"The page is shown"}]`,
+				`[{"name":"Browser","default":"Chrome","required":true,"options":["Chrome","Firefox"]}]`))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, 1, nil, "Top page", "## Preconditions", 0, "Draft", 0, 0, 5).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("^UPDATE `test_case_custom_fields` SET `deleted_at`=\\? WHERE test_case_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO `test_case_custom_fields`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, "Browser", "Chrome").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE `test_case_steps` SET `deleted_at`=\\? WHERE test_case_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 0, "Open the page", "The page is shown", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"project_id": 1, "title": "Top page", "template_id": 5}`
+	r := gin.Default()
+	r.POST("protected/cases", h.PostTestCase)
+	req, _ := http.NewRequest("POST", "/protected/cases", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.TestCase
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(5), *response.TemplateID)
+	assert.Equal(t, "## Preconditions", response.Content)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostTestCaseWithTemplateRejectsInvalidCustomField - テンプレートの選択肢に無い値は保存しないテスト
+func TestPostTestCaseWithTemplateRejectsInvalidCustomField(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_templates` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "custom_fields"}).
+			AddRow(5, 1, "UI", `[{"name":"Browser","required":true,"options":["Chrome","Firefox"]}]`))
+
+	body := `{"project_id": 1, "title": "Top page", "template_id": 5, "custom_fields": {"Browser": "Safari"}}`
+	r := gin.Default()
+	r.POST("protected/cases", h.PostTestCase)
+	req, _ := http.NewRequest("POST", "/protected/cases", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetTestCase(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
 	gin.SetMode(gin.TestMode)
@@ -127,6 +442,20 @@ func TestGetTestCase(t *testing.T) {
 	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
 		WithArgs(id).
 		WillReturnRows(rows)
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE .* ORDER BY order_index, id").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(2, id, 1, "Submit", "Logged in").
+			AddRow(1, id, 0, "Open login page", ""))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}))
 
 	// Set up HTTP request
 	r := gin.Default()
@@ -145,6 +474,62 @@ func TestGetTestCase(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
 	assert.Equal(t, "Test Case 1", resp.Title)
+	var stepResp util.TestCase
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stepResp))
+	assert.Equal(t, []util.TestCaseStep{
+		{Action: "Open login page"},
+		{Action: "Submit", ExpectedResult: "Logged in"},
+	}, stepResp.Steps)
+}
+
+// TestGetTestCaseExpandsSharedSteps - 共有ステップの参照を展開して返すテスト
+func TestGetTestCaseExpandsSharedSteps(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	id := 1
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(id, "Logout succeeds"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE .* ORDER BY order_index, id").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result", "shared_step_id"}).
+			AddRow(1, id, 0, "", "", 3).
+			AddRow(2, id, 1, "Log out", "Login page is shown", nil))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE id IN \\(\\?\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Login"))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_step_items` WHERE `shared_step_items`.`shared_step_id` = \\? .*ORDER BY order_index, id").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shared_step_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 3, 0, "Open login page", "Form is shown").
+			AddRow(2, 3, 1, "Submit", "Top page is shown"))
+
+	r := gin.Default()
+	r.GET("/protected/cases/:id", h.GetTestCase)
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/protected/cases/%d", id), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp util.TestCase
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	sharedStepID := uint(3)
+	assert.Equal(t, []util.TestCaseStep{
+		{Action: "Open login page", ExpectedResult: "Form is shown", SharedStepID: &sharedStepID},
+		{Action: "Submit", ExpectedResult: "Top page is shown", SharedStepID: &sharedStepID},
+		{Action: "Log out", ExpectedResult: "Login page is shown"},
+	}, resp.Steps)
 }
 
 // TestPutTestCase - テストケースを更新するテスト
@@ -160,16 +545,12 @@ func TestPutTestCase(t *testing.T) {
 	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
 		WithArgs(id).
 		WillReturnRows(rows)
-	// トランザクションの開始を期待
+	// 更新は一つのトランザクションで行う
 	mock.ExpectBegin()
 	// UPDATE クエリを期待
 	mock.ExpectExec("^UPDATE `test_cases`").
 		WithArgs(sqlmock.AnyArg(), "Updated Title", "Updated Content", id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	// トランザクションのコミットを期待
-	mock.ExpectCommit()
-
-	mock.ExpectBegin()
 	// UPDATE クエリを期待
 	mock.ExpectExec("^UPDATE `test_cases`").
 		WithArgs(sqlmock.AnyArg(), nil, id).
@@ -195,6 +576,30 @@ func TestPutTestCase(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestPutTestCaseRejectsForeignSharedStep(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(1, 2, "Original Title"))
+	// 別プロジェクトの共有ステップは見つからないため、何も更新しない
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `shared_steps` WHERE \\(project_id = \\? AND id IN \\(\\?\\)\\)").
+		WithArgs(2, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	r := gin.Default()
+	r.PUT("/protected/cases/:id", h.PutTestCase)
+	body := `{"title": "Updated Title", "steps": [{"shared_step_id": 9}]}`
+	req, _ := http.NewRequest("PUT", "/protected/cases/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 // TestDeleteTestCase - テストケースを削除するテスト
 func TestDeleteTestCase(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
@@ -202,15 +607,13 @@ func TestDeleteTestCase(t *testing.T) {
 
 	id := 1
 
-	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_cases`").
-		WithArgs(sqlmock.AnyArg(), id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
-
+	deletedAt := &sameTime{}
 	mock.ExpectBegin()
 	mock.ExpectExec("^UPDATE `test_run_cases`").
-		WithArgs(sqlmock.AnyArg(), id).
+		WithArgs(deletedAt, id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE `test_cases`").
+		WithArgs(deletedAt, id).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -291,28 +694,44 @@ func TestPutTestSuite(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+// expectTestSuiteSubtree はスイート 1 > 2 > 3 の階層と、配下のテストケース 10, 11 を返すクエリを登録する
+func expectTestSuiteSubtree(mock sqlmock.Sqlmock, inTransaction bool) {
+	projectID := 5
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE `test_suites`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).AddRow(1, projectID, "Parent", nil))
+	// 削除する場合は子孫スイートとテストケースをトランザクションの中で読み込む
+	if inTransaction {
+		mock.ExpectBegin()
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).
+			AddRow(1, projectID, "Parent", nil).
+			AddRow(2, projectID, "Child", 1).
+			AddRow(3, projectID, "GrandChild", 2).
+			AddRow(4, projectID, "Other", nil))
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE test_suite_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+}
+
 func TestDeleteTestSuites(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
 	gin.SetMode(gin.TestMode)
 
 	id := 1
-
-	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_suites`").
-		WithArgs(sqlmock.AnyArg(), id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
-
-	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_cases`").
-		WithArgs(sqlmock.AnyArg(), id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
-
-	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_run_cases`").
-		WithArgs(sqlmock.AnyArg(), id).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// 子孫スイートを含めて一つのトランザクションで削除する
+	expectTestSuiteSubtree(mock, true)
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `deleted_at`=\\? WHERE test_case_id IN").
+		WithArgs(sqlmock.AnyArg(), 10, 11).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\? WHERE id IN").
+		WithArgs(sqlmock.AnyArg(), 10, 11).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_suites` SET `deleted_at`=\\? WHERE id IN").
+		WithArgs(sqlmock.AnyArg(), 1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 3))
 	mock.ExpectCommit()
 
 	r := gin.Default()
@@ -326,31 +745,122 @@ func TestDeleteTestSuites(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestPutTestCasesBulk(t *testing.T) {
+func TestDeleteTestSuitesKeepResults(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
 	gin.SetMode(gin.TestMode)
 
-	projectID := 1
-	projectCode := "PROJECT"
-	rows := sqlmock.NewRows([]string{"id", "code"}).
-		AddRow(projectID, projectCode)
-	mock.ExpectQuery("^SELECT \\* FROM `projects`").
-		WithArgs(projectCode).
+	expectTestSuiteSubtree(mock, true)
+
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\? WHERE id IN").
+		WithArgs(sqlmock.AnyArg(), 10, 11).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_suites` SET `deleted_at`=\\? WHERE id IN").
+		WithArgs(sqlmock.AnyArg(), 1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.DELETE("/protected/suites/:id", h.DeleteTestSuite)
+	req, _ := http.NewRequest("DELETE", "/protected/suites/1?keep_results=true", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTestSuitesDryRun(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectTestSuiteSubtree(mock, false)
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_run_cases` WHERE test_case_id IN").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	r := gin.Default()
+	r.DELETE("/protected/suites/:id", h.DeleteTestSuite)
+	req, _ := http.NewRequest("DELETE", "/protected/suites/1?dry_run=true", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var summary util.TestSuiteDeletionSummary
+	err := json.Unmarshal(w.Body.Bytes(), &summary)
+	assert.NoError(t, err)
+	assert.Equal(t, util.TestSuiteDeletionSummary{DryRun: true, TestSuites: 3, TestCases: 2, TestRunCases: 3}, summary)
+}
+
+func TestDeleteTestSuitesRejectsInvalidFlags(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.DELETE("/protected/suites/:id", h.DeleteTestSuite)
+	// 読めない値を false として扱うと、確認のつもりで実際に削除してしまう
+	for _, query := range []string{"dry_run=yes", "keep_results=yes"} {
+		req, _ := http.NewRequest("DELETE", "/protected/suites/1?"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutTestCasesBulk(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+	rows := sqlmock.NewRows([]string{"id", "code"}).
+		AddRow(projectID, projectCode)
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
 		WillReturnRows(rows)
 
-	// モックデータの設定
-	testCaseRequestRows := []handler.TestCasePutRequestRow{}
-	testCaseRequestRows = append(testCaseRequestRows, handler.TestCasePutRequestRow{TestCaseID: uint(1), OrderIndex: 0})
+	// ケース20をスイート2からスイート1の2番目へ移動する
 	requestBody := handler.TestCasePutRequest{
-		TestSuiteID:         uint(1),
-		TestCaseRequestRows: testCaseRequestRows,
+		TestSuiteID: uint(1),
+		TestCaseRequestRows: []handler.TestCasePutRequestRow{
+			{TestCaseID: uint(10), OrderIndex: 0},
+			{TestCaseID: uint(20), OrderIndex: 1},
+			{TestCaseID: uint(11), OrderIndex: 2},
+		},
 	}
 
-	// TestRunCasesのINSERTクエリを模擬
+	// 並び順は更新と同じトランザクションで読み込む
 	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_cases`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), projectID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_suites` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(1, projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE \\(project_id = \\? AND id IN \\(\\?,\\?,\\?\\)\\)").
+		WithArgs(projectID, 10, 20, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "order_index"}).
+			AddRow(10, projectID, 1, 0).
+			AddRow(11, projectID, 1, 1).
+			AddRow(20, projectID, 2, 0))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE test_suite_id IN \\(\\?,\\?\\)").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "order_index"}).
+			AddRow(10, projectID, 1, 0).
+			AddRow(11, projectID, 1, 1).
+			AddRow(20, projectID, 2, 0).
+			AddRow(21, projectID, 2, 1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `order_index`=\\?,`test_suite_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(1, 1, sqlmock.AnyArg(), 20).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `order_index`=\\?,`test_suite_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(2, 1, sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 移動元のスイートは並び順が詰められる
+	mock.ExpectExec("^UPDATE `test_cases` SET `order_index`=\\?,`test_suite_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(0, 2, sqlmock.AnyArg(), 21).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	// HTTPリクエストの設定
@@ -371,31 +881,78 @@ func TestPutTestCasesBulk(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestPutTestSuitesBulk(t *testing.T) {
+func TestPutTestCasesBulkRejectsForeignTestCase(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
 	gin.SetMode(gin.TestMode)
 
 	projectID := 1
 	projectCode := "PROJECT"
-	rows := sqlmock.NewRows([]string{"id", "code"}).
-		AddRow(projectID, projectCode)
 	mock.ExpectQuery("^SELECT \\* FROM `projects`").
 		WithArgs(projectCode).
-		WillReturnRows(rows)
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_suites`").
+		WithArgs(1, projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// 別プロジェクトのケースは見つからない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE \\(project_id = \\? AND id IN").
+		WithArgs(projectID, 99).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
 
-	// モックデータの設定
-	testCaseRequestRows := []handler.TestSuiteRequestRow{}
-	testCaseRequestRows = append(testCaseRequestRows, handler.TestSuiteRequestRow{TestSuiteID: uint(1), OrderIndex: 0})
-	requestBody := handler.TestSuiteRequest{
-		ParentID:            nil,
-		TestCaseRequestRows: testCaseRequestRows,
+	requestBody := handler.TestCasePutRequest{
+		TestSuiteID:         uint(1),
+		TestCaseRequestRows: []handler.TestCasePutRequestRow{{TestCaseID: uint(99), OrderIndex: 0}},
 	}
+	r := gin.Default()
+	body, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest("PUT", "/protected/"+projectCode+"/cases/bulk", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.PUT("/protected/:project_code/cases/bulk", h.PutTestCaseBulk)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	// TestRunCasesのINSERTクエリを模擬
+func expectProjectTestSuitesForReorder(mock sqlmock.Sqlmock, projectID int, projectCode string) {
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	// 階層は更新と同じトランザクションで読み込む
 	mock.ExpectBegin()
-	mock.ExpectExec("^UPDATE `test_suites`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), projectID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// 1 ─┬ 3
+	//    └ 4
+	// 2
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "parent_id", "order_index"}).
+			AddRow(1, projectID, nil, 0).
+			AddRow(2, projectID, nil, 1).
+			AddRow(3, projectID, 1, 0).
+			AddRow(4, projectID, 1, 5))
+}
+
+func TestPutTestSuitesBulk(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+	expectProjectTestSuitesForReorder(mock, projectID, projectCode)
+
+	// スイート2をスイート1の先頭へ移動する
+	parentID := uint(1)
+	requestBody := handler.TestSuiteRequest{
+		ParentID:            &parentID,
+		TestCaseRequestRows: []handler.TestSuiteRequestRow{{TestSuiteID: uint(2), OrderIndex: 0}},
+	}
+
+	for _, update := range [][]int{{0, 2}, {1, 3}, {2, 4}} {
+		mock.ExpectExec("^UPDATE `test_suites` SET `order_index`=\\?,`parent_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+			WithArgs(update[0], 1, sqlmock.AnyArg(), update[1]).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
 	mock.ExpectCommit()
 
 	// HTTPリクエストの設定
@@ -416,56 +973,1221 @@ func TestPutTestSuitesBulk(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestPutTestSuitesBulkRejectsInvalidParent(t *testing.T) {
+	tests := []struct {
+		name     string
+		parentID uint
+		suiteID  uint
+	}{
+		{name: "self", parentID: 1, suiteID: 1},
+		{name: "descendant", parentID: 3, suiteID: 1},
+		{name: "other project", parentID: 99, suiteID: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := setupMockTestCaseHandler()
+			gin.SetMode(gin.TestMode)
+
+			projectCode := "PROJECT"
+			expectProjectTestSuitesForReorder(mock, 1, projectCode)
+			mock.ExpectRollback()
+
+			parentID := tt.parentID
+			requestBody := handler.TestSuiteRequest{
+				ParentID:            &parentID,
+				TestCaseRequestRows: []handler.TestSuiteRequestRow{{TestSuiteID: tt.suiteID, OrderIndex: 0}},
+			}
+			r := gin.Default()
+			body, _ := json.Marshal(requestBody)
+			req, _ := http.NewRequest("PUT", "/protected/"+projectCode+"/suites/bulk", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			r.PUT("/protected/:project_code/suites/bulk", h.PutTestSuiteBulk)
+			r.ServeHTTP(w, req)
+
+			// 更新は一切行われない
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 取り込む場合（inTransaction）は既存のスイートなどを書き込みと同じトランザクションで読み込む
+func expectTestCaseImport(mock sqlmock.Sqlmock, projectID int, projectCode string, inTransaction bool) {
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	if inTransaction {
+		mock.ExpectBegin()
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id", "order_index"}).
+			AddRow(1, projectID, "Auth", nil, 0))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(3, "Release 1"))
+	mock.ExpectQuery("^SELECT test_suite_id, MAX\\(order_index\\) AS order_index FROM `test_cases`").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"test_suite_id", "order_index"}).AddRow(1, 4))
+}
+
+func postTestCasesBulk(h *handler.TestCaseHandler, projectCode string, query string, requestBody handler.TestCasesPostRequest) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/:project_code/cases/bulk", h.PostTestCasesBulk)
+
+	body, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest("POST", "/protected/"+projectCode+"/cases/bulk"+query, bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
 func TestPostTestCasesBulk(t *testing.T) {
 	h, mock := setupMockTestCaseHandler()
 	gin.SetMode(gin.TestMode)
 
 	projectID := 1
 	projectCode := "PROJECT"
-	rows := sqlmock.NewRows([]string{"id", "code"}).
-		AddRow(projectID, projectCode)
-	mock.ExpectQuery("^SELECT \\* FROM `projects`").
-		WithArgs(projectCode).
-		WillReturnRows(rows)
+	expectTestCaseImport(mock, projectID, projectCode, true)
 
-	// モックデータの設定
-	testCasePostRequests := []handler.TestCasePostRequest{}
-	testCasePostRequests = append(testCasePostRequests, handler.TestCasePostRequest{TestSuiteName: "testSuite", Title: "title", Content: "content"})
+	milestoneID := uint(3)
 	requestBody := handler.TestCasesPostRequest{
-		TestCases: testCasePostRequests,
+		TestCases: []handler.TestCasePostRequest{
+			{TestSuitePath: "Auth/Login/SSO", Title: "SSO login", Content: "content", MilestoneId: &milestoneID},
+			{TestSuiteName: "Auth", Title: "Logout", Tags: []string{"smoke"}},
+		},
 	}
 
-	testSuiteRows := sqlmock.NewRows([]string{"id", "name"})
+	// 足りない階層のスイートだけを作成し、すべてを一つのトランザクションで登録する
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "Login", 1, 0).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "SSO", 2, 0).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	// 作成者はリクエストではなくログイン中のユーザー
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, projectID, milestoneID, "SSO login", "content", 0, "Draft", 7, 7, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, projectID, nil, "Logout", "", 5, "Draft", 7, 7, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectExec("^INSERT INTO `test_case_tags`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 11, "smoke").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := postTestCasesBulk(h, projectCode, "", requestBody)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.TestCases)
+	assert.Equal(t, 2, response.TestSuites)
+	assert.Equal(t, []uint{10, 11}, response.TestCaseIDs)
+	assert.Empty(t, response.Errors)
+
+	// モックの期待が満たされたか検証
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPostTestCasesBulkValidationError(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseImport(mock, 1, projectCode, true)
+	mock.ExpectRollback()
+
+	otherMilestoneID := uint(99)
+	requestBody := handler.TestCasesPostRequest{
+		TestCases: []handler.TestCasePostRequest{
+			{TestSuitePath: "Auth", Title: "valid"},
+			{TestSuitePath: " / ", Title: "", MilestoneId: &otherMilestoneID},
+		},
+	}
+
+	w := postTestCasesBulk(h, projectCode, "", requestBody)
+
+	// 一件でもエラーがあれば何も登録しない
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response util.TestCaseImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.TestCaseImportError{
+		{Row: 2, Field: "title", Message: "title is required"},
+		{Row: 2, Field: "test_suite_path", Message: "test suite is required"},
+		{Row: 2, Field: "milestone_id", Message: "milestone not found in project"},
+	}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostTestCasesBulkWithTemplate - 取り込みでもテンプレートを検証するテスト
+func TestPostTestCasesBulkWithTemplate(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseImport(mock, 1, projectCode, true)
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_templates` WHERE \\(project_id = \\? AND id IN \\(\\?,\\?\\)\\)").
+		WithArgs(1, 5, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "custom_fields"}).
+			AddRow(5, 1, "UI", `[{"name":"Browser","required":true}]`))
+	mock.ExpectRollback()
+
+	templateID := uint(5)
+	otherTemplateID := uint(6)
+	requestBody := handler.TestCasesPostRequest{
+		TestCases: []handler.TestCasePostRequest{
+			{TestSuitePath: "Auth", Title: "Login", TemplateID: &templateID},
+			{TestSuitePath: "Auth", Title: "Logout", TemplateID: &otherTemplateID},
+		},
+	}
+
+	w := postTestCasesBulk(h, projectCode, "", requestBody)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response util.TestCaseImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.TestCaseImportError{
+		{Row: 1, Field: "custom_fields", Message: `custom field "Browser" is required`},
+		{Row: 2, Field: "template_id", Message: "template not found in project"},
+	}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestCasesBulkDryRun(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseImport(mock, 1, projectCode, false)
+
+	requestBody := handler.TestCasesPostRequest{
+		TestCases: []handler.TestCasePostRequest{
+			{TestSuitePath: "Auth/Login/SSO", Title: "SSO login"},
+			{TestSuitePath: "Auth/Login", Title: "Password login"},
+			{TestSuitePath: "Billing", Title: ""},
+		},
+	}
+
+	w := postTestCasesBulk(h, projectCode, "?dry_run=true", requestBody)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.DryRun)
+	assert.Equal(t, 3, response.TestCases)
+	assert.Equal(t, 3, response.TestSuites)
+	assert.Empty(t, response.TestCaseIDs)
+	assert.Equal(t, []util.TestCaseImportError{{Row: 3, Field: "title", Message: "title is required"}}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestCasesBulkRejectsInvalidDryRun(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+
+	requestBody := handler.TestCasesPostRequest{TestCases: []handler.TestCasePostRequest{{TestSuitePath: "Auth", Title: "Login"}}}
+	w := postTestCasesBulk(h, "PROJECT", "?dry_run=yes", requestBody)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectTestCaseExport はスイート 1（Auth）配下のエクスポートで発行されるクエリを設定する
+func expectTestCaseExport(mock sqlmock.Sqlmock, projectID int, projectCode string) {
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "title"}).AddRow(projectID, projectCode, "Shop"))
 	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
-		WithArgs(sqlmock.AnyArg(), projectID, projectID, sqlmock.AnyArg()).
-		WillReturnRows(testSuiteRows)
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).
+			AddRow(1, projectID, "Auth", nil).
+			AddRow(3, projectID, "Billing", nil).
+			AddRow(2, projectID, "Login", 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE test_cases.project_id = \\? AND test_cases.test_suite_id IS NOT NULL AND test_cases.test_suite_id IN \\(\\?,\\?\\)").
+		WithArgs(projectID, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "title", "content", "milestone_id", "created_by_id", "updated_by_id"}).
+			AddRow(10, 2, "Password login", "Use a registered account", 3, 7, 8).
+			AddRow(11, 1, "Logout", "", nil, 7, 7))
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Alice"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}).AddRow(1, 10, "browser", "Chrome"))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones`").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(3, "Release 1"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE `test_case_steps`.`test_case_id` IN \\(\\?,\\?\\) AND `test_case_steps`.`deleted_at` IS NULL ORDER BY order_index, id").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 10, 0, "Open the page", "Form is shown").
+			AddRow(2, 10, 1, "Enter the password\nand submit", "").
+			AddRow(3, 11, 0, "Click logout", ""))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}).
+			AddRow(1, 10, "smoke").
+			AddRow(2, 10, "login"))
+	mock.ExpectQuery("^SELECT \\* FROM `users`").
+		WithArgs(8, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Alice").AddRow(8, "Bob"))
+}
+
+func exportTestCases(h *handler.TestCaseHandler, projectCode string, query string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases/export", h.ExportTestCases)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/cases/export"+query, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportTestCasesCSV(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseExport(mock, 1, projectCode)
+
+	w := exportTestCases(h, projectCode, "?format=csv&test_suite_id=1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="PROJECT-test-cases.csv"`, w.Header().Get("Content-Disposition"))
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(w.Body.Bytes(), []byte("\xef\xbb\xbf")))).ReadAll()
+	assert.NoError(t, err)
+	// スイートのケースを子スイートより先に並べ、パスは最上位から書く
+	assert.Equal(t, [][]string{
+		{"Suite", "Title", "Content", "Steps", "Expected Results", "Milestone", "Tags", "Created By", "Updated By", "Custom: browser"},
+		{"Auth", "Logout", "", "1. Click logout", "", "", "", "Alice", "Alice", ""},
+		{"Auth/Login", "Password login", "Use a registered account", "1. Open the page\n2. Enter the password\nand submit", "1. Form is shown\n2.", "Release 1", "smoke, login", "Alice", "Bob", "Chrome"},
+	}, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// エクスポートしたファイルを既定の対応付けで取り込み直すと同じ内容になる
+	ih, importMock := setupMockImportHandler()
+	importMock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+	expectImporterQueries(importMock, 1, false)
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/preview", "export.csv", w.Body.Bytes(), nil)
+	preview := serveImport("/protected/:project_code/cases/import/preview", ih.PreviewTestCaseImport, req)
+
+	assert.Equal(t, http.StatusOK, preview.Code)
+	var response util.TestCaseImportPreview
+	assert.NoError(t, json.Unmarshal(preview.Body.Bytes(), &response))
+	assert.Empty(t, response.Result.Errors)
+	assert.Equal(t, util.TestCaseImportPreviewRow{
+		Row:           3,
+		TestSuitePath: "Auth/Login",
+		Title:         "Password login",
+		Content:       "Use a registered account",
+		Milestone:     "Release 1",
+		Tags:          []string{"smoke", "login"},
+		CustomFields:  map[string]string{"browser": "Chrome"},
+		Steps: []util.TestCaseStep{
+			{Action: "Open the page", ExpectedResult: "Form is shown"},
+			{Action: "Enter the password\nand submit"},
+		},
+	}, response.Rows[1])
+	assert.NoError(t, importMock.ExpectationsWereMet())
+}
+
+func TestExportTestCasesMarkdown(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseExport(mock, 1, projectCode)
+
+	w := exportTestCases(h, projectCode, "?format=markdown&test_suite_id=1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# Shop\n"+
+		"\n## Auth\n"+
+		"\n### Logout\n\n"+
+		"- Created By: Alice\n"+
+		"- Updated By: Alice\n"+
+		"\n| # | Step | Expected Result |\n| --- | --- | --- |\n"+
+		"| 1 | Click logout |  |\n"+
+		"\n### Login\n"+
+		"\n#### Password login\n\n"+
+		"- Milestone: Release 1\n"+
+		"- Tags: smoke, login\n"+
+		"- Created By: Alice\n"+
+		"- Updated By: Bob\n"+
+		"- browser: Chrome\n"+
+		"\nUse a registered account\n"+
+		"\n| # | Step | Expected Result |\n| --- | --- | --- |\n"+
+		"| 1 | Open the page | Form is shown |\n"+
+		"| 2 | Enter the password<br>and submit |  |\n", w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportTestCasesXLSX(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	expectTestCaseExport(mock, 1, projectCode)
+
+	w := exportTestCases(h, projectCode, "?format=xlsx&test_suite_id=1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	rows, err := util.ReadXLSX(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, []string{"Auth/Login", "Password login", "Use a registered account", "1. Open the page\n2. Enter the password\nand submit", "1. Form is shown\n2.", "Release 1", "smoke, login", "Alice", "Bob", "Chrome"}, rows[2])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportTestCasesUnknownTestSuite(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PROJECT"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).AddRow(1, 1, "Auth", nil))
+
+	w := exportTestCases(h, projectCode, "?test_suite_id=99")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportGherkin(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PROJECT"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).
+			AddRow(1, projectID, "Login", nil).
+			AddRow(3, projectID, "Other", nil).
+			AddRow(2, projectID, "SSO", 1).
+			AddRow(4, projectID, "Google", 2))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE \\(project_id = \\? AND test_suite_id IS NOT NULL\\) AND test_suite_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(projectID, 1, 2, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "title", "content"}).
+			AddRow(31, 1, "Password login", "Users with a password.").
+			AddRow(30, 2, "SSO with <provider>", "Examples:\n  | provider |\n  | Google   |").
+			AddRow(32, 4, "Workspace SSO", ""))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(31, 30, 32).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}).AddRow(1, 30, "scenario_key", "sso-google"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps`").
+		WithArgs(31, 30, 32).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 31, 0, "Open the page", "Form is shown").
+			AddRow(2, 30, 0, "Given the login page is open", "").
+			AddRow(3, 30, 1, "When I sign in with <provider>\n\"\"\"\npayload\n\"\"\"", ""))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(31, 30, 32).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}).
+			AddRow(1, 31, "smoke").
+			AddRow(2, 30, "web"))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases/export/gherkin", h.ExportGherkin)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/cases/export/gherkin?test_suite_id=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="login.feature"`, w.Header().Get("Content-Disposition"))
+	// 子スイートは Rule になり、孫スイートのテストケースも Rule にまとめる
+	assert.Equal(t, `Feature: Login
+
+  @smoke @key:login/password-login
+  Scenario: Password login
+    Users with a password.
+    * Open the page
+    Then Form is shown
+
+  Rule: SSO
+
+    @web @key:sso-google
+    Scenario Outline: SSO with <provider>
+      Given the login page is open
+      When I sign in with <provider>
+        """
+        payload
+        """
+
+      Examples:
+        | provider |
+        | Google   |
+
+    @key:login/sso/workspace-sso
+    Scenario: Workspace SSO
+`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func transferTestCases(h *handler.TestCaseHandler, action string, projectCode string, requestBody handler.TestCaseTransferRequest) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/:project_code/cases/copy", h.CopyTestCases)
+	r.POST("/protected/:project_code/cases/move", h.MoveTestCases)
+
+	body, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest("POST", "/protected/"+projectCode+"/cases/"+action, bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func expectTestCaseTransfer(mock sqlmock.Sqlmock, projectCode string) {
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE `projects`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(2, "OTHER"))
+	// 1 Auth ─ 3 Login
+	// 2 Other
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id", "order_index"}).
+			AddRow(1, 1, "Auth", nil, 0).
+			AddRow(2, 1, "Other", nil, 1).
+			AddRow(3, 1, "Login", 1, 2))
+}
+
+func TestCopyTestCases(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectTestCaseTransfer(mock, "PROJECT")
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name"}).AddRow(5, 2, "Imported"))
+	// スイート1の配下と、個別に選んだケース20をまとめて取得する
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE project_id = \\? AND \\(id IN \\(\\?\\) OR test_suite_id IN \\(\\?,\\?\\)\\)").
+		WithArgs(1, 20, 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "milestone_id", "title", "content", "order_index"}).
+			AddRow(10, 1, 3, 3, "Login succeeds", "content", 1).
+			AddRow(20, 1, 2, nil, "Other case", "", 0))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones`").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Release 1"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters`").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}).
+			AddRow(1, 10, `["locale"]`, `[["ja"],["en"]]`))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps`").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 10, 0, "Open login page", "Form is shown"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}).AddRow(1, 10, "smoke"))
+	// 移動先のプロジェクトには同じマイルストーンが無い
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
 
 	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `milestones`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "Release 1", "", "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectQuery("^SELECT COALESCE\\(MAX\\(order_index\\) \\+ 1, 0\\) AS next FROM `test_suites` WHERE project_id = \\? AND parent_id = \\?").
+		WithArgs(2, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"next"}).AddRow(1))
+	mock.ExpectQuery("^SELECT COALESCE\\(MAX\\(order_index\\) \\+ 1, 0\\) AS next FROM `test_cases` WHERE test_suite_id = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"next"}).AddRow(4))
+	// 選んだスイートは移動先のスイートの末尾に、配下のスイートは元の並び順のまま作成する
 	mock.ExpectExec("^INSERT INTO `test_suites`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "Auth", 5, 1).
+		WillReturnResult(sqlmock.NewResult(11, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "Login", 11, 2).
+		WillReturnResult(sqlmock.NewResult(12, 1))
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 12, 2, 8, "Login succeeds", "content", 1, "Draft", 7, 7, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5, 2, nil, "Other case", "", 4, "Draft", 7, 7, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(30, 2))
+	mock.ExpectExec("^INSERT INTO `test_case_tags`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, "smoke").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, 0, "Open login page", "Form is shown", nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_parameters`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, `["locale"]`, `[["ja"],["en"]]`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
+	targetTestSuiteID := uint(5)
+	w := transferTestCases(h, "copy", "PROJECT", handler.TestCaseTransferRequest{
+		TargetProjectID:   2,
+		TargetTestSuiteID: &targetTestSuiteID,
+		TestCaseIDs:       []uint{20},
+		TestSuiteIDs:      []uint{1, 3},
+		Milestones:        "create",
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseTransferResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.TransferredID{{SourceID: 1, ID: 11}, {SourceID: 3, ID: 12}}, response.TestSuites)
+	assert.Equal(t, []util.TransferredID{{SourceID: 10, ID: 30}, {SourceID: 20, ID: 31}}, response.TestCases)
+	assert.Equal(t, []util.TransferredID{{SourceID: 3, ID: 8}}, response.Milestones)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectTestCaseMove はスイート1の配下をプロジェクト2へ移動するときの、テストランを扱う前までのクエリを期待する
+func expectTestCaseMove(mock sqlmock.Sqlmock) {
+	expectTestCaseTransfer(mock, "PROJECT")
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE project_id = \\? AND test_suite_id IN \\(\\?,\\?\\)").
+		WithArgs(1, 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "milestone_id", "title"}).
+			AddRow(10, 1, 3, 3, "Login succeeds"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_custom_fields`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name", "value"}))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones`").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Release 1"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_tags`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "name"}))
+	// タイトルの大文字小文字を区別せずに移動先のマイルストーンと対応付ける
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(9, "release 1"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+
 	mock.ExpectBegin()
-	mock.ExpectExec("^INSERT INTO `test_cases`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT COALESCE\\(MAX\\(order_index\\) \\+ 1, 0\\) AS next FROM `test_suites` WHERE project_id = \\? AND parent_id IS NULL").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"next"}).AddRow(3))
+	mock.ExpectExec("^UPDATE `test_suites` SET `order_index`=\\?,`parent_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(3, nil, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_suites` SET `project_id`=\\?,`updated_at`=\\? WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(2, sqlmock.AnyArg(), 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_cases` SET `milestone_id`=\\?,`project_id`=\\?,`updated_at`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(9, 2, sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 移動元のプロジェクトのテンプレートは参照しないように外す
+	mock.ExpectExec("^UPDATE `test_cases` SET `template_id`=\\?,`updated_at`=\\? WHERE \\(id IN \\(\\?\\) AND template_id IS NOT NULL\\)").
+		WithArgs(nil, sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// 移動しないテストケースとの依存は外す
+	mock.ExpectExec("^DELETE FROM `test_case_dependencies` WHERE \\(test_case_id IN \\(\\?\\) AND prerequisite_id NOT IN \\(\\?\\)\\) OR \\(prerequisite_id IN \\(\\?\\) AND test_case_id NOT IN \\(\\?\\)\\)").
+		WithArgs(10, 10, 10, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 移動元のプロジェクトの要件との紐付けは外す
+	mock.ExpectExec("^DELETE FROM `requirement_test_cases` WHERE test_case_id IN \\(\\?\\) AND requirement_id IN \\(SELECT `id` FROM `requirements` WHERE project_id = \\?\\)").
+		WithArgs(10, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestMoveTestCases(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectTestCaseMove(mock)
+	// 移動元のプロジェクトのテストランからは外す
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `deleted_at`=\\? WHERE \\(test_case_id IN \\(\\?\\) AND test_run_id IN \\(SELECT `id` FROM `test_runs` WHERE project_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 10, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
 	mock.ExpectCommit()
 
-	// HTTPリクエストの設定
+	w := transferTestCases(h, "move", "PROJECT", handler.TestCaseTransferRequest{
+		TargetProjectID: 2,
+		TestSuiteIDs:    []uint{1},
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseTransferResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.TransferredID{{SourceID: 1, ID: 1}, {SourceID: 3, ID: 3}}, response.TestSuites)
+	assert.Equal(t, []util.TransferredID{{SourceID: 10, ID: 10}}, response.TestCases)
+	assert.Empty(t, response.Milestones)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveTestCasesKeepRunHistory(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	// テストランのケースは残す
+	expectTestCaseMove(mock)
+	mock.ExpectCommit()
+
+	w := transferTestCases(h, "move", "PROJECT", handler.TestCaseTransferRequest{
+		TargetProjectID: 2,
+		TestSuiteIDs:    []uint{1},
+		KeepRunHistory:  true,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 移動元のテストランでは、移動したケースを別のグループにまとめて表示する
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs`").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "project_id"}).AddRow(4, "Regression", 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"}).AddRow(8, 4, 10, 5))
+	mock.ExpectQuery("^SELECT \\* FROM `comments`").
+		WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_case_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "Passed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "title"}).AddRow(10, 2, 3, "Login succeeds"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id"}).AddRow(2, 1, "Other", nil))
+
 	r := gin.Default()
-	body, _ := json.Marshal(requestBody)
-	req, _ := http.NewRequest("POST", "/protected/"+projectCode+"/cases/bulk", bytes.NewBuffer(body))
-	w := httptest.NewRecorder()
+	r.GET("/protected/runs/:id", handler.NewTestRunHandler(h.DB).GetTestRunCases)
+	req, _ := http.NewRequest("GET", "/protected/runs/4", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
 
-	// テスト実行
-	r.POST("/protected/:project_code/cases/bulk", h.PostTestCasesBulk)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestRunCasesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.TestSuites, 1)
+	assert.Equal(t, uint(0), response.TestSuites[0].ID)
+	assert.Equal(t, "Moved to another project", response.TestSuites[0].Name)
+	assert.Len(t, response.TestSuites[0].TestCases, 1)
+	assert.Equal(t, uint(8), response.TestSuites[0].TestCases[0].ID)
+	assert.Equal(t, []util.JSONOnlyTestSuite{{ID: 0, Title: "Moved to another project", Children: []util.JSONOnlyTestSuite{}}}, response.OnlyTestSuites)
+	assert.Equal(t, 1, response.Charts[0].Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCopyTestCasesRejectsForeignTestCase(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectTestCaseTransfer(mock, "PROJECT")
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name"}).AddRow(5, 2, "Imported"))
+	// 別プロジェクトのケースは見つからない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE project_id = \\? AND id IN").
+		WithArgs(1, 99).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	targetTestSuiteID := uint(5)
+	w := transferTestCases(h, "copy", "PROJECT", handler.TestCaseTransferRequest{
+		TargetProjectID:   2,
+		TargetTestSuiteID: &targetTestSuiteID,
+		TestCaseIDs:       []uint{99},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func postTestCaseReviewAction(h *handler.TestCaseHandler, action string, testCaseID int, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/cases/:id/review", h.RequestTestCaseReview)
+	r.POST("/protected/cases/:id/approve", h.ApproveTestCase)
+	r.POST("/protected/cases/:id/reject", h.RejectTestCase)
+
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/protected/cases/%d/%s", testCaseID, action), strings.NewReader(body))
+	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestApproveTestCase(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	testCaseID := 5
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(testCaseID, "InReview"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Approver"))
+	mock.ExpectBegin()
+	// 担当者以外が承認した場合は担当者に加える
+	mock.ExpectExec("^UPDATE `test_case_reviewers` SET `decision`=\\?,`updated_at`=\\? WHERE \\(test_case_id = \\? AND user_id = \\?\\)").
+		WithArgs("Approved", sqlmock.AnyArg(), testCaseID, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO `test_case_reviewers`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, testCaseID, 7, "Approved").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `state`=\\?,`updated_by_id`=\\?,`updated_at`=\\?").
+		WithArgs("Approved", 7, sqlmock.AnyArg(), testCaseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_review_comments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, testCaseID, 7, "Approve", "Looks good").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_reviewers` WHERE test_case_id = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "user_id", "decision"}).
+			AddRow(2, testCaseID, 8, "").
+			AddRow(3, testCaseID, 7, "Approved"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(8, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Approver").AddRow(8, "Reviewer"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_review_comments` WHERE test_case_id = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "user_id", "action", "content"}).
+			AddRow(8, testCaseID, 8, "RequestReview", "").
+			AddRow(9, testCaseID, 7, "Approve", "Looks good"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(8, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Approver").AddRow(8, "Reviewer"))
+
+	w := postTestCaseReviewAction(h, "approve", testCaseID, `{"content": " Looks good "}`)
 
-	// レスポンスと期待される結果を検証
 	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseReview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Approved", response.State)
+	assert.Equal(t, []util.TestCaseReviewer{
+		{User: util.User{ID: 8, Name: "Reviewer"}, Decision: ""},
+		{User: util.User{ID: 7, Name: "Approver"}, Decision: "Approved"},
+	}, response.Reviewers)
+	assert.Len(t, response.Comments, 2)
+	assert.Equal(t, "Approve", response.Comments[1].Action)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestApproveTestCaseNotInReview(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(5, "Draft"))
+
+	w := postTestCaseReviewAction(h, "approve", 5, "")
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestRejectTestCaseRequiresComment(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	w := postTestCaseReviewAction(h, "reject", 5, `{"content": "  "}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestRequestTestCaseReview(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	testCaseID := 5
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(testCaseID, "Draft"))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `users` WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(8, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "Author"))
+	mock.ExpectBegin()
+	// 以前のレビュー担当者は置き換える
+	mock.ExpectExec("^UPDATE `test_case_reviewers` SET `deleted_at`=\\? WHERE test_case_id = \\?").
+		WithArgs(sqlmock.AnyArg(), testCaseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_reviewers`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, testCaseID, 8, "",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, testCaseID, 9, "",
+		).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("^UPDATE `test_cases` SET `state`=\\?,`updated_by_id`=\\?,`updated_at`=\\?").
+		WithArgs("InReview", 7, sqlmock.AnyArg(), testCaseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_review_comments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, testCaseID, 7, "RequestReview", "Please check").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_reviewers` WHERE test_case_id = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "user_id", "decision"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_review_comments` WHERE test_case_id = \\?").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "user_id", "action", "content"}))
+
+	w := postTestCaseReviewAction(h, "review", testCaseID, `{"reviewer_ids": [8, 9, 8], "comment": "Please check"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseReview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "InReview", response.State)
 
-	// モックの期待が満たされたか検証
 	err := mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestRequestTestCaseReviewRejectsApprovedTestCase(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	// 承認済みのテストケースは編集して下書きに戻るまでレビューを依頼できない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(5, "Approved"))
+
+	w := postTestCaseReviewAction(h, "review", 5, `{"reviewer_ids": [8]}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPutTestCaseDependencies(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(1, 1, "Refund order"))
+	// 循環の確認は依存を登録するトランザクションの中で行う
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases` WHERE \\(project_id = \\? AND id IN \\(\\?,\\?\\)\\)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_dependencies` WHERE test_case_id IN \\(SELECT `id` FROM `test_cases` WHERE project_id = \\?.*\\) FOR UPDATE$").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "prerequisite_id"}).
+			AddRow(1, 5).
+			AddRow(4, 1))
+	mock.ExpectExec("^DELETE FROM `test_case_dependencies` WHERE test_case_id = \\?").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_dependencies`").
+		WithArgs(1, 2, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE id IN \\(SELECT `prerequisite_id` FROM `test_case_dependencies` WHERE test_case_id = \\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).
+			AddRow(2, 1, "Create order").
+			AddRow(3, 1, "Pay order"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE id IN \\(SELECT `test_case_id` FROM `test_case_dependencies` WHERE prerequisite_id = \\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(4, 1, "Close order"))
+
+	r := gin.Default()
+	r.PUT("/protected/cases/:id/dependencies", h.PutTestCaseDependencies)
+	req := httptest.NewRequest("PUT", "/protected/cases/1/dependencies", strings.NewReader(`{"prerequisite_ids": [2, 3, 2]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseDependencies
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Prerequisites, 2)
+	assert.Equal(t, "Close order", response.Dependents[0].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutTestCaseDependenciesRejectsCycle(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(1, 1, "Create order"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases`").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// 2 は 3 を、3 は 1 を前提条件にしている
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_dependencies`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "prerequisite_id"}).
+			AddRow(2, 3).
+			AddRow(3, 1))
+	mock.ExpectRollback()
+
+	r := gin.Default()
+	r.PUT("/protected/cases/:id/dependencies", h.PutTestCaseDependencies)
+	req := httptest.NewRequest("PUT", "/protected/cases/1/dependencies", strings.NewReader(`{"prerequisite_ids": [2]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutTestCaseDependenciesRejectsSelf(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(1, 1, "Create order"))
+
+	r := gin.Default()
+	r.PUT("/protected/cases/:id/dependencies", h.PutTestCaseDependencies)
+	req := httptest.NewRequest("PUT", "/protected/cases/1/dependencies", strings.NewReader(`{"prerequisite_ids": [1]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTestCaseDuplicates(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT test_cases.id, test_cases.project_id, test_cases.title, test_cases.content AS body, test_cases.test_suite_id FROM `test_cases` WHERE test_cases.deleted_at IS NULL AND test_cases.project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "body", "test_suite_id"}).
+			AddRow(10, 1, "Login with valid password", "Open the login page", 3).
+			AddRow(11, 1, "Login with a valid password", "", 4).
+			AddRow(12, 1, "Export report", "", 3))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?,\\?,\\?\\) AND").
+		WithArgs(10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "action", "expected_result"}).
+			AddRow(1, 11, "Open the login page", ""))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/cases/duplicates", h.GetTestCaseDuplicates)
+	req := httptest.NewRequest("GET", "/protected/PROJECT/cases/duplicates?threshold=0.7", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseDuplicatesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0.7, response.Threshold)
+	// 手順も含めて比べる
+	assert.Len(t, response.Clusters, 1)
+	assert.Equal(t, 0.875, response.Clusters[0].Similarity)
+	assert.Equal(t, uint(10), response.Clusters[0].TestCases[0].ID)
+	assert.Equal(t, uint(11), response.Clusters[0].TestCases[1].ID)
+	assert.Equal(t, util.TestCaseDuplicatePair{TestCaseID: 10, DuplicateID: 11, Similarity: 0.875}, response.Clusters[0].Pairs[0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	req = httptest.NewRequest("GET", "/protected/PROJECT/cases/duplicates?threshold=1.5", nil)
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMergeTestCases(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases` WHERE \\(project_id = \\? AND id IN \\(\\?,\\?,\\?\\)\\)").
+		WithArgs(1, 10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	// 11 と 10 は同じテストケースを前提条件にしている。6 は 12 を前提条件にしている
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_dependencies` WHERE test_case_id IN \\(SELECT `id` FROM `test_cases` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "prerequisite_id"}).
+			AddRow(11, 5).
+			AddRow(10, 5).
+			AddRow(6, 12))
+	mock.ExpectQuery("^SELECT \\* FROM `requirement_test_cases` WHERE test_case_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"requirement_id", "test_case_id"}).
+			AddRow(1, 10).
+			AddRow(1, 11).
+			AddRow(2, 12))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE test_case_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id"}).
+			AddRow(1, 10, 1).
+			AddRow(2, 11, 2).
+			AddRow(3, 12, 3).
+			AddRow(4, 11, 4))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `test_case_id`=\\?,`updated_at`=\\? WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, sqlmock.AnyArg(), 2, 3, 4).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("^DELETE FROM `requirement_test_cases` WHERE test_case_id IN \\(\\?,\\?\\)").
+		WithArgs(11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^INSERT INTO `requirement_test_cases`").
+		WithArgs(2, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM `test_case_dependencies` WHERE test_case_id IN \\(\\?,\\?\\) OR prerequisite_id IN \\(\\?,\\?\\)").
+		WithArgs(11, 12, 11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^INSERT INTO `test_case_dependencies`").
+		WithArgs(6, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\? WHERE `test_cases`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(sqlmock.AnyArg(), 11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.POST("/protected/:project_code/cases/merge", h.MergeTestCases)
+	req := httptest.NewRequest("POST", "/protected/PROJECT/cases/merge", strings.NewReader(`{"target_id": 10, "source_ids": [11, 12, 11]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseMergeResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []uint{11, 12}, response.MergedTestCaseIDs)
+	assert.Equal(t, int64(3), response.TestRunCases)
+	assert.Equal(t, int64(0), response.TrashedTestRunCases)
+	assert.Equal(t, 1, response.Requirements)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeTestCasesInSameTestRun(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases`").
+		WithArgs(1, 10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_dependencies`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "prerequisite_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `requirement_test_cases`").
+		WithArgs(10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"requirement_id", "test_case_id"}))
+	// ラン 1 は残すテストケースのものを残す。ラン 3 は同じパラメータの行のうち最後に更新したものを残す
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE test_case_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, 11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "parameter_index", "updated_at"}).
+			AddRow(1, 11, 1, nil, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(2, 10, 1, nil, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(3, 11, 2, nil, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(4, 11, 3, 0, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(5, 12, 3, 0, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(6, 12, 3, 1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	// ゴミ箱から一緒に復元できるよう、ラン上のケースとテストケースの削除日時を揃える
+	deletedAt := &sameTime{}
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `deleted_at`=\\? WHERE `test_run_cases`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(deletedAt, 1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `test_case_id`=\\?,`updated_at`=\\? WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, sqlmock.AnyArg(), 3, 5, 6).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("^DELETE FROM `requirement_test_cases`").
+		WithArgs(11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_dependencies`").
+		WithArgs(11, 12, 11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\? WHERE `test_cases`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(deletedAt, 11, 12).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.POST("/protected/:project_code/cases/merge", h.MergeTestCases)
+	req := httptest.NewRequest("POST", "/protected/PROJECT/cases/merge", strings.NewReader(`{"target_id": 10, "source_ids": [11, 12]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseMergeResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(3), response.TestRunCases)
+	assert.Equal(t, int64(2), response.TrashedTestRunCases)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeTestCasesRejectsCycle(t *testing.T) {
+	h, mock := setupMockTestCaseHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases`").
+		WithArgs(1, 10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	// 10 は 5 を、5 は 11 を前提条件にしているため、11 を 10 にまとめると循環する
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_dependencies`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "prerequisite_id"}).
+			AddRow(10, 5).
+			AddRow(5, 11))
+
+	r := gin.Default()
+	r.POST("/protected/:project_code/cases/merge", h.MergeTestCases)
+	req := httptest.NewRequest("POST", "/protected/PROJECT/cases/merge", strings.NewReader(`{"target_id": 10, "source_ids": [11]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
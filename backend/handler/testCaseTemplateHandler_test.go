@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/util"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setupMockTestCaseTemplateHandler() (*handler.TestCaseTemplateHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewTestCaseTemplateHandler(gormDB), mock
+}
+
+func postTestCaseTemplate(h *handler.TestCaseTemplateHandler, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/templates", h.PostTestCaseTemplate)
+	req := httptest.NewRequest("POST", "/protected/templates", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostTestCaseTemplate(t *testing.T) {
+	h, mock := setupMockTestCaseTemplateHandler()
+	gin.SetMode(gin.TestMode)
+
+	steps := `[{"action":"Open the page","expected_result":"The page is shown"}]`
+	fields := `[{"name":"Browser","default":"Chrome","required":true,"options":["Chrome","Firefox"]}]`
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE `projects`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectBegin()
+	// 空の手順は保存せず、共有ステップの参照は持たせない
+	mock.ExpectExec("^INSERT INTO `test_case_templates`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, "UI", "Screen checks", "## Preconditions", steps, fields, 1, 1).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_templates` WHERE `test_case_templates`.`id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "description", "content", "steps", "custom_fields", "created_by_id", "updated_by_id"}).
+			AddRow(4, 1, "UI", "Screen checks", "## Preconditions", steps, fields, 1, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+
+	w := postTestCaseTemplate(h, `{"project_id": 1, "name": " UI ", "description": "Screen checks", "content": "## Preconditions", `+
+		`"steps": [{"action": "Open the page", "expected_result": "The page is shown", "shared_step_id": 3}, {"action": " ", "expected_result": ""}], `+
+		`"custom_fields": [{"name": " Browser ", "default": "Chrome", "required": true, "options": ["Chrome", "Firefox"]}]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.TestCaseTemplate
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(4), response.ID)
+	assert.Equal(t, []util.TestCaseStep{{Action: "Open the page", ExpectedResult: "The page is shown"}}, response.Steps)
+	assert.Equal(t, "Browser", response.CustomFields[0].Name)
+	assert.Equal(t, "User", response.CreatedBy.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestCaseTemplateProjectNotFound(t *testing.T) {
+	h, mock := setupMockTestCaseTemplateHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE `projects`.`id` = \\?").
+		WithArgs(99).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	w := postTestCaseTemplate(h, `{"project_id": 99, "name": "UI"}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestCaseTemplateRejectsInvalidDefault(t *testing.T) {
+	h, mock := setupMockTestCaseTemplateHandler()
+	gin.SetMode(gin.TestMode)
+
+	w := postTestCaseTemplate(h, `{"project_id": 1, "name": "UI", "custom_fields": [{"name": "Browser", "default": "Safari", "options": ["Chrome", "Firefox"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postTestCaseTemplate(h, `{"project_id": 1, "name": "UI", "custom_fields": [{"name": "Browser"}, {"name": "Browser"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,608 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"backend/handler"
+	"backend/util"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setupMockImportHandler() (*handler.ImportHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewImportHandler(gormDB), mock
+}
+
+func newImportRequest(url string, fileName string, content []byte, fields map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", fileName)
+	part.Write(content)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", url, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func serveImport(route string, h gin.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST(route, h)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// expectImporterQueries は取り込み前に読み込む既存データのクエリを設定する
+// 取り込む場合（inTransaction）は既存のスイートなどを書き込みと同じトランザクションで読み込む
+func expectImporterQueries(mock sqlmock.Sqlmock, projectID int, inTransaction bool) {
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	if inTransaction {
+		mock.ExpectBegin()
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "parent_id", "order_index"}).
+			AddRow(1, projectID, "Auth", nil, 0))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\?").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(3, "Release 1"))
+	mock.ExpectQuery("^SELECT test_suite_id, MAX\\(order_index\\) AS order_index FROM `test_cases`").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"test_suite_id", "order_index"}))
+}
+
+func TestPreviewTestCaseImportCSV(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	expectImporterQueries(mock, projectID, false)
+
+	csvContent := "\xef\xbb\xbfCase,Folder,Actions,Expected,Release,Labels,Browser\n" +
+		"Login,Auth/Login,\"1. Open the page\n2. Enter the password\n   and submit\",\"1. Form is shown\n2. Logged in\",release 1,\"smoke, login\",Chrome\n" +
+		",,Log out,Logged out,,,\n" +
+		",Billing,Pay,,Release 9,,\n"
+	mapping := `{"title":"Case","test_suite_path":"Folder","steps":"Actions","expected_results":"Expected","milestone":"Release","tags":"Labels","custom_fields":{"browser":"Browser"}}`
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/preview", "cases.csv", []byte(csvContent), map[string]string{"mapping": mapping})
+	w := serveImport("/protected/:project_code/cases/import/preview", h.PreviewTestCaseImport, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseImportPreview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"Case", "Folder", "Actions", "Expected", "Release", "Labels", "Browser"}, response.Columns)
+	assert.Len(t, response.Rows, 2)
+
+	// 番号付きの手順は番号ごとにまとめ、手順だけの行は直前のケースに続ける
+	assert.Equal(t, util.TestCaseImportPreviewRow{
+		Row:           2,
+		TestSuitePath: "Auth/Login",
+		Title:         "Login",
+		Milestone:     "release 1",
+		Tags:          []string{"smoke", "login"},
+		CustomFields:  map[string]string{"browser": "Chrome"},
+		Steps: []util.TestCaseStep{
+			{Action: "Open the page", ExpectedResult: "Form is shown"},
+			{Action: "Enter the password\nand submit", ExpectedResult: "Logged in"},
+			{Action: "Log out", ExpectedResult: "Logged out"},
+		},
+	}, response.Rows[0])
+
+	assert.True(t, response.Result.DryRun)
+	assert.Equal(t, 2, response.Result.TestCases)
+	assert.Equal(t, 2, response.Result.TestSuites)
+	assert.Equal(t, []util.TestCaseImportError{
+		{Row: 4, Field: "title", Message: "title is required"},
+		{Row: 4, Field: "milestone", Message: "milestone not found in project"},
+	}, response.Result.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// buildXLSX はテスト用に共有文字列を使う最小限の XLSX を作成する
+func buildXLSX(rows [][]string) []byte {
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	write := func(name string, content string) {
+		file, _ := archive.Create(name)
+		file.Write([]byte(content))
+	}
+
+	write("xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Cases" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+	write("xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/data.xml"/></Relationships>`)
+
+	strs := []string{}
+	sheet := &strings.Builder{}
+	for r, row := range rows {
+		fmt.Fprintf(sheet, `<row r="%d">`, r+1)
+		for c, value := range row {
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(sheet, `<c r="%c%d" t="s"><v>%d</v></c>`, 'A'+c, r+1, len(strs))
+			strs = append(strs, value)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	write("xl/worksheets/data.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`+sheet.String()+`</sheetData></worksheet>`)
+
+	shared := &strings.Builder{}
+	for _, value := range strs {
+		shared.WriteString("<si><t>")
+		xmlEscape(shared, value)
+		shared.WriteString("</t></si>")
+	}
+	write("xl/sharedStrings.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+shared.String()+`</sst>`)
+
+	archive.Close()
+	return buffer.Bytes()
+}
+
+func xmlEscape(b *strings.Builder, value string) {
+	b.WriteString(strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value))
+}
+
+func TestPostTestCaseImportXLSXWithProfile(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `import_profiles` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs("5", projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "mapping"}).
+			AddRow(5, projectID, "QA sheet", `{"title":"Name","test_suite_path":"Section","steps":"Steps"}`))
+	expectImporterQueries(mock, projectID, true)
+
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, projectID, nil, "Login", "", 0, "Draft", 7, 7, nil).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 0, "Open the page", "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 1, "Submit", "", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	content := buildXLSX([][]string{
+		{"Section", "Name", "Steps"},
+		{},
+		{"Auth", "Login", "Open the page\nSubmit"},
+	})
+	req := newImportRequest("/protected/"+projectCode+"/cases/import", "cases.xlsx", content, map[string]string{"profile_id": "5"})
+	w := serveImport("/protected/:project_code/cases/import", h.PostTestCaseImport, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestCaseImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []uint{10}, response.TestCaseIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestCaseImportUnknownColumn(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+
+	// 既定の対応付けでは "Title" 列が必要
+	req := newImportRequest("/protected/"+projectCode+"/cases/import", "cases.csv", []byte("Name,Suite\nLogin,Auth\n"), nil)
+	w := serveImport("/protected/:project_code/cases/import", h.PostTestCaseImport, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `column \"Title\" not found`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetImportProfiles(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `import_profiles` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "name", "mapping"}).
+			AddRow(5, 1, "QA sheet", `{"title":"Name","tags":"Labels","custom_fields":{"browser":"Browser"}}`))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/import/profiles", h.GetImportProfiles)
+	req, _ := http.NewRequest("GET", "/protected/"+projectCode+"/import/profiles", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.ImportProfilesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.ImportProfile{{
+		ID:   5,
+		Name: "QA sheet",
+		Mapping: util.ImportColumnMapping{
+			Title:        "Name",
+			Tags:         "Labels",
+			CustomFields: map[string]string{"browser": "Browser"},
+		},
+	}}, response.Profiles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostImportProfile(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `import_profiles`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, "QA sheet", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+
+	body := `{"project_id":1,"name":" QA sheet ","mapping":{"title":"Name","test_suite_path":"Section"}}`
+	r := gin.Default()
+	r.POST("/protected/import/profiles", h.PostImportProfile)
+	req, _ := http.NewRequest("POST", "/protected/import/profiles", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.ImportProfile
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(5), response.ID)
+	assert.Equal(t, "Section", response.Mapping.TestSuitePath)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const testRailSuiteXML = `<?xml version="1.0" encoding="UTF-8"?>
+<suite>
+	<id>S1</id>
+	<name>Web</name>
+	<sections>
+		<section>
+			<id>10</id>
+			<name>Auth</name>
+			<cases>
+				<case>
+					<id>C100</id>
+					<title>Login</title>
+					<type>Functional</type>
+					<priority>High</priority>
+					<references>JIRA-1, JIRA-2</references>
+					<custom>
+						<preconds>User exists</preconds>
+						<automation_type><id>1</id><value>Automated</value></automation_type>
+						<steps_separated>
+							<step><index>1</index><content>Open page</content><expected>Form shown</expected></step>
+							<step><index>2</index><content>Submit</content><expected>Logged in</expected></step>
+						</steps_separated>
+					</custom>
+				</case>
+			</cases>
+			<sections>
+				<section>
+					<id>11</id>
+					<name>SSO</name>
+					<cases>
+						<case>
+							<id>C101</id>
+							<title>SSO login</title>
+							<custom>
+								<steps>1. Click SSO
+2. Approve</steps>
+								<expected>1. Redirected</expected>
+							</custom>
+						</case>
+					</cases>
+				</section>
+			</sections>
+		</section>
+		<section>
+			<id>12</id>
+			<name>Empty</name>
+		</section>
+	</sections>
+</suite>`
+
+func TestPostTestRailImport(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	expectImporterQueries(mock, projectID, true)
+
+	// セクションは文書の順に作成し、ケースの無いセクションも残す
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "Web", nil, 1).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "Auth", 2, 0).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "SSO", 3, 0).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "Empty", 2, 1).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, projectID, nil, "Login", "User exists", 0, "Draft", 7, 7, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 4, projectID, nil, "SSO login", "", 0, "Draft", 7, 7, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(20, 2))
+	mock.ExpectExec("^INSERT INTO `test_case_custom_fields`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, "automation_type", "Automated",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, "priority", "High",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, "references", "JIRA-1, JIRA-2",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, "type", "Functional",
+		).
+		WillReturnResult(sqlmock.NewResult(1, 4))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, 0, "Open page", "Form shown", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 20, 1, "Submit", "Logged in", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 21, 0, "Click SSO", "Redirected", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 21, 1, "Approve", "", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 4))
+	mock.ExpectCommit()
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/testrail", "suite.xml", []byte(testRailSuiteXML), map[string]string{"test_suite_path": "Web"})
+	w := serveImport("/protected/:project_code/cases/import/testrail", h.PostTestRailImport, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestRailImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 4, response.TestSuites)
+	assert.Equal(t, []uint{20, 21}, response.TestCaseIDs)
+	assert.Equal(t, []util.ImportIDMapping{{SourceID: "10", ID: 3}, {SourceID: "11", ID: 4}, {SourceID: "12", ID: 5}}, response.TestSuiteMappings)
+	assert.Equal(t, []util.ImportIDMapping{{SourceID: "C100", ID: 20}, {SourceID: "C101", ID: 21}}, response.TestCaseMappings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestRailImportDryRun(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+	expectImporterQueries(mock, 1, false)
+
+	// 既存の Auth スイートはそのまま使う
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/testrail?dry_run=true", "suite.xml", []byte(testRailSuiteXML), nil)
+	w := serveImport("/protected/:project_code/cases/import/testrail", h.PostTestRailImport, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestRailImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.DryRun)
+	assert.Equal(t, 2, response.TestCases)
+	assert.Equal(t, 2, response.TestSuites)
+	assert.Empty(t, response.Errors)
+	assert.Empty(t, response.TestCaseMappings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestRailImportRejectsInvalidDryRun(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/testrail?dry_run=yes", "suite.xml", []byte(testRailSuiteXML), nil)
+	w := serveImport("/protected/:project_code/cases/import/testrail", h.PostTestRailImport, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestRailImportInvalidXML(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/testrail", "suite.xml", []byte("<project></project>"), nil)
+	w := serveImport("/protected/:project_code/cases/import/testrail", h.PostTestRailImport, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const loginFeature = `@web
+Feature: Login
+  Users sign in.
+
+  Background:
+    Given the login page is open
+
+  @smoke
+  Scenario: Password login
+    Users with a password.
+    When I enter "alice" and "secret"
+      | field | value |
+    Then I see the dashboard
+
+  Rule: SSO
+
+    @key:sso-google
+    Scenario Outline: SSO with <provider>
+      When I sign in with <provider>
+        """
+        payload
+        """
+
+      Examples:
+        | provider |
+        | Google   |
+`
+
+func TestPostGherkinImport(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectID := 1
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	expectImporterQueries(mock, projectID, true)
+	// キーが一致する既存のテストケースは更新する
+	mock.ExpectQuery("^SELECT test_case_custom_fields.test_case_id, test_case_custom_fields.value FROM `test_case_custom_fields` JOIN test_cases").
+		WithArgs(projectID, "scenario_key", "login/password-login", "sso-google").
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id", "value"}).AddRow(30, "sso-google"))
+	mock.ExpectQuery("^SELECT `id`,`test_suite_id`,`title` FROM `test_cases` WHERE \\(project_id = \\? AND title IN \\(\\?,\\?\\)\\) AND \\(id NOT IN").
+		WithArgs(projectID, "Password login", "SSO with <provider>", "scenario_key").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "title"}))
+
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "Login", nil, 1).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("^INSERT INTO `test_suites`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "SSO", 2, 0).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `content`=\\?,`test_suite_id`=\\?,`title`=\\?,`updated_by_id`=\\?,`updated_at`=\\? WHERE `test_cases`.`deleted_at` IS NULL AND `id` = \\?").
+		WithArgs("Examples:\n  | provider |\n  | Google   |", 3, "SSO with <provider>", 7, sqlmock.AnyArg(), 30).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_case_custom_fields` SET `deleted_at`=\\? WHERE \\(test_case_id = \\? AND name = \\?\\)").
+		WithArgs(sqlmock.AnyArg(), 30, "scenario_key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_custom_fields`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, "scenario_key", "sso-google").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE `test_case_tags` SET `deleted_at`=\\?").
+		WithArgs(sqlmock.AnyArg(), 30).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_tags`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, "web").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE `test_case_steps` SET `deleted_at`=\\?").
+		WithArgs(sqlmock.AnyArg(), 30).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, 0, "Given the login page is open", "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 30, 1, "When I sign in with <provider>\n\"\"\"\npayload\n\"\"\"", "", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	// キーが無いシナリオは新しく作成する
+	mock.ExpectExec("^INSERT INTO `test_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, projectID, nil, "Password login", "Users with a password.", 0, "Draft", 7, 7, nil).
+		WillReturnResult(sqlmock.NewResult(31, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_tags`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, "web", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, "smoke").
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("^INSERT INTO `test_case_custom_fields`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, "scenario_key", "login/password-login").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, 0, "Given the login page is open", "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, 1, "When I enter \"alice\" and \"secret\"\n| field | value |", "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 31, 2, "Then I see the dashboard", "", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 3))
+	mock.ExpectCommit()
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/gherkin", "login.feature", []byte(loginFeature), nil)
+	w := serveImport("/protected/:project_code/cases/import/gherkin", h.PostGherkinImport, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.GherkinImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 1, response.Updated)
+	assert.Equal(t, 2, response.TestSuites)
+	assert.Equal(t, []uint{31, 30}, response.TestCaseIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostGherkinImportRejectsInvalidDryRun(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/gherkin?dry_run=yes", "login.feature", []byte("Feature: Login\n  Scenario: Password login\n    Given a user\n"), nil)
+	w := serveImport("/protected/:project_code/cases/import/gherkin", h.PostGherkinImport, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostGherkinImportWithoutFeature(t *testing.T) {
+	h, mock := setupMockImportHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, projectCode))
+
+	req := newImportRequest("/protected/"+projectCode+"/cases/import/gherkin", "broken.feature", []byte("Scenario: Orphan\n  Given nothing\n"), nil)
+	w := serveImport("/protected/:project_code/cases/import/gherkin", h.PostGherkinImport, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "broken.feature: line 1: Feature is required")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
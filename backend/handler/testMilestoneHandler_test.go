@@ -2,6 +2,8 @@ package handler_test
 
 import (
 	"backend/handler"
+	"backend/util"
+	"encoding/json"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupMockMilestoneHandler() (*handler.MilestoneHandler, sqlmock.Sqlmock) {
@@ -31,16 +34,144 @@ func setupMockMilestoneHandler() (*handler.MilestoneHandler, sqlmock.Sqlmock) {
 	return handler.NewMilestoneHandler(gormDB), mock
 }
 
+// TestGetMilestones - 紐づくテストケースの最新の結果から進捗を集計するテスト
+func TestGetMilestones(t *testing.T) {
+	h, mock := setupMockMilestoneHandler()
+	gin.SetMode(gin.TestMode)
+
+	now := time.Now()
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PRJ").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PRJ"))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "status", "due_date"}).
+			AddRow(1, 1, "Release 1", "Open", now).
+			AddRow(2, 1, "Release 0", "Open", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(3, 1, "Release 2", "Open", time.Date(2999, 12, 31, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectQuery("^SELECT id, milestone_id FROM `test_cases` WHERE milestone_id IN \\(\\?,\\?,\\?\\) AND `test_cases`.`deleted_at` IS NULL").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "milestone_id"}).
+			AddRow(10, 1).
+			AddRow(11, 1).
+			AddRow(12, 1).
+			AddRow(13, 2))
+	// テストラン 1 はテストプラン 5 のマイルストーンで実施したものとし、別のマイルストーンのテストラン 3 の結果は使わない
+	mock.ExpectQuery("^SELECT id, milestone_id FROM `test_plans` WHERE milestone_id IN \\(\\?,\\?,\\?\\) AND `test_plans`.`deleted_at` IS NULL").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "milestone_id"}).AddRow(5, 1))
+	mock.ExpectQuery("^SELECT id, milestone_id, test_plan_id FROM `test_runs` WHERE \\(milestone_id IN \\(\\?,\\?,\\?\\) OR \\(milestone_id IS NULL AND test_plan_id IN \\(\\?\\)\\)\\) AND `test_runs`.`deleted_at` IS NULL").
+		WithArgs(1, 2, 3, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "milestone_id", "test_plan_id"}).
+			AddRow(1, nil, 5).
+			AddRow(2, 1, 6).
+			AddRow(3, 2, 5))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE \\(test_case_id IN \\(\\?,\\?,\\?,\\?\\) AND test_run_id IN \\(\\?,\\?,\\?\\)\\) "+
+		"AND `test_run_cases`.`deleted_at` IS NULL ORDER BY updated_at DESC, id DESC").
+		WithArgs(10, 11, 12, 13, 1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id", "updated_at"}).
+			AddRow(5, 11, 3, 2, now).
+			AddRow(4, 10, 2, 2, now.Add(-24*time.Hour)).
+			AddRow(3, 12, 2, 1, now.Add(-24*time.Hour)).
+			AddRow(2, 11, 1, 3, now.Add(-10*24*time.Hour)).
+			AddRow(1, 10, 1, 3, now.Add(-20*24*time.Hour)))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` IN \\(\\?,\\?,\\?\\)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "color", "default"}).
+			AddRow(1, "Untested", "gray", true).
+			AddRow(2, "Passed", "green", false).
+			AddRow(3, "Failed", "red", false))
+
+	req := httptest.NewRequest("GET", "/protected/PRJ/milestones", nil)
+	w := httptest.NewRecorder()
+	router := gin.Default()
+	router.GET("/protected/:project_code/milestones", h.GetMilestones)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.MilestonesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Milestones, 3)
+
+	// 既定のステータスのままの結果は未実施として数え、10 日で 2 件の速さでは残り 1 件に 5 日かかる
+	release1 := response.Milestones[0]
+	assert.Equal(t, 3, release1.TestCaseCount)
+	forecast := now.AddDate(0, 0, 5).Format("2006-01-02")
+	assert.Equal(t, util.MilestoneStats{
+		Results:       []util.Chart{{Name: "Failed", Color: "red", Count: 1}, {Name: "Passed", Color: "green", Count: 1}},
+		Tested:        2,
+		Untested:      1,
+		Passed:        1,
+		PassRate:      50,
+		Progress:      66,
+		DaysRemaining: 0,
+		ForecastDate:  &forecast,
+		AtRisk:        true,
+	}, release1.Stats)
+
+	// 結果が無く予測できない場合は期日を過ぎていれば遅延とする
+	release0 := response.Milestones[1]
+	assert.Equal(t, 1, release0.Stats.Untested)
+	assert.Nil(t, release0.Stats.ForecastDate)
+	assert.True(t, release0.Stats.AtRisk)
+	assert.Less(t, release0.Stats.DaysRemaining, 0)
+
+	release2 := response.Milestones[2]
+	assert.Equal(t, 0, release2.TestCaseCount)
+	assert.Empty(t, release2.Stats.Results)
+	assert.False(t, release2.Stats.AtRisk)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestGetMilestone - マイルストーンで実施したテストプランとテストランの結果をまとめるテスト
 func TestGetMilestone(t *testing.T) {
 	h, mock := setupMockMilestoneHandler()
 	gin.SetMode(gin.TestMode)
 
 	milestoneID := 1
-	rows := sqlmock.NewRows([]string{"id", "title", "description"}).
-		AddRow(milestoneID, "Test Milestone 1", "Description")
-	mock.ExpectQuery("^SELECT \\* FROM `milestones`").
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE `milestones`.`id` = \\?").
+		WithArgs(milestoneID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "description", "status", "due_date"}).
+			AddRow(milestoneID, 2, "Release 2.3", "Description", "Active", time.Date(2999, 12, 31, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectQuery("^SELECT id, milestone_id FROM `test_cases` WHERE milestone_id IN \\(\\?\\)").
 		WithArgs(milestoneID).
-		WillReturnRows(rows)
+		WillReturnRows(sqlmock.NewRows([]string{"id", "milestone_id"}))
+	// テストランにマイルストーンが無い場合はテストプランのマイルストーンを使う
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE \\(milestone_id = \\? OR \\(milestone_id IS NULL AND test_plan_id IN "+
+		"\\(SELECT `id` FROM `test_plans` WHERE milestone_id = \\? AND `test_plans`.`deleted_at` IS NULL\\)\\)\\) AND `test_runs`.`deleted_at` IS NULL").
+		WithArgs(milestoneID, milestoneID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "milestone_id", "created_by_id", "updated_by_id"}).
+			AddRow(4, 2, 2, "Smoke", nil, 7, 7).
+			AddRow(6, 2, 3, "Hotfix", milestoneID, 7, 7))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`test_run_id` IN \\(\\?,\\?\\)").
+		WithArgs(4, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"}).
+			AddRow(1, 4, 10, 2).
+			AddRow(2, 6, 11, 3).
+			AddRow(3, 6, 12, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` IN \\(\\?,\\?,\\?\\)").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "color", "default"}).
+			AddRow(1, "Untested", "gray", true).
+			AddRow(2, "Passed", "green", false).
+			AddRow(3, "Failed", "red", false))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE \\(milestone_id = \\? OR id IN \\(\\?,\\?\\)\\) AND `test_plans`.`deleted_at` IS NULL").
+		WithArgs(milestoneID, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "milestone_id", "created_by_id", "updated_by_id"}).
+			AddRow(2, 2, "Regression", milestoneID, 7, 7).
+			AddRow(3, 2, "Hotfix", nil, 7, 7))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
 
 	req := httptest.NewRequest("GET", fmt.Sprintf("/protected/milestones/%d", milestoneID), nil)
 	w := httptest.NewRecorder()
@@ -49,6 +180,19 @@ func TestGetMilestone(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.MilestoneDetail
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Release 2.3", response.Title)
+	assert.Equal(t, "2999-12-31", response.DueDate)
+	assert.Len(t, response.TestPlans, 2)
+	assert.Len(t, response.TestRuns, 2)
+	assert.Equal(t, uint(3), response.TestRuns[1].TestPlanID)
+	assert.Equal(t, []util.Chart{
+		{Name: "Failed", Color: "red", Count: 1},
+		{Name: "Passed", Color: "green", Count: 1},
+		{Name: "Untested", Color: "gray", Count: 1},
+	}, response.Charts)
+	assert.Equal(t, 67, response.Percentage)
 	err := mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
@@ -2,6 +2,8 @@ package handler_test
 
 import (
 	"backend/handler"
+	"backend/util"
+	"encoding/json"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupMockTestPlanHandler() (*handler.TestPlanHandler, sqlmock.Sqlmock) {
@@ -84,7 +87,7 @@ func TestPostTestPlan(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO `test_plans`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "Active", "New Plan", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "Active", "New Plan", sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 1, nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -123,14 +126,113 @@ func TestPutTestPlan(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestGetTestPlansByMilestone - マイルストーンで実施するテストプランだけを返すテスト
+func TestGetTestPlansByMilestone(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PRJ").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(2, "PRJ"))
+	// テストランだけをマイルストーンに紐づけたテストプランも含める
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE project_id = \\? AND \\(milestone_id = \\? OR id IN "+
+		"\\(SELECT `test_plan_id` FROM `test_runs` WHERE milestone_id = \\? AND `test_runs`.`deleted_at` IS NULL\\)\\) AND `test_plans`.`deleted_at` IS NULL").
+		WithArgs(2, 3, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "milestone_id", "created_by_id", "updated_by_id"}).
+			AddRow(1, 2, "Regression", 3, 7, 7))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(7, "User"))
+
+	req := httptest.NewRequest("GET", "/protected/PRJ/plans?milestone_id=3", nil)
+	w := httptest.NewRecorder()
+	router := gin.Default()
+	router.GET("/protected/:project_code/plans", h.GetTestPlans)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestPlansResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.TestPlans, 1)
+	assert.Equal(t, uint(3), *response.TestPlans[0].MilestoneID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPostTestPlanRejectsForeignMilestone - 別のプロジェクトのマイルストーンには紐づけないテスト
+func TestPostTestPlanRejectsForeignMilestone(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `milestones` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	body := strings.NewReader(`{"project_id": 1, "title": "New Plan", "status": "Active", "milestone_id": 9}`)
+	req := httptest.NewRequest("POST", "/plans", body)
+	w := httptest.NewRecorder()
+	router := gin.Default()
+	router.POST("/plans", h.PostTestPlan)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestPutTestPlanMilestone - 指定したマイルストーンに紐づけ直すテスト
+func TestPutTestPlanMilestone(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	testPlanID := 1
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE `test_plans`.`id` = \\?").
+		WithArgs(testPlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id"}).AddRow(testPlanID, 2))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `milestones` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_plans` SET `updated_at`=\\?,`title`=\\? WHERE id = \\?").
+		WithArgs(sqlmock.AnyArg(), "Updated Plan", testPlanID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_plans` SET `milestone_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(3, sqlmock.AnyArg(), testPlanID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := strings.NewReader(`{"title": "Updated Plan", "milestone_id": 3}`)
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/protected/plans/%d", testPlanID), body)
+	w := httptest.NewRecorder()
+	router := gin.Default()
+	router.PUT("/protected/plans/:id", h.PutTestPlan)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestDeletePlan(t *testing.T) {
 	h, mock := setupMockTestPlanHandler()
 	gin.SetMode(gin.TestMode)
 
 	testPlanID := 1
+	deletedAt := &sameTime{}
 	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT `id` FROM `test_runs`").
+		WithArgs(testPlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3))
+	mock.ExpectExec("^UPDATE `test_run_cases`").
+		WithArgs(deletedAt, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("^UPDATE `test_runs`").
+		WithArgs(deletedAt, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
 	mock.ExpectExec("^UPDATE `test_plans`").
-		WithArgs(sqlmock.AnyArg(), testPlanID).
+		WithArgs(deletedAt, testPlanID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
@@ -144,3 +246,390 @@ func TestDeletePlan(t *testing.T) {
 	err := mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+// expectLargeTestPlan は numRuns 個のランがそれぞれ casesPerRun 件のケースを持つ合成データのクエリを登録する
+func expectLargeTestPlan(mock sqlmock.Sqlmock, numRuns, casesPerRun int) {
+	testPlanID := 1
+	projectID := 1
+	statusID := 1
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans`").
+		WithArgs(testPlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(testPlanID, projectID, "Large Plan"))
+
+	testRunRows := sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title"})
+	testRunCaseRows := sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"})
+	for run := 1; run <= numRuns; run++ {
+		testRunRows.AddRow(run, projectID, testPlanID, fmt.Sprintf("Run %d", run))
+		for i := 1; i <= casesPerRun; i++ {
+			testRunCaseRows.AddRow((run-1)*casesPerRun+i, run, i, statusID)
+		}
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs`").
+		WithArgs(testPlanID).
+		WillReturnRows(testRunRows)
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(anyArgs(numRuns)...).
+		WillReturnRows(testRunCaseRows)
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(statusID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "color", "default"}).AddRow(statusID, "Untested", "gray", true))
+}
+
+// TestGetTestPlanLargePlan はランやケースの数が変わってもクエリの件数（4 件）が変わらないことを確かめる
+func TestGetTestPlanLargePlan(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		numRuns, casesPerRun int
+	}{
+		{"small", 1, 1},
+		{"large", 20, 250},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, mock := setupMockTestPlanHandler()
+			gin.SetMode(gin.TestMode)
+
+			expectLargeTestPlan(mock, tc.numRuns, tc.casesPerRun)
+
+			req := httptest.NewRequest("GET", "/protected/plans/1", nil)
+			w := httptest.NewRecorder()
+			router := gin.New()
+			router.GET("/protected/plans/:id", h.GetTestPlan)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			var responseData util.TestPlanDetail
+			err := json.Unmarshal(w.Body.Bytes(), &responseData)
+			assert.NoError(t, err)
+			assert.Len(t, responseData.TestRuns, tc.numRuns)
+			assert.Equal(t, tc.casesPerRun, responseData.TestRuns[0].Count)
+			assert.Len(t, responseData.TestRuns[0].TestCaseIDs, tc.casesPerRun)
+		})
+	}
+}
+
+func cloneTestPlan(h *handler.TestPlanHandler, testPlanID int, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/plans/:id/clone", h.CloneTestPlan)
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/protected/plans/%d/clone", testPlanID), strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCloneTestPlan(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	testPlanID := 1
+	projectID := 2
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans`").
+		WithArgs(testPlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "status", "started_at"}).
+			AddRow(testPlanID, projectID, "Regression 1", "Completed", time.Now()))
+	runCreatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`test_plan_id` = \\?").
+		WithArgs(testPlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "status", "created_at"}).
+			AddRow(4, projectID, testPlanID, "Smoke", "Completed", runCreatedAt))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`test_run_id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "assigned_to_id", "status_id", "parameter_index", "parameters"}).
+			AddRow(1, 4, 10, 3, 6, nil, "").
+			AddRow(2, 4, 11, nil, 6, 0, `[{"name":"locale","value":"ja"}]`).
+			AddRow(3, 4, 11, nil, 6, 1, `[{"name":"locale","value":"en"}]`).
+			AddRow(4, 4, 14, nil, 6, nil, ""))
+	// 元のランに追加した後で廃止されたケースは複製しない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` IN \\(\\?,\\?,\\?\\)").
+		WithArgs(10, 11, 14).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "state"}).
+			AddRow(10, 1, "Approved").
+			AddRow(11, 1, "Draft").
+			AddRow(14, 1, "Deprecated"))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE statuses.default = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "default"}).AddRow(5, "Untested", true))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+
+	// 状態は初期の状態に戻し、開始・終了日時は空にする
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_plans`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, "NotExecuted", "Regression 2", nil, nil, 7, 7, nil).
+		WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("^INSERT INTO `test_runs`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, projectID, 20, "Smoke", nil, nil, 7, 7, "NotExecuted", "", false, nil).
+		WillReturnResult(sqlmock.NewResult(30, 1))
+	// 元のランの作成後に同じスイートへ追加されたケースを探す
+	// 廃止したケースは加えない
+	mock.ExpectQuery("^SELECT `id`,`state` FROM `test_cases` WHERE \\(project_id = \\? AND test_suite_id IN \\(\\?\\) AND created_at > \\?\\)").
+		WithArgs(projectID, 1, runCreatedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(10, "Approved").AddRow(12, "Draft").AddRow(13, "Deprecated"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}).
+			AddRow(1, 12, `["payment"]`, `[["card"],["paypal"]]`))
+	// 担当者は引き継ぎ、結果は既定のステータスに戻す。パラメータ表の行ごとのケースは行の値ごと複製する
+	mock.ExpectExec("^INSERT INTO `test_run_cases`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 30, 3, 5, nil, "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 11, 30, nil, 5, 0, `[{"name":"locale","value":"ja"}]`, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 11, 30, nil, 5, 1, `[{"name":"locale","value":"en"}]`, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 12, 30, nil, 5, 0, `[{"name":"payment","value":"card"}]`, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 12, 30, nil, 5, 1, `[{"name":"payment","value":"paypal"}]`, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(100, 5))
+	mock.ExpectCommit()
+
+	w := cloneTestPlan(h, testPlanID, `{"title": "Regression 2", "include_new_cases": true}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.TestPlanCloneResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(20), response.ID)
+	assert.Equal(t, []util.TransferredID{{SourceID: 4, ID: 30}}, response.TestRuns)
+	assert.Equal(t, 1, response.AddedTestCases)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloneTestPlanSkipsDeletedTestCase(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "status"}).AddRow(1, 2, "Regression 1", "Completed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`test_plan_id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "status"}).AddRow(4, 2, 1, "Smoke", "Completed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`test_run_id` = \\?").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"}).
+			AddRow(1, 4, 10, 6).
+			AddRow(2, 4, 15, 6))
+	// 元のランに追加した後で削除されたケース15は見つからない
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` IN \\(\\?,\\?\\) AND `test_cases`.`deleted_at` IS NULL").
+		WithArgs(10, 15).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "state"}).AddRow(10, 1, "Draft"))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE statuses.default = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "default"}).AddRow(5, "Untested", true))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_plans`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "NotExecuted", "Regression 2", nil, nil, 7, 7, nil).
+		WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("^INSERT INTO `test_runs`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 20, "Smoke", nil, nil, 7, 7, "NotExecuted", "", false, nil).
+		WillReturnResult(sqlmock.NewResult(30, 1))
+	mock.ExpectExec("^INSERT INTO `test_run_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 30, nil, 5, nil, "", nil).
+		WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectCommit()
+
+	w := cloneTestPlan(h, 1, `{"title": "Regression 2"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloneTestPlanWithoutTitle(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	w := cloneTestPlan(h, 1, `{"title": " "}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestPlanSchedule(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_plans` WHERE \\(id = \\? AND project_id = \\?\\)").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(7, "user@example.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_plan_schedules`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, "Smoke {date}", "cron", "0 9 * * 1", 0, false, true, nil, nil, 7, 7).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+	// 月曜 9:00 の次回の実行時刻を返す
+	updatedAt := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+	mock.ExpectQuery("^SELECT \\* FROM `test_plan_schedules` WHERE `test_plan_schedules`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "trigger_type", "cron", "active", "updated_at"}).
+			AddRow(5, 2, 1, "Smoke {date}", "cron", "0 9 * * 1", true, updatedAt))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE `test_plans`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "Smoke"))
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/plans/schedules", h.PostTestPlanSchedule)
+	body := `{"project_id": 2, "test_plan_id": 1, "title": "Smoke {date}", "trigger": "cron", "cron": "0 9 * * 1"}`
+	req := httptest.NewRequest("POST", "/protected/plans/schedules", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.TestPlanSchedule
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Smoke", response.TestPlanTitle)
+	if assert.NotNil(t, response.NextRunAt) {
+		assert.Equal(t, "2024-01-08 09:00", *response.NextRunAt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostTestPlanScheduleInvalidCron(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.POST("/protected/plans/schedules", h.PostTestPlanSchedule)
+	body := `{"project_id": 2, "test_plan_id": 1, "trigger": "cron", "cron": "0 25 * * *"}`
+	req := httptest.NewRequest("POST", "/protected/plans/schedules", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectDueTestPlanSchedule(mock sqlmock.Sqlmock) {
+	// 月曜 8:00 に更新したスケジュールは、同じ日の 9:00 に実行する
+	mock.ExpectQuery("^SELECT \\* FROM `test_plan_schedules` WHERE active = \\?").
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "trigger_type", "cron", "active", "created_by_id", "updated_at"}).
+			AddRow(1, 2, 1, "Smoke {date}", "cron", "0 9 * * 1", true, 3, time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local)))
+}
+
+func TestRunDueTestPlanSchedules(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	sender := &MockEmailSender{}
+	now := time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)
+
+	expectDueTestPlanSchedule(mock)
+	mock.ExpectBegin()
+	// 読み込んだ時の updated_at のままの場合だけ実行する
+	mock.ExpectExec("^UPDATE `test_plan_schedules` SET `last_run_at`=\\?,`updated_at`=\\? WHERE \\(id = \\? AND updated_at = \\?\\)").
+		WithArgs(now, now, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE `test_plans`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(1, 2, "Smoke"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title"}).AddRow(4, 2, 1, "Login"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "assigned_to_id", "status_id"}).AddRow(1, 4, 10, 8, 6))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(10, "Approved"))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "default"}).AddRow(5, true))
+	mock.ExpectExec("^INSERT INTO `test_plans`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, "NotExecuted", "Smoke 2024-01-01", nil, nil, 3, 3, nil).
+		WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("^INSERT INTO `test_runs`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 20, "Login", nil, nil, 3, 3, "NotExecuted", "", false, nil).
+		WillReturnResult(sqlmock.NewResult(30, 1))
+	mock.ExpectExec("^INSERT INTO `test_run_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 30, 8, 5, nil, "", nil).
+		WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectExec("^UPDATE `test_plan_schedules` SET `last_test_plan_id`=\\?").
+		WithArgs(20, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// 担当者に通知する
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE id IN \\(\\?\\)").
+		WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(8, "tester@example.com"))
+
+	assert.NoError(t, handler.RunDueTestPlanSchedules(h.DB, sender, now))
+	assert.Equal(t, []string{`Test plan "Smoke 2024-01-01" has been created`}, sender.SentEmails)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunDueTestPlanSchedulesClaimedByAnotherReplica(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	sender := &MockEmailSender{}
+	now := time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)
+
+	expectDueTestPlanSchedule(mock)
+	mock.ExpectBegin()
+	// 他のレプリカが先に更新していれば複製しない
+	mock.ExpectExec("^UPDATE `test_plan_schedules` SET `last_run_at`=\\?,`updated_at`=\\?").
+		WithArgs(now, now, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, handler.RunDueTestPlanSchedules(h.DB, sender, now))
+	assert.Empty(t, sender.SentEmails)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunDueTestPlanSchedulesNotYetDue(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	sender := &MockEmailSender{}
+
+	expectDueTestPlanSchedule(mock)
+
+	assert.NoError(t, handler.RunDueTestPlanSchedules(h.DB, sender, time.Date(2024, 1, 1, 8, 59, 0, 0, time.Local)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTestPlanSchedulesWithMilestoneTrigger(t *testing.T) {
+	h, mock := setupMockTestPlanHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(2, "PROJECT"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plan_schedules` WHERE project_id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "title", "trigger_type", "days_before_due", "active", "updated_at"}).
+			AddRow(1, 2, 1, "Regression {milestone}", "milestone", 2, true, time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE `test_plans`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(1, "Regression"))
+	// 期日の2日前が更新時刻より後の最初のマイルストーンで実行する
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE \\(project_id IN \\(\\?\\) AND status <> \\?\\)").
+		WithArgs(2, "Completed").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "due_date"}).
+			AddRow(3, 2, "Sprint 1", time.Date(2024, 1, 12, 0, 0, 0, 0, time.Local)).
+			AddRow(4, 2, "Sprint 2", time.Date(2024, 1, 26, 0, 0, 0, 0, time.Local)))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/plans/schedules", h.GetTestPlanSchedules)
+	req := httptest.NewRequest("GET", "/protected/PROJECT/plans/schedules", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TestPlanSchedulesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Schedules, 1) && assert.NotNil(t, response.Schedules[0].NextRunAt) {
+		assert.Equal(t, "2024-01-24 00:00", *response.Schedules[0].NextRunAt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -69,6 +69,9 @@ func TestGetProject(t *testing.T) {
 	mock.ExpectQuery("^SELECT \\* FROM `users`").
 		WithArgs(updatedByUserID).
 		WillReturnRows(updatedByUserRows)
+	mock.ExpectQuery("^SELECT id, milestone_id FROM `test_cases` WHERE milestone_id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "milestone_id"}))
 
 	req := httptest.NewRequest("GET", fmt.Sprintf("/protected/projects/%s", ProjectCode), nil)
 	w := httptest.NewRecorder()
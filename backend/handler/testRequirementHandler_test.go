@@ -0,0 +1,246 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/util"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setupMockRequirementHandler() (*handler.RequirementHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewRequirementHandler(gormDB), mock
+}
+
+func postRequirement(h *handler.RequirementHandler, body string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.POST("/protected/requirements", h.PostRequirement)
+	req := httptest.NewRequest("POST", "/protected/requirements", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func expectRequirementProject(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE `projects`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+}
+
+func TestPostRequirement(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectRequirementProject(mock)
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `requirements` WHERE \\(project_id = \\? AND requirement_key = \\? AND id <> \\?\\)").
+		WithArgs(1, "REQ-1", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_cases` WHERE \\(project_id = \\? AND id IN \\(\\?,\\?\\)\\)").
+		WithArgs(1, 10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `requirements`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, "REQ-1", "Login", "Users can log in", "https://example.com/REQ-1").
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec("^DELETE FROM `requirement_test_cases` WHERE requirement_id = \\?").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// 同じテストケースは一度だけ紐づける
+	mock.ExpectExec("^INSERT INTO `requirement_test_cases`").
+		WithArgs(5, 10, 5, 11).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `requirements` WHERE `requirements`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "requirement_key", "title"}).AddRow(5, 1, "REQ-1", "Login"))
+	mock.ExpectQuery("^SELECT \\* FROM `requirement_test_cases` WHERE `requirement_test_cases`.`requirement_id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"requirement_id", "test_case_id"}).AddRow(5, 10).AddRow(5, 11))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(10, "Login succeeds").AddRow(11, "Login fails"))
+
+	w := postRequirement(h, `{"project_id": 1, "key": " REQ-1 ", "title": "Login", "description": "Users can log in", "url": "https://example.com/REQ-1", "test_case_ids": [10, 11, 10]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.Requirement
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "REQ-1", response.Key)
+	assert.Equal(t, []uint{10, 11}, response.TestCaseIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostRequirementDuplicateKey(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	expectRequirementProject(mock)
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `requirements`").
+		WithArgs(1, "REQ-1", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	w := postRequirement(h, `{"project_id": 1, "key": "REQ-1", "title": "Login"}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostRequirementProjectNotFound(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE `projects`.`id` = \\?").
+		WithArgs(99).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	w := postRequirement(h, `{"project_id": 99, "key": "REQ-1", "title": "Login"}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportRequirements(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT \\* FROM `requirements` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "requirement_key", "title"}).AddRow(5, 1, "REQ-1", "Old title"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `requirements`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, "REQ-2", "Logout", "", "").
+		WillReturnResult(sqlmock.NewResult(6, 1))
+	// 既にあるキーの要件は更新する
+	mock.ExpectExec("^UPDATE `requirements` SET `description`=\\?,`title`=\\?,`url`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs("Users can log in", "Login", "https://example.com/REQ-1", sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	content := "Key,Title,Description,URL\nREQ-1,Login,Users can log in,https://example.com/REQ-1\nREQ-2,Logout,,\n"
+	req := newImportRequest("/protected/PROJECT/requirements/import", "requirements.csv", []byte(content), nil)
+	w := serveImport("/protected/:project_code/requirements/import", h.ImportRequirements, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.RequirementImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, util.RequirementImportResult{Requirements: 2, Created: 1, Updated: 1, Errors: []util.TestCaseImportError{}}, response)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportRequirementsValidationError(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT \\* FROM `requirements` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "requirement_key", "title"}))
+
+	content := "key,title\nREQ-1,Login\nREQ-1,Login again\n,No key\n"
+	req := newImportRequest("/protected/PROJECT/requirements/import", "requirements.csv", []byte(content), nil)
+	w := serveImport("/protected/:project_code/requirements/import", h.ImportRequirements, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response util.RequirementImportResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []util.TestCaseImportError{
+		{Row: 3, Field: "key", Message: "duplicate key (row 2)"},
+		{Row: 4, Field: "key", Message: "key is required"},
+	}, response.Errors)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportRequirementsRejectsInvalidDryRun(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+
+	req := newImportRequest("/protected/PROJECT/requirements/import?dry_run=yes", "requirements.csv", []byte("key,title\nREQ-1,Login\n"), nil)
+	w := serveImport("/protected/:project_code/requirements/import", h.ImportRequirements, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTraceability(t *testing.T) {
+	h, mock := setupMockRequirementHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects`").
+		WithArgs("PROJECT").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PROJECT"))
+	mock.ExpectQuery("^SELECT \\* FROM `requirements` WHERE project_id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "requirement_key", "title"}).
+			AddRow(5, 1, "REQ-1", "Login").
+			AddRow(6, 1, "REQ-2", "Logout").
+			AddRow(7, 1, "REQ-3", "Audit log"))
+	mock.ExpectQuery("^SELECT \\* FROM `requirement_test_cases` WHERE `requirement_test_cases`.`requirement_id` IN \\(\\?,\\?,\\?\\)").
+		WithArgs(5, 6, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"requirement_id", "test_case_id"}).AddRow(5, 10).AddRow(6, 11))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(10, 11).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(10, "Login succeeds").AddRow(11, "Logout succeeds"))
+	// 指定したプランのテストランの結果だけを見る
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE \\(test_case_id IN \\(\\?,\\?\\) AND test_run_id IN \\(SELECT `id` FROM `test_runs` WHERE project_id = \\? AND test_plan_id = \\?").
+		WithArgs(10, 11, 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"}).
+			AddRow(1, 20, 10, 2).
+			AddRow(2, 20, 11, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` IN \\(\\?,\\?\\)").
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "color", "default"}).
+			AddRow(1, "Untested", "gray", true).
+			AddRow(2, "Passed", "green", false))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/requirements/traceability", h.GetTraceability)
+	req := httptest.NewRequest("GET", "/protected/PROJECT/requirements/traceability?test_plan_id=3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TraceabilityMatrix
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, util.TraceabilitySummary{Requirements: 3, Tested: 1, Untested: 1, Uncovered: 1, Percentage: 33}, response.Summary)
+	if assert.Len(t, response.Requirements, 3) {
+		assert.Equal(t, "tested", response.Requirements[0].Coverage)
+		assert.Equal(t, "Passed", response.Requirements[0].TestCases[0].Status.Name)
+		// 既定のステータスのままの結果は未実施として扱う
+		assert.Equal(t, "untested", response.Requirements[1].Coverage)
+		assert.Nil(t, response.Requirements[1].TestCases[0].Status)
+		assert.Equal(t, "uncovered", response.Requirements[2].Coverage)
+		assert.Empty(t, response.Requirements[2].TestCases)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
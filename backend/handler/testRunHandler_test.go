@@ -5,6 +5,7 @@ import (
 	"backend/model"
 	"backend/util"
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
@@ -14,6 +15,7 @@ import (
 	"gorm.io/gorm"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -128,6 +130,12 @@ func TestGetTestRunCase(t *testing.T) {
 		WithArgs(updatedByUserID).
 		WillReturnRows(updatedByUserRows)
 
+	// テスト手順を取得
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(1, testCaseID, 0, "Open the page", "The page is shown"))
+
 	// Set up HTTP request
 	r := gin.Default()
 	r.GET("/protected/runs/cases/:id", h.GetTestRunCase)
@@ -152,6 +160,7 @@ func TestGetTestRunCase(t *testing.T) {
 	assert.Equal(t, "Assigned User", responseData.AssignedTo.Name)
 	assert.Equal(t, "Test Case Title", responseData.Title)
 	assert.Equal(t, "Test Content", responseData.Content)
+	assert.Equal(t, []util.TestCaseStep{{Action: "Open the page", ExpectedResult: "The page is shown"}}, responseData.Steps)
 }
 
 func TestGetTestRunCases(t *testing.T) {
@@ -175,18 +184,6 @@ func TestGetTestRunCases(t *testing.T) {
 		WithArgs(testRunID).
 		WillReturnRows(testRunRow)
 
-	testRunCaseStatusRows := sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id", "assigned_to_id"}).
-		AddRow(testRunCaseID, testRunID, testCaseID, statusID, assignedToID)
-	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
-		WithArgs(testRunID).
-		WillReturnRows(testRunCaseStatusRows)
-
-	testRunStatusRows := sqlmock.NewRows([]string{"id", "name"}).
-		AddRow(statusID, "Status Name")
-	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
-		WithArgs(statusID).
-		WillReturnRows(testRunStatusRows)
-
 	// TestRunCaseテーブルからのクエリを期待
 	testRunCaseRows := sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id", "assigned_to_id"}).
 		AddRow(testRunCaseID, testRunID, testCaseID, statusID, assignedToID)
@@ -221,23 +218,17 @@ func TestGetTestRunCases(t *testing.T) {
 		WithArgs(testCaseID).
 		WillReturnRows(testCaseRows)
 
-	// TestSuiteテーブルからのクエリを期待
-	testSuiteRows := sqlmock.NewRows([]string{"id", "name"}).
-		AddRow(testSuiteID, "TestSuites Name")
-	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
-		WithArgs(testSuiteID).
-		WillReturnRows(testSuiteRows)
-
-	// TestSuiteテーブルからのクエリを期待
-	testSuiteNewRows := sqlmock.NewRows([]string{"id", "name", "project_id", "parent_id"}).
-		AddRow(3, "TestSuites Name", 1, nil)
-	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
-		WithArgs(testSuiteID).
-		WillReturnRows(testSuiteNewRows)
+	// ラン内のテストケースの手順を一括で取得する
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}))
 
+	// プロジェクトのTestSuiteを一括で取得する
+	testSuiteRows := sqlmock.NewRows([]string{"id", "name", "project_id", "parent_id"}).
+		AddRow(testSuiteID, "TestSuites Name", projectID, nil)
 	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
 		WithArgs(projectID).
-		WillReturnRows(testSuiteNewRows)
+		WillReturnRows(testSuiteRows)
 
 	// Set up HTTP request
 	r := gin.Default()
@@ -267,6 +258,62 @@ func TestGetTestRunCases(t *testing.T) {
 	assert.Equal(t, "Assigned User Name", responseData.TestSuites[0].TestCases[0].AssignedTo.Name)
 }
 
+func TestGetTestRunCasesGroupsParameterIterations(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "project_id"}).AddRow(1, "Checkout", 10))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id", "parameter_index", "parameters"}).
+			AddRow(8, 1, 2, 4, 0, `[{"name":"locale","value":"ja"}]`).
+			AddRow(9, 1, 2, 5, 1, `[{"name":"locale","value":"en"}]`))
+	mock.ExpectQuery("^SELECT \\* FROM `comments`").
+		WithArgs(8, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_case_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(4, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Passed").AddRow(5, "Failed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_suite_id", "title", "content"}).
+			AddRow(2, 3, "Checkout succeeds", "Locale: {{locale}}"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 2, 0, "Open the {{locale}} store", "Prices are shown for {{locale}}"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "project_id", "parent_id"}).AddRow(3, "Checkout", 10, nil))
+
+	r := gin.Default()
+	r.GET("/protected/runs/:id/cases", h.GetTestRunCases)
+	req, _ := http.NewRequest("GET", "/protected/runs/1/cases", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	var responseData util.TestRunCasesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &responseData))
+	// 行ごとのケースは元のテストケースの下にまとめ、結果が異なる場合はまとめた項目の状態を空にする
+	assert.Len(t, responseData.TestSuites[0].TestCases, 1)
+	parent := responseData.TestSuites[0].TestCases[0]
+	assert.Equal(t, uint(0), parent.ID)
+	assert.Equal(t, "Checkout succeeds", parent.Title)
+	assert.Equal(t, uint(0), parent.Status.ID)
+	assert.Len(t, parent.Iterations, 2)
+	iteration := parent.Iterations[1]
+	assert.Equal(t, uint(9), iteration.ID)
+	assert.Equal(t, "Failed", iteration.Status.Name)
+	assert.Equal(t, "Locale: en", iteration.Content)
+	assert.Equal(t, []util.TestCaseStep{{Action: "Open the en store", ExpectedResult: "Prices are shown for en"}}, iteration.Steps)
+	assert.Equal(t, []util.TestRunCaseParameter{{Name: "locale", Value: "en"}}, iteration.Parameters)
+	assert.Equal(t, "Open the ja store", parent.Iterations[0].Steps[0].Action)
+}
+
 func TestPostTestRun(t *testing.T) {
 	h, mock := setupMockTestRunHandler()
 	gin.SetMode(gin.TestMode)
@@ -278,7 +325,7 @@ func TestPostTestRun(t *testing.T) {
 	}
 	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO `test_runs`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -316,9 +363,18 @@ func TestPostTestRunCase(t *testing.T) {
 		AssignedToID: nil,
 		TestRunID:    1,
 	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "approved_only"}).AddRow(1, false))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(1, "Draft"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}))
 	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO `test_run_cases`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, 1, nil, 1, nil, "", nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -362,6 +418,18 @@ func TestPutTestRun(t *testing.T) {
 		WithArgs(sqlmock.AnyArg(), updatedData.ProjectID, updatedData.Title, testRunID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	// milestone_id に null を指定した場合はテストプランのマイルストーンに戻す
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_runs` SET `milestone_id`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(nil, sqlmock.AnyArg(), testRunID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// approved_only は false でも指定した値で更新する
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_runs` SET `approved_only`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(false, sqlmock.AnyArg(), testRunID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	// HTTPリクエストの設定
 	r := gin.Default()
@@ -399,6 +467,15 @@ func TestPutTestRunCase(t *testing.T) {
 	mock.ExpectExec("^UPDATE `test_run_cases`").
 		WithArgs(sqlmock.AnyArg(), updatedTestRunCase.TestCaseID, updatedTestRunCase.TestRunID, updatedTestRunCase.StatusID, testRunCaseID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`id` = \\?").
+		WithArgs(testRunCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id"}).AddRow(testRunCaseID, 2, 3, 5))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` = \\?").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "Skipped"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(2, "Create order"))
 	mock.ExpectCommit()
 
 	// HTTPリクエストの設定
@@ -423,6 +500,117 @@ func TestPutTestRunCase(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestPutTestRunCaseBlocksDependents(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	// 結果と前提条件にしているケースの変更は一つのトランザクションで行う
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_run_cases`").
+		WithArgs(sqlmock.AnyArg(), 3, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id"}).AddRow(1, 2, 3, 3))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "Failed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(2, "Create order"))
+	mock.ExpectQuery("^SELECT `test_case_id` FROM `test_case_dependencies` WHERE prerequisite_id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id"}).AddRow(4).AddRow(6))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE name = \\?").
+		WithArgs("Blocked").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "Blocked"))
+	// 既に Blocked のケースは変更しない
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE \\(test_run_id = \\? AND test_case_id IN \\(\\?,\\?\\)\\)").
+		WithArgs(3, 4, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id"}).
+			AddRow(7, 4, 3, 1).
+			AddRow(8, 6, 3, 5))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `blocked_from_status_id`=\\?,`status_id`=\\?").
+		WithArgs(1, 5, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `comments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 5, `Blocked because the prerequisite "Create order" failed.`, 1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.PUT("/protected/runs/cases/:id", h.PutTestRunCase)
+	req, _ := http.NewRequest("PUT", "/protected/runs/cases/1", strings.NewReader(`{"status_id": 3}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutTestRunCaseRestoresDependents(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	// 結果と前提条件にしているケースの変更は一つのトランザクションで行う
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_run_cases`").
+		WithArgs(sqlmock.AnyArg(), 2, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE `test_run_cases`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id"}).AddRow(1, 2, 3, 2))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE `statuses`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Passed"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(2, "Create order"))
+	mock.ExpectQuery("^SELECT `test_case_id` FROM `test_case_dependencies` WHERE prerequisite_id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id"}).AddRow(4).AddRow(6))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses` WHERE name = \\?").
+		WithArgs("Blocked").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(5, "Blocked"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases` WHERE \\(test_run_id = \\? AND test_case_id IN \\(\\?,\\?\\)\\)").
+		WithArgs(3, 4, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "test_run_id", "status_id", "blocked_from_status_id"}).
+			AddRow(7, 4, 3, 5, 1).
+			AddRow(8, 6, 3, 5, 1))
+	// テストケース6は他の前提条件がまだ失敗している
+	mock.ExpectQuery("^SELECT DISTINCT `test_case_dependencies`.`test_case_id` FROM `test_case_dependencies` JOIN test_run_cases").
+		WithArgs(3, 4, 6, "Failed").
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id"}).AddRow(6))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `blocked_from_status_id`=\\?,`status_id`=\\?").
+		WithArgs(nil, 1, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO `comments`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 1, `Unblocked because the prerequisite "Create order" passed.`, 1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.PUT("/protected/runs/cases/:id", h.PutTestRunCase)
+	req, _ := http.NewRequest("PUT", "/protected/runs/cases/1", strings.NewReader(`{"status_id": 2}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestDeleteTestRunCase(t *testing.T) {
 	h, mock := setupMockTestRunHandler()
 	gin.SetMode(gin.TestMode)
@@ -460,9 +648,13 @@ func TestDeleteTestRun(t *testing.T) {
 	testRunID := 1
 
 	// モックの期待を設定
+	deletedAt := &sameTime{}
 	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_run_cases`").
+		WithArgs(deletedAt, testRunID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
 	mock.ExpectExec("^UPDATE `test_runs`").
-		WithArgs(sqlmock.AnyArg(), testRunID).
+		WithArgs(deletedAt, testRunID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
@@ -493,6 +685,10 @@ func TestPostTestRunCaseBulk(t *testing.T) {
 		TestRunID:   1,
 	}
 
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`id` = \\?").
+		WithArgs(requestBody.TestRunID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "approved_only"}).AddRow(1, false))
+
 	// 既存のTestRunCasesのSELECTクエリを模擬
 	existingTestRunCasesRows := sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"})
 	// 既存のデータがある場合、ここで行を追加してください。例えば:
@@ -500,12 +696,25 @@ func TestPostTestRunCaseBulk(t *testing.T) {
 	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
 		WithArgs(requestBody.TestRunID).
 		WillReturnRows(existingTestRunCasesRows)
+	mock.ExpectQuery("^SELECT `id`,`state` FROM `test_cases` WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(1, "Draft").AddRow(2, "Approved").AddRow(3, "InReview"))
+	// テストケース 2 はパラメータ表の行ごとに追加する
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters` WHERE test_case_id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}).
+			AddRow(1, 2, `["locale","payment"]`, `[["ja","card"],["en","paypal"]]`))
 
 	// TestRunCasesのINSERTクエリを模擬
 	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO `test_run_cases`").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 3)) // 3件の挿入を模擬
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, 1, nil, sqlmock.AnyArg(), nil, "", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, nil, sqlmock.AnyArg(), 0, `[{"name":"locale","value":"ja"},{"name":"payment","value":"card"}]`, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, nil, sqlmock.AnyArg(), 1, `[{"name":"locale","value":"en"},{"name":"payment","value":"paypal"}]`, nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, 1, nil, sqlmock.AnyArg(), nil, "", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 4)) // 4件の挿入を模擬
 	mock.ExpectCommit()
 
 	// HTTPリクエストの設定
@@ -642,3 +851,211 @@ func TestDeleteTestRunCaseComment(t *testing.T) {
 	// モックの期待が満たされたか確認
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func anyArgs(n int) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	return args
+}
+
+// expectLargeTestRunCases は numSuites 個のスイート（depth 階層）と numCases 件のランケースを持つ合成データのクエリを登録する。
+// 登録するクエリは 7 件で、ケース数や階層の深さに関わらず発行されるクエリはこれだけでなければならない
+func expectLargeTestRunCases(mock sqlmock.Sqlmock, numSuites, numCases, depth int) {
+	testRunID := 1
+	projectID := 1
+	statusID := 1
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs`").
+		WithArgs(testRunID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "project_id"}).AddRow(testRunID, "Large Run", projectID))
+
+	testRunCaseRows := sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"})
+	testCaseRows := sqlmock.NewRows([]string{"id", "test_suite_id", "title", "order_index"})
+	for i := 1; i <= numCases; i++ {
+		testRunCaseRows.AddRow(i, testRunID, i, statusID)
+		testCaseRows.AddRow(i, i%numSuites+1, fmt.Sprintf("Case %d", i), i)
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(testRunID).
+		WillReturnRows(testRunCaseRows)
+	mock.ExpectQuery("^SELECT \\* FROM `comments`").
+		WithArgs(anyArgs(numCases)...).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_case_id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(statusID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "color", "default"}).AddRow(statusID, "Untested", "gray", true))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases`").
+		WithArgs(anyArgs(numCases)...).
+		WillReturnRows(testCaseRows)
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN").
+		WithArgs(anyArgs(numCases)...).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id"}))
+
+	testSuiteRows := sqlmock.NewRows([]string{"id", "name", "project_id", "parent_id", "order_index"})
+	for i := 1; i <= numSuites; i++ {
+		var parentID interface{}
+		if (i-1)%depth != 0 {
+			parentID = i - 1
+		}
+		testSuiteRows.AddRow(i, fmt.Sprintf("Suite %d", i), projectID, parentID, i)
+	}
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites`").
+		WithArgs(projectID).
+		WillReturnRows(testSuiteRows)
+}
+
+// TestGetTestRunCasesLargeRun はラン上のケースの数やスイートの深さが変わってもクエリの件数が変わらないことを確かめる。
+// 登録した以外のクエリを発行すると sqlmock がエラーを返し、足りない場合は ExpectationsWereMet が失敗する
+func TestGetTestRunCasesLargeRun(t *testing.T) {
+	for _, tc := range []struct {
+		name                       string
+		numSuites, numCases, depth int
+	}{
+		{"small", 3, 10, 3},
+		{"large", 300, 3000, 3},
+		{"deep", 50, 500, 50},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, mock := setupMockTestRunHandler()
+			gin.SetMode(gin.TestMode)
+
+			expectLargeTestRunCases(mock, tc.numSuites, tc.numCases, tc.depth)
+
+			r := gin.New()
+			r.GET("/protected/runs/:id", h.GetTestRunCases)
+			req, _ := http.NewRequest("GET", "/protected/runs/1", nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			var responseData util.TestRunCasesResponseData
+			err := json.Unmarshal(w.Body.Bytes(), &responseData)
+			assert.NoError(t, err)
+			assert.Len(t, responseData.TestSuites, tc.numSuites/tc.depth)
+
+			total, deepest := 0, 0
+			var count func(suites []util.TestRunCasesTestSuite, level int)
+			count = func(suites []util.TestRunCasesTestSuite, level int) {
+				for _, suite := range suites {
+					total += len(suite.TestCases)
+					if level > deepest {
+						deepest = level
+					}
+					count(suite.TestSuites, level+1)
+				}
+			}
+			count(responseData.TestSuites, 1)
+			assert.Equal(t, tc.numCases, total)
+			assert.Equal(t, tc.depth, deepest)
+		})
+	}
+}
+
+func TestPostTestRunCaseBulkApprovedOnly(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	requestBody := handler.TestRunCasesRequest{
+		TestCaseIDs: []uint{1, 2, 3},
+		TestRunID:   1,
+	}
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`id` = \\?").
+		WithArgs(requestBody.TestRunID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "approved_only"}).AddRow(1, true))
+	// 廃止したテストケース 4 は選択肢に出ないためリクエストに含まれない
+	mock.ExpectQuery("^SELECT \\* FROM `test_run_cases`").
+		WithArgs(requestBody.TestRunID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_run_id", "test_case_id", "status_id"}).AddRow(20, 1, 4, 2))
+	mock.ExpectQuery("^SELECT `id`,`state` FROM `test_cases` WHERE id IN \\(\\?,\\?,\\?,\\?\\)").
+		WithArgs(1, 2, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).
+			AddRow(1, "Draft").
+			AddRow(2, "Approved").
+			AddRow(3, "Deprecated").
+			AddRow(4, "Deprecated"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}))
+	mock.ExpectQuery("^SELECT \\* FROM `statuses`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Untested"))
+	// 承認済みのテストケース 2 だけを追加し、既存のランのテストケースは削除しない
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `test_run_cases`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, 1, nil, 2, nil, "", nil).
+		WillReturnResult(sqlmock.NewResult(21, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	body, _ := json.Marshal(requestBody)
+	req, _ := http.NewRequest("POST", "/protected/runs/cases/bulk", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.POST("/protected/runs/cases/bulk", h.PostTestRunCaseBulk)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response []model.TestRunCase
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response, 1)
+
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPostTestRunCaseRequiresParameterRow(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "approved_only"}).AddRow(1, false))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(2, "Draft"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_parameters` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "names", "rows"}).
+			AddRow(1, 2, `["locale"]`, `[["ja"],["en"]]`))
+
+	// 存在しない行は指定できない
+	parameterIndex := 2
+	r := gin.Default()
+	body, _ := json.Marshal(model.TestRunCase{TestRunID: 1, TestCaseID: 2, StatusID: 1, ParameterIndex: &parameterIndex})
+	req, _ := http.NewRequest("POST", "/protected/runs/cases", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.POST("/protected/runs/cases", h.PostTestRunCase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPostTestRunCaseRejectsDeprecatedTestCase(t *testing.T) {
+	h, mock := setupMockTestRunHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE `test_runs`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "approved_only"}).AddRow(1, false))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE `test_cases`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow(3, "Deprecated"))
+
+	r := gin.Default()
+	body, _ := json.Marshal(model.TestRunCase{TestRunID: 1, TestCaseID: 3, StatusID: 1})
+	req, _ := http.NewRequest("POST", "/protected/runs/cases", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	r.POST("/protected/runs/cases", h.PostTestRunCase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	err := mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,175 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/model"
+	"backend/util"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setupMockSearchHandler(index handler.SearchIndex) (*handler.SearchHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewSearchHandler(gormDB, index), mock
+}
+
+func search(h *handler.SearchHandler, url string) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.GET("/protected/search", h.Search)
+	r.GET("/protected/:project_code/search", h.SearchProject)
+	req := httptest.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSearchProjectWithMySQLIndex(t *testing.T) {
+	h, mock := setupMockSearchHandler(&handler.MySQLSearchIndex{})
+	gin.SetMode(gin.TestMode)
+
+	against := `+"login" +"error"`
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs("PRJ").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PRJ"))
+	mock.ExpectQuery("^SELECT test_cases.id, test_cases.project_id, test_cases.title, test_cases.content AS body, test_cases.test_suite_id, "+
+		"GREATEST\\(MATCH\\(test_cases.title, test_cases.content\\) AGAINST \\(\\? IN BOOLEAN MODE\\), COALESCE\\(\\(SELECT MAX\\(MATCH\\(test_case_steps.action, test_case_steps.expected_result\\) AGAINST \\(\\? IN BOOLEAN MODE\\)\\).+ AS score "+
+		"FROM `test_cases` WHERE test_cases.deleted_at IS NULL AND test_cases.project_id = \\? AND \\(MATCH\\(test_cases.title, test_cases.content\\) AGAINST \\(\\? IN BOOLEAN MODE\\) OR test_cases.id IN \\(SELECT test_case_id FROM test_case_steps .+\\) "+
+		"OR test_cases.id IN \\(SELECT test_case_steps.test_case_id FROM test_case_steps JOIN shared_step_items .+\\)\\) "+
+		"ORDER BY score DESC LIMIT 5").
+		WithArgs(against, against, against, 1, against, against, against).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "body", "test_suite_id", "score"}).
+			AddRow(10, 1, "Login", "Open the login page", 3, 1.5))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\) AND `test_case_steps`.`deleted_at` IS NULL ORDER BY test_case_id, order_index").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result", "shared_step_id"}).
+			AddRow(1, 10, 0, "Enter a wrong password", "An error is shown", nil).
+			AddRow(2, 10, 1, "", "", 5))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE id IN \\(\\?\\)").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(5, 1, "Reset"))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_step_items` WHERE `shared_step_items`.`shared_step_id` = \\? .*ORDER BY order_index, id").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shared_step_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 5, 0, "Reset the password", "A mail is sent"))
+	mock.ExpectQuery("^SELECT comments.id, test_runs.project_id, test_cases.title, comments.content AS body, .+, MATCH\\(comments.content\\) AGAINST \\(\\? IN BOOLEAN MODE\\) AS score "+
+		"FROM `comments` JOIN test_run_cases .+ JOIN test_runs .+ JOIN test_cases .+ WHERE comments.deleted_at IS NULL AND test_runs.project_id = \\? AND MATCH\\(comments.content\\) AGAINST \\(\\? IN BOOLEAN MODE\\) "+
+		"ORDER BY score DESC LIMIT 5").
+		WithArgs(against, 1, against).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "body", "test_plan_id", "test_run_id", "test_case_id", "test_run_case_id", "score"}).
+			AddRow(7, 1, "Login", "Login error again", 2, 4, 10, 20, 2.25))
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PRJ"))
+
+	w := search(h, "/protected/PRJ/search?q=Login+error&type=test_case,comment&limit=5")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.SearchResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Login error", response.Query)
+	assert.Equal(t, uint(1), *response.ProjectID)
+	// 一致の度合いが高い順に並ぶ
+	assert.Len(t, response.Hits, 2)
+	assert.Equal(t, "comment", response.Hits[0].Type)
+	assert.Equal(t, uint(7), response.Hits[0].ID)
+	assert.Equal(t, "PRJ", response.Hits[0].ProjectCode)
+	assert.Equal(t, uint(4), *response.Hits[0].TestRunID)
+	assert.Equal(t, uint(20), *response.Hits[0].TestRunCaseID)
+	assert.Equal(t, "Login error again", response.Hits[0].Snippet)
+	assert.Equal(t, "test_case", response.Hits[1].Type)
+	assert.Equal(t, uint(3), *response.Hits[1].TestSuiteID)
+	// 手順に一致した場合も抜粋に含める（共有ステップの手順も含める）
+	assert.Equal(t, "Open the login page Enter a wrong password An error is shown Reset the password A mail is sent", response.Hits[1].Snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchWithMemoryIndexReloadsAfterWrite(t *testing.T) {
+	index := handler.NewMemorySearchIndex()
+	h, mock := setupMockSearchHandler(index)
+	gin.SetMode(gin.TestMode)
+	assert.NoError(t, index.Prepare(h.DB))
+
+	expectTestCases := func(title string) {
+		mock.ExpectQuery("^SELECT test_cases.id, test_cases.project_id, test_cases.title, test_cases.content AS body, test_cases.test_suite_id " +
+			"FROM `test_cases` WHERE test_cases.deleted_at IS NULL AND test_cases.project_id IN \\(SELECT id FROM projects WHERE deleted_at IS NULL\\)$").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "body", "test_suite_id"}).
+				AddRow(10, 1, title, "ログイン画面を開く", 3).
+				AddRow(11, 2, "Logout", "", 4))
+		mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?,\\?\\) AND").
+			WithArgs(10, 11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "action", "expected_result"}))
+	}
+	expectProjects := func() {
+		mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE id IN \\(\\?\\)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "PRJ"))
+	}
+
+	expectTestCases("Login")
+	expectProjects()
+	w := search(h, "/protected/search?q=ログイン&type=test_case")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.SearchResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Hits, 1)
+	assert.Equal(t, uint(10), response.Hits[0].ID)
+	assert.Equal(t, "PRJ", response.Hits[0].ProjectCode)
+
+	// 書き込みが無ければ読み込み直さない（入力途中の語は前方一致で探す）
+	expectProjects()
+	w = search(h, "/protected/search?q=log&type=test_case&limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Hits, 1)
+
+	// テストケースを書き換えると次の検索で読み込み直す
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE `test_cases` SET `title`=\\?").
+		WithArgs("Sign in", sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, h.DB.Model(&model.TestCase{}).Where("id = ?", 10).Update("title", "Sign in").Error)
+
+	expectTestCases("Sign in")
+	expectProjects()
+	w = search(h, "/protected/search?q=sign&type=test_case")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Hits, 1)
+	assert.Equal(t, "Sign in", response.Hits[0].Title)
+	assert.Equal(t, "ログイン画面を開く", response.Hits[0].Snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchRequiresQuery(t *testing.T) {
+	h, mock := setupMockSearchHandler(handler.NewMemorySearchIndex())
+	gin.SetMode(gin.TestMode)
+
+	w := search(h, "/protected/search?q=+")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = search(h, "/protected/search?q=login&type=test_plan")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,189 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/util"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setupMockSharedStepHandler() (*handler.SharedStepHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewSharedStepHandler(gormDB), mock
+}
+
+func TestPostSharedStep(t *testing.T) {
+	h, mock := setupMockSharedStepHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE email = \\?").
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO `shared_steps`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 1, "Login", "Logs in as the default user", 1, 1).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("^UPDATE `shared_step_items` SET `deleted_at`=\\? WHERE shared_step_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// 空の手順は保存しない
+	mock.ExpectExec("^INSERT INTO `shared_step_items`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, 0, "Open login page", "Form is shown",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, 1, "Submit", "Top page is shown",
+		).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE `shared_steps`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title", "created_by_id", "updated_by_id"}).AddRow(3, 1, "Login", 1, 1))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_step_items` WHERE `shared_step_items`.`shared_step_id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shared_step_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 3, 0, "Open login page", "Form is shown").
+			AddRow(2, 3, 1, "Submit", "Top page is shown"))
+	mock.ExpectQuery("^SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User"))
+	mock.ExpectQuery("^SELECT test_case_steps.shared_step_id, COUNT\\(DISTINCT test_case_steps.test_case_id\\) AS count FROM `test_case_steps`").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"shared_step_id", "count"}))
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set("email", "user@example.com")
+	})
+	r.POST("/protected/shared-steps", h.PostSharedStep)
+	body := `{"project_id": 1, "title": " Login ", "description": "Logs in as the default user", "steps": [{"action": "Open login page", "expected_result": "Form is shown"}, {"action": " ", "expected_result": ""}, {"action": "Submit", "expected_result": "Top page is shown"}]}`
+	req := httptest.NewRequest("POST", "/protected/shared-steps", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response util.SharedStep
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Login", response.Title)
+	assert.Len(t, response.Steps, 2)
+	assert.Equal(t, 0, response.UsageCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostSharedStepRequiresTitle(t *testing.T) {
+	h, mock := setupMockSharedStepHandler()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.Default()
+	r.POST("/protected/shared-steps", h.PostSharedStep)
+	req := httptest.NewRequest("POST", "/protected/shared-steps", strings.NewReader(`{"project_id": 1, "title": " "}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteSharedStep(t *testing.T) {
+	h, mock := setupMockSharedStepHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE `shared_steps`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Login"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT DISTINCT `test_case_id` FROM `test_case_steps` WHERE shared_step_id = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"test_case_id"}).AddRow(10))
+	mock.ExpectQuery("^SELECT \\* FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "test_case_id", "order_index", "action", "expected_result", "shared_step_id"}).
+			AddRow(1, 10, 0, "", "", 3).
+			AddRow(2, 10, 1, "Log out", "Login page is shown", nil))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE id IN \\(\\?\\)").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Login"))
+	mock.ExpectQuery("^SELECT \\* FROM `shared_step_items` WHERE `shared_step_items`.`shared_step_id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "shared_step_id", "order_index", "action", "expected_result"}).
+			AddRow(1, 3, 0, "Open login page", "Form is shown").
+			AddRow(2, 3, 1, "Submit", "Top page is shown"))
+	mock.ExpectExec("^UPDATE `test_case_steps` SET `deleted_at`=\\? WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	// 参照していた手順を通常の手順として保存し直す
+	mock.ExpectExec("^INSERT INTO `test_case_steps`").
+		WithArgs(
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 0, "Open login page", "Form is shown", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 1, "Submit", "Top page is shown", nil,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 10, 2, "Log out", "Login page is shown", nil,
+		).
+		WillReturnResult(sqlmock.NewResult(3, 3))
+	mock.ExpectExec("^UPDATE `shared_step_items` SET `deleted_at`=\\? WHERE shared_step_id = \\?").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `shared_steps` SET `deleted_at`=\\? WHERE `shared_steps`.`id` = \\?").
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.DELETE("/protected/shared-steps/:id", h.DeleteSharedStep)
+	req := httptest.NewRequest("DELETE", "/protected/shared-steps/3", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSharedStepUsages(t *testing.T) {
+	h, mock := setupMockSharedStepHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectQuery("^SELECT \\* FROM `shared_steps` WHERE `shared_steps`.`id` = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "title"}).AddRow(3, 1, "Login"))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE id IN \\(SELECT `test_case_id` FROM `test_case_steps` WHERE shared_step_id = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "title"}).
+			AddRow(10, 1, 2, "Login succeeds").
+			AddRow(11, 1, 2, "Logout succeeds"))
+
+	r := gin.Default()
+	r.GET("/protected/shared-steps/:id/usages", h.GetSharedStepUsages)
+	req := httptest.NewRequest("GET", "/protected/shared-steps/3/usages", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.SharedStepUsagesResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(3), response.SharedStepID)
+	assert.Len(t, response.TestCases, 2)
+	assert.Equal(t, "Logout succeeds", response.TestCases[1].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,424 @@
+package handler_test
+
+import (
+	"backend/handler"
+	"backend/util"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sameTime は最初に渡された時刻を記録し、以降は同じ時刻であることを検証する
+type sameTime struct {
+	value *time.Time
+}
+
+func (s *sameTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	if s.value == nil {
+		s.value = &t
+		return true
+	}
+	return s.value.Equal(t)
+}
+
+func setupMockTrashHandler() (*handler.TrashHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a stub database connection", err))
+	}
+
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      db,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	if err != nil {
+		panic(fmt.Sprintf("An error '%s' was not expected when opening a gorm database connection", err))
+	}
+
+	return handler.NewTrashHandler(gormDB, newMemoryStorage()), mock
+}
+
+func TestGetTrash(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	projectCode := "PRJ"
+	projectID := 1
+	older := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	newer := time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)
+
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE code = \\?").
+		WithArgs(projectCode).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(projectID, projectCode))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE \\(project_id = \\? AND deleted_at IS NOT NULL\\) AND \\(NOT EXISTS").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "deleted_at"}).AddRow(1, "Suite", newer))
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE \\(project_id = \\? AND deleted_at IS NOT NULL\\) AND \\(NOT EXISTS").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "deleted_at"}).AddRow(2, "Case", older))
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE project_id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE \\(project_id = \\? AND deleted_at IS NOT NULL\\) AND \\(NOT EXISTS").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE project_id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	r := gin.Default()
+	r.GET("/protected/:project_code/trash", h.GetTrash)
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/protected/%s/trash", projectCode), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response util.TrashResponseData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint(projectID), response.ProjectID)
+	assert.Equal(t, []util.TrashItem{
+		{Type: "suites", ID: 1, Title: "Suite", DeletedAt: "2024-01-02 10:00"},
+		{Type: "cases", ID: 2, Title: "Case", DeletedAt: "2024-01-01 10:00"},
+	}, response.Items)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreTestCase(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	testCaseID := 2
+	deletedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_cases` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(testCaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_suite_id", "deleted_at"}).AddRow(testCaseID, 1, 3, deletedAt))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `projects` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_suites` WHERE id = \\?").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(nil, sqlmock.AnyArg(), testCaseID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `deleted_at`=\\?,`updated_at`=\\? WHERE test_case_id = \\? AND deleted_at = \\?").
+		WithArgs(nil, sqlmock.AnyArg(), testCaseID, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.POST("/protected/trash/:type/:id/restore", h.RestoreTrashItem)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/protected/trash/cases/%d/restore", testCaseID), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreTestSuite(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	deletedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "parent_id", "deleted_at"}).AddRow(1, 1, nil, deletedAt))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `projects` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("^SELECT \\* FROM `test_suites` WHERE project_id = \\?$").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "parent_id", "deleted_at"}).
+			AddRow(1, 1, nil, deletedAt).
+			AddRow(2, 1, 1, deletedAt))
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE test_suite_id IN \\(\\?,\\?\\) AND deleted_at = \\?").
+		WithArgs(1, 2, deletedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+	mock.ExpectExec("^UPDATE `test_suites` SET `deleted_at`=\\?,`updated_at`=\\? WHERE id IN \\(\\?,\\?\\) AND deleted_at = \\?").
+		WithArgs(nil, sqlmock.AnyArg(), 1, 2, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_cases` SET `deleted_at`=\\?,`updated_at`=\\? WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(nil, sqlmock.AnyArg(), 10, 11).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("^UPDATE `test_run_cases` SET `deleted_at`=\\?,`updated_at`=\\? WHERE test_case_id IN \\(\\?,\\?\\) AND deleted_at = \\?").
+		WithArgs(nil, sqlmock.AnyArg(), 10, 11, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	r := gin.Default()
+	r.POST("/protected/trash/:type/:id/restore", h.RestoreTrashItem)
+	req, _ := http.NewRequest("POST", "/protected/trash/suites/1/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreTestRunWithDeletedPlan(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_runs` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "project_id", "test_plan_id", "deleted_at"}).AddRow(5, 1, 2, time.Now()))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `projects` WHERE id = \\?").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("^SELECT count\\(\\*\\) FROM `test_plans` WHERE id = \\?").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	r := gin.Default()
+	r.POST("/protected/trash/:type/:id/restore", h.RestoreTrashItem)
+	req, _ := http.NewRequest("POST", "/protected/trash/runs/5/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeTestPlan(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `test_plans` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery("^SELECT `id` FROM `test_runs` WHERE test_plan_id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("^SELECT `id` FROM `test_run_cases` WHERE test_run_id IN \\(\\?\\)").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("^DELETE FROM `comments` WHERE test_run_case_id IN \\(\\?\\)").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM `test_run_cases` WHERE id IN \\(\\?\\)").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM `test_runs` WHERE id IN \\(\\?\\)").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM `test_plan_schedules` WHERE test_plan_id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^UPDATE `test_plan_schedules` SET `last_test_plan_id`=\\?,`updated_at`=\\? WHERE last_test_plan_id IN \\(\\?\\)").
+		WithArgs(nil, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_plans` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE \\(\\(parent_type = \\? AND parent_id NOT IN \\(SELECT id FROM test_cases\\)\\)").
+		WithArgs("test_case", "test_run_case", "comment").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	r := gin.Default()
+	r.DELETE("/protected/trash/:type/:id", h.PurgeTrashItem)
+	req, _ := http.NewRequest("DELETE", "/protected/trash/plans/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeProject(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `projects` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at"}).AddRow(1, time.Now()))
+	for _, table := range []string{"test_plans", "test_runs", "test_suites", "test_cases", "milestones", "requirements", "shared_steps"} {
+		mock.ExpectQuery(fmt.Sprintf("^SELECT `id` FROM `%s` WHERE project_id IN \\(\\?\\)", table)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	// プロジェクト単位の設定もまとめて物理削除する
+	for _, table := range []string{"test_plan_schedules", "test_case_templates", "import_profiles"} {
+		mock.ExpectExec(fmt.Sprintf("^DELETE FROM `%s` WHERE project_id IN \\(\\?\\)", table)).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("^DELETE FROM `projects` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE").
+		WithArgs("test_case", "test_run_case", "comment").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	r := gin.Default()
+	r.DELETE("/protected/trash/:type/:id", h.PurgeTrashItem)
+	req, _ := http.NewRequest("DELETE", "/protected/trash/projects/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeMilestone(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at"}).AddRow(1, time.Now()))
+	// 削除されたものも含めて紐付けを外す
+	for _, table := range []string{"test_cases", "test_plans", "test_runs"} {
+		mock.ExpectExec("^UPDATE `"+table+"` SET `milestone_id`=\\?,`updated_at`=\\? WHERE milestone_id IN \\(\\?\\)$").
+			WithArgs(nil, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("^DELETE FROM `milestones` WHERE id IN \\(\\?\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("^SELECT \\* FROM `attachments`").
+		WithArgs("test_case", "test_run_case", "comment").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	r := gin.Default()
+	r.DELETE("/protected/trash/:type/:id", h.PurgeTrashItem)
+	req, _ := http.NewRequest("DELETE", "/protected/trash/milestones/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeItemNotInTrash(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("^SELECT \\* FROM `milestones` WHERE id = \\? AND deleted_at IS NOT NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	r := gin.Default()
+	r.DELETE("/protected/trash/:type/:id", h.PurgeTrashItem)
+	req, _ := http.NewRequest("DELETE", "/protected/trash/milestones/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeInvalidType(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	gin.SetMode(gin.TestMode)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	r := gin.Default()
+	r.DELETE("/protected/trash/:type/:id", h.PurgeTrashItem)
+	req, _ := http.NewRequest("DELETE", "/protected/trash/users/1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	h, mock := setupMockTrashHandler()
+	deletedBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+	mock.ExpectBegin()
+	for _, table := range []string{"projects", "test_plans", "test_runs", "test_suites"} {
+		mock.ExpectQuery(fmt.Sprintf("^SELECT `id` FROM `%s` WHERE deleted_at < \\?", table)).
+			WithArgs(deletedBefore).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	mock.ExpectQuery("^SELECT `id` FROM `test_cases` WHERE deleted_at < \\?").
+		WithArgs(deletedBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("^SELECT `id` FROM `test_run_cases` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("^DELETE FROM `test_case_tags` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_custom_fields` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_parameters` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_steps` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_reviewers` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_review_comments` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `requirement_test_cases` WHERE test_case_id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_case_dependencies` WHERE test_case_id IN \\(\\?\\) OR prerequisite_id IN \\(\\?\\)").
+		WithArgs(7, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^DELETE FROM `test_cases` WHERE id IN \\(\\?\\)").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"milestones", "requirements", "shared_steps", "test_run_cases"} {
+		mock.ExpectQuery(fmt.Sprintf("^SELECT `id` FROM `%s` WHERE deleted_at < \\?", table)).
+			WithArgs(deletedBefore).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+	for _, table := range []string{"comments", "test_case_tags", "test_case_custom_fields", "test_case_parameters", "test_case_steps", "test_case_reviewers", "shared_step_items", "test_case_templates", "import_profiles"} {
+		mock.ExpectExec(fmt.Sprintf("^DELETE FROM `%s` WHERE deleted_at < \\?", table)).
+			WithArgs(deletedBefore).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+	// 物理削除したテストケースの添付ファイルを保存先から削除する
+	storage := h.Storage.(*memoryStorage)
+	storage.files["attachments/test_case/7/abc"] = []byte("screenshot")
+	mock.ExpectQuery("^SELECT \\* FROM `attachments` WHERE").
+		WithArgs("test_case", "test_run_case", "comment").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_type", "parent_id", "storage_key"}).AddRow(4, "test_case", 7, "attachments/test_case/7/abc"))
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM `attachments` WHERE id IN \\(\\?\\)").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, handler.PurgeExpiredTrash(h.DB, h.Storage, deletedBefore))
+	assert.Empty(t, storage.files)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+)
+
+// 前提条件の結果で自動的に変更する状態の名前
+const (
+	statusNamePassed  = "Passed"
+	statusNameFailed  = "Failed"
+	statusNameBlocked = "Blocked"
+)
+
+// TestCaseDependenciesRequest は前提条件のテストケースを置き換えるリクエスト
+type TestCaseDependenciesRequest struct {
+	PrerequisiteIDs []uint `json:"prerequisite_ids"`
+}
+
+// GetTestCaseDependencies はテストケースの前提条件と、このテストケースを前提条件にしているテストケースを返す
+func (h *TestCaseHandler) GetTestCaseDependencies(c *gin.Context) {
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+	h.respondTestCaseDependencies(c, testCase)
+}
+
+// PutTestCaseDependencies は前提条件を置き換える。前提条件は同じプロジェクトのテストケースに限り、循環する依存は登録できない
+func (h *TestCaseHandler) PutTestCaseDependencies(c *gin.Context) {
+	var req TestCaseDependenciesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+
+	prerequisiteIDs := uniqueUints(req.PrerequisiteIDs)
+	for _, prerequisiteID := range prerequisiteIDs {
+		if prerequisiteID == testCase.ID {
+			handleError(c, http.StatusBadRequest, "Test case cannot depend on itself", nil)
+			return
+		}
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkTestCasePrerequisites(tx, testCase, prerequisiteIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseDependency{}).Error; err != nil {
+			return err
+		}
+		if len(prerequisiteIDs) == 0 {
+			return nil
+		}
+		dependencies := make([]model.TestCaseDependency, 0, len(prerequisiteIDs))
+		for _, prerequisiteID := range prerequisiteIDs {
+			dependencies = append(dependencies, model.TestCaseDependency{TestCaseID: testCase.ID, PrerequisiteID: prerequisiteID})
+		}
+		return tx.Create(&dependencies).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTestCaseNotInProject):
+			handleError(c, http.StatusBadRequest, "Test case not found in project", err)
+		case errors.Is(err, errDependencyCycle):
+			handleError(c, http.StatusConflict, "Dependency cycle detected", err)
+		default:
+			handleError(c, http.StatusInternalServerError, "Failed to update dependencies", err)
+		}
+		return
+	}
+	h.respondTestCaseDependencies(c, testCase)
+}
+
+// errDependencyCycle は前提条件をたどると元のテストケースに戻る場合のエラー
+var errDependencyCycle = errors.New("dependency cycle detected")
+
+// checkTestCasePrerequisites は前提条件が同じプロジェクトのテストケースで、依存が循環しないことを確かめる。
+// 同時に登録された依存で循環しないよう、プロジェクトの依存を更新用にロックして読み込む
+func checkTestCasePrerequisites(db *gorm.DB, testCase model.TestCase, prerequisiteIDs []uint) error {
+	if len(prerequisiteIDs) == 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&model.TestCase{}).Where("project_id = ? AND id IN ?", testCase.ProjectID, prerequisiteIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(prerequisiteIDs) {
+		return errTestCaseNotInProject
+	}
+
+	var dependencies []model.TestCaseDependency
+	projectTestCases := db.Model(&model.TestCase{}).Select("id").Where("project_id = ?", testCase.ProjectID)
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("test_case_id IN (?)", projectTestCases).Find(&dependencies).Error; err != nil {
+		return err
+	}
+	// 置き換える前のこのテストケースの前提条件は除いて確認する
+	prerequisitesByTestCase := map[uint][]uint{}
+	for _, dependency := range dependencies {
+		if dependency.TestCaseID != testCase.ID {
+			prerequisitesByTestCase[dependency.TestCaseID] = append(prerequisitesByTestCase[dependency.TestCaseID], dependency.PrerequisiteID)
+		}
+	}
+	for _, prerequisiteID := range prerequisiteIDs {
+		if dependsOnTestCase(prerequisitesByTestCase, prerequisiteID, testCase.ID) {
+			return errDependencyCycle
+		}
+	}
+	return nil
+}
+
+func (h *TestCaseHandler) respondTestCaseDependencies(c *gin.Context, testCase model.TestCase) {
+	var prerequisites []model.TestCase
+	prerequisiteIDs := h.DB.Model(&model.TestCaseDependency{}).Select("prerequisite_id").Where("test_case_id = ?", testCase.ID)
+	if err := h.DB.Where("id IN (?)", prerequisiteIDs).Order("id").Find(&prerequisites).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve dependencies", err)
+		return
+	}
+	var dependents []model.TestCase
+	dependentIDs := h.DB.Model(&model.TestCaseDependency{}).Select("test_case_id").Where("prerequisite_id = ?", testCase.ID)
+	if err := h.DB.Where("id IN (?)", dependentIDs).Order("id").Find(&dependents).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve dependencies", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, util.TestCaseDependencies{
+		TestCaseID:    testCase.ID,
+		Prerequisites: convertTestCasesToDependencyJSON(prerequisites),
+		Dependents:    convertTestCasesToDependencyJSON(dependents),
+	})
+}
+
+func convertTestCasesToDependencyJSON(testCases []model.TestCase) []util.TestCaseDependencyEntity {
+	entities := []util.TestCaseDependencyEntity{}
+	for _, testCase := range testCases {
+		entities = append(entities, util.TestCaseDependencyEntity{ID: testCase.ID, TestSuiteID: testCase.TestSuiteID, Title: testCase.Title})
+	}
+	return entities
+}
+
+// dependsOnTestCase は from のテストケースが前提条件をたどって target に依存しているかを返す
+func dependsOnTestCase(prerequisitesByTestCase map[uint][]uint, from uint, target uint) bool {
+	visited := map[uint]bool{}
+	queue := []uint{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == target {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		queue = append(queue, prerequisitesByTestCase[current]...)
+	}
+	return false
+}
+
+// dependentTestRunCaseChange は前提条件の結果によって変更する同じテストランのケース
+type dependentTestRunCaseChange struct {
+	TestRunCaseID       uint
+	StatusID            uint
+	BlockedFromStatusID *uint
+	Content             string
+}
+
+// updateDependentTestRunCases はテストランのケースの結果に合わせて、前提条件にしているケースを Blocked にする、または元の状態に戻す
+func updateDependentTestRunCases(c *gin.Context, tx *gorm.DB, id uint) error {
+	var testRunCase model.TestRunCase
+	if err := tx.Preload("Status").Preload("TestCase").First(&testRunCase, id).Error; err != nil {
+		return err
+	}
+	changes, err := planDependentTestRunCaseChanges(tx, testRunCase)
+	if err != nil {
+		return err
+	}
+	if testRunCase.BlockedFromStatusID == nil && len(changes) == 0 {
+		return nil
+	}
+
+	var accessUser model.User
+	if len(changes) > 0 {
+		if accessUser, err = findAccessUser(c, tx); err != nil {
+			return err
+		}
+	}
+
+	// 手動で結果を付けたケースは前提条件が成功しても元に戻さない
+	if testRunCase.BlockedFromStatusID != nil {
+		if err := tx.Model(&model.TestRunCase{}).Where("id = ?", testRunCase.ID).Update("blocked_from_status_id", nil).Error; err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		updates := map[string]interface{}{"status_id": change.StatusID, "blocked_from_status_id": change.BlockedFromStatusID}
+		if err := tx.Model(&model.TestRunCase{}).Where("id = ?", change.TestRunCaseID).Updates(updates).Error; err != nil {
+			return err
+		}
+		statusID := change.StatusID
+		comment := model.Comment{
+			TestRunCaseID: change.TestRunCaseID,
+			StatusID:      &statusID,
+			Content:       change.Content,
+			CreatedByID:   accessUser.ID,
+			UpdatedByID:   accessUser.ID,
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planDependentTestRunCaseChanges は前提条件が失敗したら依存するケースを Blocked にし、成功したら自動で Blocked にしたケースを戻す。
+// 他の前提条件がまだ失敗しているケースは Blocked のままにする。
+// 対象は直接の依存だけで、Blocked にしたケースをさらに前提条件にしているケースは変更しない
+func planDependentTestRunCaseChanges(db *gorm.DB, testRunCase model.TestRunCase) ([]dependentTestRunCaseChange, error) {
+	if testRunCase.Status == nil || (testRunCase.Status.Name != statusNameFailed && testRunCase.Status.Name != statusNamePassed) {
+		return nil, nil
+	}
+
+	var dependentIDs []uint
+	if err := db.Model(&model.TestCaseDependency{}).Where("prerequisite_id = ?", testRunCase.TestCaseID).Pluck("test_case_id", &dependentIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(dependentIDs) == 0 {
+		return nil, nil
+	}
+
+	var blocked model.Status
+	if err := db.Where("name = ?", statusNameBlocked).First(&blocked).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var dependents []model.TestRunCase
+	if err := db.Where("test_run_id = ? AND test_case_id IN ?", testRunCase.TestRunID, dependentIDs).Order("id").Find(&dependents).Error; err != nil {
+		return nil, err
+	}
+
+	changes := []dependentTestRunCaseChange{}
+	if testRunCase.Status.Name == statusNameFailed {
+		for _, dependent := range dependents {
+			if dependent.StatusID == blocked.ID {
+				continue
+			}
+			blockedFromStatusID := dependent.StatusID
+			changes = append(changes, dependentTestRunCaseChange{
+				TestRunCaseID:       dependent.ID,
+				StatusID:            blocked.ID,
+				BlockedFromStatusID: &blockedFromStatusID,
+				Content:             fmt.Sprintf("Blocked because the prerequisite \"%s\" failed.", testRunCase.TestCase.Title),
+			})
+		}
+		return changes, nil
+	}
+
+	candidates := []model.TestRunCase{}
+	candidateTestCaseIDs := []uint{}
+	for _, dependent := range dependents {
+		if dependent.BlockedFromStatusID != nil && dependent.StatusID == blocked.ID {
+			candidates = append(candidates, dependent)
+			candidateTestCaseIDs = append(candidateTestCaseIDs, dependent.TestCaseID)
+		}
+	}
+	if len(candidates) == 0 {
+		return changes, nil
+	}
+
+	var stillBlockedIDs []uint
+	err := db.Model(&model.TestCaseDependency{}).
+		Joins("JOIN test_run_cases ON test_run_cases.test_case_id = test_case_dependencies.prerequisite_id AND test_run_cases.test_run_id = ? AND test_run_cases.deleted_at IS NULL", testRunCase.TestRunID).
+		Joins("JOIN statuses ON statuses.id = test_run_cases.status_id").
+		Where("test_case_dependencies.test_case_id IN ? AND statuses.name = ?", uniqueUints(candidateTestCaseIDs), statusNameFailed).
+		Distinct().
+		Pluck("test_case_dependencies.test_case_id", &stillBlockedIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	stillBlocked := map[uint]bool{}
+	for _, testCaseID := range stillBlockedIDs {
+		stillBlocked[testCaseID] = true
+	}
+
+	for _, candidate := range candidates {
+		if stillBlocked[candidate.TestCaseID] {
+			continue
+		}
+		changes = append(changes, dependentTestRunCaseChange{
+			TestRunCaseID: candidate.ID,
+			StatusID:      *candidate.BlockedFromStatusID,
+			Content:       fmt.Sprintf("Unblocked because the prerequisite \"%s\" passed.", testRunCase.TestCase.Title),
+		})
+	}
+	return changes, nil
+}
@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// DefaultDuplicateThreshold は重複とみなす類似度の既定値
+const DefaultDuplicateThreshold = 0.8
+
+// TestCaseMergeRequest は重複したテストケースを 1 件にまとめるリクエスト
+type TestCaseMergeRequest struct {
+	TargetID  uint   `json:"target_id"`  // 残すテストケース
+	SourceIDs []uint `json:"source_ids"` // まとめて削除するテストケース
+}
+
+// duplicateCandidate は類似度を比べるテストケースと、タイトル・内容・手順の語の集合
+type duplicateCandidate struct {
+	Row    searchRow
+	Tokens map[string]bool
+}
+
+func (h *TestCaseHandler) findProjectByCode(c *gin.Context) (model.Project, bool) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return project, false
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return project, false
+	}
+	return project, true
+}
+
+// GetTestCaseDuplicates はタイトル・内容・手順の語の重なり（Jaccard 係数）が threshold 以上のテストケースをクラスターにまとめて返す
+func (h *TestCaseHandler) GetTestCaseDuplicates(c *gin.Context) {
+	project, ok := h.findProjectByCode(c)
+	if !ok {
+		return
+	}
+
+	threshold := DefaultDuplicateThreshold
+	if value := c.Query("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			handleError(c, http.StatusBadRequest, "Invalid threshold", err)
+			return
+		}
+		threshold = parsed
+	}
+
+	var rows []searchRow
+	if err := searchSource(h.DB, searchTypeTestCase, &project.ID, "").Scan(&rows).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+		return
+	}
+	if err := appendSearchSteps(h.DB, rows); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test case steps", err)
+		return
+	}
+
+	candidates := []duplicateCandidate{}
+	for _, row := range rows {
+		tokens := map[string]bool{}
+		for _, token := range searchTokens(row.Title + "\n" + row.Body) {
+			tokens[token] = true
+		}
+		if len(tokens) > 0 {
+			candidates = append(candidates, duplicateCandidate{Row: row, Tokens: tokens})
+		}
+	}
+
+	c.JSON(http.StatusOK, util.TestCaseDuplicatesResponseData{
+		ProjectID: project.ID,
+		Threshold: threshold,
+		Clusters:  findDuplicateClusters(candidates, threshold),
+	})
+}
+
+// findDuplicateClusters は類似度が threshold 以上の組を探し、つながっているテストケースを 1 つのクラスターにまとめる
+func findDuplicateClusters(candidates []duplicateCandidate, threshold float64) []util.TestCaseDuplicateCluster {
+	// 語の数が少ない順に並べ、語の数の比が threshold を下回る組は比べない（Jaccard 係数は語の数の比を超えない）
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if len(candidates[i].Tokens) != len(candidates[j].Tokens) {
+			return len(candidates[i].Tokens) < len(candidates[j].Tokens)
+		}
+		return candidates[i].Row.ID < candidates[j].Row.ID
+	})
+
+	parents := make([]int, len(candidates))
+	for i := range parents {
+		parents[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parents[i] != i {
+			parents[i] = root(parents[i])
+		}
+		return parents[i]
+	}
+
+	pairs := []util.TestCaseDuplicatePair{}
+	pairIndexes := [][2]int{}
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			if float64(len(candidates[i].Tokens)) < threshold*float64(len(candidates[j].Tokens)) {
+				break
+			}
+			shared := 0
+			for token := range candidates[i].Tokens {
+				if candidates[j].Tokens[token] {
+					shared++
+				}
+			}
+			similarity := float64(shared) / float64(len(candidates[i].Tokens)+len(candidates[j].Tokens)-shared)
+			if similarity < threshold {
+				continue
+			}
+			first, second := candidates[i].Row.ID, candidates[j].Row.ID
+			if first > second {
+				first, second = second, first
+			}
+			pairs = append(pairs, util.TestCaseDuplicatePair{TestCaseID: first, DuplicateID: second, Similarity: math.Round(similarity*1000) / 1000})
+			pairIndexes = append(pairIndexes, [2]int{i, j})
+			parents[root(i)] = root(j)
+		}
+	}
+
+	clusterIndexes := map[int]int{}
+	clusters := []util.TestCaseDuplicateCluster{}
+	for k, pair := range pairs {
+		r := root(pairIndexes[k][0])
+		index, ok := clusterIndexes[r]
+		if !ok {
+			index = len(clusters)
+			clusterIndexes[r] = index
+			clusters = append(clusters, util.TestCaseDuplicateCluster{TestCases: []util.TestCaseDuplicateEntity{}, Pairs: []util.TestCaseDuplicatePair{}})
+		}
+		clusters[index].Pairs = append(clusters[index].Pairs, pair)
+		if pair.Similarity > clusters[index].Similarity {
+			clusters[index].Similarity = pair.Similarity
+		}
+	}
+	for i, candidate := range candidates {
+		if index, ok := clusterIndexes[root(i)]; ok {
+			clusters[index].TestCases = append(clusters[index].TestCases, util.TestCaseDuplicateEntity{
+				ID:          candidate.Row.ID,
+				TestSuiteID: candidate.Row.TestSuiteID,
+				Title:       candidate.Row.Title,
+			})
+		}
+	}
+
+	for i := range clusters {
+		sort.Slice(clusters[i].TestCases, func(a, b int) bool { return clusters[i].TestCases[a].ID < clusters[i].TestCases[b].ID })
+		sort.Slice(clusters[i].Pairs, func(a, b int) bool {
+			if clusters[i].Pairs[a].Similarity != clusters[i].Pairs[b].Similarity {
+				return clusters[i].Pairs[a].Similarity > clusters[i].Pairs[b].Similarity
+			}
+			if clusters[i].Pairs[a].TestCaseID != clusters[i].Pairs[b].TestCaseID {
+				return clusters[i].Pairs[a].TestCaseID < clusters[i].Pairs[b].TestCaseID
+			}
+			return clusters[i].Pairs[a].DuplicateID < clusters[i].Pairs[b].DuplicateID
+		})
+	}
+	// 類似度の高いクラスターから並べる
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].Similarity != clusters[j].Similarity {
+			return clusters[i].Similarity > clusters[j].Similarity
+		}
+		return clusters[i].TestCases[0].ID < clusters[j].TestCases[0].ID
+	})
+	return clusters
+}
+
+// MergeTestCases は重複したテストケースを target_id にまとめる。
+// テストランのケース（結果とコメントを含む）、要件の紐づけ、依存関係を残すテストケースへ付け替え、まとめたテストケースはゴミ箱へ移す
+func (h *TestCaseHandler) MergeTestCases(c *gin.Context) {
+	project, ok := h.findProjectByCode(c)
+	if !ok {
+		return
+	}
+
+	var req TestCaseMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	sourceIDs := uniqueUints(req.SourceIDs)
+	if req.TargetID == 0 || len(sourceIDs) == 0 {
+		handleError(c, http.StatusBadRequest, "Target and source test cases are required", nil)
+		return
+	}
+	merged := map[uint]bool{}
+	for _, id := range sourceIDs {
+		if id == req.TargetID {
+			handleError(c, http.StatusBadRequest, "Test case cannot be merged into itself", nil)
+			return
+		}
+		merged[id] = true
+	}
+
+	var count int64
+	if err := h.DB.Model(&model.TestCase{}).Where("project_id = ? AND id IN ?", project.ID, append([]uint{req.TargetID}, sourceIDs...)).Count(&count).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+		return
+	}
+	if int(count) != len(sourceIDs)+1 {
+		handleError(c, http.StatusBadRequest, "Test case not found in project", nil)
+		return
+	}
+
+	// まとめるテストケースの依存関係を残すテストケースへ付け替えても循環しないことを確かめる
+	var dependencies []model.TestCaseDependency
+	projectTestCases := h.DB.Model(&model.TestCase{}).Select("id").Where("project_id = ?", project.ID)
+	if err := h.DB.Where("test_case_id IN (?)", projectTestCases).Find(&dependencies).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve dependencies", err)
+		return
+	}
+	mergedID := func(id uint) uint {
+		if merged[id] {
+			return req.TargetID
+		}
+		return id
+	}
+	// 付け替えた結果が既にある依存関係や自分自身への依存になる場合は登録しない
+	existing := map[model.TestCaseDependency]bool{}
+	prerequisitesByTestCase := map[uint][]uint{}
+	for _, dependency := range dependencies {
+		if !merged[dependency.TestCaseID] && !merged[dependency.PrerequisiteID] {
+			existing[dependency] = true
+			prerequisitesByTestCase[dependency.TestCaseID] = append(prerequisitesByTestCase[dependency.TestCaseID], dependency.PrerequisiteID)
+		}
+	}
+	redirected := []model.TestCaseDependency{}
+	for _, dependency := range dependencies {
+		mapped := model.TestCaseDependency{TestCaseID: mergedID(dependency.TestCaseID), PrerequisiteID: mergedID(dependency.PrerequisiteID)}
+		if mapped.TestCaseID == mapped.PrerequisiteID || existing[mapped] {
+			continue
+		}
+		existing[mapped] = true
+		prerequisitesByTestCase[mapped.TestCaseID] = append(prerequisitesByTestCase[mapped.TestCaseID], mapped.PrerequisiteID)
+		redirected = append(redirected, mapped)
+	}
+	for _, dependency := range redirected {
+		if dependsOnTestCase(prerequisitesByTestCase, dependency.PrerequisiteID, dependency.TestCaseID) {
+			handleError(c, http.StatusConflict, "Dependency cycle detected", nil)
+			return
+		}
+	}
+
+	var links []model.RequirementTestCase
+	if err := h.DB.Where("test_case_id IN ?", append([]uint{req.TargetID}, sourceIDs...)).Find(&links).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve requirements", err)
+		return
+	}
+	linked := map[uint]bool{}
+	for _, link := range links {
+		if link.TestCaseID == req.TargetID {
+			linked[link.RequirementID] = true
+		}
+	}
+	newLinks := []model.RequirementTestCase{}
+	for _, link := range links {
+		if link.TestCaseID != req.TargetID && !linked[link.RequirementID] {
+			linked[link.RequirementID] = true
+			newLinks = append(newLinks, model.RequirementTestCase{RequirementID: link.RequirementID, TestCaseID: req.TargetID})
+		}
+	}
+
+	result := util.TestCaseMergeResult{TestCaseID: req.TargetID, MergedTestCaseIDs: sourceIDs, Requirements: len(newLinks)}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// まとめた後に同じランで重複するケースは、残すテストケースのもの（無ければ最後に更新したもの）を残してゴミ箱へ移す
+		tx = withDeletionTime(tx, deletionTimestamp())
+		var testRunCases []model.TestRunCase
+		if err := tx.Where("test_case_id IN ?", append([]uint{req.TargetID}, sourceIDs...)).Find(&testRunCases).Error; err != nil {
+			return err
+		}
+		type mergedTestRunCaseKey struct {
+			TestRunID uint
+			Key       testRunCaseKey
+		}
+		mergedKey := func(testRunCase model.TestRunCase) mergedTestRunCaseKey {
+			testRunCase.TestCaseID = req.TargetID
+			return mergedTestRunCaseKey{TestRunID: testRunCase.TestRunID, Key: newTestRunCaseKey(testRunCase)}
+		}
+		kept := map[mergedTestRunCaseKey]model.TestRunCase{}
+		for _, testRunCase := range testRunCases {
+			current, ok := kept[mergedKey(testRunCase)]
+			if !ok || (current.TestCaseID != req.TargetID && (testRunCase.TestCaseID == req.TargetID || testRunCase.UpdatedAt.After(current.UpdatedAt))) {
+				kept[mergedKey(testRunCase)] = testRunCase
+			}
+		}
+		movedIDs := []uint{}
+		trashedIDs := []uint{}
+		for _, testRunCase := range testRunCases {
+			if kept[mergedKey(testRunCase)].ID != testRunCase.ID {
+				trashedIDs = append(trashedIDs, testRunCase.ID)
+			} else if testRunCase.TestCaseID != req.TargetID {
+				movedIDs = append(movedIDs, testRunCase.ID)
+			}
+		}
+		if len(trashedIDs) > 0 {
+			if err := tx.Delete(&model.TestRunCase{}, trashedIDs).Error; err != nil {
+				return err
+			}
+		}
+		if len(movedIDs) > 0 {
+			if err := tx.Model(&model.TestRunCase{}).Where("id IN ?", movedIDs).Update("test_case_id", req.TargetID).Error; err != nil {
+				return err
+			}
+		}
+		result.TestRunCases = int64(len(movedIDs))
+		result.TrashedTestRunCases = int64(len(trashedIDs))
+
+		if err := tx.Where("test_case_id IN ?", sourceIDs).Delete(&model.RequirementTestCase{}).Error; err != nil {
+			return err
+		}
+		if len(newLinks) > 0 {
+			if err := tx.Create(&newLinks).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("test_case_id IN ? OR prerequisite_id IN ?", sourceIDs, sourceIDs).Delete(&model.TestCaseDependency{}).Error; err != nil {
+			return err
+		}
+		if len(redirected) > 0 {
+			if err := tx.Create(&redirected).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&model.TestCase{}, sourceIDs).Error
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to merge test cases", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
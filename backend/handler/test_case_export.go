@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// customFieldColumnPrefix はエクスポートでカスタムフィールドの列に付けるヘッダーの接頭辞。
+// 既定の対応付けでの取り込みでは、この接頭辞の列をカスタムフィールドとして読み込む
+const customFieldColumnPrefix = "Custom: "
+
+// exportedTestCase はエクスポートする1件のテストケースと、そのスイートのパスを表す
+type exportedTestCase struct {
+	TestSuitePath []string
+	TestCase      model.TestCase
+}
+
+// ExportTestCases はプロジェクト、または test_suite_id で指定したスイート配下のテストケースを
+// CSV / XLSX / Markdown で返す。絞り込みと並び順は GetTestCases と同じパラメータを使う
+func (h *TestCaseHandler) ExportTestCases(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "xlsx" && format != "markdown" {
+		handleError(c, http.StatusBadRequest, "Invalid format", nil)
+		return
+	}
+
+	filter, err := parseTestCaseFilter(c)
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid filter parameters", err)
+		return
+	}
+
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	testSuites := []model.TestSuite{}
+	if result := h.DB.Where("project_id = ?", project.ID).
+		Order("test_suites.order_index ASC, test_suites.id ASC").
+		Find(&testSuites); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
+	}
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+
+	// スイートを指定した場合はそのスイートだけを根として辿る（パスはプロジェクトの最上位から書く）
+	roots := testSuiteMap[0]
+	var rootPath []string
+	query := filter.apply(h.DB.Where("test_cases.project_id = ?", project.ID)).
+		Where("test_cases.test_suite_id IS NOT NULL")
+	if v := c.Query("test_suite_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid test_suite_id", err)
+			return
+		}
+		testSuiteByID := make(map[uint]model.TestSuite, len(testSuites))
+		for _, testSuite := range testSuites {
+			testSuiteByID[testSuite.ID] = testSuite
+		}
+		root, ok := testSuiteByID[uint(id)]
+		if !ok {
+			handleError(c, http.StatusNotFound, "Test suite not found", nil)
+			return
+		}
+		roots = []model.TestSuite{root}
+		rootPath = testSuiteAncestorNames(root, testSuiteByID)
+		query = query.Where("test_cases.test_suite_id IN ?", collectTestSuiteSubtree(root.ID, testSuiteMap))
+	}
+
+	testCases := []model.TestCase{}
+	if result := query.
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Preload("Tags").
+		Preload("CustomFields").
+		Preload("Steps", orderTestCaseSteps).
+		Order(filter.order()).
+		Find(&testCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
+	}
+	stepLists := make([]*[]model.TestCaseStep, 0, len(testCases))
+	for i := range testCases {
+		stepLists = append(stepLists, &testCases[i].Steps)
+	}
+	if err := resolveSharedSteps(h.DB, stepLists...); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+		return
+	}
+
+	testCaseMap := make(map[uint][]model.TestCase)
+	for _, testCase := range testCases {
+		testCaseMap[*testCase.TestSuiteID] = append(testCaseMap[*testCase.TestSuiteID], testCase)
+	}
+
+	// スイートの並び順で深さ優先に辿り、各スイートのテストケースを子スイートより先に並べる
+	exported := []exportedTestCase{}
+	visited := map[uint]bool{}
+	var walk func(testSuites []model.TestSuite, path []string)
+	walk = func(testSuites []model.TestSuite, path []string) {
+		for _, testSuite := range testSuites {
+			if visited[testSuite.ID] {
+				continue
+			}
+			visited[testSuite.ID] = true
+			suitePath := append(append([]string{}, path...), testSuite.Name)
+			for _, testCase := range testCaseMap[testSuite.ID] {
+				exported = append(exported, exportedTestCase{TestSuitePath: suitePath, TestCase: testCase})
+			}
+			walk(testSuiteMap[testSuite.ID], suitePath)
+		}
+	}
+	walk(roots, rootPath)
+
+	fileName := project.Code + "-test-cases"
+	switch format {
+	case "csv":
+		buffer := &bytes.Buffer{}
+		// Excel で文字化けしないよう BOM を付ける（取り込み時は読み飛ばす）
+		buffer.WriteString("\xef\xbb\xbf")
+		writer := csv.NewWriter(buffer)
+		if err := writer.WriteAll(testCaseExportRecords(exported)); err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to export test cases", err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+fileName+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buffer.Bytes())
+	case "xlsx":
+		buffer := &bytes.Buffer{}
+		if err := util.WriteXLSX(buffer, "Test Cases", testCaseExportRecords(exported)); err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to export test cases", err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+fileName+`.xlsx"`)
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buffer.Bytes())
+	case "markdown":
+		c.Header("Content-Disposition", `attachment; filename="`+fileName+`.md"`)
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(testCaseExportMarkdown(project, exported)))
+	}
+}
+
+// testSuiteAncestorNames は最上位からそのスイートの親までの名前を返す
+func testSuiteAncestorNames(testSuite model.TestSuite, testSuiteByID map[uint]model.TestSuite) []string {
+	names := []string{}
+	visited := map[uint]bool{testSuite.ID: true}
+	for parentID := testSuite.ParentID; parentID != nil; {
+		parent, ok := testSuiteByID[*parentID]
+		if !ok || visited[parent.ID] {
+			break
+		}
+		visited[parent.ID] = true
+		names = append([]string{parent.Name}, names...)
+		parentID = parent.ParentID
+	}
+	return names
+}
+
+// testCaseExportRecords はヘッダー行を含む表形式のデータを返す。
+// 列名は取り込みの既定の対応付けと同じなので、そのまま取り込み直せる
+func testCaseExportRecords(exported []exportedTestCase) [][]string {
+	fieldNames := []string{}
+	seen := map[string]bool{}
+	for _, item := range exported {
+		for _, field := range item.TestCase.CustomFields {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				fieldNames = append(fieldNames, field.Name)
+			}
+		}
+	}
+	sort.Strings(fieldNames)
+
+	header := []string{
+		defaultImportColumnMapping.TestSuitePath,
+		defaultImportColumnMapping.Title,
+		defaultImportColumnMapping.Content,
+		defaultImportColumnMapping.Steps,
+		defaultImportColumnMapping.ExpectedResults,
+		defaultImportColumnMapping.Milestone,
+		defaultImportColumnMapping.Tags,
+		"Created By",
+		"Updated By",
+	}
+	for _, name := range fieldNames {
+		header = append(header, customFieldColumnPrefix+name)
+	}
+
+	records := [][]string{header}
+	for _, item := range exported {
+		testCase := item.TestCase
+		actions, expectedResults := formatExportSteps(testCaseStepResponses(testCase.Steps))
+		record := []string{
+			strings.Join(item.TestSuitePath, testSuitePathSeparator),
+			testCase.Title,
+			testCase.Content,
+			actions,
+			expectedResults,
+			testCase.Milestone.Title,
+			strings.Join(testCaseTagNames(testCase.Tags), ", "),
+			testCase.CreatedBy.Name,
+			testCase.UpdatedBy.Name,
+		}
+		values := testCaseCustomFieldMap(testCase.CustomFields)
+		for _, name := range fieldNames {
+			record = append(record, values[name])
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// formatExportSteps は手順と期待結果をそれぞれ番号付きの複数行にまとめる。
+// 期待結果が一つも無い場合は列を空にする
+func formatExportSteps(steps []util.TestCaseStep) (string, string) {
+	actions := []string{}
+	expectedResults := []string{}
+	hasExpectedResult := false
+	for i, step := range steps {
+		actions = append(actions, strings.TrimSpace(fmt.Sprintf("%d. %s", i+1, step.Action)))
+		expectedResults = append(expectedResults, strings.TrimSpace(fmt.Sprintf("%d. %s", i+1, step.ExpectedResult)))
+		if step.ExpectedResult != "" {
+			hasExpectedResult = true
+		}
+	}
+	if !hasExpectedResult {
+		expectedResults = nil
+	}
+	return strings.Join(actions, "\n"), strings.Join(expectedResults, "\n")
+}
+
+// testCaseExportMarkdown はスイートを見出しの階層で表した Markdown を返す
+func testCaseExportMarkdown(project model.Project, exported []exportedTestCase) string {
+	var b strings.Builder
+	b.WriteString("# " + project.Title + "\n")
+
+	var currentPath []string
+	for _, item := range exported {
+		// 前のテストケースと異なる階層からスイートの見出しを書く
+		common := 0
+		for common < len(currentPath) && common < len(item.TestSuitePath) && currentPath[common] == item.TestSuitePath[common] {
+			common++
+		}
+		for depth := common; depth < len(item.TestSuitePath); depth++ {
+			b.WriteString("\n" + markdownHeading(depth+2) + " " + item.TestSuitePath[depth] + "\n")
+		}
+		currentPath = item.TestSuitePath
+
+		testCase := item.TestCase
+		b.WriteString("\n" + markdownHeading(len(item.TestSuitePath)+2) + " " + testCase.Title + "\n\n")
+		if testCase.Milestone.Title != "" {
+			b.WriteString("- Milestone: " + testCase.Milestone.Title + "\n")
+		}
+		if tags := testCaseTagNames(testCase.Tags); len(tags) > 0 {
+			b.WriteString("- Tags: " + strings.Join(tags, ", ") + "\n")
+		}
+		if testCase.CreatedBy.Name != "" {
+			b.WriteString("- Created By: " + testCase.CreatedBy.Name + "\n")
+		}
+		if testCase.UpdatedBy.Name != "" {
+			b.WriteString("- Updated By: " + testCase.UpdatedBy.Name + "\n")
+		}
+		values := testCaseCustomFieldMap(testCase.CustomFields)
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.WriteString("- " + name + ": " + values[name] + "\n")
+		}
+
+		if content := strings.TrimSpace(testCase.Content); content != "" {
+			b.WriteString("\n" + content + "\n")
+		}
+
+		if steps := testCaseStepResponses(testCase.Steps); len(steps) > 0 {
+			b.WriteString("\n| # | Step | Expected Result |\n| --- | --- | --- |\n")
+			for i, step := range steps {
+				b.WriteString(fmt.Sprintf("| %d | %s | %s |\n", i+1, markdownTableCell(step.Action), markdownTableCell(step.ExpectedResult)))
+			}
+		}
+	}
+	return b.String()
+}
+
+// markdownHeading は見出しの記号を返す（Markdown の見出しは6段までなので深い階層は6段目にまとめる）
+func markdownHeading(level int) string {
+	if level > 6 {
+		level = 6
+	}
+	return strings.Repeat("#", level)
+}
+
+func markdownTableCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	value = strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", "\n"), "\n", "<br>")
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChildrenLimit = 100
+	maxChildrenLimit     = 500
+)
+
+var testCaseSortColumns = map[string]string{
+	"order_index": "test_cases.order_index",
+	"title":       "test_cases.title",
+	"created_at":  "test_cases.created_at",
+	"updated_at":  "test_cases.updated_at",
+}
+
+// testCaseFilter はテストケース一覧のクエリパラメータを表す
+type testCaseFilter struct {
+	Query        string
+	MilestoneID  *uint
+	CreatedByID  *uint
+	UpdatedSince *time.Time
+	Tags         []string
+	CustomFields map[string]string
+	States       []string
+	Selectable   bool // ランに追加できるテストケース（廃止したものを除く）のみ
+	SortColumn   string
+	SortDesc     bool
+}
+
+func parseTestCaseFilter(c *gin.Context) (testCaseFilter, error) {
+	filter := testCaseFilter{
+		Query:        strings.TrimSpace(c.Query("q")),
+		CustomFields: c.QueryMap("cf"),
+		SortColumn:   testCaseSortColumns["order_index"],
+	}
+
+	if v := c.Query("milestone_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid milestone_id")
+		}
+		milestoneID := uint(id)
+		filter.MilestoneID = &milestoneID
+	}
+
+	if v := c.Query("created_by_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid created_by_id")
+		}
+		createdByID := uint(id)
+		filter.CreatedByID = &createdByID
+	}
+
+	if v := c.Query("updated_since"); v != "" {
+		updatedSince, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			updatedSince, err = time.Parse("2006-01-02", v)
+			if err != nil {
+				return filter, errors.New("invalid updated_since")
+			}
+		}
+		filter.UpdatedSince = &updatedSince
+	}
+
+	if v := c.Query("tags"); v != "" {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	if v := c.Query("state"); v != "" {
+		for _, state := range strings.Split(v, ",") {
+			switch state = strings.TrimSpace(state); state {
+			case "":
+			case testCaseStateDraft, testCaseStateInReview, testCaseStateApproved, testCaseStateDeprecated:
+				filter.States = append(filter.States, state)
+			default:
+				return filter, errors.New("invalid state")
+			}
+		}
+	}
+
+	if v := c.Query("selectable"); v != "" {
+		selectable, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid selectable")
+		}
+		filter.Selectable = selectable
+	}
+
+	if v := c.Query("sort"); v != "" {
+		column, ok := testCaseSortColumns[v]
+		if !ok {
+			return filter, errors.New("invalid sort")
+		}
+		filter.SortColumn = column
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		filter.SortDesc = true
+	default:
+		return filter, errors.New("invalid order")
+	}
+
+	return filter, nil
+}
+
+// isActive は絞り込み条件が一つでも指定されているかを返す（並び順は含まない）
+func (f testCaseFilter) isActive() bool {
+	return f.Query != "" ||
+		f.MilestoneID != nil ||
+		f.CreatedByID != nil ||
+		f.UpdatedSince != nil ||
+		len(f.Tags) > 0 ||
+		len(f.CustomFields) > 0 ||
+		len(f.States) > 0 ||
+		f.Selectable
+}
+
+func (f testCaseFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.Query != "" {
+		// 入力に含まれる % と _ はワイルドカードではなく文字として探す
+		like := "%" + likeEscaper.Replace(f.Query) + "%"
+		db = db.Where("(test_cases.title LIKE ? ESCAPE '\\\\' OR test_cases.content LIKE ? ESCAPE '\\\\')", like, like)
+	}
+	if f.MilestoneID != nil {
+		db = db.Where("test_cases.milestone_id = ?", *f.MilestoneID)
+	}
+	if f.CreatedByID != nil {
+		db = db.Where("test_cases.created_by_id = ?", *f.CreatedByID)
+	}
+	if f.UpdatedSince != nil {
+		db = db.Where("test_cases.updated_at >= ?", *f.UpdatedSince)
+	}
+	if len(f.Tags) > 0 {
+		db = db.Where("test_cases.id IN (SELECT test_case_id FROM test_case_tags WHERE name IN ? AND deleted_at IS NULL GROUP BY test_case_id HAVING COUNT(DISTINCT name) = ?)", f.Tags, len(f.Tags))
+	}
+	if len(f.States) > 0 {
+		db = db.Where("test_cases.state IN ?", f.States)
+	}
+	if f.Selectable {
+		db = db.Where("test_cases.state <> ?", testCaseStateDeprecated)
+	}
+	names := make([]string, 0, len(f.CustomFields))
+	for name := range f.CustomFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		db = db.Where("test_cases.id IN (SELECT test_case_id FROM test_case_custom_fields WHERE name = ? AND value = ? AND deleted_at IS NULL)", name, f.CustomFields[name])
+	}
+	return db
+}
+
+func (f testCaseFilter) order() string {
+	direction := " ASC"
+	if f.SortDesc {
+		direction = " DESC"
+	}
+	return f.SortColumn + direction + ", test_cases.id" + direction
+}
+
+// likeEscaper は LIKE のパターンで特別な意味を持つ文字をエスケープする
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func parseChildrenOffset(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(v)
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid offset")
+	}
+	return offset, nil
+}
+
+func testCaseTagNames(tags []model.TestCaseTag) []string {
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func testCaseCustomFieldMap(fields []model.TestCaseCustomField) map[string]string {
+	values := map[string]string{}
+	for _, field := range fields {
+		values[field.Name] = field.Value
+	}
+	return values
+}
+
+func testCaseStepResponses(steps []model.TestCaseStep) []util.TestCaseStep {
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].OrderIndex < steps[j].OrderIndex
+	})
+	responses := []util.TestCaseStep{}
+	for _, step := range steps {
+		responses = append(responses, util.TestCaseStep{Action: step.Action, ExpectedResult: step.ExpectedResult, SharedStepID: step.SharedStepID})
+	}
+	return responses
+}
+
+// orderTestCaseSteps はテスト手順を並び順で読み込むための Preload 条件
+func orderTestCaseSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("order_index, id")
+}
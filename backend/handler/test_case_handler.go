@@ -5,10 +5,13 @@ import (
 	"backend/util"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type TestCaseHandler struct {
@@ -26,6 +29,15 @@ func handleError(c *gin.Context, status int, msg string, err error) {
 	c.JSON(status, gin.H{"error": msg})
 }
 
+// parseBoolQuery はクエリパラメータを真偽値として読む。指定が無い場合は false とする
+func parseBoolQuery(c *gin.Context, name string) (bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
 func createTestCaseResponse(testCase model.TestCase) util.TestCase {
 	var milestone *util.TestCaseMilestone
 	if testCase.MilestoneID != nil {
@@ -33,13 +45,162 @@ func createTestCaseResponse(testCase model.TestCase) util.TestCase {
 	}
 
 	return util.TestCase{
-		ID:        testCase.ID,
-		Title:     testCase.Title,
-		Content:   testCase.Content,
-		Milestone: milestone,
-		CreatedBy: util.User{ID: testCase.CreatedByID, Name: testCase.CreatedBy.Name},
-		UpdatedBy: util.User{ID: testCase.UpdatedByID, Name: testCase.UpdatedBy.Name},
+		ID:           testCase.ID,
+		Title:        testCase.Title,
+		Content:      testCase.Content,
+		State:        testCase.State,
+		Milestone:    milestone,
+		Tags:         testCaseTagNames(testCase.Tags),
+		CustomFields: testCaseCustomFieldMap(testCase.CustomFields),
+		Steps:        testCaseStepResponses(testCase.Steps),
+		Parameters:   testCaseParameterResponse(testCase.Parameter),
+		TemplateID:   testCase.TemplateID,
+		CreatedBy:    util.User{ID: testCase.CreatedByID, Name: testCase.CreatedBy.Name},
+		UpdatedBy:    util.User{ID: testCase.UpdatedByID, Name: testCase.UpdatedBy.Name},
+	}
+}
+
+type testCaseAttributesRequest struct {
+	Tags         *[]string                `json:"tags"`
+	CustomFields *map[string]string       `json:"custom_fields"`
+	Steps        *[]util.TestCaseStep     `json:"steps"`
+	Parameters   *util.TestCaseParameters `json:"parameters"`
+}
+
+// normalizeParameters は保存する前にパラメータ表を確認する。列が無い場合は空の表にする（保存時に削除する）
+func (attributes *testCaseAttributesRequest) normalizeParameters() error {
+	if attributes.Parameters == nil {
+		return nil
+	}
+	parameters, err := normalizeTestCaseParameters(*attributes.Parameters)
+	if err != nil {
+		return err
+	}
+	if parameters == nil {
+		parameters = &util.TestCaseParameters{}
+	}
+	attributes.Parameters = parameters
+	return nil
+}
+
+// saveTestCaseAttributes はリクエストに含まれるタグ・カスタムフィールド・手順・パラメータ表で既存の値を置き換える
+func saveTestCaseAttributes(db *gorm.DB, testCase *model.TestCase, attributes testCaseAttributesRequest) error {
+	if attributes.Tags != nil {
+		if err := db.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseTag{}).Error; err != nil {
+			return err
+		}
+		tags := buildTestCaseTags(testCase.ID, *attributes.Tags)
+		if len(tags) > 0 {
+			if err := db.Create(&tags).Error; err != nil {
+				return err
+			}
+		}
+		testCase.Tags = tags
 	}
+
+	if attributes.CustomFields != nil {
+		if err := db.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseCustomField{}).Error; err != nil {
+			return err
+		}
+		fields := buildTestCaseCustomFields(testCase.ID, *attributes.CustomFields)
+		if len(fields) > 0 {
+			if err := db.Create(&fields).Error; err != nil {
+				return err
+			}
+		}
+		testCase.CustomFields = fields
+	}
+
+	if attributes.Steps != nil {
+		if err := validateSharedStepReferences(db, testCase.ProjectID, *attributes.Steps); err != nil {
+			return err
+		}
+		if err := db.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseStep{}).Error; err != nil {
+			return err
+		}
+		steps := buildTestCaseSteps(testCase.ID, *attributes.Steps)
+		if len(steps) > 0 {
+			if err := db.Create(&steps).Error; err != nil {
+				return err
+			}
+		}
+		testCase.Steps = steps
+	}
+
+	if attributes.Parameters != nil {
+		if err := db.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseParameter{}).Error; err != nil {
+			return err
+		}
+		testCase.Parameter = nil
+		if len(attributes.Parameters.Names) > 0 {
+			parameter := buildTestCaseParameter(testCase.ID, *attributes.Parameters)
+			if err := db.Create(parameter).Error; err != nil {
+				return err
+			}
+			testCase.Parameter = parameter
+		}
+	}
+
+	return nil
+}
+
+// buildTestCaseTags は空白や重複を取り除いたタグを組み立てる
+func buildTestCaseTags(testCaseID uint, names []string) []model.TestCaseTag {
+	tags := []model.TestCaseTag{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, model.TestCaseTag{TestCaseID: testCaseID, Name: name})
+	}
+	return tags
+}
+
+// buildTestCaseCustomFields は名前順に並べたカスタムフィールドを組み立てる
+func buildTestCaseCustomFields(testCaseID uint, values map[string]string) []model.TestCaseCustomField {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := []model.TestCaseCustomField{}
+	for _, name := range names {
+		fields = append(fields, model.TestCaseCustomField{TestCaseID: testCaseID, Name: name, Value: values[name]})
+	}
+	return fields
+}
+
+// buildTestCaseSteps は空の手順を除いて並び順を振ったテスト手順を組み立てる。
+// 共有ステップから展開した手順は、同じ共有ステップが続く間を一つの参照にまとめる
+func buildTestCaseSteps(testCaseID uint, steps []util.TestCaseStep) []model.TestCaseStep {
+	testCaseSteps := []model.TestCaseStep{}
+	for _, step := range steps {
+		if step.SharedStepID != nil {
+			if n := len(testCaseSteps); n > 0 && testCaseSteps[n-1].SharedStepID != nil && *testCaseSteps[n-1].SharedStepID == *step.SharedStepID {
+				continue
+			}
+			sharedStepID := *step.SharedStepID
+			testCaseSteps = append(testCaseSteps, model.TestCaseStep{
+				TestCaseID:   testCaseID,
+				OrderIndex:   len(testCaseSteps),
+				SharedStepID: &sharedStepID,
+			})
+			continue
+		}
+		if strings.TrimSpace(step.Action) == "" && strings.TrimSpace(step.ExpectedResult) == "" {
+			continue
+		}
+		testCaseSteps = append(testCaseSteps, model.TestCaseStep{
+			TestCaseID:     testCaseID,
+			OrderIndex:     len(testCaseSteps),
+			Action:         step.Action,
+			ExpectedResult: step.ExpectedResult,
+		})
+	}
+	return testCaseSteps
 }
 
 func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
@@ -49,6 +210,12 @@ func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseTestCaseFilter(c)
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid filter parameters", err)
+		return
+	}
+
 	var project model.Project
 	if result := h.DB.Where("code = ?", projectCode).First(&project); result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
@@ -59,35 +226,40 @@ func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
 		return
 	}
 
+	// 階層の深さに関わらず、スイートとテストケースをそれぞれ一度のクエリで取得してメモリ上で木を組み立てる
 	testSuites := []model.TestSuite{}
 	if result := h.DB.Where("project_id = ?", project.ID).
-		Order("test_suites.order_index ASC, test_suites.id ASC"). // TestSuite の並び順をここで指定
-		Preload("TestCases", func(db *gorm.DB) *gorm.DB {
-			return db.Order("test_cases.order_index ASC, test_cases.id ASC")
-		}).
-		Preload("TestCases.Milestone").
-		Preload("TestCases.CreatedBy").
-		Preload("TestCases.UpdatedBy").
-		Preload("TestSuites.TestCases", func(db *gorm.DB) *gorm.DB {
-			return db.Order("test_cases.order_index ASC, test_cases.id ASC")
-		}).
-		Preload("TestSuites.TestCases.Milestone").
+		Order("test_suites.order_index ASC, test_suites.id ASC").
 		Find(&testSuites); result.Error != nil {
 		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
 		return
 	}
 
-	testSuiteMap := make(map[uint][]model.TestSuite)
-	for _, testSuite := range testSuites {
-		var parentID uint
-		if testSuite.ParentID != nil {
-			parentID = *testSuite.ParentID
-		}
-		testSuiteMap[parentID] = append(testSuiteMap[parentID], testSuite)
+	testCases := []model.TestCase{}
+	if result := filter.apply(h.DB.Where("test_cases.project_id = ?", project.ID)).
+		Where("test_cases.test_suite_id IS NOT NULL").
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Preload("Tags").
+		Preload("CustomFields").
+		Order(filter.order()).
+		Find(&testCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
 	}
 
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+	testCaseMap := make(map[uint][]model.TestCase)
+	directCounts := make(map[uint]int)
+	for _, testCase := range testCases {
+		testCaseMap[*testCase.TestSuiteID] = append(testCaseMap[*testCase.TestSuiteID], testCase)
+		directCounts[*testCase.TestSuiteID]++
+	}
+	caseCounts := countSubtreeTestCases(directCounts, testSuiteMap)
+
 	topLevelTestSuites := testSuiteMap[0]
-	jsonTestSuites := convertToJSONTestSuites(topLevelTestSuites, testSuiteMap)
+	jsonTestSuites := convertToJSONTestSuites(topLevelTestSuites, testSuiteMap, testCaseMap, caseCounts, filter.isActive())
 	jsonOnlyTestSuites := convertToJSONOnlyTestSuites(topLevelTestSuites, testSuiteMap)
 
 	responseData := util.TestCasesResponseData{
@@ -99,23 +271,155 @@ func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
 	c.JSON(http.StatusOK, responseData)
 }
 
-func convertToJSONTestSuites(testSuites []model.TestSuite, testSuiteMap map[uint][]model.TestSuite) []util.JSONTestSuite {
-	jsonTestSuites := []util.JSONTestSuite{}
+// GetTestCaseChildren は指定したスイート直下の子スイートとテストケースをページングして返す（大規模プロジェクトの遅延読み込み用）
+func (h *TestCaseHandler) GetTestCaseChildren(c *gin.Context) {
+	projectCode := c.Param("project_code")
+	if projectCode == "" {
+		handleError(c, http.StatusBadRequest, "Project Code is required", nil)
+		return
+	}
+
+	filter, err := parseTestCaseFilter(c)
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid filter parameters", err)
+		return
+	}
+
+	offset, err := parseChildrenOffset(c.Query("offset"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid offset", err)
+		return
+	}
+
+	limit := defaultChildrenLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			handleError(c, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		if limit > maxChildrenLimit {
+			limit = maxChildrenLimit
+		}
+	}
+
+	var project model.Project
+	if result := h.DB.Where("code = ?", projectCode).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	var testSuiteID *uint
+	if v := c.Query("suite_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid suite_id", err)
+			return
+		}
+		suiteID := uint(id)
+		testSuiteID = &suiteID
+	}
+
+	var testSuites []model.TestSuite
+	if result := h.DB.Where("project_id = ?", project.ID).
+		Order("test_suites.order_index ASC, test_suites.id ASC").
+		Find(&testSuites); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test suites", result.Error)
+		return
+	}
+
+	var matchedTestCases []model.TestCase
+	if result := filter.apply(h.DB.Model(&model.TestCase{})).
+		Select("test_cases.id, test_cases.test_suite_id").
+		Where("test_cases.project_id = ?", project.ID).
+		Find(&matchedTestCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", result.Error)
+		return
+	}
+
+	directCounts := make(map[uint]int)
+	for _, testCase := range matchedTestCases {
+		if testCase.TestSuiteID != nil {
+			directCounts[*testCase.TestSuiteID]++
+		}
+	}
+
+	suiteExists := false
+	var parentID uint
+	if testSuiteID != nil {
+		parentID = *testSuiteID
+	}
 	for _, testSuite := range testSuites {
-		jsonTestCases := []util.TestCase{}
-		for _, testCase := range testSuite.TestCases {
-			jsonTestCases = append(jsonTestCases, createTestCaseResponse(testCase))
+		if testSuiteID != nil && testSuite.ID == *testSuiteID {
+			suiteExists = true
 		}
+	}
+	if testSuiteID != nil && !suiteExists {
+		handleError(c, http.StatusNotFound, "TestSuite not found", nil)
+		return
+	}
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+	caseCounts := countSubtreeTestCases(directCounts, testSuiteMap)
 
-		subTestSuites := convertToJSONTestSuites(testSuiteMap[testSuite.ID], testSuiteMap)
+	jsonTestSuites := []util.JSONTestSuite{}
+	for _, testSuite := range testSuiteMap[parentID] {
+		if filter.isActive() && caseCounts[testSuite.ID] == 0 {
+			continue
+		}
 		jsonTestSuites = append(jsonTestSuites, util.JSONTestSuite{
 			ID:         testSuite.ID,
 			Name:       testSuite.Name,
-			TestSuites: subTestSuites,
-			TestCases:  jsonTestCases,
+			CaseCount:  caseCounts[testSuite.ID],
+			TestSuites: []util.JSONTestSuite{},
+			TestCases:  []util.TestCase{},
 		})
 	}
-	return jsonTestSuites
+
+	query := filter.apply(h.DB.Where("test_cases.project_id = ?", project.ID))
+	if testSuiteID != nil {
+		query = query.Where("test_cases.test_suite_id = ?", *testSuiteID)
+	} else {
+		query = query.Where("test_cases.test_suite_id IS NULL")
+	}
+	var testCases []model.TestCase
+	if result := query.
+		Preload("Milestone").
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Preload("Tags").
+		Preload("CustomFields").
+		Order(filter.order()).
+		Offset(offset).
+		Limit(limit + 1).
+		Find(&testCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", result.Error)
+		return
+	}
+
+	// 次のページの offset。並び順の位置で数えるため、ページを読む間に追加や削除があると行がずれることがある
+	var nextOffset *int
+	if len(testCases) > limit {
+		testCases = testCases[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	jsonTestCases := []util.TestCase{}
+	for _, testCase := range testCases {
+		jsonTestCases = append(jsonTestCases, createTestCaseResponse(testCase))
+	}
+
+	c.JSON(http.StatusOK, util.TestSuiteChildrenResponseData{
+		ProjectID:   project.ID,
+		TestSuiteID: testSuiteID,
+		TestSuites:  jsonTestSuites,
+		TestCases:   jsonTestCases,
+		NextOffset:  nextOffset,
+	})
 }
 
 func convertToJSONOnlyTestSuites(testSuites []model.TestSuite, testSuiteMap map[uint][]model.TestSuite) []util.JSONOnlyTestSuite {
@@ -145,7 +449,7 @@ func (h *TestCaseHandler) GetTestCase(c *gin.Context) {
 	}
 
 	var testCase model.TestCase
-	if result := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Preload("Milestone").First(&testCase, id); result.Error != nil {
+	if result := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Preload("Milestone").Preload("Tags").Preload("CustomFields").Preload("Steps", orderTestCaseSteps).Preload("Parameter").First(&testCase, id); result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			handleError(c, http.StatusNotFound, "Test case not found", result.Error)
 		} else {
@@ -154,21 +458,76 @@ func (h *TestCaseHandler) GetTestCase(c *gin.Context) {
 		return
 	}
 
+	if err := resolveSharedSteps(h.DB, &testCase.Steps); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+		return
+	}
 	c.JSON(http.StatusOK, createTestCaseResponse(testCase))
 }
 
 func (h *TestCaseHandler) PostTestCase(c *gin.Context) {
 	var newTestCase model.TestCase
-	if err := c.ShouldBindJSON(&newTestCase); err != nil {
+	if err := c.ShouldBindBodyWith(&newTestCase, binding.JSON); err != nil {
 		handleError(c, http.StatusBadRequest, "Invalid request data", err)
 		return
 	}
 
-	if result := h.DB.Create(&newTestCase); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to create test case", result.Error)
+	var attributes testCaseAttributesRequest
+	if err := c.ShouldBindBodyWith(&attributes, binding.JSON); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	if err := attributes.normalizeParameters(); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid parameters", err)
+		return
+	}
+
+	// テンプレートを指定した場合は内容・手順・カスタムフィールドを補って検証する
+	if newTestCase.TemplateID != nil {
+		template, err := loadTestCaseTemplate(h.DB, newTestCase.ProjectID, *newTestCase.TemplateID)
+		if err != nil {
+			if errors.Is(err, errTestCaseTemplateNotFound) {
+				handleError(c, http.StatusBadRequest, "Template not found in project", err)
+				return
+			}
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve template", err)
+			return
+		}
+		values := testCaseTemplateValues{Content: newTestCase.Content, Steps: attributes.Steps}
+		if attributes.CustomFields != nil {
+			values.CustomFields = *attributes.CustomFields
+		}
+		values, err = applyTestCaseTemplate(template, values)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid custom fields", err)
+			return
+		}
+		newTestCase.Content = values.Content
+		attributes.Steps = values.Steps
+		attributes.CustomFields = &values.CustomFields
+	}
+
+	// 状態はレビューの操作でのみ変更する
+	newTestCase.State = testCaseStateDraft
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newTestCase).Error; err != nil {
+			return err
+		}
+		return saveTestCaseAttributes(tx, &newTestCase, attributes)
+	}); err != nil {
+		if errors.Is(err, errSharedStepNotFound) {
+			handleError(c, http.StatusBadRequest, "Shared step not found in project", err)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to create test case", err)
 		return
 	}
 
+	if err := resolveSharedSteps(h.DB, &newTestCase.Steps); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+		return
+	}
 	c.JSON(http.StatusCreated, createTestCaseResponse(newTestCase))
 }
 
@@ -181,10 +540,20 @@ func (h *TestCaseHandler) PutTestCase(c *gin.Context) {
 	id := uint(idInt)
 
 	var updatedTestCase model.TestCase
-	if err := c.ShouldBindJSON(&updatedTestCase); err != nil {
+	if err := c.ShouldBindBodyWith(&updatedTestCase, binding.JSON); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+
+	var attributes testCaseAttributesRequest
+	if err := c.ShouldBindBodyWith(&attributes, binding.JSON); err != nil {
 		handleError(c, http.StatusBadRequest, "Invalid request data", err)
 		return
 	}
+	if err := attributes.normalizeParameters(); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid parameters", err)
+		return
+	}
 
 	var existingTestCase model.TestCase
 	if result := h.DB.First(&existingTestCase, id); result.Error != nil {
@@ -196,18 +565,49 @@ func (h *TestCaseHandler) PutTestCase(c *gin.Context) {
 		return
 	}
 
-	if result := h.DB.Model(&existingTestCase).Updates(updatedTestCase); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to update test case", result.Error)
-		return
+	// 書き込む前に共有ステップの参照を確認する
+	if attributes.Steps != nil {
+		if err := validateSharedStepReferences(h.DB, existingTestCase.ProjectID, *attributes.Steps); err != nil {
+			if errors.Is(err, errSharedStepNotFound) {
+				handleError(c, http.StatusBadRequest, "Shared step not found in project", err)
+				return
+			}
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+			return
+		}
+	}
+
+	// テンプレートは作成時にのみ設定する
+	updatedTestCase.TemplateID = nil
+	// 承認済みのテストケースを編集した場合は再度レビューが必要になる
+	updatedTestCase.State = ""
+	if existingTestCase.State == testCaseStateApproved {
+		updatedTestCase.State = testCaseStateDraft
 	}
 
-	if updatedTestCase.MilestoneID == nil {
-		if result := h.DB.Model(&existingTestCase).Select("MilestoneID").Updates(updatedTestCase); result.Error != nil {
-			handleError(c, http.StatusInternalServerError, "Failed to update test case", result.Error)
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingTestCase).Updates(updatedTestCase).Error; err != nil {
+			return err
+		}
+		if updatedTestCase.MilestoneID == nil {
+			if err := tx.Model(&existingTestCase).Select("MilestoneID").Updates(updatedTestCase).Error; err != nil {
+				return err
+			}
+		}
+		return saveTestCaseAttributes(tx, &existingTestCase, attributes)
+	}); err != nil {
+		if errors.Is(err, errSharedStepNotFound) {
+			handleError(c, http.StatusBadRequest, "Shared step not found in project", err)
 			return
 		}
+		handleError(c, http.StatusInternalServerError, "Failed to update test case", err)
+		return
 	}
 
+	if err := resolveSharedSteps(h.DB, &existingTestCase.Steps); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve shared steps", err)
+		return
+	}
 	c.JSON(http.StatusOK, createTestCaseResponse(existingTestCase))
 }
 
@@ -219,13 +619,15 @@ func (h *TestCaseHandler) DeleteTestCase(c *gin.Context) {
 		return
 	}
 
-	if result := h.DB.Delete(&model.TestCase{}, id); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to delete test case", result.Error)
-		return
-	}
-
-	if result := h.DB.Where("test_case_id = ?", id).Delete(&model.TestRunCase{}); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to delete test run case", result.Error)
+	// 一緒に削除したラン上のケースをゴミ箱から復元できるよう削除日時を揃える
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		tx = withDeletionTime(tx, deletionTimestamp())
+		if err := tx.Where("test_case_id = ?", id).Delete(&model.TestRunCase{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.TestCase{}, id).Error
+	}); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete test case", err)
 		return
 	}
 
@@ -279,6 +681,8 @@ func (h *TestCaseHandler) PutTestSuite(c *gin.Context) {
 	c.JSON(http.StatusOK, existingTestSuite)
 }
 
+// DeleteTestSuite はスイートを子孫スイート・配下のテストケースごと一つのトランザクションで削除する。
+// dry_run=true の場合は削除せずに影響件数を返し、keep_results=true の場合はテストラン上の結果を残す
 func (h *TestCaseHandler) DeleteTestSuite(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -287,24 +691,92 @@ func (h *TestCaseHandler) DeleteTestSuite(c *gin.Context) {
 		return
 	}
 
-	if result := h.DB.Delete(&model.TestSuite{}, id); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to delete test suite", result.Error)
+	dryRun, err := parseBoolQuery(c, "dry_run")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		return
+	}
+	keepResults, err := parseBoolQuery(c, "keep_results")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid keep_results", err)
 		return
 	}
 
-	if result := h.DB.Where("test_suite_id = ?", id).Delete(&model.TestCase{}); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to delete test case", result.Error)
+	var testSuite model.TestSuite
+	if result := h.DB.First(&testSuite, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "TestSuite not found", result.Error)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", result.Error)
+		}
 		return
 	}
 
-	if result := h.DB.Where("test_case_id IN (SELECT id FROM test_cases WHERE test_suite_id = ?)", id).Delete(&model.TestRunCase{}); result.Error != nil {
-		handleError(c, http.StatusInternalServerError, "Failed to delete test run case", result.Error)
+	if dryRun {
+		testSuiteIDs, testCaseIDs, err := findTestSuiteDeletion(h.DB, testSuite)
+		if err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test suites", err)
+			return
+		}
+		var testRunCaseCount int64
+		if len(testCaseIDs) > 0 {
+			if result := h.DB.Model(&model.TestRunCase{}).Where("test_case_id IN ?", testCaseIDs).Count(&testRunCaseCount); result.Error != nil {
+				handleError(c, http.StatusInternalServerError, "Failed to count test run cases", result.Error)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, util.TestSuiteDeletionSummary{
+			DryRun:       true,
+			KeepResults:  keepResults,
+			TestSuites:   len(testSuiteIDs),
+			TestCases:    len(testCaseIDs),
+			TestRunCases: int(testRunCaseCount),
+		})
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 読み込みと削除の間に配下が変わらないように、対象はトランザクションの中で読み込む
+		testSuiteIDs, testCaseIDs, err := findTestSuiteDeletion(tx, testSuite)
+		if err != nil {
+			return err
+		}
+		tx = withDeletionTime(tx, deletionTimestamp())
+		// テストケースを削除する前にラン上のケースを削除する
+		if len(testCaseIDs) > 0 {
+			if !keepResults {
+				if err := tx.Where("test_case_id IN ?", testCaseIDs).Delete(&model.TestRunCase{}).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("id IN ?", testCaseIDs).Delete(&model.TestCase{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", testSuiteIDs).Delete(&model.TestSuite{}).Error
+	}); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete test suite", err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+// findTestSuiteDeletion はスイートと子孫スイートの ID と、それらの配下のテストケースの ID を返す
+func findTestSuiteDeletion(db *gorm.DB, testSuite model.TestSuite) ([]uint, []uint, error) {
+	var projectTestSuites []model.TestSuite
+	if err := db.Where("project_id = ?", testSuite.ProjectID).Find(&projectTestSuites).Error; err != nil {
+		return nil, nil, err
+	}
+	testSuiteIDs := collectTestSuiteSubtree(testSuite.ID, groupTestSuitesByParent(projectTestSuites))
+
+	var testCaseIDs []uint
+	if err := db.Model(&model.TestCase{}).Where("test_suite_id IN ?", testSuiteIDs).Pluck("id", &testCaseIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	return testSuiteIDs, testCaseIDs, nil
+}
+
 type TestCasePutRequestRow struct {
 	TestCaseID uint `json:"test_case_id"`
 	OrderIndex int  `json:"index"`
@@ -337,23 +809,104 @@ func (h *TestCaseHandler) PutTestCaseBulk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+
+	rows := make([]reorderRow, 0, len(req.TestCaseRequestRows))
+	seen := map[uint]bool{}
 	for _, row := range req.TestCaseRequestRows {
-		update := map[string]interface{}{
-			"test_suite_id": req.TestSuiteID,
-			"order_index":   row.OrderIndex,
-		}
-		if err := h.DB.Model(&model.TestCase{}).
-			Where("id = ?", row.TestCaseID).
-			Where("project_id", project.ID).
-			Updates(update).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
+		if seen[row.TestCaseID] {
+			handleError(c, http.StatusBadRequest, "Duplicate test case in request", nil)
 			return
 		}
+		seen[row.TestCaseID] = true
+		rows = append(rows, reorderRow{ID: row.TestCaseID, OrderIndex: row.OrderIndex})
+	}
+
+	// 並び順は同時に行われた移動や追加を含めて求めるため、読み込みもトランザクションの中で行う
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		updates, err := planTestCaseReorder(tx, project.ID, req.TestSuiteID, rows)
+		if err != nil {
+			return err
+		}
+		for _, update := range updates {
+			if err := tx.Model(&model.TestCase{}).
+				Where("id = ?", update.ID).
+				Updates(map[string]interface{}{
+					"test_suite_id": update.ParentID,
+					"order_index":   update.OrderIndex,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		switch {
+		case errors.Is(err, errTestSuiteNotInProject):
+			handleError(c, http.StatusBadRequest, "Test suite not found in project", err)
+		case errors.Is(err, errTestCaseNotInProject):
+			handleError(c, http.StatusBadRequest, "Test case not found in project", err)
+		default:
+			handleError(c, http.StatusInternalServerError, "Update failed", err)
+		}
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// errTestCaseNotInProject は並べ替えるテストケースが別のプロジェクトや存在しない場合のエラー
+var errTestCaseNotInProject = errors.New("test case not found in project")
+
+// planTestCaseReorder は移動先と移動元のスイートのテストケースを読み込み、リクエストを検証したうえで更新内容を求める
+func planTestCaseReorder(db *gorm.DB, projectID uint, testSuiteID uint, rows []reorderRow) ([]reorderUpdate, error) {
+	var testSuiteCount int64
+	if err := db.Model(&model.TestSuite{}).Where("id = ? AND project_id = ?", testSuiteID, projectID).Count(&testSuiteCount).Error; err != nil {
+		return nil, err
+	}
+	if testSuiteCount == 0 {
+		return nil, errTestSuiteNotInProject
+	}
+
+	// 移動元のスイートも並び順を詰めるため、移動するケースの現在のスイートを調べる
+	testSuiteIDs := []uint{testSuiteID}
+	if len(rows) > 0 {
+		testCaseIDs := make([]uint, 0, len(rows))
+		for _, row := range rows {
+			testCaseIDs = append(testCaseIDs, row.ID)
+		}
+		var movedTestCases []model.TestCase
+		if err := db.Where("project_id = ? AND id IN ?", projectID, testCaseIDs).Find(&movedTestCases).Error; err != nil {
+			return nil, err
+		}
+		if len(movedTestCases) != len(testCaseIDs) {
+			return nil, errTestCaseNotInProject
+		}
+		for _, testCase := range movedTestCases {
+			if testCase.TestSuiteID != nil && *testCase.TestSuiteID != testSuiteID {
+				testSuiteIDs = append(testSuiteIDs, *testCase.TestSuiteID)
+			}
+		}
+	}
+
+	var testCases []model.TestCase
+	if err := db.Where("test_suite_id IN ?", testSuiteIDs).Order("order_index, id").Find(&testCases).Error; err != nil {
+		return nil, err
+	}
+	entries := map[uint]reorderEntry{}
+	siblings := map[uint][]uint{}
+	for _, testCase := range testCases {
+		entries[testCase.ID] = reorderEntry{ParentID: *testCase.TestSuiteID, OrderIndex: testCase.OrderIndex}
+		siblings[*testCase.TestSuiteID] = append(siblings[*testCase.TestSuiteID], testCase.ID)
+	}
+	return planReorder(entries, siblings, testSuiteID, rows), nil
+}
+
+var (
+	errTestSuiteParentNotFound = errors.New("parent test suite not found in project")
+	errTestSuiteNotInProject   = errors.New("test suite not found in project")
+	errTestSuiteDuplicated     = errors.New("duplicate test suite in request")
+	errTestSuiteCycle          = errors.New("cannot move a test suite into itself or its descendants")
+)
+
 type TestSuiteRequestRow struct {
 	TestSuiteID uint `json:"test_suite_id"`
 	OrderIndex  int  `json:"index"`
@@ -386,30 +939,106 @@ func (h *TestCaseHandler) PutTestSuiteBulk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	for _, row := range req.TestCaseRequestRows {
-		update := map[string]interface{}{
-			"parent_id":   req.ParentID,
-			"order_index": row.OrderIndex,
-		}
-		if err := h.DB.Model(&model.TestSuite{}).
-			Where("id = ?", row.TestSuiteID).
-			Where("project_id = ?", project.ID).
-			Updates(update).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed"})
-			return
+
+	// 並び替えの計算に使う階層は更新と同じトランザクションで読み込む
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		updates, err := planTestSuiteReorder(tx, project.ID, req)
+		if err != nil {
+			return err
 		}
+		for _, update := range updates {
+			var parent *uint
+			if update.ParentID != 0 {
+				id := update.ParentID
+				parent = &id
+			}
+			if err := tx.Model(&model.TestSuite{}).
+				Where("id = ?", update.ID).
+				Updates(map[string]interface{}{
+					"parent_id":   parent,
+					"order_index": update.OrderIndex,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		switch {
+		case errors.Is(err, errTestSuiteParentNotFound):
+			handleError(c, http.StatusBadRequest, "Parent test suite not found in project", err)
+		case errors.Is(err, errTestSuiteNotInProject):
+			handleError(c, http.StatusBadRequest, "Test suite not found in project", err)
+		case errors.Is(err, errTestSuiteDuplicated):
+			handleError(c, http.StatusBadRequest, "Duplicate test suite in request", err)
+		case errors.Is(err, errTestSuiteCycle):
+			handleError(c, http.StatusBadRequest, "Cannot move a test suite into itself or its descendants", err)
+		default:
+			handleError(c, http.StatusInternalServerError, "Update failed", err)
+		}
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// planTestSuiteReorder はプロジェクトのスイート階層を読み込み、リクエストを検証したうえで更新内容を求める
+func planTestSuiteReorder(db *gorm.DB, projectID uint, req TestSuiteRequest) ([]reorderUpdate, error) {
+	var testSuites []model.TestSuite
+	if err := db.Where("project_id = ?", projectID).Order("order_index, id").Find(&testSuites).Error; err != nil {
+		return nil, err
+	}
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+	entries := map[uint]reorderEntry{}
+	siblings := map[uint][]uint{}
+	for _, testSuite := range testSuites {
+		var parentID uint
+		if testSuite.ParentID != nil {
+			parentID = *testSuite.ParentID
+		}
+		entries[testSuite.ID] = reorderEntry{ParentID: parentID, OrderIndex: testSuite.OrderIndex}
+		siblings[parentID] = append(siblings[parentID], testSuite.ID)
+	}
+
+	var parentID uint
+	if req.ParentID != nil {
+		if _, ok := entries[*req.ParentID]; !ok || *req.ParentID == 0 {
+			return nil, errTestSuiteParentNotFound
+		}
+		parentID = *req.ParentID
+	}
+
+	rows := make([]reorderRow, 0, len(req.TestCaseRequestRows))
+	seen := map[uint]bool{}
+	for _, row := range req.TestCaseRequestRows {
+		if _, ok := entries[row.TestSuiteID]; !ok {
+			return nil, errTestSuiteNotInProject
+		}
+		if seen[row.TestSuiteID] {
+			return nil, errTestSuiteDuplicated
+		}
+		seen[row.TestSuiteID] = true
+		// 自分自身や子孫の下へは移動できない
+		if parentID != 0 {
+			for _, id := range collectTestSuiteSubtree(row.TestSuiteID, testSuiteMap) {
+				if id == parentID {
+					return nil, errTestSuiteCycle
+				}
+			}
+		}
+		rows = append(rows, reorderRow{ID: row.TestSuiteID, OrderIndex: row.OrderIndex})
+	}
+	return planReorder(entries, siblings, parentID, rows), nil
+}
+
 type TestCasePostRequest struct {
-	TestSuiteName string `json:"test_suite_name"`
-	Title         string `json:"title"`
-	Content       string `json:"content"`
-	MilestoneId   *uint  `json:"milestone_id"`
-	CreatedById   uint   `json:"created_by_id"`
-	UpdatedById   uint   `json:"updated_by_id"`
+	TestSuiteName string            `json:"test_suite_name"`
+	TestSuitePath string            `json:"test_suite_path"`
+	Title         string            `json:"title"`
+	Content       string            `json:"content"`
+	MilestoneId   *uint             `json:"milestone_id"`
+	Tags          []string          `json:"tags"`
+	CustomFields  map[string]string `json:"custom_fields"`
+	TemplateID    *uint             `json:"template_id"`
 }
 
 type TestCasesPostRequest struct {
@@ -438,28 +1067,39 @@ func (h *TestCaseHandler) PostTestCasesBulk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	for _, row := range req.TestCases {
-		var testSuite model.TestSuite
-		err := h.DB.Where("name = ? AND project_id = ?", row.TestSuiteName, project.ID).FirstOrCreate(&testSuite, model.TestSuite{ProjectID: project.ID, Name: row.TestSuiteName}).Error
-		if err != nil {
-			handleError(c, http.StatusInternalServerError, "Failed to retrieve or create test suite", err)
-			return
-		}
+	dryRun, err := parseBoolQuery(c, "dry_run")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		return
+	}
 
-		insert := map[string]interface{}{
-			"project_id":    project.ID,
-			"test_suite_id": testSuite.ID,
-			"Title":         row.Title,
-			"content":       row.Content,
-			"milestone_id":  row.MilestoneId,
-			"created_by_id": row.CreatedById,
-			"updated_by_id": row.UpdatedById,
-		}
-		if err := h.DB.Model(&model.TestCase{}).Create(insert).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Insert failed"})
-			return
+	// 作成者・更新者はクライアントの指定ではなくログイン中のユーザーにする
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	rows := make([]testCaseImportRow, 0, len(req.TestCases))
+	for _, testCase := range req.TestCases {
+		// test_suite_path が無い場合は従来どおり test_suite_name を一階層のスイート名として扱う
+		path := []string{strings.TrimSpace(testCase.TestSuiteName)}
+		if testCase.TestSuitePath != "" {
+			path = splitTestSuitePath(testCase.TestSuitePath)
+		} else if path[0] == "" {
+			path = []string{}
 		}
+		rows = append(rows, testCaseImportRow{
+			TestSuitePath: path,
+			Title:         testCase.Title,
+			Content:       testCase.Content,
+			MilestoneID:   testCase.MilestoneId,
+			Tags:          testCase.Tags,
+			CustomFields:  testCase.CustomFields,
+			TemplateID:    testCase.TemplateID,
+		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	result, err := importTestCases(h.DB, project, accessUser, rows, dryRun)
+	writeTestCaseImportResult(c, result, err)
 }
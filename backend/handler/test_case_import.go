@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// testSuitePathSeparator はスイートのパス（例: "Auth/Login/SSO"）の区切り文字
+const testSuitePathSeparator = "/"
+
+// testCaseImportRow はインポートする1件のテストケースを表す
+type testCaseImportRow struct {
+	SourceFile     string // 複数のファイルを取り込む場合のファイル名
+	SourceRow      int    // 取り込み元ファイルの行番号（0 の場合は何件目かで表す）
+	TestSuitePath  []string
+	Title          string
+	Content        string
+	MilestoneID    *uint
+	MilestoneTitle string // MilestoneID が無い場合にタイトルでマイルストーンを探す
+	Tags           []string
+	CustomFields   map[string]string
+	Steps          []util.TestCaseStep
+	TemplateID     *uint // 内容・手順・カスタムフィールドを補うテンプレート
+}
+
+// splitTestSuitePath はスイートのパスを要素に分割する（前後の空白と空の要素は除く）
+func splitTestSuitePath(path string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(path, testSuitePathSeparator) {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// testSuitePathKey は内部で使うパスのキーを返す（スイート名に区切り文字が含まれていても衝突しないようにする）
+func testSuitePathKey(segments []string) string {
+	return strings.Join(segments, "\x00")
+}
+
+// testCaseImporter はテストケースを一つのプロジェクトへまとめて取り込む
+type testCaseImporter struct {
+	project        model.Project
+	user           model.User
+	testSuites     map[string]uint
+	milestones     map[uint]bool
+	milestoneIDs   map[string]uint
+	nextSuiteOrder map[uint]int
+	nextCaseOrder  map[uint]int
+	templates      map[uint]util.TestCaseTemplate
+}
+
+// newTestCaseImporter は既存のスイート・マイルストーン・並び順を読み込む
+func newTestCaseImporter(db *gorm.DB, project model.Project, user model.User) (*testCaseImporter, error) {
+	importer := &testCaseImporter{
+		project:        project,
+		user:           user,
+		testSuites:     map[string]uint{},
+		milestones:     map[uint]bool{},
+		milestoneIDs:   map[string]uint{},
+		nextSuiteOrder: map[uint]int{},
+		nextCaseOrder:  map[uint]int{},
+		templates:      map[uint]util.TestCaseTemplate{},
+	}
+
+	var testSuites []model.TestSuite
+	if err := db.Where("project_id = ?", project.ID).Order("order_index, id").Find(&testSuites).Error; err != nil {
+		return nil, err
+	}
+	testSuiteByID := make(map[uint]model.TestSuite, len(testSuites))
+	for _, testSuite := range testSuites {
+		testSuiteByID[testSuite.ID] = testSuite
+	}
+	for _, testSuite := range testSuites {
+		// 親を辿ってパスを組み立てる（循環していても止まるように訪問済みを記録する）
+		segments := []string{testSuite.Name}
+		visited := map[uint]bool{testSuite.ID: true}
+		for parentID := testSuite.ParentID; parentID != nil; {
+			parent, ok := testSuiteByID[*parentID]
+			if !ok || visited[parent.ID] {
+				break
+			}
+			visited[parent.ID] = true
+			segments = append([]string{parent.Name}, segments...)
+			parentID = parent.ParentID
+		}
+		// 同じ名前のスイートが並んでいる場合は先頭のものを使う
+		if key := testSuitePathKey(segments); importer.testSuites[key] == 0 {
+			importer.testSuites[key] = testSuite.ID
+		}
+
+		var parentID uint
+		if testSuite.ParentID != nil {
+			parentID = *testSuite.ParentID
+		}
+		if testSuite.OrderIndex >= importer.nextSuiteOrder[parentID] {
+			importer.nextSuiteOrder[parentID] = testSuite.OrderIndex + 1
+		}
+	}
+
+	var milestones []model.Milestone
+	if err := db.Where("project_id = ?", project.ID).Order("id").Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	for _, milestone := range milestones {
+		importer.milestones[milestone.ID] = true
+		if key := strings.ToLower(strings.TrimSpace(milestone.Title)); importer.milestoneIDs[key] == 0 {
+			importer.milestoneIDs[key] = milestone.ID
+		}
+	}
+
+	var caseOrders []struct {
+		TestSuiteID uint
+		OrderIndex  int
+	}
+	if err := db.Model(&model.TestCase{}).
+		Select("test_suite_id, MAX(order_index) AS order_index").
+		Where("project_id = ? AND test_suite_id IS NOT NULL", project.ID).
+		Group("test_suite_id").
+		Scan(&caseOrders).Error; err != nil {
+		return nil, err
+	}
+	for _, caseOrder := range caseOrders {
+		importer.nextCaseOrder[caseOrder.TestSuiteID] = caseOrder.OrderIndex + 1
+	}
+
+	return importer, nil
+}
+
+// loadTemplates は行で指定されたプロジェクトのテンプレートを読み込む
+func (i *testCaseImporter) loadTemplates(db *gorm.DB, rows []testCaseImportRow) error {
+	ids := []uint{}
+	for _, row := range rows {
+		if row.TemplateID != nil {
+			ids = append(ids, *row.TemplateID)
+		}
+	}
+	if ids = uniqueUints(ids); len(ids) == 0 {
+		return nil
+	}
+	var templates []model.TestCaseTemplate
+	if err := db.Where("project_id = ? AND id IN ?", i.project.ID, ids).Find(&templates).Error; err != nil {
+		return err
+	}
+	for _, template := range templates {
+		i.templates[template.ID] = createTestCaseTemplateResponse(template)
+	}
+	return nil
+}
+
+// validate は各行を検証し、行番号付きのエラーを返す。マイルストーンのタイトルは ID に解決し、テンプレートで値を補う
+func (i *testCaseImporter) validate(rows []testCaseImportRow) []util.TestCaseImportError {
+	errs := []util.TestCaseImportError{}
+	for index := range rows {
+		row := &rows[index]
+		rowNumber := row.SourceRow
+		if rowNumber == 0 {
+			rowNumber = index + 1
+		}
+		if strings.TrimSpace(row.Title) == "" {
+			errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "title", Message: "title is required"})
+		}
+		if len(row.TestSuitePath) == 0 {
+			errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "test_suite_path", Message: "test suite is required"})
+		}
+		if row.MilestoneID == nil && strings.TrimSpace(row.MilestoneTitle) != "" {
+			if id, ok := i.milestoneIDs[strings.ToLower(strings.TrimSpace(row.MilestoneTitle))]; ok {
+				row.MilestoneID = &id
+			} else {
+				errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "milestone", Message: "milestone not found in project"})
+			}
+		}
+		if row.MilestoneID != nil && !i.milestones[*row.MilestoneID] {
+			errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "milestone_id", Message: "milestone not found in project"})
+		}
+		if row.TemplateID != nil {
+			template, ok := i.templates[*row.TemplateID]
+			if !ok {
+				errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "template_id", Message: errTestCaseTemplateNotFound.Error()})
+				continue
+			}
+			values := testCaseTemplateValues{Content: row.Content, CustomFields: row.CustomFields}
+			if row.Steps != nil {
+				values.Steps = &row.Steps
+			}
+			values, err := applyTestCaseTemplate(template, values)
+			if err != nil {
+				errs = append(errs, util.TestCaseImportError{File: row.SourceFile, Row: rowNumber, Field: "custom_fields", Message: err.Error()})
+				continue
+			}
+			row.Content = values.Content
+			row.Steps = *values.Steps
+			row.CustomFields = values.CustomFields
+		}
+	}
+	return errs
+}
+
+// countNewTestSuites は取り込みで新しく作られるスイートの数を返す
+func (i *testCaseImporter) countNewTestSuites(rows []testCaseImportRow) int {
+	paths := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		paths = append(paths, row.TestSuitePath)
+	}
+	return i.countNewTestSuitePaths(paths)
+}
+
+// countNewTestSuitePaths はパスのスイートを用意するために新しく作られるスイートの数を返す
+func (i *testCaseImporter) countNewTestSuitePaths(paths [][]string) int {
+	created := map[string]bool{}
+	for _, path := range paths {
+		for depth := 1; depth <= len(path); depth++ {
+			key := testSuitePathKey(path[:depth])
+			if i.testSuites[key] == 0 {
+				created[key] = true
+			}
+		}
+	}
+	return len(created)
+}
+
+// ensureTestSuite はパスのスイートを返し、存在しない階層は作成する
+func (i *testCaseImporter) ensureTestSuite(tx *gorm.DB, segments []string) (uint, error) {
+	var parentID uint
+	for depth := 1; depth <= len(segments); depth++ {
+		key := testSuitePathKey(segments[:depth])
+		if id := i.testSuites[key]; id != 0 {
+			parentID = id
+			continue
+		}
+
+		testSuite := model.TestSuite{
+			ProjectID:  i.project.ID,
+			Name:       segments[depth-1],
+			OrderIndex: i.nextSuiteOrder[parentID],
+		}
+		if parentID != 0 {
+			id := parentID
+			testSuite.ParentID = &id
+		}
+		if err := tx.Create(&testSuite).Error; err != nil {
+			return 0, err
+		}
+		i.nextSuiteOrder[parentID]++
+		i.testSuites[key] = testSuite.ID
+		parentID = testSuite.ID
+	}
+	return parentID, nil
+}
+
+// importRows は検証済みの行を取り込み、作成したテストケースと新しく作ったスイートの数を返す
+func (i *testCaseImporter) importRows(tx *gorm.DB, rows []testCaseImportRow) ([]model.TestCase, int, error) {
+	suiteCount := len(i.testSuites)
+	testCases := make([]model.TestCase, 0, len(rows))
+	for _, row := range rows {
+		testSuiteID, err := i.ensureTestSuite(tx, row.TestSuitePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		testCases = append(testCases, model.TestCase{
+			TestSuiteID: &testSuiteID,
+			ProjectID:   i.project.ID,
+			MilestoneID: row.MilestoneID,
+			Title:       strings.TrimSpace(row.Title),
+			Content:     row.Content,
+			OrderIndex:  i.nextCaseOrder[testSuiteID],
+			CreatedByID: i.user.ID,
+			UpdatedByID: i.user.ID,
+			TemplateID:  row.TemplateID,
+		})
+		i.nextCaseOrder[testSuiteID]++
+	}
+	if len(testCases) == 0 {
+		return testCases, 0, nil
+	}
+	if err := tx.CreateInBatches(&testCases, 100).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tags := []model.TestCaseTag{}
+	fields := []model.TestCaseCustomField{}
+	steps := []model.TestCaseStep{}
+	for index, row := range rows {
+		testCases[index].Tags = buildTestCaseTags(testCases[index].ID, row.Tags)
+		testCases[index].CustomFields = buildTestCaseCustomFields(testCases[index].ID, row.CustomFields)
+		testCases[index].Steps = buildTestCaseSteps(testCases[index].ID, row.Steps)
+		tags = append(tags, testCases[index].Tags...)
+		fields = append(fields, testCases[index].CustomFields...)
+		steps = append(steps, testCases[index].Steps...)
+	}
+	if len(tags) > 0 {
+		if err := tx.CreateInBatches(&tags, 100).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(fields) > 0 {
+		if err := tx.CreateInBatches(&fields, 100).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(steps) > 0 {
+		if err := tx.CreateInBatches(&steps, 100).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return testCases, len(i.testSuites) - suiteCount, nil
+}
+
+// errImportRowsInvalid は検証エラーがあるため取り込みをやめる（トランザクションを取り消す）ことを表す
+var errImportRowsInvalid = errors.New("import rows are invalid")
+
+// runImport は prepare で既存のスイートなどを読み込んで行を検証し、問題が無ければ write で書き込む。
+// ドライランの場合は読み込みと検証だけを行う。取り込む場合は同時に作成されたスイートを重複して作らないよう、
+// 読み込みから書き込みまでを一つのトランザクションで行い、prepare が false を返した場合は何も書き込まない
+func runImport(db *gorm.DB, dryRun bool, prepare func(db *gorm.DB) (bool, error), write func(tx *gorm.DB) error) error {
+	if dryRun {
+		_, err := prepare(db)
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		valid, err := prepare(tx)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errImportRowsInvalid
+		}
+		return write(tx)
+	})
+	if errors.Is(err, errImportRowsInvalid) {
+		return nil
+	}
+	return err
+}
+
+// importTestCases は行を検証し、問題が無ければ一つのトランザクションで取り込む。
+// 検証エラーがある場合やドライランの場合は何も書き込まない
+func importTestCases(db *gorm.DB, project model.Project, user model.User, rows []testCaseImportRow, dryRun bool) (util.TestCaseImportResult, error) {
+	result := util.TestCaseImportResult{
+		DryRun:      dryRun,
+		TestCases:   len(rows),
+		TestCaseIDs: []uint{},
+	}
+	var importer *testCaseImporter
+	err := runImport(db, dryRun, func(db *gorm.DB) (bool, error) {
+		var err error
+		if importer, err = newTestCaseImporter(db, project, user); err != nil {
+			return false, err
+		}
+		if err := importer.loadTemplates(db, rows); err != nil {
+			return false, err
+		}
+		result.Errors = importer.validate(rows)
+		if dryRun {
+			result.TestSuites = importer.countNewTestSuites(rows)
+		}
+		return len(result.Errors) == 0, nil
+	}, func(tx *gorm.DB) error {
+		testCases, testSuiteCount, err := importer.importRows(tx, rows)
+		if err != nil {
+			return err
+		}
+		result.TestSuites = testSuiteCount
+		for _, testCase := range testCases {
+			result.TestCaseIDs = append(result.TestCaseIDs, testCase.ID)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// writeTestCaseImportResult は取り込み結果をレスポンスとして返す
+func writeTestCaseImportResult(c *gin.Context, result util.TestCaseImportResult, err error) {
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Insert failed", err)
+		return
+	}
+	if !result.DryRun && len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// errInvalidTestCaseParameters はパラメータ表の列名や行の値の数が正しくない場合のエラー
+var errInvalidTestCaseParameters = errors.New("invalid test case parameters")
+
+// testRunCaseKey はテストラン内のケースを区別するキー（パラメータ表の行から作成したケースは行ごとに別になる）
+type testRunCaseKey struct {
+	TestCaseID     uint
+	ParameterIndex int
+}
+
+func newTestRunCaseKey(testRunCase model.TestRunCase) testRunCaseKey {
+	key := testRunCaseKey{TestCaseID: testRunCase.TestCaseID, ParameterIndex: -1}
+	if testRunCase.ParameterIndex != nil {
+		key.ParameterIndex = *testRunCase.ParameterIndex
+	}
+	return key
+}
+
+// normalizeTestCaseParameters は列名の前後の空白を取り除き、列名と行の値の数を確認する。
+// 列が無い場合は nil を返す（パラメータ表を削除する）
+func normalizeTestCaseParameters(parameters util.TestCaseParameters) (*util.TestCaseParameters, error) {
+	if len(parameters.Names) == 0 {
+		if len(parameters.Rows) > 0 {
+			return nil, errInvalidTestCaseParameters
+		}
+		return nil, nil
+	}
+	normalized := util.TestCaseParameters{Names: []string{}, Rows: [][]string{}}
+	seen := map[string]bool{}
+	for _, name := range parameters.Names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return nil, errInvalidTestCaseParameters
+		}
+		seen[name] = true
+		normalized.Names = append(normalized.Names, name)
+	}
+	for _, row := range parameters.Rows {
+		if len(row) != len(normalized.Names) {
+			return nil, errInvalidTestCaseParameters
+		}
+		normalized.Rows = append(normalized.Rows, append([]string{}, row...))
+	}
+	return &normalized, nil
+}
+
+// buildTestCaseParameter はパラメータ表を保存用に組み立てる
+func buildTestCaseParameter(testCaseID uint, parameters util.TestCaseParameters) *model.TestCaseParameter {
+	names, _ := json.Marshal(parameters.Names)
+	rows, _ := json.Marshal(parameters.Rows)
+	return &model.TestCaseParameter{TestCaseID: testCaseID, Names: string(names), Rows: string(rows)}
+}
+
+// testCaseParameterResponse は保存したパラメータ表を返す。表が無い場合は nil
+func testCaseParameterResponse(parameter *model.TestCaseParameter) *util.TestCaseParameters {
+	if parameter == nil {
+		return nil
+	}
+	parameters := util.TestCaseParameters{Names: []string{}, Rows: [][]string{}}
+	if err := json.Unmarshal([]byte(parameter.Names), &parameters.Names); err != nil || len(parameters.Names) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(parameter.Rows), &parameters.Rows); err != nil {
+		return nil
+	}
+	return &parameters
+}
+
+// loadTestCaseParameters はテストケースごとのパラメータ表を一括で取得する
+func loadTestCaseParameters(db *gorm.DB, testCaseIDs []uint) (map[uint]util.TestCaseParameters, error) {
+	parametersByTestCase := map[uint]util.TestCaseParameters{}
+	if testCaseIDs = uniqueUints(testCaseIDs); len(testCaseIDs) == 0 {
+		return parametersByTestCase, nil
+	}
+	var records []model.TestCaseParameter
+	if err := db.Where("test_case_id IN ?", testCaseIDs).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if parameters := testCaseParameterResponse(&records[i]); parameters != nil {
+			parametersByTestCase[records[i].TestCaseID] = *parameters
+		}
+	}
+	return parametersByTestCase, nil
+}
+
+// buildParameterizedTestRunCases はテストケースをランに追加するケースを組み立てる。
+// パラメータ表に行がある場合は行ごとに値を控えたケースを作る
+func buildParameterizedTestRunCases(testRunID uint, testCaseID uint, statusID uint, assignedToID *uint, parameters util.TestCaseParameters) []model.TestRunCase {
+	if len(parameters.Rows) == 0 {
+		return []model.TestRunCase{{TestRunID: testRunID, TestCaseID: testCaseID, StatusID: statusID, AssignedToID: assignedToID}}
+	}
+	testRunCases := []model.TestRunCase{}
+	for index := range parameters.Rows {
+		parameterIndex := index
+		testRunCases = append(testRunCases, model.TestRunCase{
+			TestRunID:      testRunID,
+			TestCaseID:     testCaseID,
+			StatusID:       statusID,
+			AssignedToID:   assignedToID,
+			ParameterIndex: &parameterIndex,
+			Parameters:     testRunCaseParameterSnapshot(parameters, index),
+		})
+	}
+	return testRunCases
+}
+
+// testRunCaseParameterSnapshot は行の値を列名と組にして JSON で返す。追加後にパラメータ表を変更しても結果の対象は変わらない
+func testRunCaseParameterSnapshot(parameters util.TestCaseParameters, index int) string {
+	values := []util.TestRunCaseParameter{}
+	for i, name := range parameters.Names {
+		values = append(values, util.TestRunCaseParameter{Name: name, Value: parameters.Rows[index][i]})
+	}
+	snapshot, _ := json.Marshal(values)
+	return string(snapshot)
+}
+
+func testRunCaseParameters(testRunCase model.TestRunCase) []util.TestRunCaseParameter {
+	if testRunCase.ParameterIndex == nil || testRunCase.Parameters == "" {
+		return nil
+	}
+	var values []util.TestRunCaseParameter
+	if err := json.Unmarshal([]byte(testRunCase.Parameters), &values); err != nil {
+		return nil
+	}
+	return values
+}
+
+// applyTestRunCaseParameters はパラメータ表の行から作成したケースの内容と手順の {{列名}} を行の値に置き換える
+func applyTestRunCaseParameters(testRunCases []model.TestRunCase) {
+	for i := range testRunCases {
+		values := testRunCaseParameters(testRunCases[i])
+		if len(values) == 0 {
+			continue
+		}
+		pairs := []string{}
+		for _, value := range values {
+			pairs = append(pairs, "{{"+value.Name+"}}", value.Value)
+		}
+		replacer := strings.NewReplacer(pairs...)
+		testCase := &testRunCases[i].TestCase
+		testCase.Content = replacer.Replace(testCase.Content)
+		steps := make([]model.TestCaseStep, 0, len(testCase.Steps))
+		for _, step := range testCase.Steps {
+			step.Action = replacer.Replace(step.Action)
+			step.ExpectedResult = replacer.Replace(step.ExpectedResult)
+			steps = append(steps, step)
+		}
+		testCase.Steps = steps
+	}
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// テストケースの状態（新規作成時は Draft）
+const (
+	testCaseStateDraft      = "Draft"
+	testCaseStateInReview   = "InReview"
+	testCaseStateApproved   = "Approved"
+	testCaseStateDeprecated = "Deprecated"
+)
+
+// レビューのコメントに記録する操作
+const (
+	reviewActionComment       = "Comment"
+	reviewActionRequestReview = "RequestReview"
+	reviewActionApprove       = "Approve"
+	reviewActionReject        = "Reject"
+	reviewActionDeprecate     = "Deprecate"
+)
+
+// DefaultTestCaseApprovalPermission はテストケースの承認に必要な権限の既定値
+const DefaultTestCaseApprovalPermission = "approve"
+
+// TestCaseApprovalPermission は承認・差し戻し・廃止に必要な権限名を返す（TEST_CASE_APPROVAL_PERMISSION で変更できる）
+func TestCaseApprovalPermission() string {
+	if permission := strings.TrimSpace(os.Getenv("TEST_CASE_APPROVAL_PERMISSION")); permission != "" {
+		return permission
+	}
+	return DefaultTestCaseApprovalPermission
+}
+
+// TestCaseReviewRequest はレビュー依頼のリクエスト
+type TestCaseReviewRequest struct {
+	ReviewerIDs []uint `json:"reviewer_ids"`
+	Comment     string `json:"comment"`
+}
+
+// TestCaseReviewCommentRequest は承認・差し戻し・廃止とレビューコメントのリクエスト
+type TestCaseReviewCommentRequest struct {
+	Content string `json:"content"`
+}
+
+func (h *TestCaseHandler) GetTestCaseReview(c *gin.Context) {
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+	h.respondTestCaseReview(c, testCase)
+}
+
+// RequestTestCaseReview はレビュー担当者を置き換えてテストケースをレビュー中にする。
+// 承認済みのテストケースは編集して下書きに戻るまで依頼できない
+func (h *TestCaseHandler) RequestTestCaseReview(c *gin.Context) {
+	var req TestCaseReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	reviewerIDs := uniqueUints(req.ReviewerIDs)
+	if len(reviewerIDs) == 0 {
+		handleError(c, http.StatusBadRequest, "Reviewers are required", nil)
+		return
+	}
+
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+	if testCase.State == testCaseStateApproved {
+		handleError(c, http.StatusConflict, "Test case is already approved", nil)
+		return
+	}
+
+	var count int64
+	if err := h.DB.Model(&model.User{}).Where("id IN ?", reviewerIDs).Count(&count).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve reviewers", err)
+		return
+	}
+	if int(count) != len(reviewerIDs) {
+		handleError(c, http.StatusBadRequest, "Reviewer not found", nil)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("test_case_id = ?", testCase.ID).Delete(&model.TestCaseReviewer{}).Error; err != nil {
+			return err
+		}
+		reviewers := make([]model.TestCaseReviewer, 0, len(reviewerIDs))
+		for _, reviewerID := range reviewerIDs {
+			reviewers = append(reviewers, model.TestCaseReviewer{TestCaseID: testCase.ID, UserID: reviewerID})
+		}
+		if err := tx.Create(&reviewers).Error; err != nil {
+			return err
+		}
+		return changeTestCaseState(tx, &testCase, testCaseStateInReview, reviewActionRequestReview, strings.TrimSpace(req.Comment), user.ID)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to request review", err)
+		return
+	}
+
+	h.respondTestCaseReview(c, testCase)
+}
+
+// ApproveTestCase はレビュー中のテストケースを承認する（承認の権限はルーターで確認する）
+func (h *TestCaseHandler) ApproveTestCase(c *gin.Context) {
+	h.decideTestCaseReview(c, testCaseStateApproved, reviewActionApprove, false)
+}
+
+// RejectTestCase はレビュー中のテストケースを差し戻して下書きに戻す。理由のコメントが必要
+func (h *TestCaseHandler) RejectTestCase(c *gin.Context) {
+	h.decideTestCaseReview(c, testCaseStateDraft, reviewActionReject, true)
+}
+
+func (h *TestCaseHandler) decideTestCaseReview(c *gin.Context, state string, action string, commentRequired bool) {
+	content, ok := bindReviewComment(c, commentRequired)
+	if !ok {
+		return
+	}
+
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+	if testCase.State != testCaseStateInReview {
+		handleError(c, http.StatusConflict, "Test case is not in review", nil)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	decision := testCaseStateApproved
+	if action == reviewActionReject {
+		decision = "Rejected"
+	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// 担当者以外が判定した場合は担当者に加える
+		result := tx.Model(&model.TestCaseReviewer{}).
+			Where("test_case_id = ? AND user_id = ?", testCase.ID, user.ID).
+			Update("decision", decision)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reviewer := model.TestCaseReviewer{TestCaseID: testCase.ID, UserID: user.ID, Decision: decision}
+			if err := tx.Create(&reviewer).Error; err != nil {
+				return err
+			}
+		}
+		return changeTestCaseState(tx, &testCase, state, action, content, user.ID)
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update review", err)
+		return
+	}
+
+	h.respondTestCaseReview(c, testCase)
+}
+
+// DeprecateTestCase はテストケースを廃止する。廃止したテストケースはランに追加できないが、既存のランには残る
+func (h *TestCaseHandler) DeprecateTestCase(c *gin.Context) {
+	content, ok := bindReviewComment(c, false)
+	if !ok {
+		return
+	}
+
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+	if testCase.State == testCaseStateDeprecated {
+		handleError(c, http.StatusConflict, "Test case is already deprecated", nil)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return changeTestCaseState(tx, &testCase, testCaseStateDeprecated, reviewActionDeprecate, content, user.ID)
+	}); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to deprecate test case", err)
+		return
+	}
+
+	h.respondTestCaseReview(c, testCase)
+}
+
+func (h *TestCaseHandler) PostTestCaseReviewComment(c *gin.Context) {
+	content, ok := bindReviewComment(c, true)
+	if !ok {
+		return
+	}
+
+	testCase, ok := h.findTestCaseForReview(c)
+	if !ok {
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	comment := model.TestCaseReviewComment{
+		TestCaseID: testCase.ID,
+		UserID:     user.ID,
+		Action:     reviewActionComment,
+		Content:    content,
+		User:       user,
+	}
+	if err := h.DB.Create(&comment).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create comment", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, convertReviewCommentToJSON(comment))
+}
+
+func bindReviewComment(c *gin.Context, required bool) (string, bool) {
+	var req TestCaseReviewCommentRequest
+	// 本文が任意の操作は空のリクエストも受け付ける
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid request data", err)
+			return "", false
+		}
+	}
+	content := strings.TrimSpace(req.Content)
+	if required && content == "" {
+		handleError(c, http.StatusBadRequest, "Comment is required", nil)
+		return "", false
+	}
+	return content, true
+}
+
+func (h *TestCaseHandler) findTestCaseForReview(c *gin.Context) (model.TestCase, bool) {
+	var testCase model.TestCase
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return testCase, false
+	}
+	if err := h.DB.First(&testCase, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test case not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", err)
+		}
+		return testCase, false
+	}
+	return testCase, true
+}
+
+// changeTestCaseState は状態を変更し、操作をレビューの履歴に残す
+func changeTestCaseState(tx *gorm.DB, testCase *model.TestCase, state string, action string, content string, userID uint) error {
+	if err := tx.Model(testCase).Updates(map[string]interface{}{"state": state, "updated_by_id": userID}).Error; err != nil {
+		return err
+	}
+	testCase.State = state
+	comment := model.TestCaseReviewComment{TestCaseID: testCase.ID, UserID: userID, Action: action, Content: content}
+	return tx.Create(&comment).Error
+}
+
+func (h *TestCaseHandler) respondTestCaseReview(c *gin.Context, testCase model.TestCase) {
+	var reviewers []model.TestCaseReviewer
+	if err := h.DB.Preload("User").Where("test_case_id = ?", testCase.ID).Order("id").Find(&reviewers).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve reviewers", err)
+		return
+	}
+	var comments []model.TestCaseReviewComment
+	if err := h.DB.Preload("User").Where("test_case_id = ?", testCase.ID).Order("id").Find(&comments).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve comments", err)
+		return
+	}
+
+	review := util.TestCaseReview{
+		TestCaseID: testCase.ID,
+		State:      testCase.State,
+		Reviewers:  []util.TestCaseReviewer{},
+		Comments:   []util.TestCaseReviewComment{},
+	}
+	for _, reviewer := range reviewers {
+		review.Reviewers = append(review.Reviewers, util.TestCaseReviewer{
+			User:     convertUserToJSON(reviewer.User),
+			Decision: reviewer.Decision,
+		})
+	}
+	for _, comment := range comments {
+		review.Comments = append(review.Comments, convertReviewCommentToJSON(comment))
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+func convertReviewCommentToJSON(comment model.TestCaseReviewComment) util.TestCaseReviewComment {
+	return util.TestCaseReviewComment{
+		ID:        comment.ID,
+		Action:    comment.Action,
+		Content:   comment.Content,
+		CreatedBy: convertUserToJSON(comment.User),
+		CreatedAt: comment.CreatedAt.Format("2006-01-02 15:04"),
+	}
+}
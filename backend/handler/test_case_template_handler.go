@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errTestCaseTemplateNotFound はテストケースと別のプロジェクトや存在しないテンプレートを指定した場合のエラー
+var errTestCaseTemplateNotFound = errors.New("template not found in project")
+
+type TestCaseTemplateHandler struct {
+	DB *gorm.DB
+}
+
+func NewTestCaseTemplateHandler(db *gorm.DB) *TestCaseTemplateHandler {
+	return &TestCaseTemplateHandler{DB: db}
+}
+
+type testCaseTemplateRequest struct {
+	ProjectID    uint                         `json:"project_id"`
+	Name         string                       `json:"name"`
+	Description  string                       `json:"description"`
+	Content      string                       `json:"content"`
+	Steps        []util.TestCaseStep          `json:"steps"`
+	CustomFields []util.TestCaseTemplateField `json:"custom_fields"`
+}
+
+// testCaseTemplateValues はテンプレートで補うテストケースの値。Steps が nil の場合は手順の指定が無いことを表す
+type testCaseTemplateValues struct {
+	Content      string
+	Steps        *[]util.TestCaseStep
+	CustomFields map[string]string
+}
+
+func createTestCaseTemplateResponse(template model.TestCaseTemplate) util.TestCaseTemplate {
+	steps := []util.TestCaseStep{}
+	if template.Steps != "" {
+		if err := json.Unmarshal([]byte(template.Steps), &steps); err != nil {
+			steps = []util.TestCaseStep{}
+		}
+	}
+	fields := []util.TestCaseTemplateField{}
+	if template.CustomFields != "" {
+		if err := json.Unmarshal([]byte(template.CustomFields), &fields); err != nil {
+			fields = []util.TestCaseTemplateField{}
+		}
+	}
+	return util.TestCaseTemplate{
+		ID:           template.ID,
+		ProjectID:    template.ProjectID,
+		Name:         template.Name,
+		Description:  template.Description,
+		Content:      template.Content,
+		Steps:        steps,
+		CustomFields: fields,
+		CreatedBy:    util.User{ID: template.CreatedByID, Name: template.CreatedBy.Name},
+		UpdatedBy:    util.User{ID: template.UpdatedByID, Name: template.UpdatedBy.Name},
+	}
+}
+
+func (h *TestCaseTemplateHandler) GetTestCaseTemplates(c *gin.Context) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	var templates []model.TestCaseTemplate
+	if err := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Where("project_id = ?", project.ID).Order("name, id").Find(&templates).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve templates", err)
+		return
+	}
+
+	responses := []util.TestCaseTemplate{}
+	for _, template := range templates {
+		responses = append(responses, createTestCaseTemplateResponse(template))
+	}
+	c.JSON(http.StatusOK, util.TestCaseTemplatesResponseData{
+		ProjectID: project.ID,
+		Templates: responses,
+	})
+}
+
+func (h *TestCaseTemplateHandler) GetTestCaseTemplate(c *gin.Context) {
+	template, ok := h.findTestCaseTemplate(c)
+	if !ok {
+		return
+	}
+	h.respondTestCaseTemplate(c, http.StatusOK, template.ID)
+}
+
+func (h *TestCaseTemplateHandler) PostTestCaseTemplate(c *gin.Context) {
+	req, ok := bindTestCaseTemplateRequest(c)
+	if !ok {
+		return
+	}
+
+	var project model.Project
+	if result := h.DB.First(&project, req.ProjectID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	steps, fields, err := marshalTestCaseTemplate(req)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create template", err)
+		return
+	}
+	template := model.TestCaseTemplate{
+		ProjectID:    req.ProjectID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Content:      req.Content,
+		Steps:        steps,
+		CustomFields: fields,
+		CreatedByID:  user.ID,
+		UpdatedByID:  user.ID,
+	}
+	if err := h.DB.Create(&template).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create template", err)
+		return
+	}
+
+	h.respondTestCaseTemplate(c, http.StatusCreated, template.ID)
+}
+
+// PutTestCaseTemplate はテンプレートを置き換える。作成済みのテストケースは変わらない
+func (h *TestCaseTemplateHandler) PutTestCaseTemplate(c *gin.Context) {
+	req, ok := bindTestCaseTemplateRequest(c)
+	if !ok {
+		return
+	}
+
+	template, ok := h.findTestCaseTemplate(c)
+	if !ok {
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	steps, fields, err := marshalTestCaseTemplate(req)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update template", err)
+		return
+	}
+	if err := h.DB.Model(&model.TestCaseTemplate{}).Where("id = ?", template.ID).Updates(map[string]interface{}{
+		"name":          req.Name,
+		"description":   req.Description,
+		"content":       req.Content,
+		"steps":         steps,
+		"custom_fields": fields,
+		"updated_by_id": user.ID,
+	}).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update template", err)
+		return
+	}
+
+	h.respondTestCaseTemplate(c, http.StatusOK, template.ID)
+}
+
+// DeleteTestCaseTemplate はテンプレートを削除する。作成済みのテストケースには使ったテンプレートの ID が残る
+func (h *TestCaseTemplateHandler) DeleteTestCaseTemplate(c *gin.Context) {
+	template, ok := h.findTestCaseTemplate(c)
+	if !ok {
+		return
+	}
+	if err := h.DB.Delete(&model.TestCaseTemplate{}, template.ID).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete template", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TestCaseTemplateHandler) findTestCaseTemplate(c *gin.Context) (model.TestCaseTemplate, bool) {
+	var template model.TestCaseTemplate
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return template, false
+	}
+	if err := h.DB.First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Template not found", err)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", err)
+		}
+		return template, false
+	}
+	return template, true
+}
+
+func (h *TestCaseTemplateHandler) respondTestCaseTemplate(c *gin.Context, code int, id uint) {
+	var template model.TestCaseTemplate
+	if err := h.DB.Preload("CreatedBy").Preload("UpdatedBy").First(&template, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve template", err)
+		return
+	}
+	c.JSON(code, createTestCaseTemplateResponse(template))
+}
+
+// bindTestCaseTemplateRequest はテンプレートのリクエストを読み込んで検証する。
+// 空の手順は除き、共有ステップの参照はテンプレートには持たせない
+func bindTestCaseTemplateRequest(c *gin.Context) (testCaseTemplateRequest, bool) {
+	var req testCaseTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		handleError(c, http.StatusBadRequest, "Name is required", nil)
+		return req, false
+	}
+
+	steps := []util.TestCaseStep{}
+	for _, step := range req.Steps {
+		if strings.TrimSpace(step.Action) == "" && strings.TrimSpace(step.ExpectedResult) == "" {
+			continue
+		}
+		steps = append(steps, util.TestCaseStep{Action: step.Action, ExpectedResult: step.ExpectedResult})
+	}
+	req.Steps = steps
+
+	seen := map[string]bool{}
+	fields := []util.TestCaseTemplateField{}
+	for _, field := range req.CustomFields {
+		field.Name = strings.TrimSpace(field.Name)
+		if field.Name == "" || seen[field.Name] {
+			handleError(c, http.StatusBadRequest, "Custom field names must be unique and not empty", nil)
+			return req, false
+		}
+		seen[field.Name] = true
+		if field.Default != "" && len(field.Options) > 0 && !containsTemplateOption(field.Options, field.Default) {
+			handleError(c, http.StatusBadRequest, "Default value must be one of the options", nil)
+			return req, false
+		}
+		fields = append(fields, field)
+	}
+	req.CustomFields = fields
+	return req, true
+}
+
+func marshalTestCaseTemplate(req testCaseTemplateRequest) (string, string, error) {
+	steps, err := json.Marshal(req.Steps)
+	if err != nil {
+		return "", "", err
+	}
+	fields, err := json.Marshal(req.CustomFields)
+	if err != nil {
+		return "", "", err
+	}
+	return string(steps), string(fields), nil
+}
+
+func containsTemplateOption(options []string, value string) bool {
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTestCaseTemplate はプロジェクトのテンプレートを返す
+func loadTestCaseTemplate(db *gorm.DB, projectID uint, id uint) (util.TestCaseTemplate, error) {
+	var template model.TestCaseTemplate
+	if err := db.Where("id = ? AND project_id = ?", id, projectID).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.TestCaseTemplate{}, errTestCaseTemplateNotFound
+		}
+		return util.TestCaseTemplate{}, err
+	}
+	return createTestCaseTemplateResponse(template), nil
+}
+
+// applyTestCaseTemplate は空の内容と指定の無い手順をテンプレートで補い、カスタムフィールドに既定値を設定する。
+// 必須のカスタムフィールドが空の場合や、選択肢に無い値の場合はエラーを返す
+func applyTestCaseTemplate(template util.TestCaseTemplate, values testCaseTemplateValues) (testCaseTemplateValues, error) {
+	if strings.TrimSpace(values.Content) == "" {
+		values.Content = template.Content
+	}
+	if values.Steps == nil {
+		steps := append([]util.TestCaseStep{}, template.Steps...)
+		values.Steps = &steps
+	}
+
+	customFields := map[string]string{}
+	for _, field := range template.CustomFields {
+		if field.Default != "" {
+			customFields[field.Name] = field.Default
+		}
+	}
+	for name, value := range values.CustomFields {
+		customFields[name] = value
+	}
+	for _, field := range template.CustomFields {
+		value := strings.TrimSpace(customFields[field.Name])
+		if value == "" {
+			if field.Required {
+				return values, fmt.Errorf("custom field %q is required", field.Name)
+			}
+			continue
+		}
+		if len(field.Options) > 0 && !containsTemplateOption(field.Options, customFields[field.Name]) {
+			return values, fmt.Errorf("custom field %q must be one of %s", field.Name, strings.Join(field.Options, ", "))
+		}
+	}
+	values.CustomFields = customFields
+	return values, nil
+}
@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+const (
+	transferMilestonesMatch  = "match"  // 移動先のプロジェクトの同じタイトルのマイルストーンを使い、無ければ外す
+	transferMilestonesCreate = "create" // 移動先に無いマイルストーンは作成する
+	transferMilestonesDrop   = "drop"   // マイルストーンを外す
+)
+
+// TestCaseTransferRequest はテストケースとスイートを別のプロジェクトへコピー・移動するリクエスト
+type TestCaseTransferRequest struct {
+	TargetProjectID   uint   `json:"target_project_id"`
+	TargetTestSuiteID *uint  `json:"target_test_suite_id"`
+	TestCaseIDs       []uint `json:"test_case_ids"`
+	TestSuiteIDs      []uint `json:"test_suite_ids"`
+	Milestones        string `json:"milestones"`
+	KeepRunHistory    bool   `json:"keep_run_history"`
+}
+
+// testCaseTransfer は検証済みのコピー・移動の対象を表す
+type testCaseTransfer struct {
+	Request         TestCaseTransferRequest
+	Source          model.Project
+	Target          model.Project
+	User            model.User
+	Roots           []model.TestSuite          // 選択したスイートのうち、他の選択したスイートの配下に無いもの
+	TestSuiteMap    map[uint][]model.TestSuite // 移動元のスイートを親ごとにまとめたもの
+	SubtreeCases    []model.TestCase           // 選択したスイート配下のテストケース
+	SingleCases     []model.TestCase           // 個別に選択したテストケース（スイートごと移すものは除く）
+	TargetMilestone map[string]uint            // 移動先のマイルストーン（小文字のタイトル → ID）
+}
+
+// loadTestCaseTransfer はリクエストを検証し、コピー・移動の対象を読み込む
+func (h *TestCaseHandler) loadTestCaseTransfer(c *gin.Context) (*testCaseTransfer, bool) {
+	transfer := &testCaseTransfer{}
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&transfer.Source); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return nil, false
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return nil, false
+	}
+
+	req := &transfer.Request
+	if err := c.ShouldBindJSON(req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return nil, false
+	}
+	if req.Milestones == "" {
+		req.Milestones = transferMilestonesMatch
+	}
+	if req.Milestones != transferMilestonesMatch && req.Milestones != transferMilestonesCreate && req.Milestones != transferMilestonesDrop {
+		handleError(c, http.StatusBadRequest, "Invalid milestones option", nil)
+		return nil, false
+	}
+	if len(req.TestCaseIDs) == 0 && len(req.TestSuiteIDs) == 0 {
+		handleError(c, http.StatusBadRequest, "No test cases or test suites selected", nil)
+		return nil, false
+	}
+
+	if result := h.DB.First(&transfer.Target, req.TargetProjectID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusBadRequest, "Target project not found", result.Error)
+			return nil, false
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return nil, false
+	}
+
+	var testSuites []model.TestSuite
+	if err := h.DB.Where("project_id = ?", transfer.Source.ID).Order("order_index, id").Find(&testSuites).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test suites", err)
+		return nil, false
+	}
+	transfer.TestSuiteMap = groupTestSuitesByParent(testSuites)
+	testSuiteByID := make(map[uint]model.TestSuite, len(testSuites))
+	for _, testSuite := range testSuites {
+		testSuiteByID[testSuite.ID] = testSuite
+	}
+
+	selected := map[uint]bool{}
+	for _, id := range req.TestSuiteIDs {
+		if _, ok := testSuiteByID[id]; !ok {
+			handleError(c, http.StatusBadRequest, "Test suite not found in project", nil)
+			return nil, false
+		}
+		selected[id] = true
+	}
+	// 配下のスイートも選択されていれば一度だけ扱う
+	subtree := map[uint]bool{}
+	for _, testSuite := range testSuites {
+		if !selected[testSuite.ID] {
+			continue
+		}
+		nested := false
+		for parentID := testSuite.ParentID; parentID != nil && !nested; {
+			parent, ok := testSuiteByID[*parentID]
+			if !ok || parent.ID == testSuite.ID {
+				break
+			}
+			nested = selected[parent.ID]
+			parentID = parent.ParentID
+		}
+		if !nested {
+			transfer.Roots = append(transfer.Roots, testSuite)
+			for _, id := range collectTestSuiteSubtree(testSuite.ID, transfer.TestSuiteMap) {
+				subtree[id] = true
+			}
+		}
+	}
+
+	if req.TargetTestSuiteID != nil {
+		var targetTestSuite model.TestSuite
+		if result := h.DB.Where("id = ? AND project_id = ?", *req.TargetTestSuiteID, transfer.Target.ID).First(&targetTestSuite); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				handleError(c, http.StatusBadRequest, "Target test suite not found in target project", result.Error)
+				return nil, false
+			}
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test suite", result.Error)
+			return nil, false
+		}
+		if subtree[targetTestSuite.ID] {
+			handleError(c, http.StatusBadRequest, "Target test suite is inside a selected test suite", nil)
+			return nil, false
+		}
+	}
+
+	subtreeIDs := make([]uint, 0, len(subtree))
+	for _, testSuite := range testSuites {
+		if subtree[testSuite.ID] {
+			subtreeIDs = append(subtreeIDs, testSuite.ID)
+		}
+	}
+	var testCases []model.TestCase
+	query := h.DB.Where("project_id = ?", transfer.Source.ID)
+	switch {
+	case len(req.TestCaseIDs) > 0 && len(subtreeIDs) > 0:
+		query = query.Where("id IN ? OR test_suite_id IN ?", req.TestCaseIDs, subtreeIDs)
+	case len(subtreeIDs) > 0:
+		query = query.Where("test_suite_id IN ?", subtreeIDs)
+	default:
+		query = query.Where("id IN ?", req.TestCaseIDs)
+	}
+	if err := query.
+		Preload("Milestone").
+		Preload("Tags").
+		Preload("CustomFields").
+		Preload("Steps", orderTestCaseSteps).
+		Preload("Parameter").
+		Order("order_index, id").
+		Find(&testCases).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+		return nil, false
+	}
+
+	found := map[uint]bool{}
+	for _, testCase := range testCases {
+		found[testCase.ID] = true
+		if testCase.TestSuiteID != nil && subtree[*testCase.TestSuiteID] {
+			transfer.SubtreeCases = append(transfer.SubtreeCases, testCase)
+		} else {
+			transfer.SingleCases = append(transfer.SingleCases, testCase)
+		}
+	}
+	for _, id := range req.TestCaseIDs {
+		if !found[id] {
+			handleError(c, http.StatusBadRequest, "Test case not found in project", nil)
+			return nil, false
+		}
+	}
+	if len(transfer.SingleCases) > 0 && req.TargetTestSuiteID == nil {
+		handleError(c, http.StatusBadRequest, "target_test_suite_id is required to transfer test cases", nil)
+		return nil, false
+	}
+
+	transfer.TargetMilestone = map[string]uint{}
+	if req.Milestones != transferMilestonesDrop {
+		var milestones []model.Milestone
+		if err := h.DB.Where("project_id = ?", transfer.Target.ID).Order("id").Find(&milestones).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve milestones", err)
+			return nil, false
+		}
+		for _, milestone := range milestones {
+			if key := strings.ToLower(strings.TrimSpace(milestone.Title)); transfer.TargetMilestone[key] == 0 {
+				transfer.TargetMilestone[key] = milestone.ID
+			}
+		}
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return nil, false
+	}
+	transfer.User = user
+	return transfer, true
+}
+
+// resolveMilestones は移動元のマイルストーンに対応する移動先のマイルストーンを返す（作成した分は result に記録する）
+func (t *testCaseTransfer) resolveMilestones(tx *gorm.DB, result *util.TestCaseTransferResult) (map[uint]*uint, error) {
+	resolved := map[uint]*uint{}
+	for _, testCase := range append(append([]model.TestCase{}, t.SubtreeCases...), t.SingleCases...) {
+		if testCase.MilestoneID == nil {
+			continue
+		}
+		sourceID := *testCase.MilestoneID
+		if _, ok := resolved[sourceID]; ok {
+			continue
+		}
+		resolved[sourceID] = nil
+		if t.Request.Milestones == transferMilestonesDrop {
+			continue
+		}
+		if t.Source.ID == t.Target.ID {
+			id := sourceID
+			resolved[sourceID] = &id
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(testCase.Milestone.Title))
+		if id, ok := t.TargetMilestone[key]; ok {
+			resolved[sourceID] = &id
+			continue
+		}
+		if t.Request.Milestones != transferMilestonesCreate || testCase.Milestone.ID == 0 {
+			continue
+		}
+		milestone := model.Milestone{
+			ProjectID:   t.Target.ID,
+			Title:       testCase.Milestone.Title,
+			Description: testCase.Milestone.Description,
+			Status:      testCase.Milestone.Status,
+			DueDate:     testCase.Milestone.DueDate,
+		}
+		if err := tx.Create(&milestone).Error; err != nil {
+			return nil, err
+		}
+		t.TargetMilestone[key] = milestone.ID
+		resolved[sourceID] = &milestone.ID
+		result.Milestones = append(result.Milestones, util.TransferredID{SourceID: sourceID, ID: milestone.ID})
+	}
+	return resolved, nil
+}
+
+// nextOrders は移動先のスイートの並び順と、移動先のスイート内のテストケースの並び順の次の値を返す
+func (t *testCaseTransfer) nextOrders(tx *gorm.DB) (int, int, error) {
+	var suiteOrder, caseOrder struct{ Next int }
+	query := tx.Model(&model.TestSuite{}).Select("COALESCE(MAX(order_index) + 1, 0) AS next").Where("project_id = ?", t.Target.ID)
+	if t.Request.TargetTestSuiteID != nil {
+		query = query.Where("parent_id = ?", *t.Request.TargetTestSuiteID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	if err := query.Scan(&suiteOrder).Error; err != nil {
+		return 0, 0, err
+	}
+	if t.Request.TargetTestSuiteID != nil && len(t.SingleCases) > 0 {
+		if err := tx.Model(&model.TestCase{}).Select("COALESCE(MAX(order_index) + 1, 0) AS next").
+			Where("test_suite_id = ?", *t.Request.TargetTestSuiteID).
+			Scan(&caseOrder).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+	return suiteOrder.Next, caseOrder.Next, nil
+}
+
+// CopyTestCases はテストケースとスイート配下を別のプロジェクト（同じプロジェクトも可）へ複製し、新しい ID を返す
+func (h *TestCaseHandler) CopyTestCases(c *gin.Context) {
+	transfer, ok := h.loadTestCaseTransfer(c)
+	if !ok {
+		return
+	}
+
+	result := util.TestCaseTransferResult{
+		TestSuites: []util.TransferredID{},
+		TestCases:  []util.TransferredID{},
+		Milestones: []util.TransferredID{},
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		milestones, err := transfer.resolveMilestones(tx, &result)
+		if err != nil {
+			return err
+		}
+		nextSuiteOrder, nextCaseOrder, err := transfer.nextOrders(tx)
+		if err != nil {
+			return err
+		}
+
+		// スイートは親から順に作成し、元のスイートと新しいスイートを対応付ける
+		copiedSuites := map[uint]uint{}
+		var copySuite func(testSuite model.TestSuite, parentID *uint, orderIndex int) error
+		copySuite = func(testSuite model.TestSuite, parentID *uint, orderIndex int) error {
+			copied := model.TestSuite{
+				ProjectID:  transfer.Target.ID,
+				Name:       testSuite.Name,
+				ParentID:   parentID,
+				OrderIndex: orderIndex,
+			}
+			if err := tx.Create(&copied).Error; err != nil {
+				return err
+			}
+			copiedSuites[testSuite.ID] = copied.ID
+			result.TestSuites = append(result.TestSuites, util.TransferredID{SourceID: testSuite.ID, ID: copied.ID})
+			for _, child := range transfer.TestSuiteMap[testSuite.ID] {
+				if _, done := copiedSuites[child.ID]; done {
+					continue
+				}
+				id := copied.ID
+				if err := copySuite(child, &id, child.OrderIndex); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, root := range transfer.Roots {
+			if err := copySuite(root, transfer.Request.TargetTestSuiteID, nextSuiteOrder); err != nil {
+				return err
+			}
+			nextSuiteOrder++
+		}
+
+		sources := append(append([]model.TestCase{}, transfer.SubtreeCases...), transfer.SingleCases...)
+		if len(sources) == 0 {
+			return nil
+		}
+		testCases := make([]model.TestCase, 0, len(sources))
+		for index, source := range sources {
+			testCase := model.TestCase{
+				ProjectID:   transfer.Target.ID,
+				Title:       source.Title,
+				Content:     source.Content,
+				OrderIndex:  source.OrderIndex,
+				CreatedByID: transfer.User.ID,
+				UpdatedByID: transfer.User.ID,
+			}
+			if index < len(transfer.SubtreeCases) {
+				testSuiteID := copiedSuites[*source.TestSuiteID]
+				testCase.TestSuiteID = &testSuiteID
+			} else {
+				testCase.TestSuiteID = transfer.Request.TargetTestSuiteID
+				testCase.OrderIndex = nextCaseOrder
+				nextCaseOrder++
+			}
+			if source.MilestoneID != nil {
+				testCase.MilestoneID = milestones[*source.MilestoneID]
+			}
+			testCases = append(testCases, testCase)
+		}
+		if err := tx.CreateInBatches(&testCases, 100).Error; err != nil {
+			return err
+		}
+
+		// 共有ステップは移動先のプロジェクトに無いため、別のプロジェクトへコピーする場合は通常の手順として展開する
+		crossProject := transfer.Source.ID != transfer.Target.ID
+		if crossProject {
+			stepLists := make([]*[]model.TestCaseStep, 0, len(sources))
+			for i := range sources {
+				stepLists = append(stepLists, &sources[i].Steps)
+			}
+			if err := resolveSharedSteps(tx, stepLists...); err != nil {
+				return err
+			}
+		}
+
+		tags := []model.TestCaseTag{}
+		fields := []model.TestCaseCustomField{}
+		steps := []model.TestCaseStep{}
+		parameters := []model.TestCaseParameter{}
+		for index, source := range sources {
+			id := testCases[index].ID
+			tags = append(tags, buildTestCaseTags(id, testCaseTagNames(source.Tags))...)
+			fields = append(fields, buildTestCaseCustomFields(id, testCaseCustomFieldMap(source.CustomFields))...)
+			stepResponses := testCaseStepResponses(source.Steps)
+			if crossProject {
+				for i := range stepResponses {
+					stepResponses[i].SharedStepID = nil
+				}
+			}
+			steps = append(steps, buildTestCaseSteps(id, stepResponses)...)
+			if parameter := testCaseParameterResponse(source.Parameter); parameter != nil {
+				parameters = append(parameters, *buildTestCaseParameter(id, *parameter))
+			}
+			result.TestCases = append(result.TestCases, util.TransferredID{SourceID: source.ID, ID: id})
+		}
+		if len(tags) > 0 {
+			if err := tx.CreateInBatches(&tags, 100).Error; err != nil {
+				return err
+			}
+		}
+		if len(fields) > 0 {
+			if err := tx.CreateInBatches(&fields, 100).Error; err != nil {
+				return err
+			}
+		}
+		if len(steps) > 0 {
+			if err := tx.CreateInBatches(&steps, 100).Error; err != nil {
+				return err
+			}
+		}
+		if len(parameters) > 0 {
+			if err := tx.CreateInBatches(&parameters, 100).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to copy test cases", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MoveTestCases はテストケースとスイート配下を別のプロジェクトへ移す。ID は変わらない。
+// keep_run_history が false の場合は、移動元のプロジェクトのテストランからこれらのテストケースを外す
+// （完了したテストランの結果は確定時の内容として残る）
+func (h *TestCaseHandler) MoveTestCases(c *gin.Context) {
+	transfer, ok := h.loadTestCaseTransfer(c)
+	if !ok {
+		return
+	}
+
+	result := util.TestCaseTransferResult{
+		TestSuites: []util.TransferredID{},
+		TestCases:  []util.TransferredID{},
+		Milestones: []util.TransferredID{},
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		milestones, err := transfer.resolveMilestones(tx, &result)
+		if err != nil {
+			return err
+		}
+		nextSuiteOrder, nextCaseOrder, err := transfer.nextOrders(tx)
+		if err != nil {
+			return err
+		}
+
+		testSuiteIDs := []uint{}
+		for _, root := range transfer.Roots {
+			if err := tx.Model(&model.TestSuite{}).Where("id = ?", root.ID).Updates(map[string]interface{}{
+				"parent_id":   transfer.Request.TargetTestSuiteID,
+				"order_index": nextSuiteOrder,
+			}).Error; err != nil {
+				return err
+			}
+			nextSuiteOrder++
+			testSuiteIDs = append(testSuiteIDs, collectTestSuiteSubtree(root.ID, transfer.TestSuiteMap)...)
+		}
+		for _, id := range testSuiteIDs {
+			result.TestSuites = append(result.TestSuites, util.TransferredID{SourceID: id, ID: id})
+		}
+		if len(testSuiteIDs) > 0 {
+			if err := tx.Model(&model.TestSuite{}).Where("id IN ?", testSuiteIDs).Update("project_id", transfer.Target.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		// スイートごと移すテストケースは、移動先のマイルストーンが同じものをまとめて更新する
+		keys := []uint{}
+		groups := map[uint][]uint{}
+		groupMilestones := map[uint]*uint{}
+		for _, testCase := range transfer.SubtreeCases {
+			var milestoneID *uint
+			if testCase.MilestoneID != nil {
+				milestoneID = milestones[*testCase.MilestoneID]
+			}
+			var key uint
+			if milestoneID != nil {
+				key = *milestoneID
+			}
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], testCase.ID)
+			groupMilestones[key] = milestoneID
+		}
+		for _, key := range keys {
+			if err := tx.Model(&model.TestCase{}).Where("id IN ?", groups[key]).Updates(map[string]interface{}{
+				"project_id":   transfer.Target.ID,
+				"milestone_id": groupMilestones[key],
+			}).Error; err != nil {
+				return err
+			}
+		}
+		for _, testCase := range transfer.SingleCases {
+			var milestoneID *uint
+			if testCase.MilestoneID != nil {
+				milestoneID = milestones[*testCase.MilestoneID]
+			}
+			if err := tx.Model(&model.TestCase{}).Where("id = ?", testCase.ID).Updates(map[string]interface{}{
+				"project_id":    transfer.Target.ID,
+				"test_suite_id": transfer.Request.TargetTestSuiteID,
+				"milestone_id":  milestoneID,
+				"order_index":   nextCaseOrder,
+			}).Error; err != nil {
+				return err
+			}
+			nextCaseOrder++
+		}
+
+		testCaseIDs := []uint{}
+		sharedStepCaseIDs := []uint{}
+		for _, testCase := range append(append([]model.TestCase{}, transfer.SubtreeCases...), transfer.SingleCases...) {
+			testCaseIDs = append(testCaseIDs, testCase.ID)
+			result.TestCases = append(result.TestCases, util.TransferredID{SourceID: testCase.ID, ID: testCase.ID})
+			for _, step := range testCase.Steps {
+				if step.SharedStepID != nil {
+					sharedStepCaseIDs = append(sharedStepCaseIDs, testCase.ID)
+					break
+				}
+			}
+		}
+		// 共有ステップは移動元のプロジェクトに残るため、別のプロジェクトへ移す場合は通常の手順として展開する
+		if transfer.Source.ID != transfer.Target.ID && len(sharedStepCaseIDs) > 0 {
+			if err := inlineSharedSteps(tx, sharedStepCaseIDs, nil); err != nil {
+				return err
+			}
+		}
+		// テンプレートは移動元のプロジェクトに残るため、使ったテンプレートの記録を外す
+		if transfer.Source.ID != transfer.Target.ID && len(testCaseIDs) > 0 {
+			if err := tx.Model(&model.TestCase{}).Where("id IN ? AND template_id IS NOT NULL", testCaseIDs).Update("template_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		// 前提条件は同じプロジェクトのテストケースに限るため、移動しないテストケースとの依存は外す
+		if transfer.Source.ID != transfer.Target.ID && len(testCaseIDs) > 0 {
+			if err := tx.Where("(test_case_id IN ? AND prerequisite_id NOT IN ?) OR (prerequisite_id IN ? AND test_case_id NOT IN ?)",
+				testCaseIDs, testCaseIDs, testCaseIDs, testCaseIDs,
+			).Delete(&model.TestCaseDependency{}).Error; err != nil {
+				return err
+			}
+		}
+		// 要件は移動元のプロジェクトに残るため、移動元の要件との紐付けは外す
+		if transfer.Source.ID != transfer.Target.ID && len(testCaseIDs) > 0 {
+			if err := tx.Where("test_case_id IN ? AND requirement_id IN (?)", testCaseIDs,
+				tx.Unscoped().Model(&model.Requirement{}).Select("id").Where("project_id = ?", transfer.Source.ID),
+			).Delete(&model.RequirementTestCase{}).Error; err != nil {
+				return err
+			}
+		}
+		if !transfer.Request.KeepRunHistory && transfer.Source.ID != transfer.Target.ID && len(testCaseIDs) > 0 {
+			if err := tx.Where("test_case_id IN ? AND test_run_id IN (?)", testCaseIDs,
+				tx.Model(&model.TestRun{}).Select("id").Where("project_id = ?", transfer.Source.ID),
+			).Delete(&model.TestRunCase{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to move test cases", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// testPlanStatusNotExecuted はテストプランとテストランの初期の状態（画面で新規作成する時と同じ値）
+const testPlanStatusNotExecuted = "NotExecuted"
+
+// TestPlanCloneRequest はテストプランの複製のリクエスト
+type TestPlanCloneRequest struct {
+	Title           string `json:"title"`
+	IncludeNewCases bool   `json:"include_new_cases"`
+}
+
+// CloneTestPlan はテストプランをテストラン、ランのテストケースと担当者ごと複製する。
+// 状態は初期の状態に戻し、開始・終了日時は空にする。include_new_cases を指定すると、
+// 元のテストランの作成後に同じスイートへ追加されたテストケースもランに加える
+func (h *TestPlanHandler) CloneTestPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	var req TestPlanCloneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		handleError(c, http.StatusBadRequest, "Title is required", nil)
+		return
+	}
+
+	source, err := findTestPlanForClone(h.DB, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test Plan not found", err)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test plan", err)
+		return
+	}
+
+	var status model.Status
+	if result := h.DB.Where("statuses.default = ?", 1).First(&status); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve default status", result.Error)
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	var response util.TestPlanCloneResult
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		response, _, err = cloneTestPlan(tx, source, req.Title, req.IncludeNewCases, status.ID, user.ID)
+		return err
+	})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to clone test plan", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// findTestPlanForClone は複製元のテストプランをテストランとランのテストケースごと取得する
+func findTestPlanForClone(db *gorm.DB, id uint) (model.TestPlan, error) {
+	var testPlan model.TestPlan
+	err := db.
+		Preload("TestRuns", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("TestRuns.TestRunCases", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("TestRuns.TestRunCases.TestCase").
+		First(&testPlan, id).Error
+	return testPlan, err
+}
+
+// cloneTestPlan は source を複製し、複製の結果と担当者のユーザー ID を返す
+func cloneTestPlan(tx *gorm.DB, source model.TestPlan, title string, includeNewCases bool, statusID uint, userID uint) (util.TestPlanCloneResult, []uint, error) {
+	result := util.TestPlanCloneResult{Title: title, TestRuns: []util.TransferredID{}}
+	assigneeIDs := []uint{}
+	assigned := map[uint]bool{}
+
+	testPlan := model.TestPlan{
+		ProjectID:   source.ProjectID,
+		Status:      testPlanStatusNotExecuted,
+		Title:       title,
+		CreatedByID: userID,
+		UpdatedByID: userID,
+		MilestoneID: source.MilestoneID,
+	}
+	if err := tx.Create(&testPlan).Error; err != nil {
+		return result, nil, err
+	}
+	result.ID = testPlan.ID
+
+	for _, sourceRun := range source.TestRuns {
+		testRun := model.TestRun{
+			ProjectID:    sourceRun.ProjectID,
+			TestPlanID:   testPlan.ID,
+			Title:        sourceRun.Title,
+			Status:       testPlanStatusNotExecuted,
+			ApprovedOnly: sourceRun.ApprovedOnly,
+			MilestoneID:  sourceRun.MilestoneID,
+			CreatedByID:  userID,
+			UpdatedByID:  userID,
+		}
+		if err := tx.Create(&testRun).Error; err != nil {
+			return result, nil, err
+		}
+		result.TestRuns = append(result.TestRuns, util.TransferredID{SourceID: sourceRun.ID, ID: testRun.ID})
+
+		testRunCases := []model.TestRunCase{}
+		included := map[uint]bool{}
+		includedKeys := map[testRunCaseKey]bool{}
+		testSuiteIDs := []uint{}
+		seenSuites := map[uint]bool{}
+		for _, sourceCase := range sourceRun.TestRunCases {
+			// 元のランに追加した後で削除・廃止されたケースや、承認済みに限るランで承認されていないケースは加えない。
+			// 削除済みのケースはプリロードされず ID が 0 になる
+			if sourceCase.TestCase.ID == 0 || !isSelectableTestCase(testRun, sourceCase.TestCase.State) {
+				continue
+			}
+			// パラメータ表の行ごとのケースは追加した時点の行の値のまま複製する
+			key := newTestRunCaseKey(sourceCase)
+			if includedKeys[key] {
+				continue
+			}
+			includedKeys[key] = true
+			included[sourceCase.TestCaseID] = true
+			testRunCases = append(testRunCases, model.TestRunCase{
+				TestRunID:      testRun.ID,
+				TestCaseID:     sourceCase.TestCaseID,
+				AssignedToID:   sourceCase.AssignedToID,
+				StatusID:       statusID,
+				ParameterIndex: sourceCase.ParameterIndex,
+				Parameters:     sourceCase.Parameters,
+			})
+			if sourceCase.AssignedToID != nil && !assigned[*sourceCase.AssignedToID] {
+				assigned[*sourceCase.AssignedToID] = true
+				assigneeIDs = append(assigneeIDs, *sourceCase.AssignedToID)
+			}
+			if suiteID := sourceCase.TestCase.TestSuiteID; suiteID != nil && !seenSuites[*suiteID] {
+				seenSuites[*suiteID] = true
+				testSuiteIDs = append(testSuiteIDs, *suiteID)
+			}
+		}
+
+		// 元のランの作成後にスイートへ追加されたテストケースを末尾に加える（担当者は未設定）
+		if includeNewCases && len(testSuiteIDs) > 0 {
+			var added []model.TestCase
+			if err := tx.Select("id", "state").
+				Where("project_id = ? AND test_suite_id IN ? AND created_at > ?", sourceRun.ProjectID, testSuiteIDs, sourceRun.CreatedAt).
+				Order("order_index, id").
+				Find(&added).Error; err != nil {
+				return result, nil, err
+			}
+			addedIDs := []uint{}
+			for _, testCase := range added {
+				if included[testCase.ID] || !isSelectableTestCase(testRun, testCase.State) {
+					continue
+				}
+				included[testCase.ID] = true
+				addedIDs = append(addedIDs, testCase.ID)
+			}
+			parameters, err := loadTestCaseParameters(tx, addedIDs)
+			if err != nil {
+				return result, nil, err
+			}
+			for _, testCaseID := range addedIDs {
+				testRunCases = append(testRunCases, buildParameterizedTestRunCases(testRun.ID, testCaseID, statusID, nil, parameters[testCaseID])...)
+				result.AddedTestCases++
+			}
+		}
+
+		if len(testRunCases) > 0 {
+			if err := tx.CreateInBatches(&testRunCases, 100).Error; err != nil {
+				return result, nil, err
+			}
+		}
+	}
+	return result, assigneeIDs, nil
+}
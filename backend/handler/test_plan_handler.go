@@ -5,6 +5,7 @@ import (
 	"backend/util"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
@@ -37,8 +38,19 @@ func (h *TestPlanHandler) GetTestPlans(c *gin.Context) {
 		return
 	}
 
+	query := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Where("project_id = ?", project.ID)
+	// マイルストーンを指定した場合は、そのマイルストーンで実施するテストランを含むテストプランも返す
+	if v := c.Query("milestone_id"); v != "" {
+		milestoneID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			handleError(c, http.StatusBadRequest, "Invalid milestone_id", err)
+			return
+		}
+		query = query.Where("milestone_id = ? OR id IN (?)", milestoneID,
+			h.DB.Model(&model.TestRun{}).Select("test_plan_id").Where("milestone_id = ?", milestoneID))
+	}
 	var testPlans []model.TestPlan
-	result := h.DB.Preload("CreatedBy").Preload("UpdatedBy").Where("project_id = ?", project.ID).Find(&testPlans)
+	result := query.Find(&testPlans)
 	if result.Error != nil {
 		log.Fatal("Failed to retrieve records: ", result.Error)
 	}
@@ -46,33 +58,7 @@ func (h *TestPlanHandler) GetTestPlans(c *gin.Context) {
 	// Prepare the response data
 	testPlanResponses := []util.TestPlan{}
 	for _, testPlan := range testPlans {
-		var startedAtStr *string
-		var completedAtStr *string
-		if testPlan.StartedAt != nil && !testPlan.StartedAt.IsZero() {
-			formattedStartedAt := testPlan.StartedAt.Format("2006-01-02 15:04")
-			startedAtStr = &formattedStartedAt
-		}
-		if testPlan.CompletedAt != nil && !testPlan.CompletedAt.IsZero() {
-			formattedCompletedAt := testPlan.CompletedAt.Format("2006-01-02 15:04")
-			completedAtStr = &formattedCompletedAt
-		}
-
-		testPlanResponses = append(testPlanResponses, util.TestPlan{
-			ID:          testPlan.ID,
-			ProjectID:   testPlan.ProjectID,
-			Title:       testPlan.Title,
-			Status:      testPlan.Status,
-			StartedAt:   startedAtStr,
-			CompletedAt: completedAtStr,
-			CreatedBy: util.User{
-				ID:   testPlan.CreatedByID,
-				Name: testPlan.CreatedBy.Name,
-			},
-			UpdatedBy: util.User{
-				ID:   testPlan.UpdatedByID,
-				Name: testPlan.UpdatedBy.Name,
-			},
-		})
+		testPlanResponses = append(testPlanResponses, createTestPlanResponse(testPlan))
 	}
 
 	data := util.TestPlansResponseData{
@@ -83,6 +69,37 @@ func (h *TestPlanHandler) GetTestPlans(c *gin.Context) {
 	c.JSON(http.StatusOK, data)
 }
 
+func createTestPlanResponse(testPlan model.TestPlan) util.TestPlan {
+	var startedAtStr *string
+	var completedAtStr *string
+	if testPlan.StartedAt != nil && !testPlan.StartedAt.IsZero() {
+		formattedStartedAt := testPlan.StartedAt.Format("2006-01-02 15:04")
+		startedAtStr = &formattedStartedAt
+	}
+	if testPlan.CompletedAt != nil && !testPlan.CompletedAt.IsZero() {
+		formattedCompletedAt := testPlan.CompletedAt.Format("2006-01-02 15:04")
+		completedAtStr = &formattedCompletedAt
+	}
+
+	return util.TestPlan{
+		ID:          testPlan.ID,
+		ProjectID:   testPlan.ProjectID,
+		MilestoneID: testPlan.MilestoneID,
+		Title:       testPlan.Title,
+		Status:      testPlan.Status,
+		StartedAt:   startedAtStr,
+		CompletedAt: completedAtStr,
+		CreatedBy: util.User{
+			ID:   testPlan.CreatedByID,
+			Name: testPlan.CreatedBy.Name,
+		},
+		UpdatedBy: util.User{
+			ID:   testPlan.UpdatedByID,
+			Name: testPlan.UpdatedBy.Name,
+		},
+	}
+}
+
 func (h *TestPlanHandler) GetTestPlan(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -103,49 +120,18 @@ func (h *TestPlanHandler) GetTestPlan(c *gin.Context) {
 
 	testRuns := []util.TestRun{}
 	for _, run := range testPlan.TestRuns {
-		// 特定のTestRunに紐づくTestCaseのIDの一覧を取得
-		var testCaseIDs []uint
-		h.DB.Model(&model.TestRunCase{}).Where("test_run_id = ?", run.ID).Pluck("test_case_id", &testCaseIDs)
-		var startedAtStr *string
-		var completedAtStr *string
-
-		if run.StartedAt != nil && !run.StartedAt.IsZero() {
-			formattedStartedAt := run.StartedAt.Format("2006-01-02 15:04")
-			startedAtStr = &formattedStartedAt
-		}
-
-		if run.CompletedAt != nil && !run.CompletedAt.IsZero() {
-			formattedCompletedAt := run.CompletedAt.Format("2006-01-02 15:04")
-			completedAtStr = &formattedCompletedAt
-		}
-		testRuns = append(testRuns, util.TestRun{
-			ID:          run.ID,
-			ProjectID:   run.ProjectID,
-			Title:       run.Title,
-			Count:       len(testCaseIDs),
-			TestCaseIDs: testCaseIDs,
-			Status:      run.Status,
-			StartedAt:   startedAtStr,
-			CompletedAt: completedAtStr,
-			CreatedBy: util.User{
-				ID:   run.CreatedByID,
-				Name: run.CreatedBy.Name,
-			},
-			UpdatedBy: util.User{
-				ID:   run.UpdatedByID,
-				Name: run.UpdatedBy.Name,
-			},
-		})
+		testRuns = append(testRuns, createTestRunResponse(run))
 	}
 
 	charts := aggregateStatusCounts(testPlan.TestRuns)
 	testPlanResponses := util.TestPlanDetail{
-		ID:        testPlan.ID,
-		ProjectID: testPlan.ProjectID,
-		Title:     testPlan.Title,
-		Status:    testPlan.Status,
-		TestRuns:  testRuns,
-		Charts:    charts,
+		ID:          testPlan.ID,
+		ProjectID:   testPlan.ProjectID,
+		Title:       testPlan.Title,
+		Status:      testPlan.Status,
+		MilestoneID: testPlan.MilestoneID,
+		TestRuns:    testRuns,
+		Charts:      charts,
 		CreatedBy: util.User{
 			ID:   testPlan.CreatedByID,
 			Name: testPlan.CreatedBy.Name,
@@ -217,6 +203,11 @@ func (h *TestPlanHandler) PostTestPlan(c *gin.Context) {
 		return
 	}
 
+	if err := validateMilestone(h.DB, newTestPlan.ProjectID, newTestPlan.MilestoneID); err != nil {
+		handleMilestoneError(c, err)
+		return
+	}
+
 	if result := h.DB.Create(&newTestPlan); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -234,16 +225,48 @@ func (h *TestPlanHandler) PutTestPlan(c *gin.Context) {
 	}
 
 	var updatedTestPlan model.TestPlan
-	if err := c.ShouldBindJSON(&updatedTestPlan); err != nil {
+	if err := c.ShouldBindBodyWith(&updatedTestPlan, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 構造体の Updates では解除できないため、マイルストーンは指定された場合だけ個別に更新する
+	specified, err := milestoneSpecified(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	milestoneID := updatedTestPlan.MilestoneID
+	updatedTestPlan.MilestoneID = nil
+	if specified && milestoneID != nil {
+		var testPlan model.TestPlan
+		if result := h.DB.First(&testPlan, id); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Test Plan not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+		if err := validateMilestone(h.DB, testPlan.ProjectID, milestoneID); err != nil {
+			handleMilestoneError(c, err)
+			return
+		}
+	}
+
 	if result := h.DB.Model(&model.TestPlan{}).Where("id = ?", id).Updates(updatedTestPlan); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
+	if specified {
+		if result := h.DB.Model(&model.TestPlan{}).Where("id = ?", id).Update("milestone_id", milestoneID); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		updatedTestPlan.MilestoneID = milestoneID
+	}
+
 	c.JSON(http.StatusOK, updatedTestPlan)
 }
 
@@ -255,8 +278,24 @@ func (h *TestPlanHandler) DeletePlan(c *gin.Context) {
 		return
 	}
 
-	if result := h.DB.Delete(&model.TestPlan{}, id); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	// テストランとラン上のケースも同じ削除日時で削除し、ゴミ箱から一緒に復元できるようにする
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		tx = withDeletionTime(tx, deletionTimestamp())
+		var testRunIDs []uint
+		if err := tx.Model(&model.TestRun{}).Where("test_plan_id = ?", id).Pluck("id", &testRunIDs).Error; err != nil {
+			return err
+		}
+		if len(testRunIDs) > 0 {
+			if err := tx.Where("test_run_id IN ?", testRunIDs).Delete(&model.TestRunCase{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id IN ?", testRunIDs).Delete(&model.TestRun{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.TestPlan{}, id).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
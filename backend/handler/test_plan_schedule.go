@@ -0,0 +1,412 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scheduleTriggerCron      = "cron"
+	scheduleTriggerMilestone = "milestone"
+)
+
+type testPlanScheduleRequest struct {
+	ProjectID       uint   `json:"project_id"`
+	TestPlanID      uint   `json:"test_plan_id"`
+	Title           string `json:"title"`
+	Trigger         string `json:"trigger"`
+	Cron            string `json:"cron"`
+	DaysBeforeDue   int    `json:"days_before_due"`
+	IncludeNewCases bool   `json:"include_new_cases"`
+	Active          *bool  `json:"active"`
+}
+
+// validate はトリガーの設定を検証する
+func (r *testPlanScheduleRequest) validate() error {
+	r.Trigger = strings.TrimSpace(r.Trigger)
+	r.Cron = strings.TrimSpace(r.Cron)
+	switch r.Trigger {
+	case scheduleTriggerCron:
+		if _, err := util.ParseCron(r.Cron); err != nil {
+			return err
+		}
+	case scheduleTriggerMilestone:
+		if r.DaysBeforeDue < 0 {
+			return errors.New("days_before_due must not be negative")
+		}
+		r.Cron = ""
+	default:
+		return errors.New("trigger must be cron or milestone")
+	}
+	return nil
+}
+
+func (h *TestPlanHandler) GetTestPlanSchedules(c *gin.Context) {
+	var project model.Project
+	if result := h.DB.Where("code = ?", c.Param("project_code")).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	var schedules []model.TestPlanSchedule
+	if err := h.DB.Preload("TestPlan").Where("project_id = ?", project.ID).Order("id").Find(&schedules).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test plan schedules", err)
+		return
+	}
+	milestones, err := findScheduleMilestones(h.DB, schedules)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve milestones", err)
+		return
+	}
+
+	responses := []util.TestPlanSchedule{}
+	for _, schedule := range schedules {
+		responses = append(responses, createTestPlanScheduleResponse(schedule, milestones[schedule.ProjectID]))
+	}
+	c.JSON(http.StatusOK, util.TestPlanSchedulesResponseData{
+		ProjectID: project.ID,
+		Schedules: responses,
+	})
+}
+
+func (h *TestPlanHandler) PostTestPlanSchedule(c *gin.Context) {
+	var req testPlanScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid schedule", err)
+		return
+	}
+	if !h.validateScheduleTemplate(c, req.ProjectID, req.TestPlanID) {
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	schedule := model.TestPlanSchedule{
+		ProjectID:       req.ProjectID,
+		TestPlanID:      req.TestPlanID,
+		Title:           strings.TrimSpace(req.Title),
+		TriggerType:     req.Trigger,
+		Cron:            req.Cron,
+		DaysBeforeDue:   req.DaysBeforeDue,
+		IncludeNewCases: req.IncludeNewCases,
+		Active:          req.Active == nil || *req.Active,
+		CreatedByID:     user.ID,
+		UpdatedByID:     user.ID,
+	}
+	if err := h.DB.Create(&schedule).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to create test plan schedule", err)
+		return
+	}
+
+	h.respondTestPlanSchedule(c, http.StatusCreated, schedule.ID)
+}
+
+// PutTestPlanSchedule はスケジュールを更新する。次の実行時刻は更新した時刻から数え直す
+func (h *TestPlanHandler) PutTestPlanSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	var req testPlanScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid request data", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid schedule", err)
+		return
+	}
+
+	var schedule model.TestPlanSchedule
+	if result := h.DB.First(&schedule, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test plan schedule not found", result.Error)
+		} else {
+			handleError(c, http.StatusInternalServerError, "Database error", result.Error)
+		}
+		return
+	}
+	if !h.validateScheduleTemplate(c, schedule.ProjectID, req.TestPlanID) {
+		return
+	}
+
+	user, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	active := schedule.Active
+	if req.Active != nil {
+		active = *req.Active
+	}
+	if err := h.DB.Model(&schedule).Updates(map[string]interface{}{
+		"test_plan_id":      req.TestPlanID,
+		"title":             strings.TrimSpace(req.Title),
+		"trigger_type":      req.Trigger,
+		"cron":              req.Cron,
+		"days_before_due":   req.DaysBeforeDue,
+		"include_new_cases": req.IncludeNewCases,
+		"active":            active,
+		"updated_by_id":     user.ID,
+	}).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update test plan schedule", err)
+		return
+	}
+
+	h.respondTestPlanSchedule(c, http.StatusOK, schedule.ID)
+}
+
+func (h *TestPlanHandler) DeleteTestPlanSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	if err := h.DB.Delete(&model.TestPlanSchedule{}, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to delete test plan schedule", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// validateScheduleTemplate は複製元のテストプランがスケジュールと同じプロジェクトにあるかを確認する
+func (h *TestPlanHandler) validateScheduleTemplate(c *gin.Context, projectID uint, testPlanID uint) bool {
+	var count int64
+	if err := h.DB.Model(&model.TestPlan{}).Where("id = ? AND project_id = ?", testPlanID, projectID).Count(&count).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test plan", err)
+		return false
+	}
+	if count == 0 {
+		handleError(c, http.StatusBadRequest, "Test plan not found in project", nil)
+		return false
+	}
+	return true
+}
+
+func (h *TestPlanHandler) respondTestPlanSchedule(c *gin.Context, code int, id uint) {
+	var schedule model.TestPlanSchedule
+	if err := h.DB.Preload("TestPlan").First(&schedule, id).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test plan schedule", err)
+		return
+	}
+	milestones, err := findScheduleMilestones(h.DB, []model.TestPlanSchedule{schedule})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve milestones", err)
+		return
+	}
+	c.JSON(code, createTestPlanScheduleResponse(schedule, milestones[schedule.ProjectID]))
+}
+
+func createTestPlanScheduleResponse(schedule model.TestPlanSchedule, milestones []model.Milestone) util.TestPlanSchedule {
+	response := util.TestPlanSchedule{
+		ID:              schedule.ID,
+		ProjectID:       schedule.ProjectID,
+		TestPlanID:      schedule.TestPlanID,
+		TestPlanTitle:   schedule.TestPlan.Title,
+		Title:           schedule.Title,
+		Trigger:         schedule.TriggerType,
+		Cron:            schedule.Cron,
+		DaysBeforeDue:   schedule.DaysBeforeDue,
+		IncludeNewCases: schedule.IncludeNewCases,
+		Active:          schedule.Active,
+		LastTestPlanID:  schedule.LastTestPlanID,
+	}
+	if schedule.LastRunAt != nil {
+		lastRunAt := schedule.LastRunAt.Format("2006-01-02 15:04")
+		response.LastRunAt = &lastRunAt
+	}
+	if schedule.Active {
+		if next, _ := nextScheduleRun(schedule, milestones); !next.IsZero() {
+			nextRunAt := next.Format("2006-01-02 15:04")
+			response.NextRunAt = &nextRunAt
+		}
+	}
+	return response
+}
+
+// findScheduleMilestones はマイルストーンをトリガーにするスケジュールのプロジェクトのマイルストーンを期日順に返す
+func findScheduleMilestones(db *gorm.DB, schedules []model.TestPlanSchedule) (map[uint][]model.Milestone, error) {
+	projectIDs := []uint{}
+	seen := map[uint]bool{}
+	for _, schedule := range schedules {
+		if schedule.TriggerType == scheduleTriggerMilestone && !seen[schedule.ProjectID] {
+			seen[schedule.ProjectID] = true
+			projectIDs = append(projectIDs, schedule.ProjectID)
+		}
+	}
+	milestones := map[uint][]model.Milestone{}
+	if len(projectIDs) == 0 {
+		return milestones, nil
+	}
+
+	var records []model.Milestone
+	if err := db.Where("project_id IN ? AND status <> ?", projectIDs, "Completed").Order("due_date, id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, milestone := range records {
+		milestones[milestone.ProjectID] = append(milestones[milestone.ProjectID], milestone)
+	}
+	return milestones, nil
+}
+
+// nextScheduleRun は最後に実行（または更新）した時刻より後の最初の実行時刻と、
+// マイルストーンがトリガーの場合はそのマイルストーンを返す
+func nextScheduleRun(schedule model.TestPlanSchedule, milestones []model.Milestone) (time.Time, *model.Milestone) {
+	// 実行時と更新時に updated_at が進むので、それ以前の実行時刻は数えない
+	after := schedule.UpdatedAt.In(time.Local)
+	switch schedule.TriggerType {
+	case scheduleTriggerCron:
+		cron, err := util.ParseCron(schedule.Cron)
+		if err != nil {
+			return time.Time{}, nil
+		}
+		return cron.Next(after), nil
+	case scheduleTriggerMilestone:
+		for i, milestone := range milestones {
+			due := milestone.DueDate
+			at := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -schedule.DaysBeforeDue)
+			if at.After(after) {
+				return at, &milestones[i]
+			}
+		}
+	}
+	return time.Time{}, nil
+}
+
+// scheduledTestPlanTitle は複製したプランのタイトルを返す。タイトルが空の場合は複製元のタイトルに日付を付ける
+func scheduledTestPlanTitle(schedule model.TestPlanSchedule, source model.TestPlan, at time.Time, milestone *model.Milestone) string {
+	title := schedule.Title
+	if title == "" {
+		title = source.Title + " {date}"
+	}
+	milestoneTitle := ""
+	if milestone != nil {
+		milestoneTitle = milestone.Title
+	}
+	return strings.NewReplacer("{date}", at.Format("2006-01-02"), "{milestone}", milestoneTitle).Replace(title)
+}
+
+// RunTestPlanScheduler は interval ごとに実行時刻を過ぎたスケジュールのテストプランを複製する
+func RunTestPlanScheduler(db *gorm.DB, sender util.EmailSender, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := RunDueTestPlanSchedules(db, sender, time.Now()); err != nil {
+			log.Printf("Error: failed to run test plan schedules: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// RunDueTestPlanSchedules は now までに実行時刻が来た有効なスケジュールを実行する。
+// 実行が遅れて複数回分の時刻を過ぎていても、複製は一度だけ行う
+func RunDueTestPlanSchedules(db *gorm.DB, sender util.EmailSender, now time.Time) error {
+	var schedules []model.TestPlanSchedule
+	if err := db.Where("active = ?", true).Order("id").Find(&schedules).Error; err != nil {
+		return err
+	}
+	milestones, err := findScheduleMilestones(db, schedules)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		at, milestone := nextScheduleRun(schedule, milestones[schedule.ProjectID])
+		if at.IsZero() || at.After(now) {
+			continue
+		}
+		if err := runTestPlanSchedule(db, sender, schedule, at, milestone, now); err != nil {
+			log.Printf("Error: failed to run test plan schedule %d: %v", schedule.ID, err)
+		}
+	}
+	return nil
+}
+
+// runTestPlanSchedule は一つのスケジュールを実行する。
+// 複数のレプリカが同時に実行しても、読み込んだ時の updated_at のままのスケジュールを更新できた一つだけが複製する
+func runTestPlanSchedule(db *gorm.DB, sender util.EmailSender, schedule model.TestPlanSchedule, at time.Time, milestone *model.Milestone, now time.Time) error {
+	var cloned util.TestPlanCloneResult
+	var assigneeIDs []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.TestPlanSchedule{}).
+			Where("id = ? AND updated_at = ?", schedule.ID, schedule.UpdatedAt).
+			Updates(map[string]interface{}{"last_run_at": now, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		source, err := findTestPlanForClone(tx, schedule.TestPlanID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 複製元が削除されたスケジュールは止める
+			log.Printf("Warning: template of test plan schedule %d is not found. The schedule is deactivated", schedule.ID)
+			return tx.Model(&model.TestPlanSchedule{}).Where("id = ?", schedule.ID).UpdateColumn("active", false).Error
+		}
+		if err != nil {
+			return err
+		}
+		var status model.Status
+		if err := tx.Where("statuses.default = ?", 1).First(&status).Error; err != nil {
+			return err
+		}
+
+		title := scheduledTestPlanTitle(schedule, source, at, milestone)
+		// マイルストーンをトリガーにした場合は、複製したテストプランをそのマイルストーンに紐づける
+		if milestone != nil {
+			source.MilestoneID = &milestone.ID
+		}
+		cloned, assigneeIDs, err = cloneTestPlan(tx, source, title, schedule.IncludeNewCases, status.ID, schedule.CreatedByID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.TestPlanSchedule{}).Where("id = ?", schedule.ID).UpdateColumn("last_test_plan_id", cloned.ID).Error
+	})
+	if err != nil || len(assigneeIDs) == 0 || sender == nil {
+		return err
+	}
+
+	var users []model.User
+	if err := db.Where("id IN ?", assigneeIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("Test plan \"%s\" has been created", cloned.Title)
+	body := fmt.Sprintf("The scheduled test plan \"%s\" has been created with %d test run(s).\nYou are assigned to test cases in it.\n", cloned.Title, len(cloned.TestRuns))
+	for _, user := range users {
+		if user.Email == "" {
+			continue
+		}
+		if err := sender.SendMail([]string{user.Email}, subject, body); err != nil {
+			log.Printf("Error: failed to notify %s of test plan %d: %v", user.Email, cloned.ID, err)
+		}
+	}
+	return nil
+}
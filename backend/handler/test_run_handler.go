@@ -5,10 +5,9 @@ import (
 	"backend/util"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
-	"log"
 	"math"
 	"net/http"
 	"sort"
@@ -61,15 +60,18 @@ func (h *TestRunHandler) GetTestRuns(c *gin.Context) {
 			completedAtStr = &formattedCompletedAt
 		}
 		responseTestRun := util.TestRun{
-			ID:          testRun.ID,
-			ProjectID:   testRun.ProjectID,
-			Count:       1,
-			Status:      testRun.Status,
-			StartedAt:   startedAtStr,
-			CompletedAt: completedAtStr,
-			Title:       testRun.Title,
-			CreatedBy:   convertUserToJSON(testRun.CreatedBy),
-			UpdatedBy:   convertUserToJSON(testRun.UpdatedBy),
+			ID:           testRun.ID,
+			ProjectID:    testRun.ProjectID,
+			Count:        1,
+			Status:       testRun.Status,
+			StartedAt:    startedAtStr,
+			CompletedAt:  completedAtStr,
+			Title:        testRun.Title,
+			TestPlanID:   testRun.TestPlanID,
+			MilestoneID:  testRun.MilestoneID,
+			ApprovedOnly: testRun.ApprovedOnly,
+			CreatedBy:    convertUserToJSON(testRun.CreatedBy),
+			UpdatedBy:    convertUserToJSON(testRun.UpdatedBy),
 		}
 		responseTestRuns = append(responseTestRuns, responseTestRun)
 	}
@@ -105,6 +107,13 @@ func (h *TestRunHandler) GetTestRunCase(c *gin.Context) {
 		}
 		return
 	}
+	testRunCases := []model.TestRunCase{testRunCase}
+	if err := loadTestRunCaseSteps(h.DB, testRunCases); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test steps", err)
+		return
+	}
+	applyTestRunCaseParameters(testRunCases)
+	testRunCase = testRunCases[0]
 
 	commentsJSON := []util.Comment{}
 	for _, comment := range testRunCase.Comments {
@@ -124,6 +133,11 @@ func (h *TestRunHandler) GetTestRunCase(c *gin.Context) {
 		Status:     util.Status{ID: testRunCase.StatusID, Name: testRunCase.Status.Name},
 		AssignedTo: assignedToJSON,
 		Comments:   commentsJSON,
+		Steps:      testCaseStepResponses(testRunCase.TestCase.Steps),
+
+		ParameterIndex:      testRunCase.ParameterIndex,
+		Parameters:          testRunCaseParameters(testRunCase),
+		BlockedFromStatusID: testRunCase.BlockedFromStatusID,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -164,7 +178,7 @@ func (h *TestRunHandler) GetTestRunCases(c *gin.Context) {
 	testRunID := uint(testRunIDInt)
 
 	var testRun = model.TestRun{}
-	preResult := h.DB.Preload("TestRunCases.Status").First(&testRun, testRunID)
+	preResult := h.DB.First(&testRun, testRunID)
 	if preResult.Error != nil {
 		if errors.Is(preResult.Error, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Test Run not found"})
@@ -178,12 +192,12 @@ func (h *TestRunHandler) GetTestRunCases(c *gin.Context) {
 		var data util.TestRunCasesResponseData
 		err := json.Unmarshal([]byte(testRun.FinalizedTestCases), &data)
 		if err != nil {
-			fmt.Println("Error unmarshalling JSON:", err)
+			handleError(c, http.StatusInternalServerError, "Failed to read finalized test cases", err)
 			return
 		}
 
 		// 念の為置き換える
-		data.TestPlanId = testRun.ProjectID
+		data.ProjectID = testRun.ProjectID
 		data.TestRunID = testRunID
 		data.TestPlanId = testRun.TestPlanID
 		data.Status = testRun.Status
@@ -192,10 +206,12 @@ func (h *TestRunHandler) GetTestRunCases(c *gin.Context) {
 		return
 	}
 
+	// ケース数に関わらずクエリ数が一定になるよう、ラン内のケースとプロジェクトのスイートをそれぞれ一括で取得する
 	var testRunCases []model.TestRunCase
-	result := h.DB.
-		Preload("TestCase.TestSuite", func(db *gorm.DB) *gorm.DB {
-			return db.Order("test_suites.order_index ASC, test_suites.id ASC")
+	// スイート削除時に結果を残したケースも表示できるよう、削除済みのケース・スイートも含めて取得する
+	if result := h.DB.
+		Preload("TestCase", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
 		}).
 		Preload("Status").
 		Preload("AssignedTo").
@@ -204,67 +220,26 @@ func (h *TestRunHandler) GetTestRunCases(c *gin.Context) {
 		Preload("Comments.UpdatedBy").
 		Preload("Comments").
 		Where("test_run_id = ?", testRunID).
-		Find(&testRunCases)
-	if result.Error != nil {
-		log.Fatal("Failed to retrieve records: ", result.Error)
-	}
-
-	testSuiteMap := make(map[uint][]model.TestRunCase)
-	testSuiteHierarchyMap := make(map[uint][]model.TestSuite)
-	addedTestSuites := make(map[uint]bool)
-	for _, trc := range testRunCases {
-		var testSuiteID uint
-		if trc.TestCase.TestSuiteID != nil {
-			testSuiteID = *trc.TestCase.TestSuiteID
-		}
-
-		testSuite := trc.TestCase.TestSuite
-		parentID := uint(0)
-		if testSuite.ParentID != nil {
-			parentID = *testSuite.ParentID
-		}
-
-		testSuiteMap[testSuiteID] = append(testSuiteMap[testSuiteID], trc)
-
-		if _, exists := addedTestSuites[testSuite.ID]; !exists {
-			testSuiteHierarchyMap[parentID] = append(testSuiteHierarchyMap[parentID], testSuite)
-			addedTestSuites[testSuite.ID] = true
-		}
+		Find(&testRunCases); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve records", result.Error)
+		return
 	}
-
-	topLevelTestSuites := []model.TestSuite{}
-	for testSuiteID := range testSuiteMap {
-		if testSuiteID != 0 {
-			var testSuite model.TestSuite
-			h.DB.First(&testSuite, testSuiteID)
-			if testSuite.ParentID == nil {
-				topLevelTestSuites = append(topLevelTestSuites, testSuite)
-			}
-		}
+	if err := loadTestRunCaseSteps(h.DB, testRunCases); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test steps", err)
+		return
 	}
-
-	sort.Slice(topLevelTestSuites, func(i, j int) bool {
-		if topLevelTestSuites[i].OrderIndex == topLevelTestSuites[j].OrderIndex {
-			return topLevelTestSuites[i].ID < topLevelTestSuites[j].ID
-		}
-		return topLevelTestSuites[i].OrderIndex < topLevelTestSuites[j].OrderIndex
-	})
+	applyTestRunCaseParameters(testRunCases)
 
 	var allTestSuites []model.TestSuite
-	resultAllSuites := h.DB.Where("project_id = ?", testRun.ProjectID).Find(&allTestSuites)
-	if resultAllSuites.Error != nil {
-		log.Fatal("Failed to retrieve TestSuites: ", result.Error)
-	}
-	childTestSuitesMap := make(map[uint][]model.TestSuite)
-	for _, testSuite := range allTestSuites {
-		if testSuite.ParentID != nil {
-			parentID := *testSuite.ParentID
-			childTestSuitesMap[parentID] = append(childTestSuitesMap[parentID], testSuite)
-		}
+	if result := h.DB.Unscoped().Where("project_id = ?", testRun.ProjectID).
+		Order("test_suites.order_index ASC, test_suites.id ASC").
+		Find(&allTestSuites); result.Error != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test suites", result.Error)
+		return
 	}
 
-	jsonTestSuites := convertToTestRunCaseTestSuites(topLevelTestSuites, testSuiteMap, childTestSuitesMap)
-	jsonOnlyTestSuites := convertToJSONOnlyTestSuites(topLevelTestSuites, testSuiteHierarchyMap)
+	jsonTestSuites, jsonOnlyTestSuites := buildTestRunCaseTree(allTestSuites, testRunCases)
+	testRun.TestRunCases = testRunCases
 	charts := aggregateStatusCounts([]model.TestRun{testRun})
 
 	responseData := util.TestRunCasesResponseData{
@@ -281,13 +256,81 @@ func (h *TestRunHandler) GetTestRunCases(c *gin.Context) {
 	c.JSON(http.StatusOK, responseData)
 }
 
+// loadTestRunCaseSteps はラン内のテストケースの手順を一括で取得し、共有ステップをその時点の手順に展開する。
+// 完了時の確定データにはこの展開済みの手順が残る
+func loadTestRunCaseSteps(db *gorm.DB, testRunCases []model.TestRunCase) error {
+	testCaseIDs := []uint{}
+	for _, testRunCase := range testRunCases {
+		testCaseIDs = append(testCaseIDs, testRunCase.TestCaseID)
+	}
+	if len(testCaseIDs) == 0 {
+		return nil
+	}
+	var steps []model.TestCaseStep
+	if err := db.Where("test_case_id IN ?", uniqueUints(testCaseIDs)).Order("order_index, id").Find(&steps).Error; err != nil {
+		return err
+	}
+	stepsByTestCase := map[uint][]model.TestCaseStep{}
+	for _, step := range steps {
+		stepsByTestCase[step.TestCaseID] = append(stepsByTestCase[step.TestCaseID], step)
+	}
+	stepLists := make([]*[]model.TestCaseStep, 0, len(testRunCases))
+	for i := range testRunCases {
+		testRunCases[i].TestCase.Steps = append([]model.TestCaseStep{}, stepsByTestCase[testRunCases[i].TestCaseID]...)
+		stepLists = append(stepLists, &testRunCases[i].TestCase.Steps)
+	}
+	return resolveSharedSteps(db, stepLists...)
+}
+
+// createTestRunResponse はケースをプリロードしたテストランをレスポンスにする
+func createTestRunResponse(run model.TestRun) util.TestRun {
+	// TestRunCases はプリロード済みのため、ランごとに問い合わせずにTestCaseのIDの一覧を組み立てる
+	testCaseIDs := []uint{}
+	for _, testRunCase := range run.TestRunCases {
+		testCaseIDs = append(testCaseIDs, testRunCase.TestCaseID)
+	}
+	var startedAtStr *string
+	var completedAtStr *string
+
+	if run.StartedAt != nil && !run.StartedAt.IsZero() {
+		formattedStartedAt := run.StartedAt.Format("2006-01-02 15:04")
+		startedAtStr = &formattedStartedAt
+	}
+
+	if run.CompletedAt != nil && !run.CompletedAt.IsZero() {
+		formattedCompletedAt := run.CompletedAt.Format("2006-01-02 15:04")
+		completedAtStr = &formattedCompletedAt
+	}
+	return util.TestRun{
+		ID:           run.ID,
+		ProjectID:    run.ProjectID,
+		TestPlanID:   run.TestPlanID,
+		MilestoneID:  run.MilestoneID,
+		Title:        run.Title,
+		Count:        len(testCaseIDs),
+		TestCaseIDs:  testCaseIDs,
+		Status:       run.Status,
+		StartedAt:    startedAtStr,
+		CompletedAt:  completedAtStr,
+		ApprovedOnly: run.ApprovedOnly,
+		CreatedBy: util.User{
+			ID:   run.CreatedByID,
+			Name: run.CreatedBy.Name,
+		},
+		UpdatedBy: util.User{
+			ID:   run.UpdatedByID,
+			Name: run.UpdatedBy.Name,
+		},
+	}
+}
+
 func calcPercentage(testRuns []model.TestRun) int {
 	var testRunCaseCount = 0
 	var defaultCount = 0
 	for _, run := range testRuns {
 		testRunCaseCount += len(run.TestRunCases)
 		for _, testRunCase := range run.TestRunCases {
-			if testRunCase.Status.Default {
+			if testRunCase.Status != nil && testRunCase.Status.Default {
 				defaultCount++
 			}
 		}
@@ -301,35 +344,79 @@ func calcPercentage(testRuns []model.TestRun) int {
 	return int(math.Round(percentage))
 }
 
-func hasTestCases(testSuiteID uint, testRunCaseMap map[uint][]model.TestRunCase, childTestSuitesMap map[uint][]model.TestSuite) bool {
-	if len(testRunCaseMap[testSuiteID]) > 0 {
-		return true
+// movedTestSuiteName は別のプロジェクトへ移動したケースをまとめるグループの名前。グループの ID は 0 とする
+const movedTestSuiteName = "Moved to another project"
+
+// buildTestRunCaseTree はプロジェクトのスイート一覧とラン内のケースから、ケースを含むスイートだけのツリーを一度の走査で組み立てる
+func buildTestRunCaseTree(testSuites []model.TestSuite, testRunCases []model.TestRunCase) ([]util.TestRunCasesTestSuite, []util.JSONOnlyTestSuite) {
+	sort.SliceStable(testRunCases, func(i, j int) bool {
+		a, b := testRunCases[i].TestCase, testRunCases[j].TestCase
+		if a.OrderIndex == b.OrderIndex {
+			if a.ID == b.ID {
+				return newTestRunCaseKey(testRunCases[i]).ParameterIndex < newTestRunCaseKey(testRunCases[j]).ParameterIndex
+			}
+			return a.ID < b.ID
+		}
+		return a.OrderIndex < b.OrderIndex
+	})
+
+	projectTestSuites := make(map[uint]bool, len(testSuites))
+	for _, testSuite := range testSuites {
+		projectTestSuites[testSuite.ID] = true
 	}
 
-	for _, childTestSuite := range childTestSuitesMap[testSuiteID] {
-		if hasTestCases(childTestSuite.ID, testRunCaseMap, childTestSuitesMap) {
-			return true
+	testRunCaseMap := make(map[uint][]model.TestRunCase)
+	directCounts := make(map[uint]int)
+	var movedTestRunCases []model.TestRunCase
+	for _, trc := range testRunCases {
+		if trc.TestCase.TestSuiteID == nil {
+			continue
+		}
+		testSuiteID := *trc.TestCase.TestSuiteID
+		// 履歴を残したまま別のプロジェクトへ移動したケースは移動先のスイートに属するため、別のグループにまとめる
+		if !projectTestSuites[testSuiteID] {
+			movedTestRunCases = append(movedTestRunCases, trc)
+			continue
 		}
+		testRunCaseMap[testSuiteID] = append(testRunCaseMap[testSuiteID], trc)
+		directCounts[testSuiteID]++
 	}
 
-	return false
+	testSuiteMap := groupTestSuitesByParent(testSuites)
+	caseCounts := countSubtreeTestCases(directCounts, testSuiteMap)
+
+	prunedTestSuiteMap := make(map[uint][]model.TestSuite)
+	for parentID, children := range testSuiteMap {
+		for _, child := range children {
+			if caseCounts[child.ID] > 0 {
+				prunedTestSuiteMap[parentID] = append(prunedTestSuiteMap[parentID], child)
+			}
+		}
+	}
+
+	topLevelTestSuites := prunedTestSuiteMap[0]
+	jsonTestSuites := convertToTestRunCaseTestSuites(topLevelTestSuites, testRunCaseMap, prunedTestSuiteMap)
+	jsonOnlyTestSuites := convertToJSONOnlyTestSuites(topLevelTestSuites, prunedTestSuiteMap)
+	if len(movedTestRunCases) > 0 {
+		jsonTestSuites = append(jsonTestSuites, util.TestRunCasesTestSuite{
+			ID:         0,
+			Name:       movedTestSuiteName,
+			TestSuites: []util.TestRunCasesTestSuite{},
+			TestCases:  groupTestRunCaseIterations(movedTestRunCases),
+		})
+		jsonOnlyTestSuites = append(jsonOnlyTestSuites, util.JSONOnlyTestSuite{
+			ID:       0,
+			Title:    movedTestSuiteName,
+			Children: []util.JSONOnlyTestSuite{},
+		})
+	}
+	return jsonTestSuites, jsonOnlyTestSuites
 }
 
 func convertToTestRunCaseTestSuites(testSuites []model.TestSuite, testRunCaseMap map[uint][]model.TestRunCase, childTestSuitesMap map[uint][]model.TestSuite) []util.TestRunCasesTestSuite {
-	sort.Slice(testSuites, func(i, j int) bool {
-		return testSuites[i].OrderIndex < testSuites[j].OrderIndex
-	})
-
 	jsonTestSuites := []util.TestRunCasesTestSuite{}
 	for _, testSuite := range testSuites {
-		if !hasTestCases(testSuite.ID, testRunCaseMap, childTestSuitesMap) {
-			continue
-		}
-
-		jsonTestRunCases := []util.TestRunCase{}
-		for _, trc := range testRunCaseMap[testSuite.ID] {
-			jsonTestRunCases = append(jsonTestRunCases, convertToJSONTestRunCase(trc))
-		}
+		jsonTestRunCases := groupTestRunCaseIterations(testRunCaseMap[testSuite.ID])
 
 		childTestSuites := childTestSuitesMap[testSuite.ID]
 
@@ -343,6 +430,39 @@ func convertToTestRunCaseTestSuites(testSuites []model.TestSuite, testRunCaseMap
 	return jsonTestSuites
 }
 
+// groupTestRunCaseIterations はパラメータ表の行ごとのケースを元のテストケースの下にまとめる。
+// まとめた項目の ID は 0 で、状態はすべての行が同じ場合だけ設定する
+func groupTestRunCaseIterations(testRunCases []model.TestRunCase) []util.TestRunCase {
+	jsonTestRunCases := []util.TestRunCase{}
+	parents := map[uint]int{}
+	for _, trc := range testRunCases {
+		jsonTestRunCase := convertToJSONTestRunCase(trc)
+		if trc.ParameterIndex == nil {
+			jsonTestRunCases = append(jsonTestRunCases, jsonTestRunCase)
+			continue
+		}
+		index, found := parents[trc.TestCaseID]
+		if !found {
+			index = len(jsonTestRunCases)
+			parents[trc.TestCaseID] = index
+			jsonTestRunCases = append(jsonTestRunCases, util.TestRunCase{
+				TestCaseId: trc.TestCaseID,
+				Title:      trc.TestCase.Title,
+				Status:     jsonTestRunCase.Status,
+				Comments:   []util.Comment{},
+				Steps:      []util.TestCaseStep{},
+				Iterations: []util.TestRunCase{},
+			})
+		}
+		parent := &jsonTestRunCases[index]
+		if parent.Status.ID != jsonTestRunCase.Status.ID {
+			parent.Status = util.Status{}
+		}
+		parent.Iterations = append(parent.Iterations, jsonTestRunCase)
+	}
+	return jsonTestRunCases
+}
+
 func (h *TestRunHandler) PostTestRun(c *gin.Context) {
 	var newTestRun model.TestRun
 	if err := c.ShouldBindJSON(&newTestRun); err != nil {
@@ -350,6 +470,11 @@ func (h *TestRunHandler) PostTestRun(c *gin.Context) {
 		return
 	}
 
+	if err := validateMilestone(h.DB, newTestRun.ProjectID, newTestRun.MilestoneID); err != nil {
+		handleMilestoneError(c, err)
+		return
+	}
+
 	if result := h.DB.Create(&newTestRun); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -365,6 +490,48 @@ func (h *TestRunHandler) PostTestRunCase(c *gin.Context) {
 		return
 	}
 
+	var testRun model.TestRun
+	if result := h.DB.First(&testRun, newTestRunCase.TestRunID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test Run not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test run", result.Error)
+		return
+	}
+
+	var testCase model.TestCase
+	if result := h.DB.First(&testCase, newTestRunCase.TestCaseID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test case not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test case", result.Error)
+		return
+	}
+	if !isSelectableTestCase(testRun, testCase.State) {
+		handleError(c, http.StatusBadRequest, "Test case cannot be added to this test run", nil)
+		return
+	}
+
+	// パラメータ表のあるテストケースは行を指定して追加する
+	parameters, err := loadTestCaseParameters(h.DB, []uint{testCase.ID})
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve parameters", err)
+		return
+	}
+	newTestRunCase.Parameters = ""
+	if table := parameters[testCase.ID]; len(table.Rows) > 0 {
+		index := newTestRunCase.ParameterIndex
+		if index == nil || *index < 0 || *index >= len(table.Rows) {
+			handleError(c, http.StatusBadRequest, "Parameter row not found", nil)
+			return
+		}
+		newTestRunCase.Parameters = testRunCaseParameterSnapshot(table, *index)
+	} else {
+		newTestRunCase.ParameterIndex = nil
+	}
+
 	if result := h.DB.Create(&newTestRunCase); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -373,6 +540,15 @@ func (h *TestRunHandler) PostTestRunCase(c *gin.Context) {
 	c.JSON(http.StatusCreated, newTestRunCase)
 }
 
+// isSelectableTestCase はテストケースをランに追加できるかを返す。
+// 廃止したテストケースは追加できず、承認済みのみのランには承認済みのテストケースだけを追加できる
+func isSelectableTestCase(testRun model.TestRun, state string) bool {
+	if state == testCaseStateDeprecated {
+		return false
+	}
+	return !testRun.ApprovedOnly || state == testCaseStateApproved
+}
+
 type TestRunCasesRequest struct {
 	TestCaseIDs []uint `json:"test_case_ids"`
 	TestRunID   uint   `json:"test_run_id"`
@@ -385,29 +561,65 @@ func (h *TestRunHandler) PostTestRunCaseBulk(c *gin.Context) {
 		return
 	}
 
+	var testRun model.TestRun
+	if result := h.DB.First(&testRun, req.TestRunID); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Test Run not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve test run", result.Error)
+		return
+	}
+
 	// 既存のTestRunCaseを取得
 	var existingTestRunCases []model.TestRunCase
 	h.DB.Where("test_run_id = ?", req.TestRunID).Find(&existingTestRunCases)
 
-	// 既存のTestCaseIDのマップを作成
-	existingTestCaseIDs := make(map[uint]bool)
+	// 既存のTestRunCaseのマップを作成（パラメータ表の行ごとのケースは行ごとに区別する）
+	existingKeys := make(map[testRunCaseKey]bool)
+	testCaseIDs := append([]uint{}, req.TestCaseIDs...)
 	for _, trc := range existingTestRunCases {
-		existingTestCaseIDs[trc.TestCaseID] = true
+		existingKeys[newTestRunCaseKey(trc)] = true
+		testCaseIDs = append(testCaseIDs, trc.TestCaseID)
+	}
+
+	// テストケースの状態を取得（削除済みのテストケースは含まれない）
+	states := make(map[uint]string)
+	if testCaseIDs = uniqueUints(testCaseIDs); len(testCaseIDs) > 0 {
+		var testCases []model.TestCase
+		if result := h.DB.Select("id", "state").Where("id IN ?", testCaseIDs).Find(&testCases); result.Error != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", result.Error)
+			return
+		}
+		for _, testCase := range testCases {
+			states[testCase.ID] = testCase.State
+		}
+	}
+
+	selectedTestCaseIDs := []uint{}
+	for _, testCaseID := range uniqueUints(req.TestCaseIDs) {
+		if state, found := states[testCaseID]; found && isSelectableTestCase(testRun, state) {
+			selectedTestCaseIDs = append(selectedTestCaseIDs, testCaseID)
+		}
+	}
+	parameters, err := loadTestCaseParameters(h.DB, selectedTestCaseIDs)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve parameters", err)
+		return
 	}
 
-	// 新しいTestRunCaseを作成
+	// 新しいTestRunCaseを作成（パラメータ表のあるテストケースは行ごとに作成する）
 	status := model.Status{}
 	h.DB.Where("statuses.default = ?", 1).First(&status)
 
 	newTestRunCases := []model.TestRunCase{}
-	for _, testCaseID := range req.TestCaseIDs {
-		if _, exists := existingTestCaseIDs[testCaseID]; !exists {
-			newTestRunCase := model.TestRunCase{
-				TestRunID:  req.TestRunID,
-				TestCaseID: testCaseID,
-				StatusID:   status.ID,
+	for _, testCaseID := range selectedTestCaseIDs {
+		for _, newTestRunCase := range buildParameterizedTestRunCases(req.TestRunID, testCaseID, status.ID, nil, parameters[testCaseID]) {
+			key := newTestRunCaseKey(newTestRunCase)
+			if !existingKeys[key] {
+				existingKeys[key] = true
+				newTestRunCases = append(newTestRunCases, newTestRunCase)
 			}
-			newTestRunCases = append(newTestRunCases, newTestRunCase)
 		}
 	}
 
@@ -419,8 +631,11 @@ func (h *TestRunHandler) PostTestRunCaseBulk(c *gin.Context) {
 		}
 	}
 
-	// 不要になったTestRunCaseを削除
+	// 不要になったTestRunCaseを削除（廃止したテストケースは選択肢に出ないため、既存のランにはそのまま残す）
 	for _, existingCase := range existingTestRunCases {
+		if states[existingCase.TestCaseID] == testCaseStateDeprecated {
+			continue
+		}
 		if _, existsInRequest := findInSlice(req.TestCaseIDs, existingCase.TestCaseID); !existsInRequest {
 			h.DB.Delete(&existingCase)
 		}
@@ -483,16 +698,64 @@ func (h *TestRunHandler) PutTestRun(c *gin.Context) {
 	}
 
 	var updatedTestRun model.TestRun
-	if err := c.ShouldBindJSON(&updatedTestRun); err != nil {
+	if err := c.ShouldBindBodyWith(&updatedTestRun, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 構造体の Updates では false に戻せないため、承認済みのみの指定は個別に更新する
+	var settings struct {
+		ApprovedOnly *bool `json:"approved_only"`
+	}
+	if err := c.ShouldBindBodyWith(&settings, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// マイルストーンも指定された場合だけ個別に更新する（null でテストプランのマイルストーンに戻す）
+	specified, err := milestoneSpecified(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	milestoneID := updatedTestRun.MilestoneID
+	updatedTestRun.MilestoneID = nil
+	if specified && milestoneID != nil {
+		var testRun model.TestRun
+		if result := h.DB.First(&testRun, id); result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Test Run not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+		if err := validateMilestone(h.DB, testRun.ProjectID, milestoneID); err != nil {
+			handleMilestoneError(c, err)
+			return
+		}
+	}
+
 	if result := h.DB.Model(&model.TestRun{}).Where("id = ?", id).Updates(updatedTestRun); result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
+	if specified {
+		if result := h.DB.Model(&model.TestRun{}).Where("id = ?", id).Update("milestone_id", milestoneID); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		updatedTestRun.MilestoneID = milestoneID
+	}
+
+	if settings.ApprovedOnly != nil {
+		if result := h.DB.Model(&model.TestRun{}).Where("id = ?", id).Update("approved_only", *settings.ApprovedOnly); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, updatedTestRun)
 }
 
@@ -510,8 +773,17 @@ func (h *TestRunHandler) PutTestRunCase(c *gin.Context) {
 		return
 	}
 
-	if result := h.DB.Model(&model.TestRunCase{}).Where("id = ?", id).Updates(updatedTestRunCase); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.TestRunCase{}).Where("id = ?", id).Updates(updatedTestRunCase).Error; err != nil {
+			return err
+		}
+		// 結果を付けた場合は前提条件にしているケースの状態も同じトランザクションで変更する
+		if updatedTestRunCase.StatusID != 0 {
+			return updateDependentTestRunCases(c, tx, uint(id))
+		}
+		return nil
+	}); err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to update test run case", err)
 		return
 	}
 
@@ -569,10 +841,16 @@ func (h *TestRunHandler) DeleteTestRun(c *gin.Context) {
 		return
 	}
 
-	// 指定されたIDを持つTestRunCaseを削除
-	if result := h.DB.Delete(&model.TestRun{}, id); result.Error != nil {
+	// 指定されたIDを持つTestRunをラン上のケースごと削除
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		tx = withDeletionTime(tx, deletionTimestamp())
+		if err := tx.Where("test_run_id = ?", id).Delete(&model.TestRunCase{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.TestRun{}, id).Error
+	}); err != nil {
 		// データベースエラーの場合
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -617,12 +895,16 @@ func convertToJSONTestRunCase(trc model.TestRunCase) util.TestRunCase {
 		Title:      trc.TestCase.Title,
 		Content:    trc.TestCase.Content,
 		Comments:   commentsJSON,
+		Steps:      testCaseStepResponses(trc.TestCase.Steps),
 		Status: util.Status{
 			ID:    trc.Status.ID,
 			Name:  trc.Status.Name,
 			Color: trc.Status.Color,
 		},
-		AssignedTo: assignedToJSON,
+		AssignedTo:          assignedToJSON,
+		ParameterIndex:      trc.ParameterIndex,
+		Parameters:          testRunCaseParameters(trc),
+		BlockedFromStatusID: trc.BlockedFromStatusID,
 	}
 }
 
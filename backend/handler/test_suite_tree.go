@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+)
+
+// groupTestSuitesByParent はスイートを親IDごとにまとめる（ルートは 0）。入力の並び順は保持される
+func groupTestSuitesByParent(testSuites []model.TestSuite) map[uint][]model.TestSuite {
+	testSuiteMap := make(map[uint][]model.TestSuite)
+	for _, testSuite := range testSuites {
+		var parentID uint
+		if testSuite.ParentID != nil {
+			parentID = *testSuite.ParentID
+		}
+		testSuiteMap[parentID] = append(testSuiteMap[parentID], testSuite)
+	}
+	return testSuiteMap
+}
+
+// collectTestSuiteSubtree は指定したスイートと、その子孫スイートのIDを返す
+func collectTestSuiteSubtree(rootID uint, testSuiteMap map[uint][]model.TestSuite) []uint {
+	ids := []uint{rootID}
+	visited := map[uint]bool{rootID: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range testSuiteMap[ids[i]] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			ids = append(ids, child.ID)
+		}
+	}
+	return ids
+}
+
+// countSubtreeTestCases は各スイート配下（子孫スイートを含む）のテストケース数を返す
+func countSubtreeTestCases(directCounts map[uint]int, testSuiteMap map[uint][]model.TestSuite) map[uint]int {
+	counts := make(map[uint]int)
+	var count func(id uint) int
+	count = func(id uint) int {
+		if total, ok := counts[id]; ok {
+			return total
+		}
+		// 親子関係が循環していても無限再帰しないよう先に登録しておく
+		counts[id] = directCounts[id]
+		total := directCounts[id]
+		for _, child := range testSuiteMap[id] {
+			total += count(child.ID)
+		}
+		counts[id] = total
+		return total
+	}
+	for _, children := range testSuiteMap {
+		for _, child := range children {
+			count(child.ID)
+		}
+	}
+	return counts
+}
+
+// convertToJSONTestSuites はスイートツリーを組み立てる。prune が true の場合は該当ケースを持たないスイートを除外する
+func convertToJSONTestSuites(testSuites []model.TestSuite, testSuiteMap map[uint][]model.TestSuite, testCaseMap map[uint][]model.TestCase, caseCounts map[uint]int, prune bool) []util.JSONTestSuite {
+	jsonTestSuites := []util.JSONTestSuite{}
+	for _, testSuite := range testSuites {
+		if prune && caseCounts[testSuite.ID] == 0 {
+			continue
+		}
+
+		jsonTestCases := []util.TestCase{}
+		for _, testCase := range testCaseMap[testSuite.ID] {
+			jsonTestCases = append(jsonTestCases, createTestCaseResponse(testCase))
+		}
+
+		subTestSuites := convertToJSONTestSuites(testSuiteMap[testSuite.ID], testSuiteMap, testCaseMap, caseCounts, prune)
+		jsonTestSuites = append(jsonTestSuites, util.JSONTestSuite{
+			ID:         testSuite.ID,
+			Name:       testSuite.Name,
+			CaseCount:  caseCounts[testSuite.ID],
+			TestSuites: subTestSuites,
+			TestCases:  jsonTestCases,
+		})
+	}
+	return jsonTestSuites
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"encoding/xml"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// testRailSuite は TestRail のスイートの XML エクスポートを表す
+type testRailSuite struct {
+	XMLName  xml.Name          `xml:"suite"`
+	ID       string            `xml:"id"`
+	Name     string            `xml:"name"`
+	Sections []testRailSection `xml:"sections>section"`
+}
+
+type testRailSection struct {
+	ID       string            `xml:"id"`
+	Name     string            `xml:"name"`
+	Cases    []testRailCase    `xml:"cases>case"`
+	Sections []testRailSection `xml:"sections>section"`
+}
+
+type testRailCase struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Type       string         `xml:"type"`
+	Priority   string         `xml:"priority"`
+	References string         `xml:"references"`
+	Custom     testRailCustom `xml:"custom"`
+}
+
+type testRailCustom struct {
+	Preconds       string `xml:"preconds"`
+	Steps          string `xml:"steps"`
+	Expected       string `xml:"expected"`
+	StepsSeparated []struct {
+		Content  string `xml:"content"`
+		Expected string `xml:"expected"`
+	} `xml:"steps_separated>step"`
+	// 上記以外のカスタムフィールド（選択肢は <value> に、それ以外は要素の文字列に値が入る）
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:"value"`
+		Text    string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+// testRailImport は TestRail のエクスポートを取り込み用の行に変換したもの
+type testRailImport struct {
+	SectionPaths [][]string // すべてのセクションのパス（ケースの無いセクションも含めて文書順）
+	SectionIDs   []string   // SectionPaths と同じ順のセクションの ID
+	CaseIDs      []string   // rows と同じ順のケースの ID
+	Rows         []testCaseImportRow
+}
+
+// parseTestRailSuite は TestRail のスイート XML を読み込む。
+// セクションはスイート、前提条件は本文、参照・種別・優先度とカスタムフィールドはカスタムフィールドになる
+func parseTestRailSuite(r io.Reader, basePath []string) (testRailImport, error) {
+	var suite testRailSuite
+	if err := xml.NewDecoder(r).Decode(&suite); err != nil {
+		return testRailImport{}, errors.New("invalid TestRail xml file: " + err.Error())
+	}
+
+	result := testRailImport{}
+	var walk func(sections []testRailSection, path []string)
+	walk = func(sections []testRailSection, path []string) {
+		for _, section := range sections {
+			// セクション名に区切り文字が含まれていても一つの階層として扱う
+			name := strings.TrimSpace(section.Name)
+			if name == "" {
+				name = "Untitled"
+			}
+			sectionPath := append(append([]string{}, path...), name)
+			result.SectionPaths = append(result.SectionPaths, sectionPath)
+			result.SectionIDs = append(result.SectionIDs, strings.TrimSpace(section.ID))
+
+			for _, testRailCase := range section.Cases {
+				result.CaseIDs = append(result.CaseIDs, strings.TrimSpace(testRailCase.ID))
+				result.Rows = append(result.Rows, testRailCaseRow(testRailCase, sectionPath))
+			}
+			walk(section.Sections, sectionPath)
+		}
+	}
+	walk(suite.Sections, basePath)
+	return result, nil
+}
+
+func testRailCaseRow(testRailCase testRailCase, path []string) testCaseImportRow {
+	row := testCaseImportRow{
+		TestSuitePath: path,
+		Title:         strings.TrimSpace(testRailCase.Title),
+		Content:       strings.TrimSpace(testRailCase.Custom.Preconds),
+		Tags:          []string{},
+		CustomFields:  map[string]string{},
+	}
+
+	if len(testRailCase.Custom.StepsSeparated) > 0 {
+		for _, step := range testRailCase.Custom.StepsSeparated {
+			row.Steps = append(row.Steps, util.TestCaseStep{
+				Action:         strings.TrimSpace(step.Content),
+				ExpectedResult: strings.TrimSpace(step.Expected),
+			})
+		}
+	} else {
+		row.Steps = parseImportSteps(testRailCase.Custom.Steps, testRailCase.Custom.Expected)
+	}
+
+	for name, value := range map[string]string{
+		"references": testRailCase.References,
+		"type":       testRailCase.Type,
+		"priority":   testRailCase.Priority,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			row.CustomFields[name] = value
+		}
+	}
+	for _, field := range testRailCase.Custom.Fields {
+		value := strings.TrimSpace(field.Value)
+		if value == "" {
+			value = strings.TrimSpace(field.Text)
+		}
+		if value != "" {
+			row.CustomFields[field.XMLName.Local] = value
+		}
+	}
+	return row
+}
+
+// PostTestRailImport は TestRail のスイート XML を一つのトランザクションで取り込み、
+// 元の ID と作成したスイート・テストケースの ID の対応を返す
+func (h *ImportHandler) PostTestRailImport(c *gin.Context) {
+	project, ok := h.findProject(c)
+	if !ok {
+		return
+	}
+	dryRun, err := parseBoolQuery(c, "dry_run")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid dry_run", err)
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "File is required", err)
+		return
+	}
+	parsed, err := readTestRailFile(fileHeader, splitTestSuitePath(c.PostForm("test_suite_path")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessUser, err := findAccessUser(c, h.DB)
+	if err != nil {
+		handleAccessUserError(c, err)
+		return
+	}
+
+	result, err := importTestRailSuite(h.DB, project, accessUser, parsed, dryRun)
+	if err != nil {
+		handleError(c, http.StatusInternalServerError, "Insert failed", err)
+		return
+	}
+	if !dryRun && len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func readTestRailFile(fileHeader *multipart.FileHeader, basePath []string) (testRailImport, error) {
+	if fileHeader.Size > maxImportFileSize {
+		return testRailImport{}, errors.New("file is too large")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return testRailImport{}, err
+	}
+	defer file.Close()
+	return parseTestRailSuite(io.LimitReader(file, maxImportFileSize), basePath)
+}
+
+// importTestRailSuite はセクションを文書の順に作成してからテストケースを取り込む
+func importTestRailSuite(db *gorm.DB, project model.Project, user model.User, parsed testRailImport, dryRun bool) (util.TestRailImportResult, error) {
+	result := util.TestRailImportResult{
+		TestCaseImportResult: util.TestCaseImportResult{
+			DryRun:      dryRun,
+			TestCases:   len(parsed.Rows),
+			TestCaseIDs: []uint{},
+		},
+		TestSuiteMappings: []util.ImportIDMapping{},
+		TestCaseMappings:  []util.ImportIDMapping{},
+	}
+	var importer *testCaseImporter
+	err := runImport(db, dryRun, func(db *gorm.DB) (bool, error) {
+		var err error
+		if importer, err = newTestCaseImporter(db, project, user); err != nil {
+			return false, err
+		}
+		result.Errors = importer.validate(parsed.Rows)
+		if dryRun {
+			result.TestSuites = importer.countNewTestSuitePaths(parsed.SectionPaths)
+		}
+		return len(result.Errors) == 0, nil
+	}, func(tx *gorm.DB) error {
+		suiteCount := len(importer.testSuites)
+		// 同じ親の下に同じ名前のセクションがある場合は一つのスイートにまとめる
+		for index, path := range parsed.SectionPaths {
+			testSuiteID, err := importer.ensureTestSuite(tx, path)
+			if err != nil {
+				return err
+			}
+			if sourceID := parsed.SectionIDs[index]; sourceID != "" {
+				result.TestSuiteMappings = append(result.TestSuiteMappings, util.ImportIDMapping{SourceID: sourceID, ID: testSuiteID})
+			}
+		}
+
+		testCases, _, err := importer.importRows(tx, parsed.Rows)
+		if err != nil {
+			return err
+		}
+		result.TestSuites = len(importer.testSuites) - suiteCount
+		for index, testCase := range testCases {
+			result.TestCaseIDs = append(result.TestCaseIDs, testCase.ID)
+			if sourceID := parsed.CaseIDs[index]; sourceID != "" {
+				result.TestCaseMappings = append(result.TestCaseMappings, util.ImportIDMapping{SourceID: sourceID, ID: testCase.ID})
+			}
+		}
+		return nil
+	})
+	return result, err
+}
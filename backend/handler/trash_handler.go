@@ -0,0 +1,638 @@
+package handler
+
+import (
+	"backend/model"
+	"backend/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	trashTypeProject   = "projects"
+	trashTypeTestSuite = "suites"
+	trashTypeTestCase  = "cases"
+	trashTypeTestRun   = "runs"
+	trashTypeTestPlan  = "plans"
+	trashTypeMilestone = "milestones"
+)
+
+var (
+	errTrashItemNotFound = errors.New("trash item not found")
+	errTrashParentExists = errors.New("parent is in the trash")
+	errTrashInvalidType  = errors.New("invalid trash type")
+)
+
+type TrashHandler struct {
+	DB      *gorm.DB
+	Storage util.FileStorage
+}
+
+func NewTrashHandler(db *gorm.DB, storage util.FileStorage) *TrashHandler {
+	return &TrashHandler{DB: db, Storage: storage}
+}
+
+// deletionTimestamp は同時に削除するレコードへ付与する削除日時を返す（DB の精度に合わせてミリ秒で切り捨てる）
+func deletionTimestamp() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+// withDeletionTime は論理削除の deleted_at を deletedAt に固定したセッションを返す
+// 親と一緒に削除された子レコードを復元時に同じ deleted_at で見分けるために使う
+func withDeletionTime(db *gorm.DB, deletedAt time.Time) *gorm.DB {
+	return db.Session(&gorm.Session{NowFunc: func() time.Time { return deletedAt }})
+}
+
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	projectCode := c.Param("project_code")
+
+	var project model.Project
+	if result := h.DB.Where("code = ?", projectCode).First(&project); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			handleError(c, http.StatusNotFound, "Project not found", result.Error)
+			return
+		}
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve project", result.Error)
+		return
+	}
+
+	itemType := c.Query("type")
+	items := []util.TrashItem{}
+	deleted := h.DB.Unscoped().Where("project_id = ? AND deleted_at IS NOT NULL", project.ID).Session(&gorm.Session{})
+
+	if itemType == "" || itemType == trashTypeTestSuite {
+		// 親フォルダーと一緒に削除されたフォルダーは親側にまとめる
+		var testSuites []model.TestSuite
+		if err := deleted.Where("NOT EXISTS (SELECT 1 FROM test_suites AS parents WHERE parents.id = test_suites.parent_id AND parents.deleted_at = test_suites.deleted_at)").
+			Find(&testSuites).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test suites", err)
+			return
+		}
+		for _, testSuite := range testSuites {
+			items = append(items, newTrashItem(trashTypeTestSuite, testSuite.ID, testSuite.Name, testSuite.DeletedAt))
+		}
+	}
+
+	if itemType == "" || itemType == trashTypeTestCase {
+		var testCases []model.TestCase
+		if err := deleted.Where("NOT EXISTS (SELECT 1 FROM test_suites WHERE test_suites.id = test_cases.test_suite_id AND test_suites.deleted_at = test_cases.deleted_at)").
+			Find(&testCases).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test cases", err)
+			return
+		}
+		for _, testCase := range testCases {
+			items = append(items, newTrashItem(trashTypeTestCase, testCase.ID, testCase.Title, testCase.DeletedAt))
+		}
+	}
+
+	if itemType == "" || itemType == trashTypeTestPlan {
+		var testPlans []model.TestPlan
+		if err := deleted.Find(&testPlans).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test plans", err)
+			return
+		}
+		for _, testPlan := range testPlans {
+			items = append(items, newTrashItem(trashTypeTestPlan, testPlan.ID, testPlan.Title, testPlan.DeletedAt))
+		}
+	}
+
+	if itemType == "" || itemType == trashTypeTestRun {
+		var testRuns []model.TestRun
+		if err := deleted.Where("NOT EXISTS (SELECT 1 FROM test_plans WHERE test_plans.id = test_runs.test_plan_id AND test_plans.deleted_at = test_runs.deleted_at)").
+			Find(&testRuns).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve test runs", err)
+			return
+		}
+		for _, testRun := range testRuns {
+			items = append(items, newTrashItem(trashTypeTestRun, testRun.ID, testRun.Title, testRun.DeletedAt))
+		}
+	}
+
+	if itemType == "" || itemType == trashTypeMilestone {
+		var milestones []model.Milestone
+		if err := deleted.Find(&milestones).Error; err != nil {
+			handleError(c, http.StatusInternalServerError, "Failed to retrieve milestones", err)
+			return
+		}
+		for _, milestone := range milestones {
+			items = append(items, newTrashItem(trashTypeMilestone, milestone.ID, milestone.Title, milestone.DeletedAt))
+		}
+	}
+
+	sortTrashItems(items)
+	c.JSON(http.StatusOK, util.TrashResponseData{
+		ProjectID: project.ID,
+		Items:     items,
+	})
+}
+
+func (h *TrashHandler) GetTrashProjects(c *gin.Context) {
+	var projects []model.Project
+	if err := h.DB.Unscoped().Where("deleted_at IS NOT NULL").Find(&projects).Error; err != nil {
+		handleError(c, http.StatusInternalServerError, "Failed to retrieve projects", err)
+		return
+	}
+
+	items := []util.TrashItem{}
+	for _, project := range projects {
+		items = append(items, newTrashItem(trashTypeProject, project.ID, project.Title, project.DeletedAt))
+	}
+
+	sortTrashItems(items)
+	c.JSON(http.StatusOK, util.TrashResponseData{Items: items})
+}
+
+func (h *TrashHandler) RestoreTrashItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return restoreTrashItem(tx, c.Param("type"), uint(id))
+	}); err != nil {
+		handleTrashError(c, "Failed to restore", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TrashHandler) PurgeTrashItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, http.StatusBadRequest, "Invalid ID format", err)
+		return
+	}
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return purgeTrashItem(tx, c.Param("type"), uint(id))
+	}); err != nil {
+		handleTrashError(c, "Failed to purge", err)
+		return
+	}
+	// 物理削除したレコードの添付ファイルを片付ける（失敗しても次回の削除で片付ける）
+	if err := DeleteOrphanAttachments(h.DB, h.Storage); err != nil {
+		log.Printf("Error: failed to delete attachments: %v", err)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeExpiredTrash は deletedBefore より前に削除されたレコードを物理削除し、それらの添付ファイルを保存先から削除する
+func PurgeExpiredTrash(db *gorm.DB, storage util.FileStorage, deletedBefore time.Time) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := func(value interface{}) ([]uint, error) {
+			var ids []uint
+			err := tx.Unscoped().Model(value).Where("deleted_at < ?", deletedBefore).Pluck("id", &ids).Error
+			return ids, err
+		}
+
+		purges := []struct {
+			value interface{}
+			purge func(*gorm.DB, []uint) error
+		}{
+			{&model.Project{}, purgeProjects},
+			{&model.TestPlan{}, purgeTestPlans},
+			{&model.TestRun{}, purgeTestRuns},
+			{&model.TestSuite{}, purgeTestSuites},
+			{&model.TestCase{}, purgeTestCases},
+			{&model.Milestone{}, purgeMilestones},
+			{&model.Requirement{}, purgeRequirements},
+			{&model.SharedStep{}, purgeSharedSteps},
+			{&model.TestRunCase{}, purgeTestRunCases},
+		}
+		for _, p := range purges {
+			ids, err := expired(p.value)
+			if err != nil {
+				return err
+			}
+			if err := p.purge(tx, ids); err != nil {
+				return err
+			}
+		}
+
+		// 単独で削除されたコメントや属性を片付ける
+		for _, value := range []interface{}{&model.Comment{}, &model.TestCaseTag{}, &model.TestCaseCustomField{}, &model.TestCaseParameter{}, &model.TestCaseStep{}, &model.TestCaseReviewer{}, &model.SharedStepItem{}, &model.TestCaseTemplate{}, &model.ImportProfile{}} {
+			if err := tx.Unscoped().Where("deleted_at < ?", deletedBefore).Delete(value).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return DeleteOrphanAttachments(db, storage)
+}
+
+// RunTrashPurger は保持期間を過ぎたゴミ箱のレコードを interval ごとに物理削除する
+func RunTrashPurger(db *gorm.DB, storage util.FileStorage, retention time.Duration, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := PurgeExpiredTrash(db, storage, time.Now().Add(-retention)); err != nil {
+			log.Printf("Error: failed to purge trash: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func newTrashItem(itemType string, id uint, title string, deletedAt gorm.DeletedAt) util.TrashItem {
+	return util.TrashItem{
+		Type:      itemType,
+		ID:        id,
+		Title:     title,
+		DeletedAt: deletedAt.Time.Format("2006-01-02 15:04"),
+	}
+}
+
+func sortTrashItems(items []util.TrashItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt > items[j].DeletedAt
+	})
+}
+
+func handleTrashError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, errTrashInvalidType):
+		handleError(c, http.StatusBadRequest, "Invalid trash type", err)
+	case errors.Is(err, errTrashItemNotFound):
+		handleError(c, http.StatusNotFound, "Item not found in trash", err)
+	case errors.Is(err, errTrashParentExists):
+		handleError(c, http.StatusConflict, "Parent is in the trash. Restore it first", err)
+	default:
+		handleError(c, http.StatusInternalServerError, msg, err)
+	}
+}
+
+// findTrashed はゴミ箱にある（論理削除済みの）レコードを取得する
+func findTrashed(tx *gorm.DB, dest interface{}, id uint) error {
+	if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(dest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errTrashItemNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// requireAlive は親レコードが削除されていないことを確認する
+func requireAlive(tx *gorm.DB, value interface{}, id uint) error {
+	var count int64
+	if err := tx.Model(value).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errTrashParentExists
+	}
+	return nil
+}
+
+func restoreRows(tx *gorm.DB, value interface{}, query string, args ...interface{}) error {
+	return tx.Unscoped().Model(value).Where(query, args...).Update("deleted_at", nil).Error
+}
+
+func restoreTrashItem(tx *gorm.DB, itemType string, id uint) error {
+	switch itemType {
+	case trashTypeProject:
+		var project model.Project
+		if err := findTrashed(tx, &project, id); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.Project{}, "id = ?", id)
+
+	case trashTypeMilestone:
+		var milestone model.Milestone
+		if err := findTrashed(tx, &milestone, id); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.Project{}, milestone.ProjectID); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.Milestone{}, "id = ?", id)
+
+	case trashTypeTestCase:
+		var testCase model.TestCase
+		if err := findTrashed(tx, &testCase, id); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.Project{}, testCase.ProjectID); err != nil {
+			return err
+		}
+		if testCase.TestSuiteID != nil {
+			if err := requireAlive(tx, &model.TestSuite{}, *testCase.TestSuiteID); err != nil {
+				return err
+			}
+		}
+		deletedAt := testCase.DeletedAt.Time
+		if err := restoreRows(tx, &model.TestCase{}, "id = ?", id); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.TestRunCase{}, "test_case_id = ? AND deleted_at = ?", id, deletedAt)
+
+	case trashTypeTestSuite:
+		var testSuite model.TestSuite
+		if err := findTrashed(tx, &testSuite, id); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.Project{}, testSuite.ProjectID); err != nil {
+			return err
+		}
+		if testSuite.ParentID != nil {
+			if err := requireAlive(tx, &model.TestSuite{}, *testSuite.ParentID); err != nil {
+				return err
+			}
+		}
+		deletedAt := testSuite.DeletedAt.Time
+
+		var projectTestSuites []model.TestSuite
+		if err := tx.Unscoped().Where("project_id = ?", testSuite.ProjectID).Find(&projectTestSuites).Error; err != nil {
+			return err
+		}
+		testSuiteIDs := collectTestSuiteSubtree(testSuite.ID, groupTestSuitesByParent(projectTestSuites))
+
+		var testCaseIDs []uint
+		if err := tx.Unscoped().Model(&model.TestCase{}).
+			Where("test_suite_id IN ? AND deleted_at = ?", testSuiteIDs, deletedAt).
+			Pluck("id", &testCaseIDs).Error; err != nil {
+			return err
+		}
+		if err := restoreRows(tx, &model.TestSuite{}, "id IN ? AND deleted_at = ?", testSuiteIDs, deletedAt); err != nil {
+			return err
+		}
+		if len(testCaseIDs) == 0 {
+			return nil
+		}
+		if err := restoreRows(tx, &model.TestCase{}, "id IN ?", testCaseIDs); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.TestRunCase{}, "test_case_id IN ? AND deleted_at = ?", testCaseIDs, deletedAt)
+
+	case trashTypeTestRun:
+		var testRun model.TestRun
+		if err := findTrashed(tx, &testRun, id); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.Project{}, testRun.ProjectID); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.TestPlan{}, testRun.TestPlanID); err != nil {
+			return err
+		}
+		deletedAt := testRun.DeletedAt.Time
+		if err := restoreRows(tx, &model.TestRun{}, "id = ?", id); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.TestRunCase{}, "test_run_id = ? AND deleted_at = ?", id, deletedAt)
+
+	case trashTypeTestPlan:
+		var testPlan model.TestPlan
+		if err := findTrashed(tx, &testPlan, id); err != nil {
+			return err
+		}
+		if err := requireAlive(tx, &model.Project{}, testPlan.ProjectID); err != nil {
+			return err
+		}
+		deletedAt := testPlan.DeletedAt.Time
+
+		var testRunIDs []uint
+		if err := tx.Unscoped().Model(&model.TestRun{}).
+			Where("test_plan_id = ? AND deleted_at = ?", id, deletedAt).
+			Pluck("id", &testRunIDs).Error; err != nil {
+			return err
+		}
+		if err := restoreRows(tx, &model.TestPlan{}, "id = ?", id); err != nil {
+			return err
+		}
+		if len(testRunIDs) == 0 {
+			return nil
+		}
+		if err := restoreRows(tx, &model.TestRun{}, "id IN ?", testRunIDs); err != nil {
+			return err
+		}
+		return restoreRows(tx, &model.TestRunCase{}, "test_run_id IN ? AND deleted_at = ?", testRunIDs, deletedAt)
+	}
+
+	return errTrashInvalidType
+}
+
+func purgeTrashItem(tx *gorm.DB, itemType string, id uint) error {
+	purges := map[string]struct {
+		value interface{}
+		purge func(*gorm.DB, []uint) error
+	}{
+		trashTypeProject:   {&model.Project{}, purgeProjects},
+		trashTypeTestSuite: {&model.TestSuite{}, purgeTestSuites},
+		trashTypeTestCase:  {&model.TestCase{}, purgeTestCases},
+		trashTypeTestRun:   {&model.TestRun{}, purgeTestRuns},
+		trashTypeTestPlan:  {&model.TestPlan{}, purgeTestPlans},
+		trashTypeMilestone: {&model.Milestone{}, purgeMilestones},
+	}
+	p, ok := purges[itemType]
+	if !ok {
+		return errTrashInvalidType
+	}
+
+	// ゴミ箱にあるものだけを物理削除できる
+	if err := findTrashed(tx, p.value, id); err != nil {
+		return err
+	}
+	return p.purge(tx, []uint{id})
+}
+
+func purgeTestRunCases(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Unscoped().Where("test_run_case_id IN ?", ids).Delete(&model.Comment{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.TestRunCase{}).Error
+}
+
+func purgeTestCases(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var testRunCaseIDs []uint
+	if err := tx.Unscoped().Model(&model.TestRunCase{}).Where("test_case_id IN ?", ids).Pluck("id", &testRunCaseIDs).Error; err != nil {
+		return err
+	}
+	if err := purgeTestRunCases(tx, testRunCaseIDs); err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseCustomField{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseParameter{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseStep{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseReviewer{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("test_case_id IN ?", ids).Delete(&model.TestCaseReviewComment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("test_case_id IN ?", ids).Delete(&model.RequirementTestCase{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("test_case_id IN ? OR prerequisite_id IN ?", ids, ids).Delete(&model.TestCaseDependency{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.TestCase{}).Error
+}
+
+func purgeTestSuites(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	// 子孫フォルダーを辿る（循環していても止まるように訪問済みを記録する）
+	visited := map[uint]bool{}
+	testSuiteIDs := []uint{}
+	for current := ids; len(current) > 0; {
+		next := []uint{}
+		for _, id := range current {
+			if !visited[id] {
+				visited[id] = true
+				testSuiteIDs = append(testSuiteIDs, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+		current = nil
+		if err := tx.Unscoped().Model(&model.TestSuite{}).Where("parent_id IN ?", next).Pluck("id", &current).Error; err != nil {
+			return err
+		}
+	}
+
+	var testCaseIDs []uint
+	if err := tx.Unscoped().Model(&model.TestCase{}).Where("test_suite_id IN ?", testSuiteIDs).Pluck("id", &testCaseIDs).Error; err != nil {
+		return err
+	}
+	if err := purgeTestCases(tx, testCaseIDs); err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", testSuiteIDs).Delete(&model.TestSuite{}).Error
+}
+
+func purgeTestRuns(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var testRunCaseIDs []uint
+	if err := tx.Unscoped().Model(&model.TestRunCase{}).Where("test_run_id IN ?", ids).Pluck("id", &testRunCaseIDs).Error; err != nil {
+		return err
+	}
+	if err := purgeTestRunCases(tx, testRunCaseIDs); err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.TestRun{}).Error
+}
+
+func purgeTestPlans(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var testRunIDs []uint
+	if err := tx.Unscoped().Model(&model.TestRun{}).Where("test_plan_id IN ?", ids).Pluck("id", &testRunIDs).Error; err != nil {
+		return err
+	}
+	if err := purgeTestRuns(tx, testRunIDs); err != nil {
+		return err
+	}
+	// 複製元のプランが無くなったスケジュールは削除し、最後に複製したプランの参照は外す
+	if err := tx.Unscoped().Where("test_plan_id IN ?", ids).Delete(&model.TestPlanSchedule{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&model.TestPlanSchedule{}).Where("last_test_plan_id IN ?", ids).Update("last_test_plan_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.TestPlan{}).Error
+}
+
+func purgeMilestones(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	// マイルストーンを参照しているテストケース・テストプラン・テストランは紐付けを外す
+	// （テストランはテストプランのマイルストーンで実施したものになる）
+	for _, value := range []interface{}{&model.TestCase{}, &model.TestPlan{}, &model.TestRun{}} {
+		if err := tx.Unscoped().Model(value).Where("milestone_id IN ?", ids).Update("milestone_id", nil).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.Milestone{}).Error
+}
+
+func purgeRequirements(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("requirement_id IN ?", ids).Delete(&model.RequirementTestCase{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.Requirement{}).Error
+}
+
+func purgeSharedSteps(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Unscoped().Where("shared_step_id IN ?", ids).Delete(&model.SharedStepItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.SharedStep{}).Error
+}
+
+func purgeProjects(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	children := []struct {
+		value interface{}
+		purge func(*gorm.DB, []uint) error
+	}{
+		{&model.TestPlan{}, purgeTestPlans},
+		{&model.TestRun{}, purgeTestRuns},
+		{&model.TestSuite{}, purgeTestSuites},
+		{&model.TestCase{}, purgeTestCases},
+		{&model.Milestone{}, purgeMilestones},
+		{&model.Requirement{}, purgeRequirements},
+		{&model.SharedStep{}, purgeSharedSteps},
+	}
+	for _, child := range children {
+		var childIDs []uint
+		if err := tx.Unscoped().Model(child.value).Where("project_id IN ?", ids).Pluck("id", &childIDs).Error; err != nil {
+			return err
+		}
+		if err := child.purge(tx, childIDs); err != nil {
+			return err
+		}
+	}
+	if err := tx.Unscoped().Where("project_id IN ?", ids).Delete(&model.TestPlanSchedule{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("project_id IN ?", ids).Delete(&model.TestCaseTemplate{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("project_id IN ?", ids).Delete(&model.ImportProfile{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&model.Project{}).Error
+}
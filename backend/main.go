@@ -40,6 +40,8 @@ func main() {
 	if err != nil {
 		log.Fatalf("データベース接続に失敗しました: %v", err)
 	}
+	// レビューの状態が追加される前からあるテストケースは、列の既定値（Draft）ではなく承認済みとして扱う
+	approveExistingTestCases := db.Migrator().HasTable(&model.TestCase{}) && !db.Migrator().HasColumn(&model.TestCase{}, "State")
 	db.AutoMigrate(
 		&model.Status{},
 		&model.Permission{},
@@ -50,10 +52,30 @@ func main() {
 		&model.Project{},
 		&model.TestSuite{},
 		&model.TestCase{},
+		&model.TestCaseTag{},
+		&model.TestCaseCustomField{},
+		&model.TestCaseStep{},
+		&model.TestCaseParameter{},
+		&model.TestCaseDependency{},
+		&model.SharedStep{},
+		&model.SharedStepItem{},
+		&model.TestCaseTemplate{},
+		&model.TestCaseReviewer{},
+		&model.TestCaseReviewComment{},
+		&model.ImportProfile{},
+		&model.Requirement{},
+		&model.RequirementTestCase{},
 		&model.TestPlan{},
+		&model.TestPlanSchedule{},
 		&model.TestRun{},
 		&model.TestRunCase{},
-		&model.Comment{})
+		&model.Comment{},
+		&model.Attachment{})
+	if approveExistingTestCases {
+		if err := db.Unscoped().Model(&model.TestCase{}).Where("state = ?", "Draft").Update("state", "Approved").Error; err != nil {
+			log.Fatalf("既存のテストケースの状態の移行に失敗しました: %v", err)
+		}
+	}
 
 	host := os.Getenv("MAIL_HOST")
 	port, _ := strconv.Atoi(os.Getenv("MAIL_PORT"))
@@ -81,6 +103,21 @@ func main() {
 	projectHandler := handler.NewProjectHandler(db)
 	testPlanHandler := handler.NewTestPlanHandler(db)
 	milestoneHandler := handler.NewMilestoneHandler(db)
+	attachmentStorage := createAttachmentStorage()
+	trashHandler := handler.NewTrashHandler(db, attachmentStorage)
+	importHandler := handler.NewImportHandler(db)
+	requirementHandler := handler.NewRequirementHandler(db)
+	sharedStepHandler := handler.NewSharedStepHandler(db)
+	attachmentHandler := handler.NewAttachmentHandler(db, attachmentStorage)
+	searchIndex, err := handler.NewSearchIndex(os.Getenv("SEARCH_BACKEND"))
+	if err != nil {
+		log.Fatalf("検索の設定が正しくありません: %v", err)
+	}
+	if err := searchIndex.Prepare(db); err != nil {
+		log.Fatalf("検索の索引の作成に失敗しました: %v", err)
+	}
+	searchHandler := handler.NewSearchHandler(db, searchIndex)
+	testCaseTemplateHandler := handler.NewTestCaseTemplateHandler(db)
 
 	// ルータの初期化
 	r := router.NewRouter(
@@ -95,15 +132,54 @@ func main() {
 		projectHandler,
 		testPlanHandler,
 		milestoneHandler,
+		trashHandler,
+		importHandler,
+		requirementHandler,
+		sharedStepHandler,
+		attachmentHandler,
+		searchHandler,
+		testCaseTemplateHandler,
 	)
 
 	createInitialData(db)
 	createInitialUser(db, emailSender)
 
+	// 保持期間を過ぎたゴミ箱のデータを定期的に物理削除する
+	if retentionDays, _ := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); retentionDays > 0 {
+		go handler.RunTrashPurger(db, attachmentStorage, time.Duration(retentionDays)*24*time.Hour, time.Hour)
+	}
+
+	// スケジュールに従ってテストプランを複製する（複数のレプリカで動かしても一度だけ複製される）
+	scheduleInterval := time.Minute
+	if seconds, _ := strconv.Atoi(os.Getenv("TEST_PLAN_SCHEDULE_INTERVAL_SECONDS")); seconds > 0 {
+		scheduleInterval = time.Duration(seconds) * time.Second
+	}
+	go handler.RunTestPlanScheduler(db, emailSender, scheduleInterval)
+
 	// サーバを起動
 	r.Run(":8000")
 }
 
+// createAttachmentStorage は ATTACHMENT_STORAGE に合わせて添付ファイルの保存先を返す（既定はサーバのディレクトリ）
+func createAttachmentStorage() util.FileStorage {
+	if os.Getenv("ATTACHMENT_STORAGE") == "s3" {
+		pathStyle, _ := strconv.ParseBool(os.Getenv("ATTACHMENT_S3_PATH_STYLE"))
+		return &util.S3Storage{
+			Endpoint:        os.Getenv("ATTACHMENT_S3_ENDPOINT"),
+			Region:          os.Getenv("ATTACHMENT_S3_REGION"),
+			Bucket:          os.Getenv("ATTACHMENT_S3_BUCKET"),
+			AccessKeyID:     os.Getenv("ATTACHMENT_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("ATTACHMENT_S3_SECRET_ACCESS_KEY"),
+			PathStyle:       pathStyle,
+		}
+	}
+	dir := os.Getenv("ATTACHMENT_LOCAL_DIR")
+	if dir == "" {
+		dir = "./attachments"
+	}
+	return &util.LocalStorage{Dir: dir}
+}
+
 func createInitialUser(db *gorm.DB, sender util.EmailSender) {
 	initialUserEmail := os.Getenv("INITIAL_USER_EMAIL")
 	initialUserName := os.Getenv("INITIAL_USER_NAME")
@@ -216,4 +292,20 @@ func createInitialData(db *gorm.DB) {
 			db.Create(&rolePermission)
 		}
 	}
+
+	// 承認に必要な権限が無い場合（既存の環境や権限名を変更した場合）は作成して管理者に付与する
+	approvalPermission := handler.TestCaseApprovalPermission()
+	var approvalPermissionCount int64
+	db.Model(&model.Permission{}).Where("name = ?", approvalPermission).Count(&approvalPermissionCount)
+	if approvalPermissionCount == 0 {
+		permission := model.Permission{Name: approvalPermission, Description: "Approve test cases"}
+		if err := db.Create(&permission).Error; err != nil {
+			log.Fatalf("承認の権限の作成に失敗しました: %v", err)
+		}
+		var role model.Role
+		if err := db.Where("name = ?", "Administrator").First(&role).Error; err != nil {
+			log.Fatalf("ロールの取得に失敗しました: %v", err)
+		}
+		db.Create(&model.RolePermission{RoleID: role.ID, PermissionID: permission.ID})
+	}
 }
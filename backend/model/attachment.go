@@ -0,0 +1,17 @@
+package model
+
+import "gorm.io/gorm"
+
+// Attachment はテストケース、テストランのケース、コメントに添付したファイル。ファイル本体は StorageKey で保存先に置く
+type Attachment struct {
+	gorm.Model
+	ParentType  string `json:"parent_type" gorm:"size:50;index:idx_attachments_parent"` // test_case、test_run_case、comment
+	ParentID    uint   `json:"parent_id" gorm:"index:idx_attachments_parent"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum" gorm:"size:64"` // SHA-256（16進数）
+	StorageKey  string `json:"-"`
+	CreatedByID uint   `json:"created_by_id"`
+	CreatedBy   User   `json:"created_by" gorm:"foreignKey:CreatedByID"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "gorm.io/gorm"
+
+// ImportProfile はスプレッドシートの列とテストケースの項目の対応付けをプロジェクトごとに保存する
+type ImportProfile struct {
+	gorm.Model
+	ProjectID uint   `json:"project_id" gorm:"index"`
+	Name      string `json:"name"`
+	Mapping   string `json:"mapping" gorm:"type:text"` // JSON
+}
@@ -0,0 +1,20 @@
+package model
+
+import "gorm.io/gorm"
+
+// Requirement はテストケースで網羅すべき要件。Key はプロジェクト内で一意
+type Requirement struct {
+	gorm.Model
+	ProjectID   uint       `json:"project_id" gorm:"index"`
+	Key         string     `json:"key" gorm:"column:requirement_key;size:100;index"` // key は MySQL の予約語のため列名を変える
+	Title       string     `json:"title"`
+	Description string     `json:"description" gorm:"type:text"`
+	URL         string     `json:"url"` // 外部のチケットや仕様書へのリンク
+	TestCases   []TestCase `json:"test_cases" gorm:"many2many:requirement_test_cases"`
+}
+
+// RequirementTestCase は要件とテストケースの対応
+type RequirementTestCase struct {
+	RequirementID uint `gorm:"primaryKey"`
+	TestCaseID    uint `gorm:"primaryKey;index"`
+}
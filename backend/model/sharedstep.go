@@ -0,0 +1,24 @@
+package model
+
+import "gorm.io/gorm"
+
+// SharedStep はプロジェクト内の複数のテストケースから参照できる共通の手順
+type SharedStep struct {
+	gorm.Model
+	ProjectID   uint             `json:"project_id" gorm:"index"`
+	Title       string           `json:"title"`
+	Description string           `json:"description" gorm:"type:text"`
+	Steps       []SharedStepItem `json:"-" gorm:"foreignKey:SharedStepID"`
+	CreatedByID uint             `json:"created_by_id"`
+	UpdatedByID uint             `json:"updated_by_id"`
+	CreatedBy   User             `gorm:"foreignKey:CreatedByID"`
+	UpdatedBy   User             `gorm:"foreignKey:UpdatedByID"`
+}
+
+type SharedStepItem struct {
+	gorm.Model
+	SharedStepID   uint   `json:"shared_step_id" gorm:"index"`
+	OrderIndex     int    `json:"order_index"`
+	Action         string `json:"action"`
+	ExpectedResult string `json:"expected_result"`
+}
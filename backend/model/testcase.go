@@ -10,6 +10,7 @@ type TestCase struct {
 	Title       string    `json:"title"`
 	Content     string    `json:"content"`
 	OrderIndex  int       `json:"order_index"`
+	State       string    `json:"state" gorm:"default:Draft;index"`
 	TestSuite   TestSuite `gorm:"foreignKey:TestSuiteID;pointer"`
 	Project     Project   `gorm:"foreignKey:ProjectID"`
 	Milestone   Milestone `gorm:"foreignKey:MilestoneID"`
@@ -19,4 +20,12 @@ type TestCase struct {
 
 	CreatedBy User `gorm:"foreignKey:CreatedByID"`
 	UpdatedBy User `gorm:"foreignKey:UpdatedByID"`
+
+	Tags         []TestCaseTag         `json:"-" gorm:"foreignKey:TestCaseID"`
+	CustomFields []TestCaseCustomField `json:"-" gorm:"foreignKey:TestCaseID"`
+	Steps        []TestCaseStep        `json:"-" gorm:"foreignKey:TestCaseID"`
+	Parameter    *TestCaseParameter    `json:"-" gorm:"foreignKey:TestCaseID"`
+
+	// TemplateID は作成時に使ったテンプレート
+	TemplateID *uint `json:"template_id" gorm:"index"`
 }
@@ -0,0 +1,10 @@
+package model
+
+import "gorm.io/gorm"
+
+type TestCaseCustomField struct {
+	gorm.Model
+	TestCaseID uint   `json:"test_case_id" gorm:"index"`
+	Name       string `json:"name" gorm:"index"`
+	Value      string `json:"value"`
+}
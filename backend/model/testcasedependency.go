@@ -0,0 +1,7 @@
+package model
+
+// TestCaseDependency はテストケースの前提条件。TestCaseID のテストケースは PrerequisiteID のテストケースが成功してから実施する
+type TestCaseDependency struct {
+	TestCaseID     uint `gorm:"primaryKey"`
+	PrerequisiteID uint `gorm:"primaryKey;index"`
+}
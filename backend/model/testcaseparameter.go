@@ -0,0 +1,11 @@
+package model
+
+import "gorm.io/gorm"
+
+// TestCaseParameter はテストケースのパラメータ表。テストランには行ごとに別のケースとして追加される
+type TestCaseParameter struct {
+	gorm.Model
+	TestCaseID uint   `json:"test_case_id" gorm:"index"`
+	Names      string `json:"names" gorm:"type:text"` // JSON
+	Rows       string `json:"rows" gorm:"type:text"`  // JSON
+}
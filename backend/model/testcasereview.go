@@ -0,0 +1,22 @@
+package model
+
+import "gorm.io/gorm"
+
+// TestCaseReviewer はテストケースのレビュー担当者と判定（空は未判定、Approved、Rejected）
+type TestCaseReviewer struct {
+	gorm.Model
+	TestCaseID uint   `json:"test_case_id" gorm:"index"`
+	UserID     uint   `json:"user_id"`
+	Decision   string `json:"decision"`
+	User       User   `gorm:"foreignKey:UserID"`
+}
+
+// TestCaseReviewComment はレビューのコメントと、状態を変えた操作の履歴
+type TestCaseReviewComment struct {
+	gorm.Model
+	TestCaseID uint   `json:"test_case_id" gorm:"index"`
+	UserID     uint   `json:"user_id"`
+	Action     string `json:"action"`
+	Content    string `json:"content" gorm:"type:text"`
+	User       User   `gorm:"foreignKey:UserID"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "gorm.io/gorm"
+
+type TestCaseStep struct {
+	gorm.Model
+	TestCaseID     uint   `json:"test_case_id" gorm:"index"`
+	OrderIndex     int    `json:"order_index"`
+	Action         string `json:"action"`
+	ExpectedResult string `json:"expected_result"`
+	// SharedStepID が設定されている手順は共有ステップの参照（手順と期待結果は空）
+	SharedStepID *uint `json:"shared_step_id" gorm:"index"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "gorm.io/gorm"
+
+type TestCaseTag struct {
+	gorm.Model
+	TestCaseID uint   `json:"test_case_id" gorm:"index"`
+	Name       string `json:"name" gorm:"index"`
+}
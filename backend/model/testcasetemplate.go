@@ -0,0 +1,18 @@
+package model
+
+import "gorm.io/gorm"
+
+// TestCaseTemplate はテストケースの種類（UI・API・探索的テストなど）ごとの既定の内容・手順・カスタムフィールド
+type TestCaseTemplate struct {
+	gorm.Model
+	ProjectID    uint   `json:"project_id" gorm:"index"`
+	Name         string `json:"name"`
+	Description  string `json:"description" gorm:"type:text"`
+	Content      string `json:"content" gorm:"type:text"`
+	Steps        string `json:"steps" gorm:"type:text"`         // JSON
+	CustomFields string `json:"custom_fields" gorm:"type:text"` // JSON
+	CreatedByID  uint   `json:"created_by_id"`
+	UpdatedByID  uint   `json:"updated_by_id"`
+	CreatedBy    User   `gorm:"foreignKey:CreatedByID"`
+	UpdatedBy    User   `gorm:"foreignKey:UpdatedByID"`
+}
@@ -17,4 +17,7 @@ type TestPlan struct {
 	UpdatedByID uint       `json:"updated_by_id"` // 更新者のユーザーID
 	CreatedBy   User       `json:"created_by" gorm:"foreignKey:CreatedByID"`
 	UpdatedBy   User       `json:"updated_by" gorm:"foreignKey:UpdatedByID"`
+
+	// MilestoneID はテストプランを実施するマイルストーン（リリース）
+	MilestoneID *uint `json:"milestone_id" gorm:"index"`
 }
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// TestPlanSchedule はテンプレートのテストプランを定期的に複製するスケジュール
+type TestPlanSchedule struct {
+	gorm.Model
+	ProjectID       uint       `json:"project_id" gorm:"index"`
+	TestPlanID      uint       `json:"test_plan_id"`                               // 複製元のテストプラン
+	Title           string     `json:"title"`                                      // 複製したプランのタイトル（{date} と {milestone} を置き換える）
+	TriggerType     string     `json:"trigger" gorm:"column:trigger_type;size:20"` // cron / milestone
+	Cron            string     `json:"cron"`                                       // 5項目の cron 式（サーバーのタイムゾーン）
+	DaysBeforeDue   int        `json:"days_before_due"`                            // マイルストーンの期日の何日前に複製するか
+	IncludeNewCases bool       `json:"include_new_cases"`
+	Active          bool       `json:"active"`
+	LastRunAt       *time.Time `json:"last_run_at"`
+	LastTestPlanID  *uint      `json:"last_test_plan_id"`
+	CreatedByID     uint       `json:"created_by_id"`
+	UpdatedByID     uint       `json:"updated_by_id"`
+	TestPlan        TestPlan   `gorm:"foreignKey:TestPlanID"`
+}
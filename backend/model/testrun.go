@@ -20,4 +20,8 @@ type TestRun struct {
 	UpdatedBy          User          `json:"updated_by" gorm:"foreignKey:UpdatedByID"`
 	Status             string        `json:"status"`
 	FinalizedTestCases string        `json:"finalized_test_cases"`
+	ApprovedOnly       bool          `json:"approved_only"`
+
+	// MilestoneID はテストプランと別のマイルストーンで実施する場合に設定する（未設定の場合はテストプランのマイルストーン）
+	MilestoneID *uint `json:"milestone_id" gorm:"index"`
 }
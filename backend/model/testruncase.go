@@ -13,4 +13,11 @@ type TestRunCase struct {
 	TestCase     TestCase  `json:"test_case" gorm:"foreignKey:TestCaseID"`
 	AssignedTo   *User     `json:"assigned_to" gorm:"foreignKey:AssignedToID"`
 	Status       *Status   `json:"status" gorm:"foreignKey:StatusID"`
+
+	// パラメータ表の行から作成したケースの場合は行の位置と、追加した時点の行の値（JSON）
+	ParameterIndex *int   `json:"parameter_index"`
+	Parameters     string `json:"parameters" gorm:"type:text"`
+
+	// 前提条件の失敗で自動的に Blocked にした場合はブロック前の状態（前提条件が成功したら戻す）
+	BlockedFromStatusID *uint `json:"blocked_from_status_id"`
 }
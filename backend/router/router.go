@@ -23,6 +23,13 @@ func NewRouter(
 	projectHandler *handler.ProjectHandler,
 	testPlanHandler *handler.TestPlanHandler,
 	milestoneHandler *handler.MilestoneHandler,
+	trashHandler *handler.TrashHandler,
+	importHandler *handler.ImportHandler,
+	requirementHandler *handler.RequirementHandler,
+	sharedStepHandler *handler.SharedStepHandler,
+	attachmentHandler *handler.AttachmentHandler,
+	searchHandler *handler.SearchHandler,
+	testCaseTemplateHandler *handler.TestCaseTemplateHandler,
 ) *gin.Engine {
 	r := gin.Default()
 
@@ -47,6 +54,9 @@ func NewRouter(
 		c.Next()
 	})
 
+	// テストケースの承認・差し戻し・廃止に必要な権限
+	approvalPermission := handler.TestCaseApprovalPermission()
+
 	r.GET("/health", healthHandler.GetHealth)
 	r.POST("/login", authHandler.PostLogin)
 
@@ -70,6 +80,7 @@ func NewRouter(
 		protected.DELETE("/projects/:id", checkPermission("edit", db), projectHandler.DeleteProject)
 
 		protected.GET("/:project_code/cases", testCaseHandler.GetTestCases)
+		protected.GET("/:project_code/cases/children", testCaseHandler.GetTestCaseChildren)
 		protected.GET("/cases/:id", testCaseHandler.GetTestCase)
 		protected.POST("/cases", checkPermission("edit", db), testCaseHandler.PostTestCase)
 		protected.PUT("/cases/:id", checkPermission("edit", db), testCaseHandler.PutTestCase)
@@ -80,6 +91,55 @@ func NewRouter(
 		protected.PUT("/:project_code/cases/bulk", checkPermission("edit", db), testCaseHandler.PutTestCaseBulk)
 		protected.PUT("/:project_code/suites/bulk", checkPermission("edit", db), testCaseHandler.PutTestSuiteBulk)
 		protected.POST("/:project_code/cases/bulk", checkPermission("edit", db), testCaseHandler.PostTestCasesBulk)
+		protected.POST("/:project_code/cases/import/preview", checkPermission("edit", db), importHandler.PreviewTestCaseImport)
+		protected.POST("/:project_code/cases/import", checkPermission("edit", db), importHandler.PostTestCaseImport)
+		protected.POST("/:project_code/cases/import/testrail", checkPermission("edit", db), importHandler.PostTestRailImport)
+		protected.POST("/:project_code/cases/import/gherkin", checkPermission("edit", db), importHandler.PostGherkinImport)
+		protected.GET("/:project_code/cases/export", testCaseHandler.ExportTestCases)
+		protected.GET("/:project_code/cases/export/gherkin", testCaseHandler.ExportGherkin)
+		protected.POST("/:project_code/cases/copy", checkPermission("edit", db), testCaseHandler.CopyTestCases)
+		protected.POST("/:project_code/cases/move", checkPermission("edit", db), testCaseHandler.MoveTestCases)
+		protected.GET("/:project_code/cases/duplicates", testCaseHandler.GetTestCaseDuplicates)
+		protected.POST("/:project_code/cases/merge", checkPermission("edit", db), testCaseHandler.MergeTestCases)
+		protected.GET("/cases/:id/review", testCaseHandler.GetTestCaseReview)
+		protected.POST("/cases/:id/review", checkPermission("edit", db), testCaseHandler.RequestTestCaseReview)
+		protected.POST("/cases/:id/review/comments", testCaseHandler.PostTestCaseReviewComment)
+		protected.POST("/cases/:id/approve", checkPermission(approvalPermission, db), testCaseHandler.ApproveTestCase)
+		protected.POST("/cases/:id/reject", checkPermission(approvalPermission, db), testCaseHandler.RejectTestCase)
+		protected.POST("/cases/:id/deprecate", checkPermission(approvalPermission, db), testCaseHandler.DeprecateTestCase)
+		protected.GET("/cases/:id/dependencies", testCaseHandler.GetTestCaseDependencies)
+		protected.PUT("/cases/:id/dependencies", checkPermission("edit", db), testCaseHandler.PutTestCaseDependencies)
+		protected.GET("/:project_code/import/profiles", importHandler.GetImportProfiles)
+		protected.POST("/import/profiles", checkPermission("edit", db), importHandler.PostImportProfile)
+		protected.PUT("/import/profiles/:id", checkPermission("edit", db), importHandler.PutImportProfile)
+		protected.DELETE("/import/profiles/:id", checkPermission("edit", db), importHandler.DeleteImportProfile)
+		protected.GET("/:project_code/requirements", requirementHandler.GetRequirements)
+		protected.GET("/:project_code/requirements/traceability", requirementHandler.GetTraceability)
+		protected.POST("/:project_code/requirements/import", checkPermission("edit", db), requirementHandler.ImportRequirements)
+		protected.POST("/requirements", checkPermission("edit", db), requirementHandler.PostRequirement)
+		protected.PUT("/requirements/:id", checkPermission("edit", db), requirementHandler.PutRequirement)
+		protected.DELETE("/requirements/:id", checkPermission("edit", db), requirementHandler.DeleteRequirement)
+		protected.GET("/:project_code/shared-steps", sharedStepHandler.GetSharedSteps)
+		protected.GET("/shared-steps/:id", sharedStepHandler.GetSharedStep)
+		protected.GET("/shared-steps/:id/usages", sharedStepHandler.GetSharedStepUsages)
+		protected.POST("/shared-steps", checkPermission("edit", db), sharedStepHandler.PostSharedStep)
+		protected.PUT("/shared-steps/:id", checkPermission("edit", db), sharedStepHandler.PutSharedStep)
+		protected.DELETE("/shared-steps/:id", checkPermission("edit", db), sharedStepHandler.DeleteSharedStep)
+		protected.GET("/:project_code/templates", testCaseTemplateHandler.GetTestCaseTemplates)
+		protected.GET("/templates/:id", testCaseTemplateHandler.GetTestCaseTemplate)
+		protected.POST("/templates", checkPermission("edit", db), testCaseTemplateHandler.PostTestCaseTemplate)
+		protected.PUT("/templates/:id", checkPermission("edit", db), testCaseTemplateHandler.PutTestCaseTemplate)
+		protected.DELETE("/templates/:id", checkPermission("edit", db), testCaseTemplateHandler.DeleteTestCaseTemplate)
+		protected.GET("/cases/:id/attachments", attachmentHandler.GetTestCaseAttachments)
+		protected.POST("/cases/:id/attachments", checkPermission("edit", db), attachmentHandler.PostTestCaseAttachment)
+		protected.GET("/runs/cases/:id/attachments", attachmentHandler.GetTestRunCaseAttachments)
+		protected.POST("/runs/cases/:id/attachments", attachmentHandler.PostTestRunCaseAttachment)
+		protected.GET("/runs/cases/comments/:id/attachments", attachmentHandler.GetCommentAttachments)
+		protected.POST("/runs/cases/comments/:id/attachments", attachmentHandler.PostCommentAttachment)
+		protected.GET("/attachments/:id/download", attachmentHandler.DownloadAttachment)
+		protected.DELETE("/attachments/:id", checkPermission("edit", db), attachmentHandler.DeleteAttachment)
+		protected.GET("/search", searchHandler.Search)
+		protected.GET("/:project_code/search", searchHandler.SearchProject)
 
 		protected.GET("/:project_code/:test_plan_id/runs", testRunHandler.GetTestRuns)
 		protected.GET("/runs/:id", testRunHandler.GetTestRunCases)
@@ -99,6 +159,11 @@ func NewRouter(
 		protected.GET("/plans/:id", testPlanHandler.GetTestPlan)
 		protected.POST("/plans", checkPermission("edit", db), testPlanHandler.PostTestPlan)
 		protected.PUT("/plans/:id", testPlanHandler.PutTestPlan)
+		protected.POST("/plans/:id/clone", checkPermission("edit", db), testPlanHandler.CloneTestPlan)
+		protected.GET("/:project_code/plans/schedules", testPlanHandler.GetTestPlanSchedules)
+		protected.POST("/plans/schedules", checkPermission("edit", db), testPlanHandler.PostTestPlanSchedule)
+		protected.PUT("/plans/schedules/:id", checkPermission("edit", db), testPlanHandler.PutTestPlanSchedule)
+		protected.DELETE("/plans/schedules/:id", checkPermission("edit", db), testPlanHandler.DeleteTestPlanSchedule)
 		protected.DELETE("/plans/:id", checkPermission("edit", db), testPlanHandler.DeletePlan)
 
 		protected.GET("/:project_code/milestones", milestoneHandler.GetMilestones)
@@ -106,6 +171,11 @@ func NewRouter(
 		protected.POST("/milestones", checkPermission("edit", db), milestoneHandler.PostMilestone)
 		protected.PUT("/milestones/:id", checkPermission("edit", db), milestoneHandler.PutMilestone)
 		protected.DELETE("/milestones/:id", checkPermission("edit", db), milestoneHandler.DeleteMilestone)
+
+		protected.GET("/:project_code/trash", trashHandler.GetTrash)
+		protected.GET("/trash/projects", checkPermission("admin", db), trashHandler.GetTrashProjects)
+		protected.POST("/trash/:type/:id/restore", checkPermission("edit", db), trashHandler.RestoreTrashItem)
+		protected.DELETE("/trash/:type/:id", checkPermission("admin", db), trashHandler.PurgeTrashItem)
 	}
 
 	return r
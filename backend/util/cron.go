@@ -0,0 +1,150 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule は「分 時 日 月 曜日」の5項目の cron 式を表す
+type CronSchedule struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+	// 日と曜日の両方を指定した場合は、どちらかに一致する日に実行する（cron と同じ）
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseCron は cron 式を読み込む。各項目は *、数値、範囲（1-5）、間隔（*/15）と、それらのカンマ区切りに対応する。
+// 曜日は 0（日曜）から 6 で、7 も日曜として扱う
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have 5 fields")
+	}
+
+	// 「*/2」のように * で始まる項目も日・曜日を限定しないものとして扱う（cron と同じ）
+	s := &CronSchedule{
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*") || fields[2] == "?",
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*") || fields[4] == "?",
+	}
+	if err := parseCronField(fields[0], 0, 59, s.minutes[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, s.hours[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, s.daysOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of month: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, s.months[:]); err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	daysOfWeek := make([]bool, 8)
+	if err := parseCronField(fields[4], 0, 7, daysOfWeek); err != nil {
+		return nil, fmt.Errorf("invalid day of week: %w", err)
+	}
+	copy(s.daysOfWeek[:], daysOfWeek[:7])
+	s.daysOfWeek[0] = s.daysOfWeek[0] || daysOfWeek[7]
+	return s, nil
+}
+
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return fmt.Errorf("invalid value %q", part)
+			}
+			start, end = value, value
+			// 「5/10」は 5 から最大値まで 10 ごと
+			if strings.Contains(part, "/") {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return fmt.Errorf("value out of range %q", part)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return nil
+}
+
+// Next は after より後で最初に一致する時刻（秒は 0）を返す。5年以内に無い場合はゼロ値を返す
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth[t.Day()]
+	dayOfWeek := s.daysOfWeek[t.Weekday()]
+	switch {
+	case s.anyDayOfMonth && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMonth:
+		return dayOfWeek
+	case s.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
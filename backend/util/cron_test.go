@@ -0,0 +1,61 @@
+package util_test
+
+import (
+	"backend/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2024-01-03 は水曜日
+	after := time.Date(2024, 1, 3, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2024, 1, 3, 10, 31, 0, 0, time.UTC)},
+		{"hourly macro", "@hourly", time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC)},
+		{"daily macro", "@daily", time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"weekly macro", "@weekly", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{"monthly macro", "@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"macro is case insensitive", "@Daily", time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"minute step", "*/15 * * * *", time.Date(2024, 1, 3, 10, 45, 0, 0, time.UTC)},
+		{"step from value", "5/20 * * * *", time.Date(2024, 1, 3, 10, 45, 0, 0, time.UTC)},
+		{"range with step", "0 9-17/4 * * *", time.Date(2024, 1, 3, 13, 0, 0, 0, time.UTC)},
+		{"list", "0 8,12,18 * * *", time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)},
+		{"7 is sunday", "0 9 * * 7", time.Date(2024, 1, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 is sunday", "0 9 * * 0", time.Date(2024, 1, 7, 9, 0, 0, 0, time.UTC)},
+		{"weekday range", "0 9 * * 1-5", time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC)},
+		{"question mark day of month", "0 9 ? * 5", time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC)},
+		// 日と曜日の両方を指定した場合はどちらかに一致する日
+		{"day of month or day of week", "0 9 10 * 5", time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC)},
+		{"day of week or day of month", "0 9 4 * 1", time.Date(2024, 1, 4, 9, 0, 0, 0, time.UTC)},
+		// * で始まる間隔の指定は限定しないものとして扱い、もう一方の項目だけで判定する
+		{"day of month step with day of week", "0 9 */2 * 1", time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"day of week step with day of month", "0 9 15 * */2", time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)},
+		{"month", "0 0 1 3 *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never within 5 years", "0 0 31 2 *", time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := util.ParseCron(tc.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, schedule.Next(after))
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := util.ParseCron(expr)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package util
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrFileNotFound は保存先にファイルが無い場合のエラー
+var ErrFileNotFound = errors.New("file not found")
+
+// FileStorage は添付ファイルの保存先。key は保存先の中で一意なパス（"/" 区切り）
+type FileStorage interface {
+	Save(key string, r io.Reader, size int64, contentType string) error
+	Open(key string) (io.ReadCloser, error)
+	// Delete は存在しないファイルを指定してもエラーにしない
+	Delete(key string) error
+}
@@ -18,12 +18,42 @@ type TestCaseMilestone struct {
 }
 
 type Milestone struct {
-	ID            uint   `json:"id"`
-	Title         string `json:"title"`
-	Description   string `json:"description"`
-	DueDate       string `json:"due_date"`
-	Status        string `json:"status"`
-	TestCaseCount int    `json:"test_case_count"`
+	ID            uint           `json:"id"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	DueDate       string         `json:"due_date"`
+	Status        string         `json:"status"`
+	TestCaseCount int            `json:"test_case_count"`
+	Stats         MilestoneStats `json:"stats"`
+}
+
+// MilestoneDetail はマイルストーンと、マイルストーンで実施したテストプランとテストランの結果
+type MilestoneDetail struct {
+	ID            uint           `json:"id"`
+	ProjectID     uint           `json:"project_id"`
+	Title         string         `json:"title"`
+	Description   string         `json:"description"`
+	DueDate       string         `json:"due_date"`
+	Status        string         `json:"status"`
+	TestCaseCount int            `json:"test_case_count"`
+	Stats         MilestoneStats `json:"stats"`
+	TestPlans     []TestPlan     `json:"test_plans"`
+	TestRuns      []TestRun      `json:"test_runs"`
+	Charts        []Chart        `json:"charts"`
+	Percentage    int            `json:"percentage"`
+}
+
+// MilestoneStats はマイルストーンに紐づくテストケースの最新の結果から見た進捗
+type MilestoneStats struct {
+	Results       []Chart `json:"results"` // 結果のあるテストケースのステータスごとの数
+	Tested        int     `json:"tested"`
+	Untested      int     `json:"untested"`
+	Passed        int     `json:"passed"`
+	PassRate      int     `json:"pass_rate"`      // 結果のあるテストケースのうち成功した割合（%）
+	Progress      int     `json:"progress"`       // 結果のあるテストケースの割合（%）
+	DaysRemaining int     `json:"days_remaining"` // 期日までの日数（過ぎた場合は負の値）
+	ForecastDate  *string `json:"forecast_date"`  // 完了の予測日（結果が無く予測できない場合は null）
+	AtRisk        bool    `json:"at_risk"`        // 完了の予測日が期日を過ぎる
 }
 
 type Chart struct {
@@ -37,17 +67,37 @@ type ProjectsResponseData struct {
 }
 
 type TestCase struct {
-	ID        uint               `json:"id"`
-	Title     string             `json:"title"`
-	Content   string             `json:"content"`
-	Milestone *TestCaseMilestone `json:"milestone"`
-	CreatedBy User               `json:"created_by"`
-	UpdatedBy User               `json:"updated_by"`
+	ID           uint                `json:"id"`
+	Title        string              `json:"title"`
+	Content      string              `json:"content"`
+	State        string              `json:"state"`
+	Milestone    *TestCaseMilestone  `json:"milestone"`
+	Tags         []string            `json:"tags"`
+	CustomFields map[string]string   `json:"custom_fields"`
+	Steps        []TestCaseStep      `json:"steps,omitempty"`
+	Parameters   *TestCaseParameters `json:"parameters,omitempty"`
+	TemplateID   *uint               `json:"template_id"`
+	CreatedBy    User                `json:"created_by"`
+	UpdatedBy    User                `json:"updated_by"`
+}
+
+// TestCaseParameters はテストケースのパラメータ表。各行の値は Names と同じ順に並ぶ
+type TestCaseParameters struct {
+	Names []string   `json:"names"`
+	Rows  [][]string `json:"rows"`
+}
+
+type TestCaseStep struct {
+	Action         string `json:"action"`
+	ExpectedResult string `json:"expected_result"`
+	// 共有ステップから展開した手順の場合は参照元の ID
+	SharedStepID *uint `json:"shared_step_id,omitempty"`
 }
 
 type JSONTestSuite struct {
 	ID         uint            `json:"id"`
 	Name       string          `json:"name"`
+	CaseCount  int             `json:"case_count"`
 	TestSuites []JSONTestSuite `json:"test_suites"`
 	TestCases  []TestCase      `json:"test_cases"`
 }
@@ -71,6 +121,22 @@ type TestCasesResponseData struct {
 	OnlyTestSuites []JSONOnlyTestSuite `json:"folders"`
 }
 
+type TestSuiteChildrenResponseData struct {
+	ProjectID   uint            `json:"project_id"`
+	TestSuiteID *uint           `json:"test_suite_id"`
+	TestSuites  []JSONTestSuite `json:"test_suites"`
+	TestCases   []TestCase      `json:"entities"`
+	NextOffset  *int            `json:"next_offset"`
+}
+
+type TestSuiteDeletionSummary struct {
+	DryRun       bool `json:"dry_run"`
+	KeepResults  bool `json:"keep_results"`
+	TestSuites   int  `json:"test_suites"`
+	TestCases    int  `json:"test_cases"`
+	TestRunCases int  `json:"test_run_cases"`
+}
+
 type TestRunCasesResponseData struct {
 	ProjectID      uint                    `json:"project_id"`
 	TestRunID      uint                    `json:"test_run_id"`
@@ -90,6 +156,20 @@ type TestRunCase struct {
 	Status     Status    `json:"status"`
 	AssignedTo *User     `json:"assigned_to"`
 	Comments   []Comment `json:"comments"`
+	// 共有ステップを展開したテスト手順（完了時の内容として確定データにも残る）
+	Steps []TestCaseStep `json:"steps"`
+	// パラメータ表の行から作成したケースの場合は行の位置と値（手順と内容には値を埋め込み済み）
+	ParameterIndex *int                   `json:"parameter_index,omitempty"`
+	Parameters     []TestRunCaseParameter `json:"parameters,omitempty"`
+	// レポートではパラメータ表の行ごとのケースを元のテストケースの下にまとめる
+	Iterations []TestRunCase `json:"iterations,omitempty"`
+	// 前提条件の失敗で自動的に Blocked にした場合はブロック前の状態
+	BlockedFromStatusID *uint `json:"blocked_from_status_id,omitempty"`
+}
+
+type TestRunCaseParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 type Status struct {
@@ -128,19 +208,24 @@ type LoginUser struct {
 type TestRun struct {
 	ID          uint    `json:"id"`
 	ProjectID   uint    `json:"project_id"`
+	TestPlanID  uint    `json:"test_plan_id"`
+	MilestoneID *uint   `json:"milestone_id"`
 	Title       string  `json:"title"`
 	Count       int     `json:"count"`
 	Status      string  `json:"status"`
 	StartedAt   *string `json:"started_at"`
 	CompletedAt *string `json:"completed_at"`
 	TestCaseIDs []uint  `json:"test_case_ids"`
-	CreatedBy   User    `json:"created_by"`
-	UpdatedBy   User    `json:"updated_by"`
+	// ApprovedOnly は承認済みのテストケースだけを追加できるランか
+	ApprovedOnly bool `json:"approved_only"`
+	CreatedBy    User `json:"created_by"`
+	UpdatedBy    User `json:"updated_by"`
 }
 
 type TestPlan struct {
 	ID          uint    `json:"id"`
 	ProjectID   uint    `json:"project_id"`
+	MilestoneID *uint   `json:"milestone_id"`
 	Title       string  `json:"title"`
 	Status      string  `json:"status"`
 	StartedAt   *string `json:"started_at"`
@@ -150,15 +235,45 @@ type TestPlan struct {
 }
 
 type TestPlanDetail struct {
-	ID         uint      `json:"id"`
-	ProjectID  uint      `json:"project_id"`
-	Title      string    `json:"title"`
-	Status     string    `json:"status"`
-	Charts     []Chart   `json:"charts"`
-	TestRuns   []TestRun `json:"test_runs"`
-	CreatedBy  User      `json:"created_by"`
-	UpdatedBy  User      `json:"updated_by"`
-	Percentage int       `json:"percentage"`
+	ID          uint      `json:"id"`
+	ProjectID   uint      `json:"project_id"`
+	MilestoneID *uint     `json:"milestone_id"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	Charts      []Chart   `json:"charts"`
+	TestRuns    []TestRun `json:"test_runs"`
+	CreatedBy   User      `json:"created_by"`
+	UpdatedBy   User      `json:"updated_by"`
+	Percentage  int       `json:"percentage"`
+}
+
+// TestPlanCloneResult は複製したテストプランと、元のテストランと複製したテストランの ID の対応
+type TestPlanCloneResult struct {
+	ID             uint            `json:"id"`
+	Title          string          `json:"title"`
+	TestRuns       []TransferredID `json:"test_runs"`
+	AddedTestCases int             `json:"added_test_cases"`
+}
+
+type TestPlanSchedule struct {
+	ID              uint    `json:"id"`
+	ProjectID       uint    `json:"project_id"`
+	TestPlanID      uint    `json:"test_plan_id"`
+	TestPlanTitle   string  `json:"test_plan_title"`
+	Title           string  `json:"title"`
+	Trigger         string  `json:"trigger"`
+	Cron            string  `json:"cron"`
+	DaysBeforeDue   int     `json:"days_before_due"`
+	IncludeNewCases bool    `json:"include_new_cases"`
+	Active          bool    `json:"active"`
+	LastRunAt       *string `json:"last_run_at"`
+	NextRunAt       *string `json:"next_run_at"`
+	LastTestPlanID  *uint   `json:"last_test_plan_id"`
+}
+
+type TestPlanSchedulesResponseData struct {
+	ProjectID uint               `json:"project_id"`
+	Schedules []TestPlanSchedule `json:"entities"`
 }
 
 type TestRunsResponseData struct {
@@ -223,3 +338,312 @@ type StatusesResponseData struct {
 type RolesResponseData struct {
 	Roles []Role `json:"entities"`
 }
+
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+type TrashResponseData struct {
+	ProjectID uint        `json:"project_id,omitempty"`
+	Items     []TrashItem `json:"entities"`
+}
+
+type TestCaseImportError struct {
+	File    string `json:"file,omitempty"`
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type TestCaseImportResult struct {
+	DryRun      bool                  `json:"dry_run"`
+	TestCases   int                   `json:"test_cases"`
+	TestSuites  int                   `json:"test_suites"`
+	TestCaseIDs []uint                `json:"test_case_ids"`
+	Errors      []TestCaseImportError `json:"errors"`
+}
+
+// ImportIDMapping は取り込み元の ID と作成したデータの ID の対応
+type ImportIDMapping struct {
+	SourceID string `json:"source_id"`
+	ID       uint   `json:"id"`
+}
+
+type TestRailImportResult struct {
+	TestCaseImportResult
+	TestSuiteMappings []ImportIDMapping `json:"test_suite_mappings"`
+	TestCaseMappings  []ImportIDMapping `json:"test_case_mappings"`
+}
+
+type GherkinImportResult struct {
+	TestCaseImportResult
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// TransferredID はコピー・移動元の ID と、コピー・移動後の ID の対応（移動では同じ ID になる）
+type TransferredID struct {
+	SourceID uint `json:"source_id"`
+	ID       uint `json:"id"`
+}
+
+type TestCaseTransferResult struct {
+	TestSuites []TransferredID `json:"test_suites"`
+	TestCases  []TransferredID `json:"test_cases"`
+	Milestones []TransferredID `json:"milestones"`
+}
+
+// ImportColumnMapping はスプレッドシートの列（ヘッダー名）とテストケースの項目の対応付け
+type ImportColumnMapping struct {
+	Title           string            `json:"title"`
+	TestSuitePath   string            `json:"test_suite_path"`
+	Content         string            `json:"content"`
+	Steps           string            `json:"steps"`
+	ExpectedResults string            `json:"expected_results"`
+	Milestone       string            `json:"milestone"`
+	Tags            string            `json:"tags"`
+	CustomFields    map[string]string `json:"custom_fields"`
+}
+
+type ImportProfile struct {
+	ID      uint                `json:"id"`
+	Name    string              `json:"name"`
+	Mapping ImportColumnMapping `json:"mapping"`
+}
+
+type ImportProfilesResponseData struct {
+	ProjectID uint            `json:"project_id"`
+	Profiles  []ImportProfile `json:"entities"`
+}
+
+type TestCaseImportPreviewRow struct {
+	Row           int               `json:"row"`
+	TestSuitePath string            `json:"test_suite_path"`
+	Title         string            `json:"title"`
+	Content       string            `json:"content"`
+	Milestone     string            `json:"milestone"`
+	Tags          []string          `json:"tags"`
+	CustomFields  map[string]string `json:"custom_fields"`
+	Steps         []TestCaseStep    `json:"steps"`
+}
+
+type TestCaseImportPreview struct {
+	Columns []string                   `json:"columns"`
+	Rows    []TestCaseImportPreviewRow `json:"rows"`
+	Result  TestCaseImportResult       `json:"result"`
+}
+
+type Requirement struct {
+	ID          uint   `json:"id"`
+	ProjectID   uint   `json:"project_id"`
+	Key         string `json:"key"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	TestCaseIDs []uint `json:"test_case_ids"`
+}
+
+type RequirementsResponseData struct {
+	ProjectID    uint          `json:"project_id"`
+	Requirements []Requirement `json:"entities"`
+}
+
+type RequirementImportResult struct {
+	DryRun       bool                  `json:"dry_run"`
+	Requirements int                   `json:"requirements"`
+	Created      int                   `json:"created"`
+	Updated      int                   `json:"updated"`
+	Errors       []TestCaseImportError `json:"errors"`
+}
+
+type TraceabilityTestCase struct {
+	ID        uint    `json:"id"`
+	Title     string  `json:"title"`
+	TestRunID *uint   `json:"test_run_id"` // 最新の結果のテストラン（結果が無い場合は null）
+	Status    *Status `json:"status"`
+}
+
+type TraceabilityRequirement struct {
+	ID        uint                   `json:"id"`
+	Key       string                 `json:"key"`
+	Title     string                 `json:"title"`
+	URL       string                 `json:"url"`
+	Coverage  string                 `json:"coverage"` // tested / untested / uncovered
+	TestCases []TraceabilityTestCase `json:"test_cases"`
+}
+
+type TraceabilitySummary struct {
+	Requirements int `json:"requirements"`
+	Tested       int `json:"tested"`
+	Untested     int `json:"untested"`
+	Uncovered    int `json:"uncovered"`
+	Percentage   int `json:"percentage"`
+}
+
+type TraceabilityMatrix struct {
+	ProjectID    uint                      `json:"project_id"`
+	TestPlanID   *uint                     `json:"test_plan_id"`
+	MilestoneID  *uint                     `json:"milestone_id"`
+	Summary      TraceabilitySummary       `json:"summary"`
+	Requirements []TraceabilityRequirement `json:"requirements"`
+}
+
+type TestCaseReviewer struct {
+	User     User   `json:"user"`
+	Decision string `json:"decision"`
+}
+
+type TestCaseReviewComment struct {
+	ID        uint   `json:"id"`
+	Action    string `json:"action"`
+	Content   string `json:"content"`
+	CreatedBy User   `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
+
+type TestCaseReview struct {
+	TestCaseID uint                    `json:"test_case_id"`
+	State      string                  `json:"state"`
+	Reviewers  []TestCaseReviewer      `json:"reviewers"`
+	Comments   []TestCaseReviewComment `json:"comments"`
+}
+
+type SharedStep struct {
+	ID          uint           `json:"id"`
+	ProjectID   uint           `json:"project_id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Steps       []TestCaseStep `json:"steps"`
+	UsageCount  int            `json:"usage_count"`
+	CreatedBy   User           `json:"created_by"`
+	UpdatedBy   User           `json:"updated_by"`
+}
+
+type SharedStepsResponseData struct {
+	ProjectID   uint         `json:"project_id"`
+	SharedSteps []SharedStep `json:"entities"`
+}
+
+type SharedStepUsage struct {
+	ID          uint   `json:"id"`
+	ProjectID   uint   `json:"project_id"`
+	TestSuiteID *uint  `json:"test_suite_id"`
+	Title       string `json:"title"`
+}
+
+type SharedStepUsagesResponseData struct {
+	SharedStepID uint              `json:"shared_step_id"`
+	TestCases    []SharedStepUsage `json:"test_cases"`
+}
+
+type TestCaseDependencyEntity struct {
+	ID          uint   `json:"id"`
+	TestSuiteID *uint  `json:"test_suite_id"`
+	Title       string `json:"title"`
+}
+
+type TestCaseDependencies struct {
+	TestCaseID    uint                       `json:"test_case_id"`
+	Prerequisites []TestCaseDependencyEntity `json:"prerequisites"`
+	// 直接の依存だけを返す。テストランで前提条件が失敗したときに Blocked になるのもこれらのケースだけ
+	Dependents []TestCaseDependencyEntity `json:"dependents"`
+}
+
+type Attachment struct {
+	ID          uint   `json:"id"`
+	ParentType  string `json:"parent_type"`
+	ParentID    uint   `json:"parent_id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	CreatedBy   User   `json:"created_by"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type AttachmentsResponseData struct {
+	ParentType  string       `json:"parent_type"`
+	ParentID    uint         `json:"parent_id"`
+	Attachments []Attachment `json:"entities"`
+}
+
+type SearchHit struct {
+	Type          string  `json:"type"`
+	ID            uint    `json:"id"`
+	ProjectID     uint    `json:"project_id"`
+	ProjectCode   string  `json:"project_code"`
+	Title         string  `json:"title"`
+	Snippet       string  `json:"snippet"`
+	Score         float64 `json:"score"`
+	TestSuiteID   *uint   `json:"test_suite_id,omitempty"`
+	TestPlanID    *uint   `json:"test_plan_id,omitempty"`
+	TestRunID     *uint   `json:"test_run_id,omitempty"`
+	TestCaseID    *uint   `json:"test_case_id,omitempty"`
+	TestRunCaseID *uint   `json:"test_run_case_id,omitempty"`
+}
+
+type SearchResponseData struct {
+	Query     string      `json:"query"`
+	ProjectID *uint       `json:"project_id"`
+	Hits      []SearchHit `json:"entities"`
+}
+
+type TestCaseDuplicateEntity struct {
+	ID          uint   `json:"id"`
+	TestSuiteID *uint  `json:"test_suite_id"`
+	Title       string `json:"title"`
+}
+
+type TestCaseDuplicatePair struct {
+	TestCaseID  uint    `json:"test_case_id"`
+	DuplicateID uint    `json:"duplicate_id"`
+	Similarity  float64 `json:"similarity"`
+}
+
+type TestCaseDuplicateCluster struct {
+	Similarity float64                   `json:"similarity"` // クラスター内で最も高い類似度
+	TestCases  []TestCaseDuplicateEntity `json:"test_cases"`
+	Pairs      []TestCaseDuplicatePair   `json:"pairs"`
+}
+
+type TestCaseDuplicatesResponseData struct {
+	ProjectID uint                       `json:"project_id"`
+	Threshold float64                    `json:"threshold"`
+	Clusters  []TestCaseDuplicateCluster `json:"entities"`
+}
+
+type TestCaseMergeResult struct {
+	TestCaseID          uint   `json:"test_case_id"`
+	MergedTestCaseIDs   []uint `json:"merged_test_case_ids"`
+	TestRunCases        int64  `json:"test_run_cases"`         // 付け替えたテストランのケースの件数
+	TrashedTestRunCases int64  `json:"trashed_test_run_cases"` // 同じランで重複したためゴミ箱へ移したケースの件数
+	Requirements        int    `json:"requirements"`           // 付け替えた要件の件数
+}
+
+// TestCaseTemplateField はテンプレートのカスタムフィールド。Options がある場合は値をその中から選ぶ
+type TestCaseTemplateField struct {
+	Name     string   `json:"name"`
+	Default  string   `json:"default"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+type TestCaseTemplate struct {
+	ID           uint                    `json:"id"`
+	ProjectID    uint                    `json:"project_id"`
+	Name         string                  `json:"name"`
+	Description  string                  `json:"description"`
+	Content      string                  `json:"content"`
+	Steps        []TestCaseStep          `json:"steps"`
+	CustomFields []TestCaseTemplateField `json:"custom_fields"`
+	CreatedBy    User                    `json:"created_by"`
+	UpdatedBy    User                    `json:"updated_by"`
+}
+
+type TestCaseTemplatesResponseData struct {
+	ProjectID uint               `json:"project_id"`
+	Templates []TestCaseTemplate `json:"entities"`
+}
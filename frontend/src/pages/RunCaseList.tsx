@@ -52,6 +52,13 @@ interface TestRunCase {
     created_by: User;
     updated_by: User;
     assigned_to: User | null;
+    parameters?: TestRunCaseParameter[];
+    iterations?: TestRunCase[];
+}
+
+interface TestRunCaseParameter {
+    name: string;
+    value: string;
 }
 
 interface TestSuite {
@@ -515,51 +522,68 @@ const RunCaseList: React.FC = () => {
         setSearchTerm(event.target.value.toLowerCase());
     };
 
+    // パラメータ表の行ごとのケースは、行の値を名前の代わりに表示する
+    const formatParameters = (parameters: TestRunCaseParameter[]) => {
+        return parameters.map(parameter => `${parameter.name}: ${parameter.value}`).join(', ');
+    };
+
+    const renderTestRunCaseRow = (testRunCase: TestRunCase, label: string, paddingLeft?: string) => (
+        <Tr key={testRunCase.id} cursor="pointer" _hover={{bg: "gray.100"}} onClick={() => handleTestCaseClick(testRunCase)}>
+            <Td fontSize="sm" paddingY="2" paddingLeft={paddingLeft} borderBottom="1px"
+                borderColor="gray.200">{label}</Td>
+            <Td width="30px" paddingX="0" paddingY="0" alignItems="center" justifyContent="center">
+                {testRunCase.assigned_to && (<Avatar size='xs' name={testRunCase.assigned_to.name}/>)}
+            </Td>
+            <Td width="120px" paddingY="0" paddingX="0">
+                <Flex justifyContent="flex-end" alignItems="center">
+                    <Menu>
+                        <MenuButton as={Button} rightIcon={<ChevronDownIcon/>}
+                                    size="xs"
+                                    bg={`${testRunCase.status.color}.200`} width="100%">
+                            {testRunCase.status.name}
+                        </MenuButton>
+                        <MenuList>
+                            {renderStatusOptions()}
+                        </MenuList>
+                    </Menu>
+                    <Box mx={2}>
+                        {selectedTestCase && selectedTestCase.id === testRunCase.id ?
+                            <IconButton
+                                aria-label={t('close_test_case')}
+                                icon={<ChevronLeftIcon/>}
+                                variant="ghost"
+                                size="sm"
+                                onClick={() => handleTestCaseClick(null)}
+                            /> :
+                            <IconButton
+                                aria-label={t('open_test_case')}
+                                icon={<ChevronRightIcon/>}
+                                variant="ghost"
+                                size="sm"
+                                onClick={() => handleTestCaseClick(testRunCase)}
+                            />}
+                    </Box>
+                </Flex>
+            </Td>
+        </Tr>
+    );
+
     const renderTestCases = (testRunCases: TestRunCase[]) => {
         const filteredTestCases = filterTestCases(testRunCases);
         return (
             <Table variant="simple">
                 <Tbody>
-                    {filteredTestCases.map(testRunCase => (
-                        <Tr cursor="pointer" _hover={{bg: "gray.100"}} onClick={() => handleTestCaseClick(testRunCase)}>
-                            <Td fontSize="sm" paddingY="2" borderBottom="1px"
-                                borderColor="gray.200">{testRunCase.title}</Td>
-                            <Td width="30px" paddingX="0" paddingY="0" alignItems="center" justifyContent="center">
-                                {testRunCase.assigned_to && (<Avatar size='xs' name={testRunCase.assigned_to.name}/>)}
-                            </Td>
-                            <Td width="120px" paddingY="0" paddingX="0">
-                                <Flex justifyContent="flex-end" alignItems="center">
-                                    <Menu>
-                                        <MenuButton as={Button} rightIcon={<ChevronDownIcon/>}
-                                                    size="xs"
-                                                    bg={`${testRunCase.status.color}.200`} width="100%">
-                                            {testRunCase.status.name}
-                                        </MenuButton>
-                                        <MenuList>
-                                            {renderStatusOptions()}
-                                        </MenuList>
-                                    </Menu>
-                                    <Box mx={2}>
-                                        {selectedTestCase && selectedTestCase.id === testRunCase.id ?
-                                            <IconButton
-                                                aria-label={t('close_test_case')}
-                                                icon={<ChevronLeftIcon/>}
-                                                variant="ghost"
-                                                size="sm"
-                                                onClick={() => handleTestCaseClick(null)}
-                                            /> :
-                                            <IconButton
-                                                aria-label={t('open_test_case')}
-                                                icon={<ChevronRightIcon/>}
-                                                variant="ghost"
-                                                size="sm"
-                                                onClick={() => handleTestCaseClick(testRunCase)}
-                                            />}
-                                    </Box>
-                                </Flex>
-                            </Td>
-                        </Tr>
-                    ))}
+                    {filteredTestCases.map(testRunCase => testRunCase.iterations && testRunCase.iterations.length > 0 ? (
+                        // まとめた項目は ID が 0 のため、元のテストケースの ID で区別し、結果は行ごとに表示する
+                        <React.Fragment key={`case${testRunCase.test_case_id}`}>
+                            <Tr>
+                                <Td colSpan={3} fontSize="sm" paddingY="2" borderBottom="1px"
+                                    borderColor="gray.200">{testRunCase.title}</Td>
+                            </Tr>
+                            {testRunCase.iterations.map(iteration =>
+                                renderTestRunCaseRow(iteration, formatParameters(iteration.parameters || []), "10"))}
+                        </React.Fragment>
+                    ) : renderTestRunCaseRow(testRunCase, testRunCase.title))}
                 </Tbody>
             </Table>
         )
@@ -714,7 +738,8 @@ const RunCaseList: React.FC = () => {
                                     <VStack align="start">
                                         <Flex justify="space-between">
                                             <Heading as="h3" size="md">{selectedTestCase.title}</Heading>
-                                            {testRunStatus !== 'Completed' && user.permissions && user.permissions.includes('edit') && (
+                                            {/* 行ごとのケースの内容には行の値を埋め込み済みのため、ここから元のテストケースは編集しない */}
+                                            {testRunStatus !== 'Completed' && !selectedTestCase.parameters && user.permissions && user.permissions.includes('edit') && (
                                                 <Button
                                                     size="sm"
                                                     onClick={() => setEditMode(true)}